@@ -1,7 +1,12 @@
 package executor
 
 import (
+	"context"
 	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
 
 	"code.cloudfoundry.org/lager"
 )
@@ -11,20 +16,186 @@ import (
 type Client interface {
 	Ping(logger lager.Logger) error
 	AllocateContainers(logger lager.Logger, requests []AllocationRequest) ([]AllocationFailure, error)
+	AllocateContainersAtomically(logger lager.Logger, requests []AllocationRequest) ([]AllocationFailure, error)
 	GetContainer(logger lager.Logger, guid string) (Container, error)
+	WatchContainer(ctx context.Context, logger lager.Logger, guid string, knownState State, timeout time.Duration) (Container, error)
 	RunContainer(lager.Logger, *RunRequest) error
 	StopContainer(logger lager.Logger, guid string) error
 	DeleteContainer(logger lager.Logger, guid string) error
+	DeleteContainers(logger lager.Logger, tags Tags) map[string]error
 	ListContainers(lager.Logger) ([]Container, error)
+	ListContainersPage(lager.Logger, ContainerListOptions) (ContainerPage, error)
 	GetBulkMetrics(lager.Logger) (map[string]Metrics, error)
 	RemainingResources(lager.Logger) (ExecutorResources, error)
 	TotalResources(lager.Logger) (ExecutorResources, error)
-	GetFiles(logger lager.Logger, guid string, path string) (io.ReadCloser, error)
+	Capacity(lager.Logger) (ExecutorCapacity, error)
+	GetFiles(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error)
+	PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error
+	PutFilesChunked(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader, opts ChunkedUploadOptions) error
+	RunProcess(ctx context.Context, logger lager.Logger, guid string, spec ProcessSpec, processIO ProcessIO) (int, error)
+	AttachContainer(logger lager.Logger, guid string) (io.ReadCloser, error)
 	VolumeDrivers(logger lager.Logger) ([]string, error)
+	PrewarmCache(logger lager.Logger, assets []CacheAsset) map[string]error
 	SubscribeToEvents(lager.Logger) (EventSource, error)
+	SubscribeToFilteredEvents(lager.Logger, EventFilter) (EventSource, error)
 	Healthy(lager.Logger) bool
 	SetHealthy(lager.Logger, bool)
+	RecordHealthcheck(lager.Logger, HealthcheckResult)
+	HealthcheckHistory(lager.Logger) []HealthcheckResult
+	Drain(lager.Logger)
+	Evacuate(lager.Logger) error
 	Cleanup(lager.Logger)
+
+	ListDeadLetters(logger lager.Logger) ([]DeadLetter, error)
+	RetryDeadLetter(logger lager.Logger, guid string) error
+	DiscardDeadLetter(logger lager.Logger, guid string) error
+}
+
+// FilesManifestName is the name of the manifest entry appended to the tar
+// stream returned by GetFiles when several paths or glob patterns are requested.
+const FilesManifestName = ".executor-files-manifest.json"
+
+// FilesManifest lists the container paths included in a combined GetFiles
+// stream, and the requested paths or patterns that matched nothing.
+type FilesManifest struct {
+	Matched   []string `json:"matched"`
+	Unmatched []string `json:"unmatched"`
+}
+
+// ChunkedUploadOptions controls PutFilesChunked. The tar stream is split, at
+// entry boundaries, into chunks of at least ChunkSize bytes, each streamed
+// into the container on its own. A chunk that fails with a retryable error
+// is sent again up to MaxRetries times before the upload gives up. Progress,
+// if set, is called after each chunk is in the container.
+//
+// Retries only happen within a single call. To resume an upload that gave
+// up, call again with the same tar stream and ChunkSize and set SkipChunks
+// to the ChunksSent last reported; those chunks are read but not resent.
+type ChunkedUploadOptions struct {
+	ChunkSize  int64
+	MaxRetries int
+	SkipChunks int
+	Progress   func(UploadProgress)
+}
+
+// UploadProgress reports how much of a chunked upload is in the container.
+// BytesSent counts the bytes of the chunks' tar streams.
+type UploadProgress struct {
+	ChunksSent int
+	BytesSent  int64
+}
+
+// ProcessSpec describes an ad-hoc process run in a container by RunProcess.
+// User defaults to root.
+type ProcessSpec struct {
+	Path string                `json:"path"`
+	Args []string              `json:"args,omitempty"`
+	Env  []EnvironmentVariable `json:"env,omitempty"`
+	Dir  string                `json:"dir,omitempty"`
+	User string                `json:"user,omitempty"`
+}
+
+// ProcessIO connects an ad-hoc process to its caller. Nil streams are left
+// unattached.
+type ProcessIO struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// RetryPolicy decides how a retrying client retries its idempotent calls.
+// Each call is attempted up to MaxAttempts times. The delay before each retry
+// doubles from InitialBackoff up to MaxBackoff, or DefaultMaxRetryBackoff if
+// that is unset, and is then moved by a random amount of up to Jitter times
+// itself either way. An attempt that has not returned within AttemptTimeout
+// is abandoned; zero waits for as long as it takes. Retryable decides which
+// errors are retried, and defaults to IsRetryable.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Jitter         float64
+	AttemptTimeout time.Duration
+	Retryable      func(error) bool
+}
+
+// DefaultMaxRetryBackoff bounds the retry backoff of a RetryPolicy without a
+// MaxBackoff, so that doubling it cannot overflow.
+const DefaultMaxRetryBackoff = time.Minute
+
+// Backoff returns the delay before the retry following retries previous ones.
+func (p RetryPolicy) Backoff(retries int) time.Duration {
+	maxBackoff := p.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = DefaultMaxRetryBackoff
+	}
+
+	backoff := p.InitialBackoff
+	for i := 0; i < retries && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+
+	if p.Jitter > 0 {
+		backoff += time.Duration((2*rand.Float64() - 1) * p.Jitter * float64(backoff))
+	}
+	return backoff
+}
+
+func (p RetryPolicy) ShouldRetry(err error) bool {
+	if p.Retryable != nil {
+		return p.Retryable(err)
+	}
+	return IsRetryable(err)
+}
+
+// IsRetryable reports whether a call that failed with err may succeed if
+// repeated: anything but a cancelled context or an executor error rejecting
+// the request itself. Rate limited calls may be retried.
+func IsRetryable(err error) bool {
+	if err == nil || err == context.Canceled || err == context.DeadlineExceeded {
+		return false
+	}
+	if execErr, ok := err.(Error); ok {
+		return execErr.HttpCode() >= http.StatusInternalServerError || execErr.HttpCode() == http.StatusTooManyRequests
+	}
+	return true
+}
+
+// ContainerListOptions selects a page of containers. Empty States matches
+// every state. Containers are ordered by guid; After is the NextCursor of the
+// previous page, and a Limit of zero returns every remaining container.
+type ContainerListOptions struct {
+	States []State `json:"states,omitempty"`
+	After  string  `json:"after,omitempty"`
+	Limit  int     `json:"limit,omitempty"`
+}
+
+// ContainerPage is one page of containers. NextCursor is empty on the last
+// page.
+type ContainerPage struct {
+	Containers []Container `json:"containers"`
+	NextCursor string      `json:"next_cursor,omitempty"`
+}
+
+// DeadLetter records a container whose Run failed before its steps started,
+// e.g. because the garden container could not be found or its steps could not
+// be built.
+type DeadLetter struct {
+	Guid     string `json:"guid"`
+	Reason   string `json:"reason"`
+	FailedAt int64  `json:"failed_at"`
+	Attempts int    `json:"attempts"`
+}
+
+// HealthcheckResult records one run of the garden health check. CheckedAt is
+// in nanoseconds since the epoch; Error is empty when the check passed.
+type HealthcheckResult struct {
+	CheckedAt int64         `json:"checked_at"`
+	Duration  time.Duration `json:"duration"`
+	Error     string        `json:"error,omitempty"`
 }
 
 type WorkPoolSettings struct {
@@ -45,6 +216,7 @@ type AllocationRequest struct {
 	Guid string
 	Resource
 	Tags
+	Annotations map[string]string
 }
 
 func NewAllocationRequest(guid string, resource *Resource, tags Tags) AllocationRequest {
@@ -82,6 +254,7 @@ type RunRequest struct {
 	Guid string
 	RunInfo
 	Tags
+	Annotations map[string]string
 }
 
 func NewRunRequest(guid string, runInfo *RunInfo, tags Tags) RunRequest {
@@ -91,3 +264,68 @@ func NewRunRequest(guid string, runInfo *RunInfo, tags Tags) RunRequest {
 		Tags:    tags,
 	}
 }
+
+func (r *RunRequest) Validate() error {
+	if r.CPUWeight > MaxCPUWeight {
+		return ErrLimitsInvalid
+	}
+	if r.NetworkBandwidthLimit != nil && !r.NetworkBandwidthLimit.Valid() {
+		return ErrLimitsInvalid
+	}
+	if !r.StopSignal.Valid() {
+		return ErrStopSignalInvalid
+	}
+	if !r.validHealthCheck() {
+		return ErrHealthCheckInvalid
+	}
+	if !r.RestartPolicy.Valid() {
+		return ErrRestartPolicyInvalid
+	}
+	if !validPorts(r.Ports, r.HostPortRange) {
+		return ErrPortMappingsInvalid
+	}
+	for _, sidecar := range r.Sidecars {
+		if sidecar.Action == nil {
+			return ErrStepsInvalid
+		}
+	}
+	for _, mount := range r.VolumeMounts {
+		if !mount.Valid() {
+			return ErrVolumeMountInvalid
+		}
+	}
+	for _, server := range r.DNSServers {
+		if net.ParseIP(server) == nil {
+			return ErrDNSConfigInvalid
+		}
+	}
+	for _, entry := range r.HostsEntries {
+		if !entry.Valid() {
+			return ErrDNSConfigInvalid
+		}
+	}
+	return nil
+}
+
+func (r *RunRequest) validHealthCheck() bool {
+	checks := 0
+	if r.Monitor != nil {
+		checks++
+	}
+	if r.HTTPCheck != nil {
+		if !r.HTTPCheck.Valid() {
+			return false
+		}
+		checks++
+	}
+	if r.PortCheck != nil {
+		if len(r.PortCheck.CheckedPorts(r.Ports)) == 0 {
+			return false
+		}
+		checks++
+	}
+	if r.StartupMonitor != nil && checks == 0 {
+		return false
+	}
+	return checks <= 1
+}
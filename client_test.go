@@ -1,6 +1,11 @@
 package executor_test
 
 import (
+	"context"
+	"errors"
+	"time"
+
+	"code.cloudfoundry.org/bbs/models"
 	. "code.cloudfoundry.org/executor"
 
 	. "github.com/onsi/ginkgo"
@@ -16,3 +21,173 @@ var _ = Describe("Allocation Request", func() {
 		Expect(err).To(MatchError(ErrGuidNotSpecified))
 	})
 })
+
+var _ = Describe("Run Request", func() {
+	var runInfo RunInfo
+
+	BeforeEach(func() {
+		runInfo = RunInfo{}
+	})
+
+	It("is valid with an HTTP check", func() {
+		runInfo.HTTPCheck = &HTTPCheck{Path: "/health", Port: 8080}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(Succeed())
+	})
+
+	It("is invalid when the HTTP check has no port", func() {
+		runInfo.HTTPCheck = &HTTPCheck{Path: "/health"}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrHealthCheckInvalid))
+	})
+
+	It("is invalid when the HTTP check status range is empty", func() {
+		runInfo.HTTPCheck = &HTTPCheck{Port: 8080, MinStatus: 300, MaxStatus: 200}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrHealthCheckInvalid))
+	})
+
+	It("is invalid when both a monitor action and an HTTP check are given", func() {
+		runInfo.Monitor = &models.Action{}
+		runInfo.HTTPCheck = &HTTPCheck{Port: 8080}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrHealthCheckInvalid))
+	})
+
+	It("is valid with a port check on the container's ports", func() {
+		runInfo.Ports = []PortMapping{{ContainerPort: 8080}}
+		runInfo.PortCheck = &PortCheck{}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(Succeed())
+	})
+
+	It("is invalid when the port check has no ports to check", func() {
+		runInfo.PortCheck = &PortCheck{}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrHealthCheckInvalid))
+	})
+
+	It("is invalid when both an HTTP check and a port check are given", func() {
+		runInfo.HTTPCheck = &HTTPCheck{Port: 8080}
+		runInfo.PortCheck = &PortCheck{Port: 8080}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrHealthCheckInvalid))
+	})
+
+	It("is invalid when a startup monitor is given without a liveness check", func() {
+		runInfo.StartupMonitor = &models.Action{}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrHealthCheckInvalid))
+	})
+
+	It("is valid with a host port range that can hold every port", func() {
+		runInfo.Ports = []PortMapping{{ContainerPort: 8080}, {ContainerPort: 9090, HostPort: 61000}}
+		runInfo.HostPortRange = &PortRange{Start: 61000, End: 61001}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(Succeed())
+	})
+
+	It("is invalid when a host port is mapped twice", func() {
+		runInfo.Ports = []PortMapping{{ContainerPort: 8080, HostPort: 61000}, {ContainerPort: 9090, HostPort: 61000}}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrPortMappingsInvalid))
+	})
+
+	It("is invalid when the host port range is too small for the ports", func() {
+		runInfo.Ports = []PortMapping{{ContainerPort: 8080}, {ContainerPort: 9090}}
+		runInfo.HostPortRange = &PortRange{Start: 61000, End: 61000}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrPortMappingsInvalid))
+	})
+
+	It("is valid with DNS servers and hosts entries", func() {
+		runInfo.DNSServers = []string{"10.0.0.2"}
+		runInfo.HostsEntries = []HostsEntry{{IP: "10.0.1.5", Hostnames: []string{"db.internal"}}}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(Succeed())
+	})
+
+	It("is invalid when a DNS server is not an IP", func() {
+		runInfo.DNSServers = []string{"dns.example.com"}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrDNSConfigInvalid))
+	})
+
+	It("is invalid when a hosts entry has no hostnames", func() {
+		runInfo.HostsEntries = []HostsEntry{{IP: "10.0.1.5"}}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrDNSConfigInvalid))
+	})
+
+	It("is invalid when the CPU weight is over the maximum", func() {
+		runInfo.CPUWeight = MaxCPUWeight + 1
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrLimitsInvalid))
+	})
+
+	It("is invalid when the network bandwidth limit has no rate", func() {
+		runInfo.NetworkBandwidthLimit = &BandwidthLimit{BurstRateInBytesPerSecond: 1024}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrLimitsInvalid))
+	})
+
+	It("is invalid with an unknown restart mode", func() {
+		runInfo.RestartPolicy = RestartPolicy{Mode: "sometimes"}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrRestartPolicyInvalid))
+	})
+
+	It("is invalid when the initial restart backoff exceeds the maximum", func() {
+		runInfo.RestartPolicy = RestartPolicy{Mode: RestartAlways, InitialBackoffMs: 2000, MaxBackoffMs: 1000}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrRestartPolicyInvalid))
+	})
+
+	It("is valid with a complete volume mount", func() {
+		runInfo.VolumeMounts = []VolumeMount{{Driver: "nfs", VolumeId: "some-volume", ContainerPath: "/data", Mode: BindMountModeRW}}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(Succeed())
+	})
+
+	It("is invalid when a volume mount has no container path", func() {
+		runInfo.VolumeMounts = []VolumeMount{{Driver: "nfs", VolumeId: "some-volume"}}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrVolumeMountInvalid))
+	})
+
+	It("is invalid when a volume mount has an unknown mode", func() {
+		runInfo.VolumeMounts = []VolumeMount{{Driver: "nfs", VolumeId: "some-volume", ContainerPath: "/data", Mode: 7}}
+		runRequest := NewRunRequest("some-guid", &runInfo, nil)
+		Expect(runRequest.Validate()).To(MatchError(ErrVolumeMountInvalid))
+	})
+})
+
+var _ = Describe("RetryPolicy", func() {
+	It("doubles the backoff up to the maximum", func() {
+		policy := RetryPolicy{InitialBackoff: time.Second, MaxBackoff: 5 * time.Second}
+		Expect(policy.Backoff(0)).To(Equal(time.Second))
+		Expect(policy.Backoff(2)).To(Equal(4 * time.Second))
+		Expect(policy.Backoff(3)).To(Equal(5 * time.Second))
+	})
+
+	It("caps the backoff at the default maximum when no maximum is set", func() {
+		policy := RetryPolicy{InitialBackoff: time.Second}
+		Expect(policy.Backoff(1)).To(Equal(2 * time.Second))
+		Expect(policy.Backoff(100)).To(Equal(DefaultMaxRetryBackoff))
+	})
+
+	It("spreads the backoff by the jitter", func() {
+		policy := RetryPolicy{InitialBackoff: time.Second, Jitter: 0.5}
+		for i := 0; i < 10; i++ {
+			Expect(policy.Backoff(0)).To(BeNumerically("~", time.Second, 500*time.Millisecond))
+		}
+	})
+
+	It("retries server errors but not rejected requests or cancellation", func() {
+		Expect(IsRetryable(errors.New("connection reset"))).To(BeTrue())
+		Expect(IsRetryable(ErrUnhealthy)).To(BeTrue())
+		Expect(IsRetryable(ErrRateLimited)).To(BeTrue())
+		Expect(IsRetryable(ErrContainerNotFound)).To(BeFalse())
+		Expect(IsRetryable(context.Canceled)).To(BeFalse())
+	})
+})
@@ -8,6 +8,7 @@ import (
 
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
 )
@@ -21,6 +22,8 @@ type StatsReporter struct {
 
 	cpuInfos     map[string]cpuInfo
 	metronClient loggregator_v2.Client
+	eventHub     event.Hub
+	events       chan executor.Event
 }
 
 type cpuInfo struct {
@@ -28,7 +31,7 @@ type cpuInfo struct {
 	timeOfSample   time.Time
 }
 
-func NewStatsReporter(logger lager.Logger, interval time.Duration, clock clock.Clock, executorClient executor.Client, metronClient loggregator_v2.Client) *StatsReporter {
+func NewStatsReporter(logger lager.Logger, interval time.Duration, clock clock.Clock, executorClient executor.Client, metronClient loggregator_v2.Client, eventHub event.Hub) *StatsReporter {
 	return &StatsReporter{
 		logger: logger,
 
@@ -36,6 +39,8 @@ func NewStatsReporter(logger lager.Logger, interval time.Duration, clock clock.C
 		clock:          clock,
 		executorClient: executorClient,
 		metronClient:   metronClient,
+		eventHub:       eventHub,
+		events:         make(chan executor.Event, event.SUBSCRIBER_BUFFER),
 	}
 }
 
@@ -45,6 +50,10 @@ func (reporter *StatsReporter) Run(signals <-chan os.Signal, ready chan<- struct
 	ticker := reporter.clock.NewTicker(reporter.interval)
 	defer ticker.Stop()
 
+	done := make(chan struct{})
+	defer close(done)
+	go reporter.emitEvents(done)
+
 	close(ready)
 
 	cpuInfos := make(map[string]*cpuInfo)
@@ -61,6 +70,20 @@ func (reporter *StatsReporter) Run(signals <-chan os.Signal, ready chan<- struct
 	return nil
 }
 
+// emitEvents sends metrics events to the hub off the reporting loop, so a
+// slow hub cannot delay the metrics sent to metron. Events that do not fit in
+// the buffer are dropped.
+func (reporter *StatsReporter) emitEvents(done <-chan struct{}) {
+	for {
+		select {
+		case ev := <-reporter.events:
+			reporter.eventHub.Emit(ev)
+		case <-done:
+			return
+		}
+	}
+}
+
 func (reporter *StatsReporter) emitContainerMetrics(logger lager.Logger, previousCpuInfos map[string]*cpuInfo, now time.Time) map[string]*cpuInfo {
 	logger = logger.Session("tick")
 
@@ -87,8 +110,13 @@ func (reporter *StatsReporter) emitContainerMetrics(logger lager.Logger, previou
 	newCpuInfos := make(map[string]*cpuInfo)
 	for guid, metric := range metrics {
 		previousCpuInfo := previousCpuInfos[guid]
-		cpu := reporter.calculateAndSendMetrics(logger, metric.MetricsConfig, metric.ContainerMetrics, previousCpuInfo, now)
+		cpu, cpuPercent := reporter.calculateAndSendMetrics(logger, metric.MetricsConfig, metric.ContainerMetrics, previousCpuInfo, now)
 		if cpu != nil {
+			select {
+			case reporter.events <- executor.NewContainerMetricsEvent(guid, metric, cpuPercent):
+			default:
+				logger.Debug("dropped-container-metrics-event", lager.Data{"guid": guid})
+			}
 			newCpuInfos[guid] = cpu
 		}
 	}
@@ -102,9 +130,9 @@ func (reporter *StatsReporter) calculateAndSendMetrics(
 	containerMetrics executor.ContainerMetrics,
 	previousInfo *cpuInfo,
 	now time.Time,
-) *cpuInfo {
+) (*cpuInfo, float64) {
 	if metricsConfig.Guid == "" {
-		return nil
+		return nil, 0
 	}
 
 	currentInfo := cpuInfo{
@@ -142,7 +170,7 @@ func (reporter *StatsReporter) calculateAndSendMetrics(
 		})
 	}
 
-	return &currentInfo
+	return &currentInfo, cpuPercent
 }
 
 // scale from 0 - 100
@@ -7,6 +7,7 @@ import (
 	"code.cloudfoundry.org/clock/fakeclock"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/containermetrics"
+	eventfakes "code.cloudfoundry.org/executor/depot/event/fakes"
 	efakes "code.cloudfoundry.org/executor/fakes"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
@@ -39,6 +40,7 @@ var _ = Describe("StatsReporter", func() {
 		fakeExecutorClient *efakes.FakeClient
 		fakeMetricSender   *msfake.FakeMetricSender
 		fakeMetronClient   *mfakes.FakeClient
+		fakeEventHub       *eventfakes.FakeHub
 
 		metricsResults chan map[string]executor.Metrics
 		process        ifrit.Process
@@ -134,6 +136,7 @@ var _ = Describe("StatsReporter", func() {
 		fakeClock = fakeclock.NewFakeClock(time.Now())
 		fakeExecutorClient = new(efakes.FakeClient)
 		fakeMetronClient = new(mfakes.FakeClient)
+		fakeEventHub = new(eventfakes.FakeHub)
 
 		fakeMetricSender = msfake.NewFakeMetricSender()
 
@@ -147,7 +150,7 @@ var _ = Describe("StatsReporter", func() {
 			return result, nil
 		}
 
-		process = ifrit.Invoke(containermetrics.NewStatsReporter(logger, interval, fakeClock, fakeExecutorClient, fakeMetronClient))
+		process = ifrit.Invoke(containermetrics.NewStatsReporter(logger, interval, fakeClock, fakeExecutorClient, fakeMetronClient, fakeEventHub))
 	})
 
 	AfterEach(func() {
@@ -196,6 +199,39 @@ var _ = Describe("StatsReporter", func() {
 			}))
 		})
 
+		It("emits a metrics event for each container", func() {
+			emittedEvents := func() []executor.Event {
+				evs := []executor.Event{}
+				for i := 0; i < fakeEventHub.EmitCallCount(); i++ {
+					evs = append(evs, fakeEventHub.EmitArgsForCall(i))
+				}
+				return evs
+			}
+
+			Eventually(emittedEvents).Should(ConsistOf(
+				executor.NewContainerMetricsEvent("guid-without-index", executor.Metrics{
+					MetricsConfig: executor.MetricsConfig{Guid: "metrics-guid-without-index"},
+					ContainerMetrics: executor.ContainerMetrics{
+						MemoryUsageInBytes: 123,
+						DiskUsageInBytes:   456,
+						TimeSpentInCPU:     100 * time.Second,
+						MemoryLimitInBytes: 789,
+						DiskLimitInBytes:   1024,
+					},
+				}, 0.0),
+				executor.NewContainerMetricsEvent("guid-with-index", executor.Metrics{
+					MetricsConfig: executor.MetricsConfig{Guid: "metrics-guid-with-index", Index: 1},
+					ContainerMetrics: executor.ContainerMetrics{
+						MemoryUsageInBytes: 321,
+						DiskUsageInBytes:   654,
+						TimeSpentInCPU:     100 * time.Second,
+						MemoryLimitInBytes: 987,
+						DiskLimitInBytes:   2048,
+					},
+				}, 0.0),
+			))
+		})
+
 		It("does not emit anything for containers with no metrics guid", func() {
 			Consistently(func() msfake.ContainerMetric {
 				return fakeMetricSender.GetContainerMetric("")
@@ -0,0 +1,160 @@
+package depot
+
+import (
+	"context"
+	"io"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+// auditClient records the container lifecycle operations, evacuations, dead
+// letter retries and discards, cache prewarming, file access and processes
+// run through the client it wraps. Each record names the calling session, the
+// container and the outcome; lager timestamps it.
+type auditClient struct {
+	executor.Client
+	auditLogger lager.Logger
+}
+
+func NewAuditClient(client executor.Client, auditLogger lager.Logger) executor.Client {
+	return &auditClient{
+		Client:      client,
+		auditLogger: auditLogger,
+	}
+}
+
+func (c *auditClient) AllocateContainers(logger lager.Logger, requests []executor.AllocationRequest) ([]executor.AllocationFailure, error) {
+	failures, err := c.Client.AllocateContainers(logger, requests)
+	c.recordAllocations(logger, requests, failures, err, false)
+	return failures, err
+}
+
+func (c *auditClient) AllocateContainersAtomically(logger lager.Logger, requests []executor.AllocationRequest) ([]executor.AllocationFailure, error) {
+	failures, err := c.Client.AllocateContainersAtomically(logger, requests)
+	c.recordAllocations(logger, requests, failures, err, true)
+	return failures, err
+}
+
+func (c *auditClient) recordAllocations(logger lager.Logger, requests []executor.AllocationRequest, failures []executor.AllocationFailure, err error, atomic bool) {
+	failed := map[string]string{}
+	for _, failure := range failures {
+		failed[failure.Guid] = failure.ErrorMsg
+	}
+
+	for i := range requests {
+		data := lager.Data{"request-id": requests[i].Tags.RequestID()}
+		if msg, ok := failed[requests[i].Guid]; ok {
+			data["error"] = msg
+		} else if atomic && len(failures) > 0 {
+			data["error"] = "not allocated because other containers in the batch failed"
+		}
+		c.record(logger, "allocate", requests[i].Guid, err, data)
+	}
+}
+
+func (c *auditClient) RunContainer(logger lager.Logger, request *executor.RunRequest) error {
+	err := c.Client.RunContainer(logger, request)
+	c.record(logger, "run", request.Guid, err, lager.Data{"request-id": request.Tags.RequestID()})
+	return err
+}
+
+func (c *auditClient) StopContainer(logger lager.Logger, guid string) error {
+	err := c.Client.StopContainer(logger, guid)
+	c.record(logger, "stop", guid, err, nil)
+	return err
+}
+
+func (c *auditClient) DeleteContainer(logger lager.Logger, guid string) error {
+	err := c.Client.DeleteContainer(logger, guid)
+	c.record(logger, "delete", guid, err, nil)
+	return err
+}
+
+func (c *auditClient) DeleteContainers(logger lager.Logger, tags executor.Tags) map[string]error {
+	errs := c.Client.DeleteContainers(logger, tags)
+	for guid, err := range errs {
+		c.record(logger, "delete", guid, err, lager.Data{"tags": tags})
+	}
+	return errs
+}
+
+func (c *auditClient) GetFiles(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error) {
+	stream, err := c.Client.GetFiles(ctx, logger, guid, paths...)
+	c.record(logger, "get-files", guid, err, lager.Data{"paths": paths})
+	return stream, err
+}
+
+func (c *auditClient) PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error {
+	err := c.Client.PutFiles(ctx, logger, guid, destPath, tarStream)
+	c.record(logger, "put-files", guid, err, lager.Data{"dest-path": destPath})
+	return err
+}
+
+func (c *auditClient) PutFilesChunked(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader, opts executor.ChunkedUploadOptions) error {
+	err := c.Client.PutFilesChunked(ctx, logger, guid, destPath, tarStream, opts)
+	c.record(logger, "put-files", guid, err, lager.Data{"dest-path": destPath, "chunked": true})
+	return err
+}
+
+func (c *auditClient) RunProcess(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error) {
+	exitStatus, err := c.Client.RunProcess(ctx, logger, guid, spec, processIO)
+	c.record(logger, "run-process", guid, err, lager.Data{
+		"path":        spec.Path,
+		"user":        spec.User,
+		"exit-status": exitStatus,
+	})
+	return exitStatus, err
+}
+
+func (c *auditClient) AttachContainer(logger lager.Logger, guid string) (io.ReadCloser, error) {
+	stream, err := c.Client.AttachContainer(logger, guid)
+	c.record(logger, "attach", guid, err, nil)
+	return stream, err
+}
+
+func (c *auditClient) Evacuate(logger lager.Logger) error {
+	err := c.Client.Evacuate(logger)
+	c.record(logger, "evacuate", "", err, nil)
+	return err
+}
+
+func (c *auditClient) RetryDeadLetter(logger lager.Logger, guid string) error {
+	err := c.Client.RetryDeadLetter(logger, guid)
+	c.record(logger, "retry-dead-letter", guid, err, nil)
+	return err
+}
+
+func (c *auditClient) DiscardDeadLetter(logger lager.Logger, guid string) error {
+	err := c.Client.DiscardDeadLetter(logger, guid)
+	c.record(logger, "discard-dead-letter", guid, err, nil)
+	return err
+}
+
+func (c *auditClient) PrewarmCache(logger lager.Logger, assets []executor.CacheAsset) map[string]error {
+	errs := c.Client.PrewarmCache(logger, assets)
+	for cacheKey, err := range errs {
+		c.record(logger, "prewarm-cache", "", err, lager.Data{"cache-key": cacheKey})
+	}
+	return errs
+}
+
+func (c *auditClient) record(caller lager.Logger, action, guid string, err error, data lager.Data) {
+	entry := lager.Data{
+		"action": action,
+		"guid":   guid,
+		"caller": caller.SessionName(),
+	}
+	for key, value := range data {
+		entry[key] = value
+	}
+	if err != nil {
+		entry["error"] = err.Error()
+	}
+
+	if _, failed := entry["error"]; failed {
+		c.auditLogger.Info("operation-failed", entry)
+		return
+	}
+	c.auditLogger.Info("operation-succeeded", entry)
+}
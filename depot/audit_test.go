@@ -0,0 +1,130 @@
+package depot_test
+
+import (
+	"context"
+	"errors"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot"
+	"code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("AuditClient", func() {
+	var (
+		logger      *lagertest.TestLogger
+		auditLogger *lagertest.TestLogger
+		fakeClient  *fakes.FakeClient
+		auditClient executor.Client
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("caller")
+		auditLogger = lagertest.NewTestLogger("audit")
+		fakeClient = new(fakes.FakeClient)
+		auditClient = depot.NewAuditClient(fakeClient, auditLogger)
+	})
+
+	It("records allocations with their request IDs", func() {
+		requests := []executor.AllocationRequest{
+			{Guid: "guid-1", Tags: executor.Tags{executor.RequestIDTag: "request-1"}},
+			{Guid: "guid-2"},
+		}
+		fakeClient.AllocateContainersReturns([]executor.AllocationFailure{
+			executor.NewAllocationFailure(&requests[1], "no room"),
+		}, nil)
+
+		_, err := auditClient.AllocateContainers(logger, requests)
+		Expect(err).NotTo(HaveOccurred())
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(2))
+
+		Expect(logs[0].Message).To(Equal("audit.operation-succeeded"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "allocate"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("guid", "guid-1"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("request-id", "request-1"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("caller", "caller"))
+
+		Expect(logs[1].Message).To(Equal("audit.operation-failed"))
+		Expect(logs[1].Data).To(HaveKeyWithValue("guid", "guid-2"))
+		Expect(logs[1].Data).To(HaveKeyWithValue("error", "no room"))
+	})
+
+	It("records failed operations with their errors", func() {
+		fakeClient.DeleteContainerReturns(executor.ErrContainerNotFound)
+
+		err := auditClient.DeleteContainer(logger, "guid-1")
+		Expect(err).To(Equal(executor.ErrContainerNotFound))
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Message).To(Equal("audit.operation-failed"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "delete"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("error", executor.ErrContainerNotFound.Error()))
+	})
+
+	It("records file access", func() {
+		fakeClient.GetFilesReturns(nil, errors.New("boom"))
+
+		_, err := auditClient.GetFiles(context.Background(), logger, "guid-1", "/some/path")
+		Expect(err).To(MatchError("boom"))
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "get-files"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("paths", []interface{}{"/some/path"}))
+	})
+
+	It("records evacuations", func() {
+		Expect(auditClient.Evacuate(logger)).To(Succeed())
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Message).To(Equal("audit.operation-succeeded"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "evacuate"))
+	})
+
+	It("records dead letter retries", func() {
+		fakeClient.RetryDeadLetterReturns(executor.ErrDeadLetterNotFound)
+
+		err := auditClient.RetryDeadLetter(logger, "guid-1")
+		Expect(err).To(Equal(executor.ErrDeadLetterNotFound))
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "retry-dead-letter"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("guid", "guid-1"))
+	})
+
+	It("records dead letter discards", func() {
+		Expect(auditClient.DiscardDeadLetter(logger, "guid-1")).To(Succeed())
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Message).To(Equal("audit.operation-succeeded"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "discard-dead-letter"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("guid", "guid-1"))
+	})
+
+	It("records each asset prewarmed into the cache", func() {
+		fakeClient.PrewarmCacheReturns(map[string]error{"cache-key": nil})
+
+		errs := auditClient.PrewarmCache(logger, []executor.CacheAsset{{From: "http://example.com/asset", CacheKey: "cache-key"}})
+		Expect(errs).To(HaveKeyWithValue("cache-key", BeNil()))
+
+		logs := auditLogger.Logs()
+		Expect(logs).To(HaveLen(1))
+		Expect(logs[0].Data).To(HaveKeyWithValue("action", "prewarm-cache"))
+		Expect(logs[0].Data).To(HaveKeyWithValue("cache-key", "cache-key"))
+	})
+
+	It("passes other calls through", func() {
+		fakeClient.HealthyReturns(true)
+		Expect(auditClient.Healthy(logger)).To(BeTrue())
+		Expect(auditLogger.Logs()).To(BeEmpty())
+	})
+})
@@ -0,0 +1,131 @@
+package callbacks
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/executor"
+)
+
+const journalEntryExtension = ".json"
+
+// Payload is the body POSTed to a container's completion callback URL.
+type Payload struct {
+	Guid          string        `json:"guid"`
+	Tags          executor.Tags `json:"tags,omitempty"`
+	Failed        bool          `json:"failed"`
+	FailureReason string        `json:"failure_reason,omitempty"`
+	Stopped       bool          `json:"stopped"`
+	Evacuated     bool          `json:"evacuated,omitempty"`
+}
+
+func NewPayload(container executor.Container) Payload {
+	return Payload{
+		Guid:          container.Guid,
+		Tags:          container.Tags,
+		Failed:        container.RunResult.Failed,
+		FailureReason: container.RunResult.FailureReason,
+		Stopped:       container.RunResult.Stopped,
+		Evacuated:     container.RunResult.Evacuated,
+	}
+}
+
+// Callback is a pending delivery of a Payload.
+type Callback struct {
+	URL      string  `json:"url"`
+	Payload  Payload `json:"payload"`
+	Attempts int     `json:"attempts"`
+}
+
+// Journal durably records callbacks that have not been delivered yet, so they
+// survive an executor restart.
+type Journal interface {
+	Put(callback Callback) error
+	Remove(guid string) error
+	Pending() ([]Callback, error)
+}
+
+type journal struct {
+	dir string
+}
+
+// NewJournal returns a Journal storing one file per pending callback in dir.
+func NewJournal(dir string) (Journal, error) {
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journal{dir: dir}, nil
+}
+
+func (j *journal) Put(callback Callback) error {
+	data, err := json.Marshal(callback)
+	if err != nil {
+		return err
+	}
+
+	tmpFile, err := ioutil.TempFile(j.dir, "pending")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	closeErr := tmpFile.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpFile.Name())
+		return err
+	}
+
+	return os.Rename(tmpFile.Name(), j.path(callback.Payload.Guid))
+}
+
+func (j *journal) Remove(guid string) error {
+	err := os.Remove(j.path(guid))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (j *journal) Pending() ([]Callback, error) {
+	entries, err := ioutil.ReadDir(j.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	callbacks := []Callback{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), journalEntryExtension) {
+			continue
+		}
+
+		data, err := ioutil.ReadFile(filepath.Join(j.dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		var callback Callback
+		err = json.Unmarshal(data, &callback)
+		if err != nil {
+			return nil, err
+		}
+
+		callbacks = append(callbacks, callback)
+	}
+
+	return callbacks, nil
+}
+
+func (j *journal) path(guid string) string {
+	return filepath.Join(j.dir, guid+journalEntryExtension)
+}
@@ -0,0 +1,220 @@
+package callbacks
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+const InitialBackoff = time.Second
+
+type notifier struct {
+	logger      lager.Logger
+	journal     Journal
+	httpClient  *http.Client
+	clock       clock.Clock
+	maxAttempts int
+	maxBackoff  time.Duration
+
+	stop chan struct{}
+	wg   sync.WaitGroup
+
+	lock    sync.Mutex
+	running bool
+}
+
+// NewNotifier returns a runner that POSTs the run result of every completed
+// container with a CompletionCallbackURL to that URL. The container store
+// reports completed containers to it through ContainerCompleted.
+//
+// Callbacks are journaled before the first attempt and only removed once they
+// have been delivered, rejected by the receiver, or have failed maxAttempts
+// times; failed attempts are retried with exponential backoff capped at
+// maxBackoff. Callbacks left in the journal are resumed when the runner starts.
+func NewNotifier(
+	logger lager.Logger,
+	journal Journal,
+	httpClient *http.Client,
+	clock clock.Clock,
+	maxAttempts int,
+	maxBackoff time.Duration,
+) *notifier {
+	return &notifier{
+		logger:      logger.Session("completion-callback-notifier"),
+		journal:     journal,
+		httpClient:  httpClient,
+		clock:       clock,
+		maxAttempts: maxAttempts,
+		maxBackoff:  maxBackoff,
+		stop:        make(chan struct{}),
+	}
+}
+
+func (n *notifier) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	logger := n.logger
+
+	n.lock.Lock()
+	pending, err := n.journal.Pending()
+	if err != nil {
+		n.lock.Unlock()
+		logger.Error("failed-to-read-journal", err)
+		return err
+	}
+
+	logger.Info("resuming-pending-callbacks", lager.Data{"count": len(pending)})
+	for _, callback := range pending {
+		n.deliverAsync(callback, false)
+	}
+	n.running = true
+	n.lock.Unlock()
+
+	close(ready)
+
+	<-signals
+
+	n.lock.Lock()
+	n.running = false
+	close(n.stop)
+	n.lock.Unlock()
+
+	n.wg.Wait()
+	return nil
+}
+
+// ContainerCompleted delivers the completion callback of container, if it has
+// one. While the notifier is not running the callback is only journaled, to
+// be delivered once it starts.
+func (n *notifier) ContainerCompleted(logger lager.Logger, container executor.Container) {
+	if container.CompletionCallbackURL == "" {
+		return
+	}
+
+	callback := Callback{
+		URL:     container.CompletionCallbackURL,
+		Payload: NewPayload(container),
+	}
+
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	if n.running {
+		n.deliverAsync(callback, true)
+		return
+	}
+
+	err := n.journal.Put(callback)
+	if err != nil {
+		logger.Error("failed-to-journal-callback", err, lager.Data{"guid": callback.Payload.Guid})
+	}
+}
+
+// deliverAsync delivers callback in the background. New callbacks are
+// journaled first, off the caller's goroutine, as journaling waits for the
+// disk. It must be called with lock held.
+func (n *notifier) deliverAsync(callback Callback, journal bool) {
+	n.wg.Add(1)
+	go func() {
+		defer n.wg.Done()
+
+		if journal {
+			err := n.journal.Put(callback)
+			if err != nil {
+				n.logger.Error("failed-to-journal-callback", err, lager.Data{"guid": callback.Payload.Guid})
+			}
+		}
+
+		n.deliver(callback)
+	}()
+}
+
+func (n *notifier) deliver(callback Callback) {
+	logger := n.logger.Session("deliver", lager.Data{"guid": callback.Payload.Guid, "url": callback.URL})
+
+	for {
+		callback.Attempts++
+		retryable, err := n.post(callback)
+		if err == nil {
+			logger.Info("delivered", lager.Data{"attempts": callback.Attempts})
+			n.remove(logger, callback)
+			return
+		}
+
+		if !retryable || callback.Attempts >= n.maxAttempts {
+			logger.Error("abandoning-callback", err, lager.Data{"attempts": callback.Attempts})
+			n.remove(logger, callback)
+			return
+		}
+
+		logger.Error("failed-to-deliver", err, lager.Data{"attempts": callback.Attempts})
+
+		err = n.journal.Put(callback)
+		if err != nil {
+			logger.Error("failed-to-journal-callback", err)
+		}
+
+		timer := n.clock.NewTimer(n.backoff(callback.Attempts))
+		select {
+		case <-timer.C():
+		case <-n.stop:
+			timer.Stop()
+			logger.Info("stopping-with-callback-pending")
+			return
+		}
+	}
+}
+
+func (n *notifier) post(callback Callback) (bool, error) {
+	body, err := json.Marshal(callback.Payload)
+	if err != nil {
+		return false, err
+	}
+
+	request, err := http.NewRequest("POST", callback.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+
+	response, err := n.httpClient.Do(request)
+	if err != nil {
+		return true, err
+	}
+	response.Body.Close()
+
+	switch {
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		return false, nil
+	case response.StatusCode == http.StatusRequestTimeout,
+		response.StatusCode == http.StatusTooManyRequests,
+		response.StatusCode >= 500:
+		return true, fmt.Errorf("callback returned status %d", response.StatusCode)
+	default:
+		return false, fmt.Errorf("callback rejected with status %d", response.StatusCode)
+	}
+}
+
+func (n *notifier) backoff(attempts int) time.Duration {
+	backoff := InitialBackoff
+	for i := 1; i < attempts && backoff < n.maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > n.maxBackoff {
+		backoff = n.maxBackoff
+	}
+	return backoff
+}
+
+func (n *notifier) remove(logger lager.Logger, callback Callback) {
+	err := n.journal.Remove(callback.Payload.Guid)
+	if err != nil {
+		logger.Error("failed-to-remove-callback-from-journal", err)
+	}
+}
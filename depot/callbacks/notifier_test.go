@@ -0,0 +1,197 @@
+package callbacks_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/callbacks"
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+)
+
+var _ = Describe("Notifier", func() {
+	var (
+		logger     *lagertest.TestLogger
+		journalDir string
+		journal    callbacks.Journal
+		fakeClock  *fakeclock.FakeClock
+		server     *httptest.Server
+
+		lock      sync.Mutex
+		statuses  []int
+		payloads  []callbacks.Payload
+		container executor.Container
+
+		notifier interface {
+			ifrit.Runner
+			containerstore.CompletionObserver
+		}
+		process ifrit.Process
+	)
+
+	received := func() []callbacks.Payload {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]callbacks.Payload{}, payloads...)
+	}
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+
+		var err error
+		journalDir, err = ioutil.TempDir("", "callbacks")
+		Expect(err).NotTo(HaveOccurred())
+		journal, err = callbacks.NewJournal(journalDir)
+		Expect(err).NotTo(HaveOccurred())
+
+		statuses = []int{http.StatusOK}
+		payloads = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var payload callbacks.Payload
+			Expect(json.NewDecoder(r.Body).Decode(&payload)).To(Succeed())
+
+			lock.Lock()
+			payloads = append(payloads, payload)
+			status := statuses[0]
+			if len(statuses) > 1 {
+				statuses = statuses[1:]
+			}
+			lock.Unlock()
+
+			w.WriteHeader(status)
+		}))
+
+		container = executor.Container{
+			Guid:    "some-guid",
+			RunInfo: executor.RunInfo{CompletionCallbackURL: server.URL},
+			RunResult: executor.ContainerRunResult{
+				Failed:        true,
+				FailureReason: "boom",
+			},
+		}
+
+		notifier = callbacks.NewNotifier(logger, journal, http.DefaultClient, fakeClock, 3, 4*time.Second)
+	})
+
+	JustBeforeEach(func() {
+		process = ginkgomon.Invoke(notifier)
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+		server.Close()
+		os.RemoveAll(journalDir)
+	})
+
+	It("posts the run result of completed containers to their callback url", func() {
+		notifier.ContainerCompleted(logger, container)
+
+		Eventually(received).Should(HaveLen(1))
+		Expect(received()[0]).To(Equal(callbacks.Payload{
+			Guid:          "some-guid",
+			Failed:        true,
+			FailureReason: "boom",
+		}))
+
+		Eventually(journal.Pending).Should(BeEmpty())
+	})
+
+	It("ignores containers without a callback url", func() {
+		container.CompletionCallbackURL = ""
+		notifier.ContainerCompleted(logger, container)
+
+		Consistently(received).Should(BeEmpty())
+	})
+
+	Context("when the receiver fails", func() {
+		BeforeEach(func() {
+			statuses = []int{http.StatusServiceUnavailable, http.StatusOK}
+		})
+
+		It("retries after a backoff and keeps the callback journaled until delivered", func() {
+			notifier.ContainerCompleted(logger, container)
+
+			Eventually(received).Should(HaveLen(1))
+			Eventually(journal.Pending).Should(HaveLen(1))
+
+			fakeClock.WaitForWatcherAndIncrement(callbacks.InitialBackoff)
+
+			Eventually(received).Should(HaveLen(2))
+			Eventually(journal.Pending).Should(BeEmpty())
+		})
+	})
+
+	Context("when the receiver keeps failing", func() {
+		BeforeEach(func() {
+			statuses = []int{http.StatusInternalServerError}
+		})
+
+		It("gives up after the maximum number of attempts", func() {
+			notifier.ContainerCompleted(logger, container)
+
+			Eventually(received).Should(HaveLen(1))
+			fakeClock.WaitForWatcherAndIncrement(callbacks.InitialBackoff)
+			Eventually(received).Should(HaveLen(2))
+			fakeClock.WaitForWatcherAndIncrement(2 * callbacks.InitialBackoff)
+			Eventually(received).Should(HaveLen(3))
+
+			Eventually(journal.Pending).Should(BeEmpty())
+			Consistently(received).Should(HaveLen(3))
+		})
+	})
+
+	Context("when the receiver rejects the callback", func() {
+		BeforeEach(func() {
+			statuses = []int{http.StatusBadRequest}
+		})
+
+		It("does not retry", func() {
+			notifier.ContainerCompleted(logger, container)
+
+			Eventually(received).Should(HaveLen(1))
+			Eventually(journal.Pending).Should(BeEmpty())
+		})
+	})
+
+	Context("when there are callbacks left in the journal", func() {
+		BeforeEach(func() {
+			err := journal.Put(callbacks.Callback{
+				URL:     server.URL,
+				Payload: callbacks.Payload{Guid: "pending-guid"},
+			})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("delivers them on startup", func() {
+			Eventually(received).Should(HaveLen(1))
+			Expect(received()[0].Guid).To(Equal("pending-guid"))
+			Eventually(journal.Pending).Should(BeEmpty())
+		})
+	})
+
+	Context("when a container completes before the notifier starts", func() {
+		BeforeEach(func() {
+			notifier.ContainerCompleted(logger, container)
+			Expect(journal.Pending()).To(HaveLen(1))
+			Expect(received()).To(BeEmpty())
+		})
+
+		It("delivers its callback on startup", func() {
+			Eventually(received).Should(HaveLen(1))
+			Expect(received()[0].Guid).To(Equal("some-guid"))
+			Eventually(journal.Pending).Should(BeEmpty())
+		})
+	})
+})
@@ -0,0 +1,72 @@
+package containerstore
+
+import (
+	"context"
+	"io"
+	"sync"
+)
+
+type contextStream struct {
+	ctx    context.Context
+	stream io.ReadCloser
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+// newContextStream wraps stream so that it is closed, and further reads fail
+// with the context's error, once ctx is done.
+func newContextStream(ctx context.Context, stream io.ReadCloser) io.ReadCloser {
+	if ctx.Done() == nil {
+		return stream
+	}
+
+	s := &contextStream{
+		ctx:    ctx,
+		stream: stream,
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			// closing the underlying stream unblocks any pending Read
+			s.stream.Close()
+		case <-s.done:
+		}
+	}()
+
+	return s
+}
+
+func (s *contextStream) Read(p []byte) (int, error) {
+	if err := s.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	n, err := s.stream.Read(p)
+	if ctxErr := s.ctx.Err(); ctxErr != nil {
+		return n, ctxErr
+	}
+	return n, err
+}
+
+func (s *contextStream) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.done)
+	})
+	return s.stream.Close()
+}
+
+// contextReader fails reads with the context's error once ctx is done.
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.reader.Read(p)
+}
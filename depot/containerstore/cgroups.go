@@ -0,0 +1,49 @@
+package containerstore
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/garden"
+)
+
+type CgroupMode string
+
+const (
+	CgroupModeAuto CgroupMode = "auto"
+	CgroupModeV1   CgroupMode = "v1"
+	CgroupModeV2   CgroupMode = "v2"
+
+	DefaultCgroupRoot = "/sys/fs/cgroup"
+)
+
+// ResolveCgroupMode turns CgroupModeAuto into the mode of the host by
+// checking for the unified hierarchy's cgroup.controllers file under root.
+func ResolveCgroupMode(mode CgroupMode, root string) (CgroupMode, error) {
+	switch mode {
+	case CgroupModeV1, CgroupModeV2:
+		return mode, nil
+	case CgroupModeAuto, "":
+		_, err := os.Stat(filepath.Join(root, "cgroup.controllers"))
+		if err == nil {
+			return CgroupModeV2, nil
+		}
+		if os.IsNotExist(err) {
+			return CgroupModeV1, nil
+		}
+		return "", err
+	default:
+		return "", fmt.Errorf("unknown cgroup mode: %q", mode)
+	}
+}
+
+// memoryUsage returns the memory charged against the container's limit. On
+// cgroup v2 hosts the hierarchical total_* counters are not populated, so the
+// usage is derived from the anonymous and active file-backed pages instead.
+func memoryUsage(mode CgroupMode, stat garden.ContainerMemoryStat) uint64 {
+	if mode == CgroupModeV2 {
+		return stat.ActiveAnon + stat.InactiveAnon + stat.ActiveFile
+	}
+	return stat.TotalUsageTowardLimit
+}
@@ -0,0 +1,65 @@
+package containerstore_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("ResolveCgroupMode", func() {
+	var cgroupRoot string
+
+	BeforeEach(func() {
+		var err error
+		cgroupRoot, err = ioutil.TempDir("", "cgroup-root")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(cgroupRoot)
+	})
+
+	It("returns an explicitly configured mode unchanged", func() {
+		mode, err := containerstore.ResolveCgroupMode(containerstore.CgroupModeV2, cgroupRoot)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(containerstore.CgroupModeV2))
+
+		mode, err = containerstore.ResolveCgroupMode(containerstore.CgroupModeV1, cgroupRoot)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(mode).To(Equal(containerstore.CgroupModeV1))
+	})
+
+	Context("when the mode is auto", func() {
+		Context("and the cgroup root is a unified hierarchy", func() {
+			BeforeEach(func() {
+				err := ioutil.WriteFile(filepath.Join(cgroupRoot, "cgroup.controllers"), []byte("cpu memory pids"), 0644)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("detects cgroup v2", func() {
+				mode, err := containerstore.ResolveCgroupMode(containerstore.CgroupModeAuto, cgroupRoot)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mode).To(Equal(containerstore.CgroupModeV2))
+			})
+		})
+
+		Context("and the cgroup root is a legacy hierarchy", func() {
+			It("detects cgroup v1", func() {
+				mode, err := containerstore.ResolveCgroupMode(containerstore.CgroupModeAuto, cgroupRoot)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(mode).To(Equal(containerstore.CgroupModeV1))
+			})
+		})
+	})
+
+	Context("when the mode is unknown", func() {
+		It("returns an error", func() {
+			_, err := containerstore.ResolveCgroupMode("v3", cgroupRoot)
+			Expect(err).To(HaveOccurred())
+		})
+	})
+})
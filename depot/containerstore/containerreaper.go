@@ -2,27 +2,45 @@ package containerstore
 
 import (
 	"os"
+	"time"
 
 	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
 )
 
+// OrphanPolicy decides what the container reaper does with garden containers
+// owned by this executor that it is not tracking, such as those left behind by
+// a crash. Their run info is lost, so they cannot be adopted again.
+type OrphanPolicy string
+
+const (
+	// OrphanPolicyDestroy destroys orphaned containers.
+	OrphanPolicyDestroy OrphanPolicy = "destroy"
+	// OrphanPolicyKeep only logs orphaned containers, leaving them for an
+	// operator to inspect.
+	OrphanPolicyKeep OrphanPolicy = "keep"
+)
+
 type containerReaper struct {
 	logger       lager.Logger
 	config       *ContainerConfig
 	clock        clock.Clock
 	containers   *nodeMap
 	gardenClient garden.Client
+	eventEmitter event.Hub
 }
 
-func newContainerReaper(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap, gardenClient garden.Client) *containerReaper {
+func newContainerReaper(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap, gardenClient garden.Client, eventEmitter event.Hub) *containerReaper {
 	return &containerReaper{
 		logger:       logger,
 		config:       config,
 		clock:        clock,
 		containers:   containers,
 		gardenClient: gardenClient,
+		eventEmitter: eventEmitter,
 	}
 }
 
@@ -45,6 +63,8 @@ func (r *containerReaper) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 				logger.Error("failed-to-reap-missing-containers", err)
 			}
 
+			r.reapCompletedContainers(logger)
+
 		case <-signals:
 			return nil
 		}
@@ -62,11 +82,18 @@ func (r *containerReaper) reapExtraGardenContainers(logger lager.Logger) error {
 	}
 
 	for key := range handles {
-		if !r.containers.Contains(key) {
-			err := r.gardenClient.Destroy(key)
-			if err != nil {
-				logger.Error("failed-to-destroy-container", err, lager.Data{"handle": key})
-			}
+		if r.containers.Contains(key) {
+			continue
+		}
+
+		if r.config.OrphanPolicy == OrphanPolicyKeep {
+			logger.Info("keeping-orphaned-container", lager.Data{"handle": key})
+			continue
+		}
+
+		err := r.gardenClient.Destroy(key)
+		if err != nil {
+			logger.Error("failed-to-destroy-container", err, lager.Data{"handle": key})
 		}
 	}
 
@@ -87,6 +114,30 @@ func (r *containerReaper) reapMissingGardenContainers(logger lager.Logger) error
 	return nil
 }
 
+func (r *containerReaper) reapCompletedContainers(logger lager.Logger) {
+	if r.config.CompletedRetention <= 0 {
+		return
+	}
+
+	cutoff := r.clock.Now().Add(-r.config.CompletedRetention)
+	for _, node := range r.containers.List() {
+		info := node.Info()
+		if info.State != executor.StateCompleted || time.Unix(0, info.CompletedAt).After(cutoff) {
+			continue
+		}
+
+		logger.Info("reaping-completed-container", lager.Data{"guid": info.Guid})
+		err := node.Destroy(logger)
+		if err != nil {
+			logger.Error("failed-to-destroy-completed-container", err, lager.Data{"guid": info.Guid})
+			continue
+		}
+
+		r.containers.Remove(info.Guid)
+		go r.eventEmitter.Emit(executor.NewContainerDestroyedEvent(info))
+	}
+}
+
 func (r *containerReaper) fetchGardenContainerHandles(logger lager.Logger) (map[string]struct{}, error) {
 	properties := garden.Properties{
 		ContainerOwnerProperty: r.config.OwnerName,
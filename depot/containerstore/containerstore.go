@@ -1,8 +1,10 @@
 package containerstore
 
 import (
+	"context"
 	"errors"
 	"io"
+	"sort"
 	"time"
 
 	"code.cloudfoundry.org/clock"
@@ -16,7 +18,10 @@ import (
 	"github.com/tedsuo/ifrit"
 )
 
-const ContainerOwnerProperty = "executor:owner"
+const (
+	ContainerOwnerProperty     = "executor:owner"
+	ContainerRequestIDProperty = "executor:request-id"
+)
 
 var (
 	ErrFailedToCAS = errors.New("failed-to-cas")
@@ -24,9 +29,13 @@ var (
 
 //go:generate counterfeiter -o containerstorefakes/fake_containerstore.go . ContainerStore
 
+// ContainerStore tracks the executor's containers and drives them through
+// their lifecycle. The depot only depends on this interface; New returns the
+// implementation backed by a garden server.
 type ContainerStore interface {
 	// Setters
 	Reserve(logger lager.Logger, req *executor.AllocationRequest) (executor.Container, error)
+	ReserveAll(logger lager.Logger, reqs []*executor.AllocationRequest) map[string]error
 	Destroy(logger lager.Logger, guid string) error
 
 	// Container Operations
@@ -34,13 +43,22 @@ type ContainerStore interface {
 	Create(logger lager.Logger, guid string) (executor.Container, error)
 	Run(logger lager.Logger, guid string) error
 	Stop(logger lager.Logger, guid string) error
+	Evacuate(logger lager.Logger, guid string) error
 
 	// Getters
 	Get(logger lager.Logger, guid string) (executor.Container, error)
 	List(logger lager.Logger) []executor.Container
+	ListPage(logger lager.Logger, opts executor.ContainerListOptions) executor.ContainerPage
 	Metrics(logger lager.Logger) (map[string]executor.ContainerMetrics, error)
 	RemainingResources(logger lager.Logger) executor.ExecutorResources
-	GetFiles(logger lager.Logger, guid, sourcePath string) (io.ReadCloser, error)
+	GetFiles(ctx context.Context, logger lager.Logger, guid string, sourcePaths ...string) (io.ReadCloser, error)
+
+	// Files
+	PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error
+
+	// Processes
+	RunProcess(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error)
+	Attach(logger lager.Logger, guid string) (io.ReadCloser, error)
 
 	// Cleanup
 	NewRegistryPruner(logger lager.Logger) ifrit.Runner
@@ -50,26 +68,79 @@ type ContainerStore interface {
 	Cleanup(logger lager.Logger)
 }
 
+//go:generate counterfeiter -o containerstorefakes/fake_completion_observer.go . CompletionObserver
+
+// CompletionObserver is told about every container that completes. Unlike a
+// subscriber to the event hub, which may miss events when it falls behind,
+// it sees every completion.
+type CompletionObserver interface {
+	ContainerCompleted(logger lager.Logger, container executor.Container)
+}
+
 type ContainerConfig struct {
 	OwnerName    string
 	INodeLimit   uint64
 	MaxCPUShares uint64
+	CgroupMode   CgroupMode
+	HelperAssets HelperAssets
+
+	// MaxINodeLimit caps the inode limit containers may ask for instead of
+	// INodeLimit. Zero caps it at INodeLimit.
+	MaxINodeLimit uint64
+
+	// DisallowPrivileged rejects run requests for privileged containers.
+	DisallowPrivileged bool
+
+	GetFilesLimits StreamLimits
+
+	// StopGracePeriod is how long a stopped container's processes are given
+	// to exit before they are killed, unless the container sets its own.
+	// Zero waits indefinitely.
+	StopGracePeriod time.Duration
 
 	ReservedExpirationTime time.Duration
 	ReapInterval           time.Duration
+
+	// OrphanPolicy is applied to garden containers owned by this executor
+	// that it is not tracking. The zero value destroys them.
+	OrphanPolicy OrphanPolicy
+
+	// CompletedRetention is how long completed containers are kept before
+	// the container reaper destroys them. Zero keeps them until they are
+	// deleted.
+	CompletedRetention time.Duration
+}
+
+// inodeLimit returns the inode limit for a container asking for requested
+// inodes, where zero asks for the default.
+func (c *ContainerConfig) inodeLimit(requested uint64) uint64 {
+	if requested == 0 {
+		return c.INodeLimit
+	}
+
+	max := c.MaxINodeLimit
+	if max == 0 {
+		max = c.INodeLimit
+	}
+	if max != 0 && requested > max {
+		return max
+	}
+	return requested
 }
 
 type containerStore struct {
-	containerConfig   ContainerConfig
-	gardenClient      garden.Client
-	dependencyManager DependencyManager
-	volumeManager     volman.Manager
-	credManager       CredManager
-	transformer       transformer.Transformer
-	containers        *nodeMap
-	eventEmitter      event.Hub
-	clock             clock.Clock
-	metronClient      loggregator_v2.Client
+	containerConfig    ContainerConfig
+	gardenClient       garden.Client
+	dependencyManager  DependencyManager
+	volumeManager      volman.Manager
+	credManager        CredManager
+	transformer        transformer.Transformer
+	containers         *nodeMap
+	hostPorts          *hostPorts
+	eventEmitter       event.Hub
+	completionObserver CompletionObserver
+	clock              clock.Clock
+	metronClient       loggregator_v2.Client
 
 	trustedSystemCertificatesPath string
 }
@@ -83,6 +154,7 @@ func New(
 	credManager CredManager,
 	clock clock.Clock,
 	eventEmitter event.Hub,
+	completionObserver CompletionObserver,
 	transformer transformer.Transformer,
 	trustedSystemCertificatesPath string,
 	metronClient loggregator_v2.Client,
@@ -94,7 +166,9 @@ func New(
 		volumeManager:                 volumeManager,
 		credManager:                   credManager,
 		containers:                    newNodeMap(totalCapacity),
+		hostPorts:                     newHostPorts(),
 		eventEmitter:                  eventEmitter,
+		completionObserver:            completionObserver,
 		transformer:                   transformer,
 		clock:                         clock,
 		metronClient:                  metronClient,
@@ -107,25 +181,13 @@ func (cs *containerStore) Cleanup(logger lager.Logger) {
 }
 
 func (cs *containerStore) Reserve(logger lager.Logger, req *executor.AllocationRequest) (executor.Container, error) {
-	logger = logger.Session("containerstore-reserve", lager.Data{"guid": req.Guid})
+	logger = logger.Session("containerstore-reserve", withRequestID(lager.Data{"guid": req.Guid}, req.Tags))
 	logger.Debug("starting")
 	defer logger.Debug("complete")
 
 	container := executor.NewReservedContainerFromAllocationRequest(req, cs.clock.Now().UnixNano())
 
-	err := cs.containers.Add(
-		newStoreNode(&cs.containerConfig,
-			container,
-			cs.gardenClient,
-			cs.dependencyManager,
-			cs.volumeManager,
-			cs.credManager,
-			cs.eventEmitter,
-			cs.transformer,
-			cs.trustedSystemCertificatesPath,
-			cs.metronClient,
-		))
-
+	err := cs.containers.Add(cs.newNode(container))
 	if err != nil {
 		logger.Error("failed-to-reserve", err)
 		return executor.Container{}, err
@@ -135,8 +197,54 @@ func (cs *containerStore) Reserve(logger lager.Logger, req *executor.AllocationR
 	return container, nil
 }
 
+// ReserveAll reserves every container requested, or none of them when any
+// does not fit. The errors are keyed by the guids of the containers that
+// could not be reserved.
+func (cs *containerStore) ReserveAll(logger lager.Logger, reqs []*executor.AllocationRequest) map[string]error {
+	logger = logger.Session("containerstore-reserve-all", lager.Data{"count": len(reqs)})
+	logger.Debug("starting")
+	defer logger.Debug("complete")
+
+	now := cs.clock.Now().UnixNano()
+	containers := make([]executor.Container, 0, len(reqs))
+	nodes := make([]*storeNode, 0, len(reqs))
+	for _, req := range reqs {
+		container := executor.NewReservedContainerFromAllocationRequest(req, now)
+		containers = append(containers, container)
+		nodes = append(nodes, cs.newNode(container))
+	}
+
+	errs := cs.containers.AddAll(nodes)
+	if len(errs) > 0 {
+		logger.Info("failed-to-reserve-all", lager.Data{"failures": len(errs)})
+		return errs
+	}
+
+	for _, container := range containers {
+		cs.eventEmitter.Emit(executor.NewContainerReservedEvent(container))
+	}
+	return nil
+}
+
+func (cs *containerStore) newNode(container executor.Container) *storeNode {
+	return newStoreNode(&cs.containerConfig,
+		container,
+		cs.gardenClient,
+		cs.dependencyManager,
+		cs.volumeManager,
+		cs.credManager,
+		cs.hostPorts,
+		cs.eventEmitter,
+		cs.completionObserver,
+		cs.transformer,
+		cs.trustedSystemCertificatesPath,
+		cs.metronClient,
+		cs.clock,
+	)
+}
+
 func (cs *containerStore) Initialize(logger lager.Logger, req *executor.RunRequest) error {
-	logger = logger.Session("containerstore-initialize", lager.Data{"guid": req.Guid})
+	logger = logger.Session("containerstore-initialize", withRequestID(lager.Data{"guid": req.Guid}, req.Tags))
 	logger.Debug("starting")
 	defer logger.Debug("complete")
 
@@ -146,6 +254,17 @@ func (cs *containerStore) Initialize(logger lager.Logger, req *executor.RunReque
 		return err
 	}
 
+	if req.Privileged && cs.containerConfig.DisallowPrivileged {
+		logger.Error("privileged-container-not-allowed", executor.ErrPrivilegedNotAllowed)
+		return executor.ErrPrivilegedNotAllowed
+	}
+
+	err = cs.transformer.ValidateActions(req.RunInfo)
+	if err != nil {
+		logger.Error("invalid-actions", err)
+		return err
+	}
+
 	err = node.Initialize(logger, req)
 	if err != nil {
 		return err
@@ -217,6 +336,27 @@ func (cs *containerStore) Stop(logger lager.Logger, guid string) error {
 	return nil
 }
 
+func (cs *containerStore) Evacuate(logger lager.Logger, guid string) error {
+	logger = logger.Session("containerstore-evacuate", lager.Data{"guid": guid})
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		logger.Error("failed-to-get-container", err)
+		return err
+	}
+
+	err = node.Evacuate(logger)
+	if err != nil {
+		logger.Error("failed-to-evacuate-container", err)
+		return err
+	}
+
+	return nil
+}
+
 func (cs *containerStore) Destroy(logger lager.Logger, guid string) error {
 	logger = logger.Session("containerstore.destroy", lager.Data{"Guid": guid})
 
@@ -264,6 +404,50 @@ func (cs *containerStore) List(logger lager.Logger) []executor.Container {
 	return containers
 }
 
+// ListPage returns the containers in the requested states, ordered by guid,
+// starting after opts.After and holding at most opts.Limit of them.
+func (cs *containerStore) ListPage(logger lager.Logger, opts executor.ContainerListOptions) executor.ContainerPage {
+	logger = logger.Session("containerstore-list-page")
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	nodes := cs.containers.List()
+
+	containers := make([]executor.Container, 0, len(nodes))
+	for i := range nodes {
+		info := nodes[i].Info()
+		if opts.After != "" && info.Guid <= opts.After {
+			continue
+		}
+		if len(opts.States) > 0 && !containsState(opts.States, info.State) {
+			continue
+		}
+		containers = append(containers, info)
+	}
+
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Guid < containers[j].Guid
+	})
+
+	page := executor.ContainerPage{Containers: containers}
+	if opts.Limit > 0 && len(containers) > opts.Limit {
+		page.Containers = containers[:opts.Limit]
+		page.NextCursor = page.Containers[opts.Limit-1].Guid
+	}
+
+	return page
+}
+
+func containsState(states []executor.State, state executor.State) bool {
+	for _, s := range states {
+		if s == state {
+			return true
+		}
+	}
+	return false
+}
+
 func (cs *containerStore) Metrics(logger lager.Logger) (map[string]executor.ContainerMetrics, error) {
 	logger = logger.Session("containerstore-metrics")
 
@@ -298,7 +482,7 @@ func (cs *containerStore) Metrics(logger lager.Logger) (map[string]executor.Cont
 			if metricEntry.Err == nil {
 				gardenMetric := metricEntry.Metrics
 				containerMetrics[guid] = executor.ContainerMetrics{
-					MemoryUsageInBytes: gardenMetric.MemoryStat.TotalUsageTowardLimit,
+					MemoryUsageInBytes: memoryUsage(cs.containerConfig.CgroupMode, gardenMetric.MemoryStat),
 					DiskUsageInBytes:   gardenMetric.DiskStat.ExclusiveBytesUsed,
 					MemoryLimitInBytes: memoryLimitMap[guid],
 					DiskLimitInBytes:   diskLimitMap[guid],
@@ -315,7 +499,7 @@ func (cs *containerStore) RemainingResources(logger lager.Logger) executor.Execu
 	return cs.containers.RemainingResources()
 }
 
-func (cs *containerStore) GetFiles(logger lager.Logger, guid, sourcePath string) (io.ReadCloser, error) {
+func (cs *containerStore) GetFiles(ctx context.Context, logger lager.Logger, guid string, sourcePaths ...string) (io.ReadCloser, error) {
 	logger = logger.Session("containerstore-getfiles")
 
 	logger.Info("starting")
@@ -326,13 +510,70 @@ func (cs *containerStore) GetFiles(logger lager.Logger, guid, sourcePath string)
 		return nil, err
 	}
 
-	return node.GetFiles(logger, sourcePath)
+	stream, err := node.GetFiles(ctx, logger, sourcePaths)
+	if err != nil {
+		return nil, err
+	}
+
+	return newLimitedStream(stream, cs.containerConfig.GetFilesLimits, cs.clock), nil
+}
+
+func (cs *containerStore) PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error {
+	logger = logger.Session("containerstore-putfiles", lager.Data{"guid": guid, "dest-path": destPath})
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		return err
+	}
+
+	err = node.PutFiles(ctx, logger, destPath, tarStream)
+	if err != nil {
+		logger.Error("failed-to-put-files", err)
+		return err
+	}
+
+	return nil
+}
+
+func (cs *containerStore) RunProcess(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error) {
+	logger = logger.Session("containerstore-runprocess", lager.Data{"guid": guid, "path": spec.Path})
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		return 0, err
+	}
+
+	exitStatus, err := node.RunProcess(ctx, logger, spec, processIO)
+	if err != nil {
+		logger.Error("failed-to-run-process", err)
+		return 0, err
+	}
+
+	logger.Info("process-exited", lager.Data{"exit-status": exitStatus})
+	return exitStatus, nil
+}
+
+func (cs *containerStore) Attach(logger lager.Logger, guid string) (io.ReadCloser, error) {
+	logger = logger.Session("containerstore-attach", lager.Data{"guid": guid})
+
+	node, err := cs.containers.Get(guid)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.Attach(logger)
 }
 
 func (cs *containerStore) NewRegistryPruner(logger lager.Logger) ifrit.Runner {
-	return newRegistryPruner(logger, &cs.containerConfig, cs.clock, cs.containers)
+	return newRegistryPruner(logger, &cs.containerConfig, cs.clock, cs.containers, cs.eventEmitter)
 }
 
 func (cs *containerStore) NewContainerReaper(logger lager.Logger) ifrit.Runner {
-	return newContainerReaper(logger, &cs.containerConfig, cs.clock, cs.containers, cs.gardenClient)
+	return newContainerReaper(logger, &cs.containerConfig, cs.clock, cs.containers, cs.gardenClient, cs.eventEmitter)
 }
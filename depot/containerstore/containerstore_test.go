@@ -1,8 +1,13 @@
 package containerstore_test
 
 import (
+	"archive/tar"
 	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"os"
@@ -20,6 +25,7 @@ import (
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/depot/containerstore/containerstorefakes"
+	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/executor/depot/transformer/faketransformer"
 	"code.cloudfoundry.org/garden"
 	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
@@ -34,7 +40,8 @@ import (
 
 var _ = Describe("Container Store", func() {
 	var (
-		containerStore containerstore.ContainerStore
+		containerStore  containerstore.ContainerStore
+		containerConfig containerstore.ContainerConfig
 
 		iNodeLimit    uint64
 		maxCPUShares  uint64
@@ -53,9 +60,10 @@ var _ = Describe("Container Store", func() {
 		credManager       *containerstorefakes.FakeCredManager
 		volumeManager     *volmanfakes.FakeManager
 
-		clock            *fakeclock.FakeClock
-		eventEmitter     *eventfakes.FakeHub
-		fakeMetronClient *mfakes.FakeClient
+		clock              *fakeclock.FakeClock
+		eventEmitter       *eventfakes.FakeHub
+		completionObserver *containerstorefakes.FakeCompletionObserver
+		fakeMetronClient   *mfakes.FakeClient
 	)
 
 	var pollForComplete = func(guid string) func() bool {
@@ -84,6 +92,23 @@ var _ = Describe("Container Store", func() {
 		return m
 	}
 
+	newContainerStore := func() containerstore.ContainerStore {
+		return containerstore.New(
+			containerConfig,
+			&totalCapacity,
+			gardenClient,
+			dependencyManager,
+			volumeManager,
+			credManager,
+			clock,
+			eventEmitter,
+			completionObserver,
+			megatron,
+			"/var/vcap/data/cf-system-trusted-certs",
+			fakeMetronClient,
+		)
+	}
+
 	BeforeEach(func() {
 		metricMap = map[string]struct{}{}
 		gardenContainer = &gardenfakes.FakeContainer{}
@@ -93,6 +118,7 @@ var _ = Describe("Container Store", func() {
 		volumeManager = &volmanfakes.FakeManager{}
 		clock = fakeclock.NewFakeClock(time.Now())
 		eventEmitter = &eventfakes.FakeHub{}
+		completionObserver = &containerstorefakes.FakeCompletionObserver{}
 
 		credManager.RunnerReturns(ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 			close(ready)
@@ -111,7 +137,7 @@ var _ = Describe("Container Store", func() {
 
 		fakeMetronClient = new(mfakes.FakeClient)
 
-		containerConfig := containerstore.ContainerConfig{
+		containerConfig = containerstore.ContainerConfig{
 			OwnerName:              ownerName,
 			INodeLimit:             iNodeLimit,
 			MaxCPUShares:           maxCPUShares,
@@ -119,19 +145,7 @@ var _ = Describe("Container Store", func() {
 			ReservedExpirationTime: 20 * time.Millisecond,
 		}
 
-		containerStore = containerstore.New(
-			containerConfig,
-			&totalCapacity,
-			gardenClient,
-			dependencyManager,
-			volumeManager,
-			credManager,
-			clock,
-			eventEmitter,
-			megatron,
-			"/var/vcap/data/cf-system-trusted-certs",
-			fakeMetronClient,
-		)
+		containerStore = newContainerStore()
 
 		fakeMetronClient.SendDurationStub = func(name string, value time.Duration) error {
 			metricMapLock.Lock()
@@ -231,6 +245,54 @@ var _ = Describe("Container Store", func() {
 		})
 	})
 
+	Describe("ReserveAll", func() {
+		var reqs []*executor.AllocationRequest
+
+		BeforeEach(func() {
+			reqs = []*executor.AllocationRequest{
+				{Guid: "guid-1", Resource: executor.Resource{MemoryMB: 10, DiskMB: 10}},
+				{Guid: "guid-2", Resource: executor.Resource{MemoryMB: 10, DiskMB: 10}},
+			}
+		})
+
+		It("reserves every container", func() {
+			Expect(containerStore.ReserveAll(logger, reqs)).To(BeEmpty())
+
+			Expect(containerStore.List(logger)).To(HaveLen(2))
+			Expect(containerStore.RemainingResources(logger).MemoryMB).To(Equal(totalCapacity.MemoryMB - 20))
+			Eventually(eventEmitter.EmitCallCount).Should(Equal(2))
+		})
+
+		Context("when one of the containers does not fit", func() {
+			BeforeEach(func() {
+				reqs[1].Resource.MemoryMB = totalCapacity.MemoryMB
+			})
+
+			It("reserves none of them and reports the one that did not fit", func() {
+				errs := containerStore.ReserveAll(logger, reqs)
+				Expect(errs).To(Equal(map[string]error{
+					"guid-2": executor.ErrInsufficientResourcesAvailable,
+				}))
+
+				Expect(containerStore.List(logger)).To(BeEmpty())
+				Expect(containerStore.RemainingResources(logger)).To(Equal(totalCapacity))
+				Consistently(eventEmitter.EmitCallCount).Should(Equal(0))
+			})
+		})
+
+		Context("when a guid is requested twice", func() {
+			BeforeEach(func() {
+				reqs[1].Guid = "guid-1"
+			})
+
+			It("reserves none of them", func() {
+				errs := containerStore.ReserveAll(logger, reqs)
+				Expect(errs).To(HaveKeyWithValue("guid-1", executor.ErrContainerGuidNotAvailable))
+				Expect(containerStore.List(logger)).To(BeEmpty())
+			})
+		})
+	})
+
 	Describe("Initialize", func() {
 		var (
 			req     *executor.RunRequest
@@ -305,6 +367,52 @@ var _ = Describe("Container Store", func() {
 				Expect(err).To(Equal(executor.ErrInvalidTransition))
 			})
 		})
+
+		Context("when privileged containers are disallowed", func() {
+			BeforeEach(func() {
+				containerConfig.DisallowPrivileged = true
+				containerStore = newContainerStore()
+
+				_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid, Tags: executor.Tags{}})
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("rejects a privileged container", func() {
+				err := containerStore.Initialize(logger, req)
+				Expect(err).To(Equal(executor.ErrPrivilegedNotAllowed))
+
+				container, err := containerStore.Get(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.State).To(Equal(executor.StateReserved))
+			})
+
+			It("initializes an unprivileged container", func() {
+				req.Privileged = false
+				err := containerStore.Initialize(logger, req)
+				Expect(err).NotTo(HaveOccurred())
+			})
+		})
+
+		Context("when the container's actions are invalid", func() {
+			BeforeEach(func() {
+				_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid, Tags: executor.Tags{}})
+				Expect(err).NotTo(HaveOccurred())
+
+				megatron.ValidateActionsReturns(executor.ErrStepsInvalid)
+			})
+
+			It("rejects the container", func() {
+				err := containerStore.Initialize(logger, req)
+				Expect(err).To(Equal(executor.ErrStepsInvalid))
+
+				Expect(megatron.ValidateActionsCallCount()).To(Equal(1))
+				Expect(megatron.ValidateActionsArgsForCall(0)).To(Equal(req.RunInfo))
+
+				container, err := containerStore.Get(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.State).To(Equal(executor.StateReserved))
+			})
+		})
 	})
 
 	Describe("Create", func() {
@@ -396,6 +504,15 @@ var _ = Describe("Container Store", func() {
 				Expect(container.State).To(Equal(executor.StateCreated))
 			})
 
+			It("fetches the container's ports and IPs with a single info call", func() {
+				container, err := containerStore.Create(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(gardenContainer.InfoCallCount()).To(Equal(1))
+				Expect(container.ExternalIP).To(Equal(externalIP))
+				Expect(container.InternalIP).To(Equal(internalIP))
+			})
+
 			It("creates the container in garden with correct image parameters", func() {
 				_, err := containerStore.Create(logger, containerGuid)
 				Expect(err).NotTo(HaveOccurred())
@@ -443,11 +560,105 @@ var _ = Describe("Container Store", func() {
 				Expect(containerSpec.Limits.CPU.LimitInShares).To(Equal(expectedCPUShares))
 			})
 
+			Context("when an inode limit is given", func() {
+				BeforeEach(func() {
+					runReq.InodeLimit = 2 * iNodeLimit
+				})
+
+				It("caps it at the default inode limit", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Limits.Disk.InodeHard).To(Equal(iNodeLimit))
+				})
+
+				Context("when the maximum inode limit is higher", func() {
+					BeforeEach(func() {
+						containerConfig.MaxINodeLimit = 4 * iNodeLimit
+						containerStore = newContainerStore()
+					})
+
+					It("creates the container with the inode limit", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						containerSpec := gardenClient.CreateArgsForCall(0)
+						Expect(containerSpec.Limits.Disk.InodeHard).To(Equal(2 * iNodeLimit))
+					})
+				})
+
+				Context("when the maximum inode limit is lower than the request", func() {
+					BeforeEach(func() {
+						containerConfig.MaxINodeLimit = iNodeLimit + 1
+						containerStore = newContainerStore()
+					})
+
+					It("caps it at the maximum", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						containerSpec := gardenClient.CreateArgsForCall(0)
+						Expect(containerSpec.Limits.Disk.InodeHard).To(Equal(iNodeLimit + 1))
+					})
+				})
+			})
+
+			Context("when a network bandwidth limit is given", func() {
+				BeforeEach(func() {
+					runReq.NetworkBandwidthLimit = &executor.BandwidthLimit{
+						RateInBytesPerSecond:      1024 * 1024,
+						BurstRateInBytesPerSecond: 4 * 1024 * 1024,
+					}
+				})
+
+				It("creates the container with the bandwidth limit", func() {
+					container, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Limits.Bandwidth).To(Equal(garden.BandwidthLimits{
+						RateInBytesPerSecond:      1024 * 1024,
+						BurstRateInBytesPerSecond: 4 * 1024 * 1024,
+					}))
+					Expect(container.NetworkBandwidthLimit).To(Equal(runReq.NetworkBandwidthLimit))
+				})
+			})
+
+			It("reports the cpu shares the container was given", func() {
+				container, err := containerStore.Create(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				expectedCPUShares := uint64(float64(maxCPUShares) * float64(runReq.CPUWeight) / 100.0)
+				Expect(container.CPUShares).To(Equal(expectedCPUShares))
+			})
+
+			Context("when running on a cgroup v2 host", func() {
+				BeforeEach(func() {
+					maxCPUShares = 1024
+					containerConfig.MaxCPUShares = maxCPUShares
+					containerConfig.CgroupMode = containerstore.CgroupModeV2
+					containerStore = newContainerStore()
+				})
+
+				It("passes the cpu shares to garden unchanged, for it to convert", func() {
+					container, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.CPUShares).To(BeEquivalentTo(512))
+
+					Expect(gardenClient.CreateCallCount()).To(Equal(1))
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Limits.CPU.LimitInShares).To(BeEquivalentTo(512))
+					Expect(containerSpec.Limits.CPU.Weight).To(BeZero())
+				})
+			})
+
 			It("downloads the correct cache dependencies", func() {
 				_, err := containerStore.Create(logger, containerGuid)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(dependencyManager.DownloadCachedDependenciesCallCount()).To(Equal(1))
-				_, mounts, _ := dependencyManager.DownloadCachedDependenciesArgsForCall(0)
+				_, guid, mounts, _ := dependencyManager.DownloadCachedDependenciesArgsForCall(0)
+				Expect(guid).To(Equal(containerGuid))
 				Expect(mounts).To(Equal(runReq.CachedDependencies))
 			})
 
@@ -481,6 +692,20 @@ var _ = Describe("Container Store", func() {
 				}))
 			})
 
+			Context("when the container has a request ID", func() {
+				BeforeEach(func() {
+					allocationReq.Tags[executor.RequestIDTag] = "some-request-id"
+				})
+
+				It("sets the request ID property", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Properties).To(HaveKeyWithValue(containerstore.ContainerRequestIDProperty, "some-request-id"))
+				})
+			})
+
 			Context("if the network is not set", func() {
 				BeforeEach(func() {
 					runReq.RunInfo.Network = nil
@@ -589,6 +814,85 @@ var _ = Describe("Container Store", func() {
 				})
 			})
 
+			Context("when helper assets are configured", func() {
+				helperMount := func(srcPath string) garden.BindMount {
+					return garden.BindMount{
+						SrcPath: srcPath,
+						DstPath: "/etc/cf-assets/helpers",
+						Mode:    garden.BindMountModeRO,
+						Origin:  garden.BindMountOriginHost,
+					}
+				}
+
+				BeforeEach(func() {
+					containerConfig.HelperAssets = containerstore.HelperAssets{
+						ContainerPath:       "/etc/cf-assets/helpers",
+						DefaultArchitecture: "amd64",
+						Dirs: map[string]string{
+							"amd64":            "/var/vcap/packages/helpers-amd64",
+							"arm64":            "/var/vcap/packages/helpers-arm64",
+							"cflinuxfs3/arm64": "/var/vcap/packages/helpers-cflinuxfs3-arm64",
+						},
+					}
+					containerStore = newContainerStore()
+				})
+
+				It("mounts the helpers for the default architecture", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(gardenClient.CreateCallCount()).To(Equal(1))
+					Expect(gardenClient.CreateArgsForCall(0).BindMounts).To(ContainElement(
+						helperMount("/var/vcap/packages/helpers-amd64"),
+					))
+				})
+
+				Context("when the container declares its architecture", func() {
+					BeforeEach(func() {
+						runReq.RunInfo.Architecture = "arm64"
+					})
+
+					It("mounts the helpers for that architecture", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(gardenClient.CreateArgsForCall(0).BindMounts).To(ContainElement(
+							helperMount("/var/vcap/packages/helpers-arm64"),
+						))
+					})
+
+					Context("and there are helpers for its stack and architecture", func() {
+						BeforeEach(func() {
+							allocationReq.Resource.RootFSPath = "preloaded:cflinuxfs3"
+						})
+
+						It("prefers the stack specific helpers", func() {
+							_, err := containerStore.Create(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(gardenClient.CreateArgsForCall(0).BindMounts).To(ContainElement(
+								helperMount("/var/vcap/packages/helpers-cflinuxfs3-arm64"),
+							))
+						})
+					})
+				})
+
+				Context("when there are no helpers for the container", func() {
+					BeforeEach(func() {
+						runReq.RunInfo.Architecture = "s390x"
+					})
+
+					It("fails fast and completes the container", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).To(Equal(containerstore.ErrNoHelperAssets))
+						Expect(gardenClient.CreateCallCount()).To(Equal(0))
+
+						container, err := containerStore.Get(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(container.State).To(Equal(executor.StateCompleted))
+						Expect(container.RunResult.Failed).To(BeTrue())
+						Expect(container.RunResult.FailureReason).To(Equal(containerstore.HelperAssetsUnavailable))
+					})
+				})
+			})
+
 			Context("when there are volume mounts configured", func() {
 				BeforeEach(func() {
 					someConfig := map[string]interface{}{"some-config": "interface"}
@@ -652,6 +956,30 @@ var _ = Describe("Container Store", func() {
 						Expect(container.RunResult.FailureReason).To(Equal(containerstore.VolmanMountFailed))
 					})
 				})
+
+				Context("when a later volume fails to mount", func() {
+					BeforeEach(func() {
+						volumeManager.MountStub = func(_ lager.Logger, driverId, volumeId string, _ map[string]interface{}) (volman.MountResponse, error) {
+							if volumeId == "some-other-volume" {
+								return volman.MountResponse{}, errors.New("some-error")
+							}
+							return volman.MountResponse{Path: "hpath1"}, nil
+						}
+					})
+
+					It("only unmounts the volumes that were mounted when the container is destroyed", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).To(HaveOccurred())
+
+						err = containerStore.Destroy(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						Expect(volumeManager.UnmountCallCount()).To(Equal(1))
+						_, driverId, volumeId := volumeManager.UnmountArgsForCall(0)
+						Expect(driverId).To(Equal("some-driver"))
+						Expect(volumeId).To(Equal("some-volume"))
+					})
+				})
 			})
 
 			Context("when there are trusted system certificates", func() {
@@ -840,61 +1168,182 @@ var _ = Describe("Container Store", func() {
 					Expect(err).NotTo(HaveOccurred())
 					Expect(fetchedContainer).To(Equal(container))
 				})
-			})
-
-			Context("when a total disk scope is request", func() {
-				BeforeEach(func() {
-					runReq.DiskScope = executor.TotalDiskLimit
-				})
 
-				It("creates the container with the correct disk scope", func() {
-					_, err := containerStore.Create(logger, containerGuid)
-					Expect(err).NotTo(HaveOccurred())
+				Context("when a host port range is given", func() {
+					var otherRunReq *executor.RunRequest
 
-					Expect(gardenClient.CreateCallCount()).To(Equal(1))
-					containerSpec := gardenClient.CreateArgsForCall(0)
-					Expect(containerSpec.Limits.Disk.Scope).To(Equal(garden.DiskLimitScopeTotal))
-				})
-			})
+					BeforeEach(func() {
+						runReq.HostPortRange = &executor.PortRange{Start: 61000, End: 61010}
 
-			Context("when creating the container fails", func() {
-				BeforeEach(func() {
-					gardenClient.CreateReturns(nil, errors.New("boom!"))
-				})
+						otherRunReq = &executor.RunRequest{
+							Guid: "other-guid",
+							RunInfo: executor.RunInfo{
+								Ports: []executor.PortMapping{{ContainerPort: 8080, HostPort: 61000}},
+							},
+						}
+					})
 
-				It("returns an error", func() {
-					_, err := containerStore.Create(logger, containerGuid)
-					Expect(err).To(Equal(errors.New("boom!")))
-				})
+					JustBeforeEach(func() {
+						_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: "other-guid", Resource: resource})
+						Expect(err).NotTo(HaveOccurred())
 
-				It("transitions to a completed state", func() {
-					_, err := containerStore.Create(logger, containerGuid)
-					Expect(err).To(Equal(errors.New("boom!")))
+						err = containerStore.Initialize(logger, otherRunReq)
+						Expect(err).NotTo(HaveOccurred())
+					})
 
-					container, err := containerStore.Get(logger, containerGuid)
-					Expect(err).NotTo(HaveOccurred())
-					Expect(container.State).To(Equal(executor.StateCompleted))
-					Expect(container.RunResult.Failed).To(BeTrue())
-					Expect(container.RunResult.FailureReason).To(Equal(containerstore.ContainerInitializationFailedMessage))
-				})
+					It("allocates the host ports from the range", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
 
-				It("emits a metric after failing to create the container", func() {
-					_, err := containerStore.Create(logger, containerGuid)
-					Expect(err).To(HaveOccurred())
-					Eventually(getMetrics).Should(HaveKey(containerstore.GardenContainerCreationFailedDuration))
-				})
-			})
+						containerSpec := gardenClient.CreateArgsForCall(0)
+						Expect(containerSpec.NetIn).To(ConsistOf(
+							garden.NetIn{HostPort: 61000, ContainerPort: 8080},
+							garden.NetIn{HostPort: 61001, ContainerPort: 9090},
+						))
+					})
+
+					It("does not give the allocated host ports to another container", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						_, err = containerStore.Create(logger, "other-guid")
+						Expect(err).To(MatchError(executor.ErrHostPortsUnavailable))
+
+						container, err := containerStore.Get(logger, "other-guid")
+						Expect(err).NotTo(HaveOccurred())
+						Expect(container.State).To(Equal(executor.StateCompleted))
+						Expect(container.RunResult.FailureReason).To(Equal(containerstore.HostPortsUnavailable))
+						Expect(gardenClient.CreateCallCount()).To(Equal(1))
+					})
+
+					It("frees the host ports once the container is destroyed", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						err = containerStore.Destroy(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						_, err = containerStore.Create(logger, "other-guid")
+						Expect(err).NotTo(HaveOccurred())
+					})
+				})
+			})
+
+			Context("when DNS servers and hosts entries are given", func() {
+				var (
+					written      map[string]string
+					writeProcess *gardenfakes.FakeProcess
+				)
 
-			Context("when requesting the container info for the created container fails", func() {
 				BeforeEach(func() {
-					gardenContainer.InfoStub = func() (garden.ContainerInfo, error) {
-						if gardenContainer.InfoCallCount() == 1 {
-							return garden.ContainerInfo{}, nil
-						}
-						return garden.ContainerInfo{}, errors.New("could not obtain info")
+					runReq.DNSServers = []string{"10.0.0.2", "10.0.0.3"}
+					runReq.HostsEntries = []executor.HostsEntry{
+						{IP: "10.0.1.5", Hostnames: []string{"db.internal", "db"}},
+					}
+
+					gardenContainer.StreamOutStub = func(spec garden.StreamOutSpec) (io.ReadCloser, error) {
+						buffer := &bytes.Buffer{}
+						tarWriter := tar.NewWriter(buffer)
+						hosts := "127.0.0.1 localhost\n"
+						Expect(tarWriter.WriteHeader(&tar.Header{Name: "hosts", Mode: 0644, Size: int64(len(hosts))})).To(Succeed())
+						_, err := tarWriter.Write([]byte(hosts))
+						Expect(err).NotTo(HaveOccurred())
+						Expect(tarWriter.Close()).To(Succeed())
+						return ioutil.NopCloser(buffer), nil
+					}
+
+					written = map[string]string{}
+					writeProcess = &gardenfakes.FakeProcess{}
+					gardenContainer.RunStub = func(spec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+						body, err := ioutil.ReadAll(processIO.Stdin)
+						Expect(err).NotTo(HaveOccurred())
+						written[spec.Args[2]] = string(body)
+						return writeProcess, nil
 					}
 				})
 
+				It("writes the resolv.conf and appends to the hosts file in place", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(gardenContainer.StreamOutArgsForCall(0).Path).To(Equal("/etc/hosts"))
+					Expect(gardenContainer.StreamInCallCount()).To(BeZero())
+					Expect(written).To(Equal(map[string]string{
+						"/etc/resolv.conf": "nameserver 10.0.0.2\nnameserver 10.0.0.3\n",
+						"/etc/hosts":       "127.0.0.1 localhost\n10.0.1.5 db.internal db\n",
+					}))
+
+					spec, _ := gardenContainer.RunArgsForCall(0)
+					Expect(spec.Path).To(Equal("/bin/sh"))
+					Expect(spec.User).To(Equal("root"))
+				})
+
+				Context("when writing them fails", func() {
+					BeforeEach(func() {
+						writeProcess.WaitReturns(1, nil)
+					})
+
+					It("destroys the garden container and fails the container", func() {
+						_, err := containerStore.Create(logger, containerGuid)
+						Expect(err).To(HaveOccurred())
+						Expect(gardenClient.DestroyCallCount()).To(Equal(1))
+
+						container, err := containerStore.Get(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						Expect(container.State).To(Equal(executor.StateCompleted))
+						Expect(container.RunResult.FailureReason).To(Equal(containerstore.ContainerInitializationFailedMessage))
+					})
+				})
+			})
+
+			Context("when a total disk scope is request", func() {
+				BeforeEach(func() {
+					runReq.DiskScope = executor.TotalDiskLimit
+				})
+
+				It("creates the container with the correct disk scope", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					Expect(gardenClient.CreateCallCount()).To(Equal(1))
+					containerSpec := gardenClient.CreateArgsForCall(0)
+					Expect(containerSpec.Limits.Disk.Scope).To(Equal(garden.DiskLimitScopeTotal))
+				})
+			})
+
+			Context("when creating the container fails", func() {
+				BeforeEach(func() {
+					gardenClient.CreateReturns(nil, errors.New("boom!"))
+				})
+
+				It("returns an error", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).To(Equal(errors.New("boom!")))
+				})
+
+				It("transitions to a completed state", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).To(Equal(errors.New("boom!")))
+
+					container, err := containerStore.Get(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+					Expect(container.State).To(Equal(executor.StateCompleted))
+					Expect(container.RunResult.Failed).To(BeTrue())
+					Expect(container.RunResult.FailureReason).To(Equal(containerstore.ContainerInitializationFailedMessage))
+				})
+
+				It("emits a metric after failing to create the container", func() {
+					_, err := containerStore.Create(logger, containerGuid)
+					Expect(err).To(HaveOccurred())
+					Eventually(getMetrics).Should(HaveKey(containerstore.GardenContainerCreationFailedDuration))
+				})
+			})
+
+			Context("when requesting the container info for the created container fails", func() {
+				BeforeEach(func() {
+					gardenContainer.InfoReturns(garden.ContainerInfo{}, errors.New("could not obtain info"))
+				})
+
 				It("returns an error", func() {
 					_, err := containerStore.Create(logger, containerGuid)
 					Expect(err).To(HaveOccurred())
@@ -1164,7 +1613,7 @@ var _ = Describe("Container Store", func() {
 
 						Eventually(eventEmitter.EmitCallCount).Should(Equal(2))
 						event := eventEmitter.EmitArgsForCall(1)
-						Expect(event).To(Equal(executor.ContainerRunningEvent{RawContainer: container}))
+						Expect(event).To(Equal(executor.NewContainerRunningEvent(container)))
 					})
 				})
 
@@ -1215,9 +1664,23 @@ var _ = Describe("Container Store", func() {
 							for i := 0; i < eventEmitter.EmitCallCount(); i++ {
 								emittedEvents = append(emittedEvents, eventEmitter.EmitArgsForCall(i))
 							}
-							Expect(emittedEvents).To(ContainElement(executor.ContainerCompleteEvent{
-								RawContainer: container,
-							}))
+							Expect(emittedEvents).To(ContainElement(executor.NewContainerCompleteEvent(container)))
+						})
+
+						It("tells the completion observer the container completed", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(pollForRunning(containerGuid)).Should(BeTrue())
+							close(completeChan)
+							Eventually(pollForComplete(containerGuid)).Should(BeTrue())
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(completionObserver.ContainerCompletedCallCount).Should(Equal(1))
+							_, observed := completionObserver.ContainerCompletedArgsForCall(0)
+							Expect(observed).To(Equal(container))
 						})
 
 						It("sets the result on the container", func() {
@@ -1259,6 +1722,150 @@ var _ = Describe("Container Store", func() {
 							Expect(container.RunResult.Stopped).To(Equal(false))
 						})
 					})
+
+					Context("after a health check failed", func() {
+						BeforeEach(func() {
+							var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+								close(ready)
+								return errors.New("BOOOOM!!!!")
+							}
+							megatron.StepsRunnerReturns(healthCheckOutputRunner{testRunner, "connection refused"}, nil)
+						})
+
+						It("records the health check output on the run result and in the completed event", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(pollForComplete(containerGuid)).Should(BeTrue())
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.RunResult.HealthCheckOutput).To(Equal("connection refused"))
+
+							emittedEvents := []executor.Event{}
+							for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+								emittedEvents = append(emittedEvents, eventEmitter.EmitArgsForCall(i))
+							}
+							Expect(emittedEvents).To(ContainElement(executor.NewContainerCompleteEvent(container)))
+						})
+					})
+
+					Context("after running out of memory", func() {
+						BeforeEach(func() {
+							var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+								close(ready)
+								return errors.New("Exited with status 137")
+							}
+							megatron.StepsRunnerReturns(testRunner, nil)
+							gardenContainer.InfoReturns(garden.ContainerInfo{Events: []string{containerstore.OutOfMemoryEvent}}, nil)
+						})
+
+						It("marks the run result and emits an out of memory event before the completed event", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(pollForComplete(containerGuid)).Should(BeTrue())
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.RunResult.Failed).To(BeTrue())
+							Expect(container.RunResult.OutOfMemory).To(BeTrue())
+
+							eventTypes := func() []executor.EventType {
+								eventTypes := []executor.EventType{}
+								for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+									eventTypes = append(eventTypes, eventEmitter.EmitArgsForCall(i).EventType())
+								}
+								return eventTypes
+							}
+							Eventually(eventTypes).Should(ContainElement(executor.EventTypeContainerComplete))
+
+							types := eventTypes()
+							Expect(types).To(ContainElement(executor.EventTypeContainerOOM))
+							Expect(indexOfEventType(types, executor.EventTypeContainerOOM)).To(BeNumerically("<", indexOfEventType(types, executor.EventTypeContainerComplete)))
+						})
+					})
+
+					Context("with a restart policy", func() {
+						var runs chan struct{}
+
+						BeforeEach(func() {
+							runReq.RestartPolicy = executor.RestartPolicy{
+								Mode:             executor.RestartOnFailure,
+								MaxRestarts:      1,
+								InitialBackoffMs: 5000,
+							}
+
+							runs = make(chan struct{}, 2)
+							var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+								runs <- struct{}{}
+								close(ready)
+								return errors.New("BOOOOM!!!!")
+							}
+							megatron.StepsRunnerReturns(testRunner, nil)
+						})
+
+						It("restarts the action after the backoff until the restarts are used up", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(runs).Should(Receive())
+							Consistently(runs).ShouldNot(Receive())
+
+							clock.WaitForWatcherAndIncrement(5 * time.Second)
+							Eventually(runs).Should(Receive())
+							Expect(megatron.StepsRunnerCallCount()).To(Equal(2))
+
+							Eventually(pollForComplete(containerGuid)).Should(BeTrue())
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.RunResult.Failed).To(BeTrue())
+							Expect(container.RunResult.FailureReason).To(Equal("BOOOOM!!!!"))
+							Expect(container.RunResult.Restarts).To(BeEquivalentTo(1))
+						})
+
+						It("does not report an out of memory event from an earlier run", func() {
+							gardenContainer.InfoReturns(garden.ContainerInfo{Events: []string{containerstore.OutOfMemoryEvent}}, nil)
+
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(runs).Should(Receive())
+							clock.WaitForWatcherAndIncrement(5 * time.Second)
+							Eventually(runs).Should(Receive())
+
+							Eventually(pollForComplete(containerGuid)).Should(BeTrue())
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.RunResult.Failed).To(BeTrue())
+							Expect(container.RunResult.OutOfMemory).To(BeFalse())
+
+							for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+								Expect(eventEmitter.EmitArgsForCall(i).EventType()).NotTo(Equal(executor.EventTypeContainerOOM))
+							}
+						})
+
+						It("does not restart the action once the container is stopped", func() {
+							err := containerStore.Run(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(runs).Should(Receive())
+							Eventually(clock.WatcherCount).Should(BeNumerically(">", 0))
+
+							err = containerStore.Stop(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+
+							Eventually(pollForComplete(containerGuid)).Should(BeTrue())
+							Expect(megatron.StepsRunnerCallCount()).To(Equal(1))
+
+							container, err := containerStore.Get(logger, containerGuid)
+							Expect(err).NotTo(HaveOccurred())
+							Expect(container.RunResult.Stopped).To(BeTrue())
+							Expect(container.RunResult.Restarts).To(BeZero())
+						})
+					})
 				})
 
 				Context("when the transformer fails to generate steps", func() {
@@ -1296,8 +1903,10 @@ var _ = Describe("Container Store", func() {
 
 	Describe("Stop", func() {
 		var finishRun chan struct{}
+		var runInfo executor.RunInfo
 		BeforeEach(func() {
 			finishRun = make(chan struct{})
+			runInfo = executor.RunInfo{}
 			var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
 				<-signals
 				finishRun <- struct{}{}
@@ -1311,7 +1920,7 @@ var _ = Describe("Container Store", func() {
 			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
 			Expect(err).NotTo(HaveOccurred())
 
-			err = containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+			err = containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid, RunInfo: runInfo})
 			Expect(err).NotTo(HaveOccurred())
 
 			_, err = containerStore.Create(logger, containerGuid)
@@ -1333,6 +1942,76 @@ var _ = Describe("Container Store", func() {
 				container, err := containerStore.Get(logger, containerGuid)
 				Expect(err).NotTo(HaveOccurred())
 				Expect(container.RunResult.Stopped).To(BeTrue())
+				Expect(container.RunResult.Killed).To(BeFalse())
+			})
+
+			It("marks the run result as evacuated when evacuating", func() {
+				err := containerStore.Evacuate(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(finishRun).Should(Receive())
+
+				container, err := containerStore.Get(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.RunResult.Stopped).To(BeTrue())
+				Expect(container.RunResult.Evacuated).To(BeTrue())
+			})
+
+			Context("when the processes do not exit within the stop grace period", func() {
+				BeforeEach(func() {
+					killed := make(chan struct{})
+					gardenContainer.StopStub = func(kill bool) error {
+						close(killed)
+						return nil
+					}
+
+					var testRunner ifrit.RunFunc = func(signals <-chan os.Signal, ready chan<- struct{}) error {
+						close(ready)
+						<-signals
+						<-killed
+						return errors.New("killed")
+					}
+					megatron.StepsRunnerReturns(testRunner, nil)
+
+					containerConfig.StopGracePeriod = time.Minute
+					containerStore = newContainerStore()
+				})
+
+				It("kills the container's processes and records it on the run result", func() {
+					err := containerStore.Stop(logger, containerGuid)
+					Expect(err).NotTo(HaveOccurred())
+
+					clock.WaitForWatcherAndIncrement(time.Minute)
+
+					Eventually(gardenContainer.StopCallCount).Should(Equal(1))
+					Expect(gardenContainer.StopArgsForCall(0)).To(BeTrue())
+
+					Eventually(func() executor.ContainerRunResult {
+						container, err := containerStore.Get(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+						return container.RunResult
+					}).Should(Equal(executor.ContainerRunResult{
+						Failed:        true,
+						FailureReason: "killed",
+						Stopped:       true,
+						Killed:        true,
+					}))
+				})
+
+				Context("when the container sets its own grace period", func() {
+					BeforeEach(func() {
+						runInfo.StopGracePeriodMs = 5000
+					})
+
+					It("kills the processes after that period instead", func() {
+						err := containerStore.Stop(logger, containerGuid)
+						Expect(err).NotTo(HaveOccurred())
+
+						clock.WaitForWatcherAndIncrement(5 * time.Second)
+
+						Eventually(gardenContainer.StopCallCount).Should(Equal(1))
+					})
+				})
 			})
 		})
 
@@ -1682,6 +2361,26 @@ var _ = Describe("Container Store", func() {
 			Expect(containers).To(ContainElement(container1))
 			Expect(containers).To(ContainElement(container2))
 		})
+
+		Describe("ListPage", func() {
+			It("filters the containers by state", func() {
+				page := containerStore.ListPage(logger, executor.ContainerListOptions{
+					States: []executor.State{executor.StateReserved},
+				})
+				Expect(page.Containers).To(Equal([]executor.Container{container2}))
+				Expect(page.NextCursor).To(BeEmpty())
+			})
+
+			It("pages through the containers in guid order", func() {
+				page := containerStore.ListPage(logger, executor.ContainerListOptions{Limit: 1})
+				Expect(page.Containers).To(Equal([]executor.Container{container1}))
+				Expect(page.NextCursor).To(Equal(containerGuid))
+
+				page = containerStore.ListPage(logger, executor.ContainerListOptions{Limit: 1, After: page.NextCursor})
+				Expect(page.Containers).To(Equal([]executor.Container{container2}))
+				Expect(page.NextCursor).To(BeEmpty())
+			})
+		})
 	})
 
 	reserveContainer := func(guid string) {
@@ -1852,6 +2551,43 @@ var _ = Describe("Container Store", func() {
 		})
 	})
 
+	Describe("Metrics on a cgroup v2 host", func() {
+		BeforeEach(func() {
+			containerConfig.CgroupMode = containerstore.CgroupModeV2
+			containerStore = newContainerStore()
+
+			reserveContainer(containerGuid)
+			initializeContainer(containerGuid)
+
+			gardenContainer.InfoReturns(garden.ContainerInfo{ExternalIP: "6.6.6.6"}, nil)
+			gardenClient.CreateReturns(gardenContainer, nil)
+			_, err := containerStore.Create(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+
+			gardenClient.BulkMetricsReturns(map[string]garden.ContainerMetricsEntry{
+				containerGuid: garden.ContainerMetricsEntry{
+					Metrics: garden.Metrics{
+						MemoryStat: garden.ContainerMemoryStat{
+							ActiveAnon:            100,
+							InactiveAnon:          20,
+							ActiveFile:            3,
+							InactiveFile:          4000,
+							TotalUsageTowardLimit: 0,
+						},
+					},
+				},
+			}, nil)
+		})
+
+		It("computes the memory usage from the anonymous and active file pages", func() {
+			metrics, err := containerStore.Metrics(logger)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(metrics).To(HaveKey(containerGuid))
+			Expect(metrics[containerGuid].MemoryUsageInBytes).To(BeEquivalentTo(123))
+		})
+	})
+
 	Describe("GetFiles", func() {
 		BeforeEach(func() {
 			gardenClient.CreateReturns(gardenContainer, nil)
@@ -1873,7 +2609,7 @@ var _ = Describe("Container Store", func() {
 			})
 
 			It("calls streamout on the garden client", func() {
-				stream, err := containerStore.GetFiles(logger, containerGuid, "/path/to/file")
+				stream, err := containerStore.GetFiles(context.Background(), logger, containerGuid, "/path/to/file")
 				Expect(err).NotTo(HaveOccurred())
 
 				Expect(gardenContainer.StreamOutCallCount()).To(Equal(1))
@@ -1886,18 +2622,375 @@ var _ = Describe("Container Store", func() {
 				Expect(err).NotTo(HaveOccurred())
 				Expect(output).To(Equal([]byte("this is the stream")))
 			})
+
+			Context("when stream limits are configured", func() {
+				BeforeEach(func() {
+					containerConfig.GetFilesLimits = containerstore.StreamLimits{
+						MaxBytes:    4,
+						IdleTimeout: time.Minute,
+						Deadline:    10 * time.Minute,
+					}
+					containerStore = newContainerStore()
+				})
+
+				It("fails the stream once it exceeds the maximum number of bytes", func() {
+					stream, err := containerStore.GetFiles(context.Background(), logger, containerGuid, "/path/to/file")
+					Expect(err).NotTo(HaveOccurred())
+
+					output, err := ioutil.ReadAll(stream)
+					Expect(err).To(Equal(executor.ErrFilesMaxBytesExceeded))
+					Expect(output).To(Equal([]byte("this")))
+				})
+
+				Context("when the container stops sending data", func() {
+					var streamWriter *io.PipeWriter
+
+					BeforeEach(func() {
+						var streamReader *io.PipeReader
+						streamReader, streamWriter = io.Pipe()
+						gardenContainer.StreamOutReturns(streamReader, nil)
+					})
+
+					AfterEach(func() {
+						streamWriter.Close()
+					})
+
+					It("closes the stream after the idle timeout", func() {
+						stream, err := containerStore.GetFiles(context.Background(), logger, containerGuid, "/path/to/file")
+						Expect(err).NotTo(HaveOccurred())
+
+						errCh := make(chan error, 1)
+						go func() {
+							_, err := stream.Read(make([]byte, 10))
+							errCh <- err
+						}()
+
+						Consistently(errCh).ShouldNot(Receive())
+						clock.WaitForWatcherAndIncrement(time.Minute)
+						Eventually(errCh).Should(Receive(Equal(executor.ErrFilesIdleTimeout)))
+					})
+				})
+			})
+
+			Context("when several paths and glob patterns are requested", func() {
+				tarStream := func(names ...string) io.ReadCloser {
+					buffer := &bytes.Buffer{}
+					tarWriter := tar.NewWriter(buffer)
+					for _, name := range names {
+						Expect(tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(name))})).To(Succeed())
+						_, err := tarWriter.Write([]byte(name))
+						Expect(err).NotTo(HaveOccurred())
+					}
+					Expect(tarWriter.Close()).To(Succeed())
+					return ioutil.NopCloser(buffer)
+				}
+
+				BeforeEach(func() {
+					gardenContainer.StreamOutStub = func(spec garden.StreamOutSpec) (io.ReadCloser, error) {
+						switch spec.Path {
+						case "/home/vcap/app/crash.dump":
+							return tarStream("crash.dump"), nil
+						case "/var/log":
+							return tarStream("log/app.log", "log/app.err", "log/other.log"), nil
+						default:
+							return nil, errors.New("no such file")
+						}
+					}
+				})
+
+				It("returns a single tar stream of the matching files and a manifest", func() {
+					stream, err := containerStore.GetFiles(context.Background(), logger, containerGuid, "/home/vcap/app/crash.dump", "/var/log/*.log", "/missing")
+					Expect(err).NotTo(HaveOccurred())
+					defer stream.Close()
+
+					Expect(gardenContainer.StreamOutCallCount()).To(Equal(3))
+					Expect(gardenContainer.StreamOutArgsForCall(1).Path).To(Equal("/var/log"))
+
+					contents := map[string]string{}
+					tarReader := tar.NewReader(stream)
+					for {
+						header, err := tarReader.Next()
+						if err == io.EOF {
+							break
+						}
+						Expect(err).NotTo(HaveOccurred())
+						body, err := ioutil.ReadAll(tarReader)
+						Expect(err).NotTo(HaveOccurred())
+						contents[header.Name] = string(body)
+					}
+
+					Expect(contents).To(HaveLen(4))
+					Expect(contents).To(HaveKeyWithValue("home/vcap/app/crash.dump", "crash.dump"))
+					Expect(contents).To(HaveKeyWithValue("var/log/app.log", "log/app.log"))
+					Expect(contents).To(HaveKeyWithValue("var/log/other.log", "log/other.log"))
+
+					var manifest executor.FilesManifest
+					Expect(json.Unmarshal([]byte(contents[executor.FilesManifestName]), &manifest)).To(Succeed())
+					Expect(manifest.Matched).To(ConsistOf("/home/vcap/app/crash.dump", "/var/log/app.log", "/var/log/other.log"))
+					Expect(manifest.Unmatched).To(ConsistOf("/missing"))
+				})
+			})
 		})
 
 		Context("when the container does not have a corresponding garden container", func() {
 			It("returns an error", func() {
-				_, err := containerStore.GetFiles(logger, containerGuid, "/path")
+				_, err := containerStore.GetFiles(context.Background(), logger, containerGuid, "/path")
 				Expect(err).To(Equal(executor.ErrContainerNotFound))
 			})
 		})
 
 		Context("when the container does not exist", func() {
 			It("returns ErrContainerNotFound", func() {
-				_, err := containerStore.GetFiles(logger, "", "/stuff")
+				_, err := containerStore.GetFiles(context.Background(), logger, "", "/stuff")
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
+	Describe("PutFiles", func() {
+		var tarStream io.Reader
+
+		BeforeEach(func() {
+			gardenClient.CreateReturns(gardenContainer, nil)
+			tarStream = bytes.NewReader([]byte("this is the stream"))
+		})
+
+		JustBeforeEach(func() {
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		Context("when the container has a corresponding garden container", func() {
+			JustBeforeEach(func() {
+				err := containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+				Expect(err).NotTo(HaveOccurred())
+
+				_, err = containerStore.Create(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("calls streamin on the garden container", func() {
+				err := containerStore.PutFiles(context.Background(), logger, containerGuid, "/path/to/dir", tarStream)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(gardenContainer.StreamInCallCount()).To(Equal(1))
+				streamInSpec := gardenContainer.StreamInArgsForCall(0)
+				Expect(streamInSpec.Path).To(Equal("/path/to/dir"))
+				Expect(streamInSpec.User).To(Equal("root"))
+				Expect(ioutil.ReadAll(streamInSpec.TarStream)).To(Equal([]byte("this is the stream")))
+			})
+
+			Context("when the context is cancelled", func() {
+				BeforeEach(func() {
+					gardenContainer.StreamInStub = func(spec garden.StreamInSpec) error {
+						_, err := ioutil.ReadAll(spec.TarStream)
+						return err
+					}
+				})
+
+				It("stops streaming and returns the context's error", func() {
+					ctx, cancel := context.WithCancel(context.Background())
+					cancel()
+
+					err := containerStore.PutFiles(ctx, logger, containerGuid, "/path/to/dir", tarStream)
+					Expect(err).To(Equal(context.Canceled))
+				})
+			})
+
+			Context("when streaming in fails", func() {
+				BeforeEach(func() {
+					gardenContainer.StreamInReturns(errors.New("no space left"))
+				})
+
+				It("returns the error", func() {
+					err := containerStore.PutFiles(context.Background(), logger, containerGuid, "/path/to/dir", tarStream)
+					Expect(err).To(MatchError("no space left"))
+				})
+			})
+
+			It("requires a destination path", func() {
+				err := containerStore.PutFiles(context.Background(), logger, containerGuid, "", tarStream)
+				Expect(err).To(Equal(executor.ErrNoDestinationPath))
+				Expect(gardenContainer.StreamInCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the container does not have a corresponding garden container", func() {
+			It("returns an error", func() {
+				err := containerStore.PutFiles(context.Background(), logger, containerGuid, "/path", tarStream)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns ErrContainerNotFound", func() {
+				err := containerStore.PutFiles(context.Background(), logger, "", "/path", tarStream)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
+	Describe("RunProcess", func() {
+		var (
+			process *gardenfakes.FakeProcess
+			spec    executor.ProcessSpec
+			stdout  *gbytes.Buffer
+		)
+
+		BeforeEach(func() {
+			process = &gardenfakes.FakeProcess{}
+			process.WaitReturns(3, nil)
+			gardenContainer.RunReturns(process, nil)
+			gardenClient.CreateReturns(gardenContainer, nil)
+
+			spec = executor.ProcessSpec{
+				Path: "/bin/ls",
+				Args: []string{"-al"},
+				Env:  []executor.EnvironmentVariable{{Name: "FOO", Value: "bar"}},
+				Dir:  "/home/vcap",
+			}
+			stdout = gbytes.NewBuffer()
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			err = containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+			_, err = containerStore.Create(logger, containerGuid)
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("runs the process in the garden container as root and returns its exit status", func() {
+			exitStatus, err := containerStore.RunProcess(context.Background(), logger, containerGuid, spec, executor.ProcessIO{Stdout: stdout})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(exitStatus).To(Equal(3))
+
+			Expect(gardenContainer.RunCallCount()).To(Equal(1))
+			processSpec, processIO := gardenContainer.RunArgsForCall(0)
+			Expect(processSpec).To(Equal(garden.ProcessSpec{
+				Path: "/bin/ls",
+				Args: []string{"-al"},
+				Env:  []string{"FOO=bar"},
+				Dir:  "/home/vcap",
+				User: "root",
+			}))
+			Expect(processIO.Stdout).To(Equal(stdout))
+		})
+
+		It("runs the process as the requested user", func() {
+			spec.User = "vcap"
+			_, err := containerStore.RunProcess(context.Background(), logger, containerGuid, spec, executor.ProcessIO{})
+			Expect(err).NotTo(HaveOccurred())
+
+			processSpec, _ := gardenContainer.RunArgsForCall(0)
+			Expect(processSpec.User).To(Equal("vcap"))
+		})
+
+		It("requires a path", func() {
+			spec.Path = ""
+			_, err := containerStore.RunProcess(context.Background(), logger, containerGuid, spec, executor.ProcessIO{})
+			Expect(err).To(Equal(executor.ErrProcessPathNotSpecified))
+			Expect(gardenContainer.RunCallCount()).To(Equal(0))
+		})
+
+		Context("when running the process fails", func() {
+			BeforeEach(func() {
+				gardenContainer.RunReturns(nil, errors.New("no such file"))
+			})
+
+			It("returns the error", func() {
+				_, err := containerStore.RunProcess(context.Background(), logger, containerGuid, spec, executor.ProcessIO{})
+				Expect(err).To(MatchError("no such file"))
+			})
+		})
+
+		Context("when the context is done before the process exits", func() {
+			var exited chan struct{}
+
+			BeforeEach(func() {
+				exited = make(chan struct{})
+				process.WaitStub = func() (int, error) {
+					<-exited
+					return 143, nil
+				}
+			})
+
+			AfterEach(func() {
+				close(exited)
+			})
+
+			It("terminates the process and returns the context's error", func() {
+				ctx, cancel := context.WithCancel(context.Background())
+
+				errs := make(chan error, 1)
+				go func() {
+					_, err := containerStore.RunProcess(ctx, logger, containerGuid, spec, executor.ProcessIO{})
+					errs <- err
+				}()
+
+				Consistently(errs).ShouldNot(Receive())
+				cancel()
+
+				Eventually(errs).Should(Receive(Equal(context.Canceled)))
+				Expect(process.SignalCallCount()).To(Equal(1))
+				Expect(process.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns ErrContainerNotFound", func() {
+				_, err := containerStore.RunProcess(context.Background(), logger, "missing-guid", spec, executor.ProcessIO{})
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
+	Describe("Attach", func() {
+		BeforeEach(func() {
+			gardenClient.CreateReturns(gardenContainer, nil)
+
+			_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: containerGuid})
+			Expect(err).NotTo(HaveOccurred())
+		})
+
+		It("returns an error before the container is created", func() {
+			_, err := containerStore.Attach(logger, containerGuid)
+			Expect(err).To(Equal(executor.ErrContainerNotRunning))
+		})
+
+		Context("when the container is created", func() {
+			BeforeEach(func() {
+				err := containerStore.Initialize(logger, &executor.RunRequest{Guid: containerGuid})
+				Expect(err).NotTo(HaveOccurred())
+				_, err = containerStore.Create(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				megatron.StepsRunnerStub = func(_ lager.Logger, _ executor.Container, _ garden.Container, logStreamer log_streamer.LogStreamer) (ifrit.Runner, error) {
+					return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
+						close(ready)
+						fmt.Fprint(logStreamer.Stdout(), "hello ")
+						fmt.Fprint(logStreamer.WithSource("other").Stderr(), "world")
+						return nil
+					}), nil
+				}
+			})
+
+			It("streams the output of the container's steps to every reader until it completes", func() {
+				reader1, err := containerStore.Attach(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+				reader2, err := containerStore.Attach(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				err = containerStore.Run(logger, containerGuid)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(ioutil.ReadAll(reader1)).To(Equal([]byte("hello world")))
+				Expect(ioutil.ReadAll(reader2)).To(Equal([]byte("hello world")))
+			})
+		})
+
+		Context("when the container does not exist", func() {
+			It("returns ErrContainerNotFound", func() {
+				_, err := containerStore.Attach(logger, "missing-guid")
 				Expect(err).To(Equal(executor.ErrContainerNotFound))
 			})
 		})
@@ -1967,12 +3060,56 @@ var _ = Describe("Container Store", func() {
 					return container.State
 				}).Should(Equal(executor.StateCompleted))
 
+				container, err := containerStore.Get(logger, "forever-reserved")
+				Expect(err).NotTo(HaveOccurred())
+				Expect(container.RunResult.FailureReason).To(Equal(containerstore.ContainerExpirationMessage))
+
 				Consistently(func() executor.State {
 					container, err := containerStore.Get(logger, "eventually-initialized")
 					Expect(err).NotTo(HaveOccurred())
 					return container.State
 				}).ShouldNot(Equal(executor.StateCompleted))
 			})
+
+			It("frees the resources of the reclaimed reservations", func() {
+				expectedResources := totalCapacity.Copy()
+				expectedResources.Subtract(&resource)
+				Eventually(func() executor.ExecutorResources {
+					return containerStore.RemainingResources(logger)
+				}).Should(Equal(expectedResources))
+			})
+
+			It("does not free the resources again when the reclaimed reservations are deleted", func() {
+				expectedResources := totalCapacity.Copy()
+				expectedResources.Subtract(&resource)
+				Eventually(func() executor.ExecutorResources {
+					return containerStore.RemainingResources(logger)
+				}).Should(Equal(expectedResources))
+
+				Expect(containerStore.Destroy(logger, "forever-reserved")).To(Succeed())
+				Expect(containerStore.RemainingResources(logger)).To(Equal(expectedResources))
+			})
+
+			It("emits a completed event followed by an allocation expired event for the reclaimed reservations", func() {
+				eventTypes := func() []executor.EventType {
+					eventTypes := []executor.EventType{}
+					for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+						eventTypes = append(eventTypes, eventEmitter.EmitArgsForCall(i).EventType())
+					}
+					return eventTypes
+				}
+				Eventually(eventTypes).Should(ContainElement(executor.EventTypeAllocationExpired))
+
+				types := eventTypes()
+				Expect(indexOfEventType(types, executor.EventTypeContainerComplete)).To(Equal(indexOfEventType(types, executor.EventTypeAllocationExpired) - 1))
+
+				for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+					if event, ok := eventEmitter.EmitArgsForCall(i).(executor.AllocationExpiredEvent); ok {
+						Expect(event.Container().Guid).To(Equal("forever-reserved"))
+						Expect(event.Container().State).To(Equal(executor.StateCompleted))
+					}
+				}
+			})
 		})
 	})
 
@@ -2078,8 +3215,8 @@ var _ = Describe("Container Store", func() {
 			events = append(events, eventEmitter.EmitArgsForCall(initialEmitCallCount))
 			events = append(events, eventEmitter.EmitArgsForCall(initialEmitCallCount+1))
 
-			Expect(events).To(ContainElement(executor.ContainerCompleteEvent{RawContainer: container4}))
-			Expect(events).To(ContainElement(executor.ContainerCompleteEvent{RawContainer: container5}))
+			Expect(events).To(ContainElement(executor.NewContainerCompleteEvent(container4)))
+			Expect(events).To(ContainElement(executor.NewContainerCompleteEvent(container5)))
 
 			Expect(gardenClient.ContainersCallCount()).To(Equal(2))
 
@@ -2093,6 +3230,66 @@ var _ = Describe("Container Store", func() {
 			Eventually(gardenClient.ContainersCallCount).Should(Equal(4))
 		})
 
+		Context("when completed containers are retained for a limited time", func() {
+			var completedGuid string
+
+			BeforeEach(func() {
+				containerConfig.CompletedRetention = time.Minute
+				containerStore = newContainerStore()
+
+				completedGuid = "completed-guid"
+				_, err := containerStore.Reserve(logger, &executor.AllocationRequest{Guid: completedGuid})
+				Expect(err).NotTo(HaveOccurred())
+				err = containerStore.Stop(logger, completedGuid)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("destroys completed containers once the retention has passed", func() {
+				clock.WaitForWatcherAndIncrement(30 * time.Millisecond)
+
+				Consistently(func() error {
+					_, err := containerStore.Get(logger, completedGuid)
+					return err
+				}).ShouldNot(HaveOccurred())
+
+				clock.WaitForWatcherAndIncrement(time.Minute)
+
+				Eventually(func() error {
+					_, err := containerStore.Get(logger, completedGuid)
+					return err
+				}).Should(Equal(executor.ErrContainerNotFound))
+
+				Eventually(func() []executor.EventType {
+					var eventTypes []executor.EventType
+					for i := 0; i < eventEmitter.EmitCallCount(); i++ {
+						eventTypes = append(eventTypes, eventEmitter.EmitArgsForCall(i).EventType())
+					}
+					return eventTypes
+				}).Should(ContainElement(executor.EventTypeContainerDestroyed))
+			})
+		})
+
+		It("destroys garden containers that it is not tracking", func() {
+			clock.WaitForWatcherAndIncrement(30 * time.Millisecond)
+
+			Eventually(gardenClient.DestroyCallCount).Should(Equal(1))
+			Expect(gardenClient.DestroyArgsForCall(0)).To(Equal("foobar"))
+		})
+
+		Context("when orphaned containers are kept", func() {
+			BeforeEach(func() {
+				containerConfig.OrphanPolicy = containerstore.OrphanPolicyKeep
+				containerStore = newContainerStore()
+			})
+
+			It("logs them instead of destroying them", func() {
+				clock.WaitForWatcherAndIncrement(30 * time.Millisecond)
+
+				Eventually(logger).Should(gbytes.Say("keeping-orphaned-container"))
+				Consistently(gardenClient.DestroyCallCount).Should(Equal(0))
+			})
+		})
+
 		Context("when listing containers in garden fails", func() {
 			BeforeEach(func() {
 				gardenClient.ContainersReturns([]garden.Container{}, errors.New("failed-to-list"))
@@ -2120,3 +3317,21 @@ var _ = Describe("Container Store", func() {
 		})
 	})
 })
+
+type healthCheckOutputRunner struct {
+	ifrit.Runner
+	output string
+}
+
+func (r healthCheckOutputRunner) HealthCheckOutput() string {
+	return r.output
+}
+
+func indexOfEventType(eventTypes []executor.EventType, eventType executor.EventType) int {
+	for i, t := range eventTypes {
+		if t == eventType {
+			return i
+		}
+	}
+	return -1
+}
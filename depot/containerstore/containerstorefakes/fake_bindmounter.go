@@ -11,10 +11,11 @@ import (
 )
 
 type FakeDependencyManager struct {
-	DownloadCachedDependenciesStub        func(logger lager.Logger, mounts []executor.CachedDependency, logStreamer log_streamer.LogStreamer) (containerstore.BindMounts, error)
+	DownloadCachedDependenciesStub        func(logger lager.Logger, guid string, mounts []executor.CachedDependency, logStreamer log_streamer.LogStreamer) (containerstore.BindMounts, error)
 	downloadCachedDependenciesMutex       sync.RWMutex
 	downloadCachedDependenciesArgsForCall []struct {
 		logger      lager.Logger
+		guid        string
 		mounts      []executor.CachedDependency
 		logStreamer log_streamer.LogStreamer
 	}
@@ -40,7 +41,7 @@ type FakeDependencyManager struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeDependencyManager) DownloadCachedDependencies(logger lager.Logger, mounts []executor.CachedDependency, logStreamer log_streamer.LogStreamer) (containerstore.BindMounts, error) {
+func (fake *FakeDependencyManager) DownloadCachedDependencies(logger lager.Logger, guid string, mounts []executor.CachedDependency, logStreamer log_streamer.LogStreamer) (containerstore.BindMounts, error) {
 	var mountsCopy []executor.CachedDependency
 	if mounts != nil {
 		mountsCopy = make([]executor.CachedDependency, len(mounts))
@@ -49,13 +50,14 @@ func (fake *FakeDependencyManager) DownloadCachedDependencies(logger lager.Logge
 	fake.downloadCachedDependenciesMutex.Lock()
 	fake.downloadCachedDependenciesArgsForCall = append(fake.downloadCachedDependenciesArgsForCall, struct {
 		logger      lager.Logger
+		guid        string
 		mounts      []executor.CachedDependency
 		logStreamer log_streamer.LogStreamer
-	}{logger, mountsCopy, logStreamer})
-	fake.recordInvocation("DownloadCachedDependencies", []interface{}{logger, mountsCopy, logStreamer})
+	}{logger, guid, mountsCopy, logStreamer})
+	fake.recordInvocation("DownloadCachedDependencies", []interface{}{logger, guid, mountsCopy, logStreamer})
 	fake.downloadCachedDependenciesMutex.Unlock()
 	if fake.DownloadCachedDependenciesStub != nil {
-		return fake.DownloadCachedDependenciesStub(logger, mounts, logStreamer)
+		return fake.DownloadCachedDependenciesStub(logger, guid, mounts, logStreamer)
 	} else {
 		return fake.downloadCachedDependenciesReturns.result1, fake.downloadCachedDependenciesReturns.result2
 	}
@@ -67,10 +69,10 @@ func (fake *FakeDependencyManager) DownloadCachedDependenciesCallCount() int {
 	return len(fake.downloadCachedDependenciesArgsForCall)
 }
 
-func (fake *FakeDependencyManager) DownloadCachedDependenciesArgsForCall(i int) (lager.Logger, []executor.CachedDependency, log_streamer.LogStreamer) {
+func (fake *FakeDependencyManager) DownloadCachedDependenciesArgsForCall(i int) (lager.Logger, string, []executor.CachedDependency, log_streamer.LogStreamer) {
 	fake.downloadCachedDependenciesMutex.RLock()
 	defer fake.downloadCachedDependenciesMutex.RUnlock()
-	return fake.downloadCachedDependenciesArgsForCall[i].logger, fake.downloadCachedDependenciesArgsForCall[i].mounts, fake.downloadCachedDependenciesArgsForCall[i].logStreamer
+	return fake.downloadCachedDependenciesArgsForCall[i].logger, fake.downloadCachedDependenciesArgsForCall[i].guid, fake.downloadCachedDependenciesArgsForCall[i].mounts, fake.downloadCachedDependenciesArgsForCall[i].logStreamer
 }
 
 func (fake *FakeDependencyManager) DownloadCachedDependenciesReturns(result1 containerstore.BindMounts, result2 error) {
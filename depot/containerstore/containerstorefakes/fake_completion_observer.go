@@ -0,0 +1,68 @@
+// This file was generated by counterfeiter
+package containerstorefakes
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/containerstore"
+	"code.cloudfoundry.org/lager"
+)
+
+type FakeCompletionObserver struct {
+	ContainerCompletedStub        func(lager.Logger, executor.Container)
+	containerCompletedMutex       sync.RWMutex
+	containerCompletedArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 executor.Container
+	}
+	invocations      map[string][][]interface{}
+	invocationsMutex sync.RWMutex
+}
+
+func (fake *FakeCompletionObserver) ContainerCompleted(arg1 lager.Logger, arg2 executor.Container) {
+	fake.containerCompletedMutex.Lock()
+	fake.containerCompletedArgsForCall = append(fake.containerCompletedArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 executor.Container
+	}{arg1, arg2})
+	fake.recordInvocation("ContainerCompleted", []interface{}{arg1, arg2})
+	fake.containerCompletedMutex.Unlock()
+	if fake.ContainerCompletedStub != nil {
+		fake.ContainerCompletedStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeCompletionObserver) ContainerCompletedCallCount() int {
+	fake.containerCompletedMutex.RLock()
+	defer fake.containerCompletedMutex.RUnlock()
+	return len(fake.containerCompletedArgsForCall)
+}
+
+func (fake *FakeCompletionObserver) ContainerCompletedArgsForCall(i int) (lager.Logger, executor.Container) {
+	fake.containerCompletedMutex.RLock()
+	defer fake.containerCompletedMutex.RUnlock()
+	return fake.containerCompletedArgsForCall[i].arg1, fake.containerCompletedArgsForCall[i].arg2
+}
+
+func (fake *FakeCompletionObserver) Invocations() map[string][][]interface{} {
+	fake.invocationsMutex.RLock()
+	defer fake.invocationsMutex.RUnlock()
+	fake.containerCompletedMutex.RLock()
+	defer fake.containerCompletedMutex.RUnlock()
+	return fake.invocations
+}
+
+func (fake *FakeCompletionObserver) recordInvocation(key string, args []interface{}) {
+	fake.invocationsMutex.Lock()
+	defer fake.invocationsMutex.Unlock()
+	if fake.invocations == nil {
+		fake.invocations = map[string][][]interface{}{}
+	}
+	if fake.invocations[key] == nil {
+		fake.invocations[key] = [][]interface{}{}
+	}
+	fake.invocations[key] = append(fake.invocations[key], args)
+}
+
+var _ containerstore.CompletionObserver = new(FakeCompletionObserver)
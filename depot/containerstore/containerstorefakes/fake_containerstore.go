@@ -2,6 +2,7 @@
 package containerstorefakes
 
 import (
+	"context"
 	"io"
 	"sync"
 
@@ -103,12 +104,13 @@ type FakeContainerStore struct {
 	remainingResourcesReturns struct {
 		result1 executor.ExecutorResources
 	}
-	GetFilesStub        func(logger lager.Logger, guid, sourcePath string) (io.ReadCloser, error)
+	GetFilesStub        func(ctx context.Context, logger lager.Logger, guid string, sourcePaths ...string) (io.ReadCloser, error)
 	getFilesMutex       sync.RWMutex
 	getFilesArgsForCall []struct {
-		logger     lager.Logger
-		guid       string
-		sourcePath string
+		ctx         context.Context
+		logger      lager.Logger
+		guid        string
+		sourcePaths []string
 	}
 	getFilesReturns struct {
 		result1 io.ReadCloser
@@ -135,6 +137,68 @@ type FakeContainerStore struct {
 	cleanupArgsForCall []struct {
 		logger lager.Logger
 	}
+	PutFilesStub        func(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error
+	putFilesMutex       sync.RWMutex
+	putFilesArgsForCall []struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		destPath  string
+		tarStream io.Reader
+	}
+	putFilesReturns struct {
+		result1 error
+	}
+	RunProcessStub        func(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error)
+	runProcessMutex       sync.RWMutex
+	runProcessArgsForCall []struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		spec      executor.ProcessSpec
+		processIO executor.ProcessIO
+	}
+	runProcessReturns struct {
+		result1 int
+		result2 error
+	}
+	AttachStub        func(logger lager.Logger, guid string) (io.ReadCloser, error)
+	attachMutex       sync.RWMutex
+	attachArgsForCall []struct {
+		logger lager.Logger
+		guid   string
+	}
+	attachReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	EvacuateStub        func(logger lager.Logger, guid string) error
+	evacuateMutex       sync.RWMutex
+	evacuateArgsForCall []struct {
+		logger lager.Logger
+		guid   string
+	}
+	evacuateReturns struct {
+		result1 error
+	}
+	ReserveAllStub        func(logger lager.Logger, reqs []*executor.AllocationRequest) map[string]error
+	reserveAllMutex       sync.RWMutex
+	reserveAllArgsForCall []struct {
+		logger lager.Logger
+		reqs   []*executor.AllocationRequest
+	}
+	reserveAllReturns struct {
+		result1 map[string]error
+	}
+	ListPageStub        func(logger lager.Logger, opts executor.ContainerListOptions) executor.ContainerPage
+	listPageMutex       sync.RWMutex
+	listPageArgsForCall []struct {
+		logger lager.Logger
+		opts   executor.ContainerListOptions
+	}
+	listPageReturns struct {
+		result1 executor.ContainerPage
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -480,17 +544,18 @@ func (fake *FakeContainerStore) RemainingResourcesReturns(result1 executor.Execu
 	}{result1}
 }
 
-func (fake *FakeContainerStore) GetFiles(logger lager.Logger, guid string, sourcePath string) (io.ReadCloser, error) {
+func (fake *FakeContainerStore) GetFiles(ctx context.Context, logger lager.Logger, guid string, sourcePaths ...string) (io.ReadCloser, error) {
 	fake.getFilesMutex.Lock()
 	fake.getFilesArgsForCall = append(fake.getFilesArgsForCall, struct {
-		logger     lager.Logger
-		guid       string
-		sourcePath string
-	}{logger, guid, sourcePath})
-	fake.recordInvocation("GetFiles", []interface{}{logger, guid, sourcePath})
+		ctx         context.Context
+		logger      lager.Logger
+		guid        string
+		sourcePaths []string
+	}{ctx, logger, guid, sourcePaths})
+	fake.recordInvocation("GetFiles", []interface{}{ctx, logger, guid, sourcePaths})
 	fake.getFilesMutex.Unlock()
 	if fake.GetFilesStub != nil {
-		return fake.GetFilesStub(logger, guid, sourcePath)
+		return fake.GetFilesStub(ctx, logger, guid, sourcePaths...)
 	} else {
 		return fake.getFilesReturns.result1, fake.getFilesReturns.result2
 	}
@@ -502,10 +567,10 @@ func (fake *FakeContainerStore) GetFilesCallCount() int {
 	return len(fake.getFilesArgsForCall)
 }
 
-func (fake *FakeContainerStore) GetFilesArgsForCall(i int) (lager.Logger, string, string) {
+func (fake *FakeContainerStore) GetFilesArgsForCall(i int) (context.Context, lager.Logger, string, []string) {
 	fake.getFilesMutex.RLock()
 	defer fake.getFilesMutex.RUnlock()
-	return fake.getFilesArgsForCall[i].logger, fake.getFilesArgsForCall[i].guid, fake.getFilesArgsForCall[i].sourcePath
+	return fake.getFilesArgsForCall[i].ctx, fake.getFilesArgsForCall[i].logger, fake.getFilesArgsForCall[i].guid, fake.getFilesArgsForCall[i].sourcePaths
 }
 
 func (fake *FakeContainerStore) GetFilesReturns(result1 io.ReadCloser, result2 error) {
@@ -606,6 +671,223 @@ func (fake *FakeContainerStore) CleanupArgsForCall(i int) lager.Logger {
 	return fake.cleanupArgsForCall[i].logger
 }
 
+func (fake *FakeContainerStore) PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error {
+	fake.putFilesMutex.Lock()
+	fake.putFilesArgsForCall = append(fake.putFilesArgsForCall, struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		destPath  string
+		tarStream io.Reader
+	}{ctx, logger, guid, destPath, tarStream})
+	fake.recordInvocation("PutFiles", []interface{}{ctx, logger, guid, destPath, tarStream})
+	fake.putFilesMutex.Unlock()
+	if fake.PutFilesStub != nil {
+		return fake.PutFilesStub(ctx, logger, guid, destPath, tarStream)
+	} else {
+		return fake.putFilesReturns.result1
+	}
+}
+
+func (fake *FakeContainerStore) PutFilesCallCount() int {
+	fake.putFilesMutex.RLock()
+	defer fake.putFilesMutex.RUnlock()
+	return len(fake.putFilesArgsForCall)
+}
+
+func (fake *FakeContainerStore) PutFilesArgsForCall(i int) (context.Context, lager.Logger, string, string, io.Reader) {
+	fake.putFilesMutex.RLock()
+	defer fake.putFilesMutex.RUnlock()
+	return fake.putFilesArgsForCall[i].ctx, fake.putFilesArgsForCall[i].logger, fake.putFilesArgsForCall[i].guid, fake.putFilesArgsForCall[i].destPath, fake.putFilesArgsForCall[i].tarStream
+}
+
+func (fake *FakeContainerStore) PutFilesReturns(result1 error) {
+	fake.PutFilesStub = nil
+	fake.putFilesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) RunProcess(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error) {
+	fake.runProcessMutex.Lock()
+	fake.runProcessArgsForCall = append(fake.runProcessArgsForCall, struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		spec      executor.ProcessSpec
+		processIO executor.ProcessIO
+	}{ctx, logger, guid, spec, processIO})
+	fake.recordInvocation("RunProcess", []interface{}{ctx, logger, guid, spec, processIO})
+	fake.runProcessMutex.Unlock()
+	if fake.RunProcessStub != nil {
+		return fake.RunProcessStub(ctx, logger, guid, spec, processIO)
+	} else {
+		return fake.runProcessReturns.result1, fake.runProcessReturns.result2
+	}
+}
+
+func (fake *FakeContainerStore) RunProcessCallCount() int {
+	fake.runProcessMutex.RLock()
+	defer fake.runProcessMutex.RUnlock()
+	return len(fake.runProcessArgsForCall)
+}
+
+func (fake *FakeContainerStore) RunProcessArgsForCall(i int) (context.Context, lager.Logger, string, executor.ProcessSpec, executor.ProcessIO) {
+	fake.runProcessMutex.RLock()
+	defer fake.runProcessMutex.RUnlock()
+	return fake.runProcessArgsForCall[i].ctx, fake.runProcessArgsForCall[i].logger, fake.runProcessArgsForCall[i].guid, fake.runProcessArgsForCall[i].spec, fake.runProcessArgsForCall[i].processIO
+}
+
+func (fake *FakeContainerStore) RunProcessReturns(result1 int, result2 error) {
+	fake.RunProcessStub = nil
+	fake.runProcessReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainerStore) Attach(logger lager.Logger, guid string) (io.ReadCloser, error) {
+	fake.attachMutex.Lock()
+	fake.attachArgsForCall = append(fake.attachArgsForCall, struct {
+		logger lager.Logger
+		guid   string
+	}{logger, guid})
+	fake.recordInvocation("Attach", []interface{}{logger, guid})
+	fake.attachMutex.Unlock()
+	if fake.AttachStub != nil {
+		return fake.AttachStub(logger, guid)
+	} else {
+		return fake.attachReturns.result1, fake.attachReturns.result2
+	}
+}
+
+func (fake *FakeContainerStore) AttachCallCount() int {
+	fake.attachMutex.RLock()
+	defer fake.attachMutex.RUnlock()
+	return len(fake.attachArgsForCall)
+}
+
+func (fake *FakeContainerStore) AttachArgsForCall(i int) (lager.Logger, string) {
+	fake.attachMutex.RLock()
+	defer fake.attachMutex.RUnlock()
+	return fake.attachArgsForCall[i].logger, fake.attachArgsForCall[i].guid
+}
+
+func (fake *FakeContainerStore) AttachReturns(result1 io.ReadCloser, result2 error) {
+	fake.AttachStub = nil
+	fake.attachReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeContainerStore) Evacuate(logger lager.Logger, guid string) error {
+	fake.evacuateMutex.Lock()
+	fake.evacuateArgsForCall = append(fake.evacuateArgsForCall, struct {
+		logger lager.Logger
+		guid   string
+	}{logger, guid})
+	fake.recordInvocation("Evacuate", []interface{}{logger, guid})
+	fake.evacuateMutex.Unlock()
+	if fake.EvacuateStub != nil {
+		return fake.EvacuateStub(logger, guid)
+	} else {
+		return fake.evacuateReturns.result1
+	}
+}
+
+func (fake *FakeContainerStore) EvacuateCallCount() int {
+	fake.evacuateMutex.RLock()
+	defer fake.evacuateMutex.RUnlock()
+	return len(fake.evacuateArgsForCall)
+}
+
+func (fake *FakeContainerStore) EvacuateArgsForCall(i int) (lager.Logger, string) {
+	fake.evacuateMutex.RLock()
+	defer fake.evacuateMutex.RUnlock()
+	return fake.evacuateArgsForCall[i].logger, fake.evacuateArgsForCall[i].guid
+}
+
+func (fake *FakeContainerStore) EvacuateReturns(result1 error) {
+	fake.EvacuateStub = nil
+	fake.evacuateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) ReserveAll(logger lager.Logger, reqs []*executor.AllocationRequest) map[string]error {
+	var reqsCopy []*executor.AllocationRequest
+	if reqs != nil {
+		reqsCopy = make([]*executor.AllocationRequest, len(reqs))
+		copy(reqsCopy, reqs)
+	}
+	fake.reserveAllMutex.Lock()
+	fake.reserveAllArgsForCall = append(fake.reserveAllArgsForCall, struct {
+		logger lager.Logger
+		reqs   []*executor.AllocationRequest
+	}{logger, reqsCopy})
+	fake.recordInvocation("ReserveAll", []interface{}{logger, reqsCopy})
+	fake.reserveAllMutex.Unlock()
+	if fake.ReserveAllStub != nil {
+		return fake.ReserveAllStub(logger, reqs)
+	} else {
+		return fake.reserveAllReturns.result1
+	}
+}
+
+func (fake *FakeContainerStore) ReserveAllCallCount() int {
+	fake.reserveAllMutex.RLock()
+	defer fake.reserveAllMutex.RUnlock()
+	return len(fake.reserveAllArgsForCall)
+}
+
+func (fake *FakeContainerStore) ReserveAllArgsForCall(i int) (lager.Logger, []*executor.AllocationRequest) {
+	fake.reserveAllMutex.RLock()
+	defer fake.reserveAllMutex.RUnlock()
+	return fake.reserveAllArgsForCall[i].logger, fake.reserveAllArgsForCall[i].reqs
+}
+
+func (fake *FakeContainerStore) ReserveAllReturns(result1 map[string]error) {
+	fake.ReserveAllStub = nil
+	fake.reserveAllReturns = struct {
+		result1 map[string]error
+	}{result1}
+}
+
+func (fake *FakeContainerStore) ListPage(logger lager.Logger, opts executor.ContainerListOptions) executor.ContainerPage {
+	fake.listPageMutex.Lock()
+	fake.listPageArgsForCall = append(fake.listPageArgsForCall, struct {
+		logger lager.Logger
+		opts   executor.ContainerListOptions
+	}{logger, opts})
+	fake.recordInvocation("ListPage", []interface{}{logger, opts})
+	fake.listPageMutex.Unlock()
+	if fake.ListPageStub != nil {
+		return fake.ListPageStub(logger, opts)
+	} else {
+		return fake.listPageReturns.result1
+	}
+}
+
+func (fake *FakeContainerStore) ListPageCallCount() int {
+	fake.listPageMutex.RLock()
+	defer fake.listPageMutex.RUnlock()
+	return len(fake.listPageArgsForCall)
+}
+
+func (fake *FakeContainerStore) ListPageArgsForCall(i int) (lager.Logger, executor.ContainerListOptions) {
+	fake.listPageMutex.RLock()
+	defer fake.listPageMutex.RUnlock()
+	return fake.listPageArgsForCall[i].logger, fake.listPageArgsForCall[i].opts
+}
+
+func (fake *FakeContainerStore) ListPageReturns(result1 executor.ContainerPage) {
+	fake.ListPageStub = nil
+	fake.listPageReturns = struct {
+		result1 executor.ContainerPage
+	}{result1}
+}
+
 func (fake *FakeContainerStore) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -637,6 +919,18 @@ func (fake *FakeContainerStore) Invocations() map[string][][]interface{} {
 	defer fake.newContainerReaperMutex.RUnlock()
 	fake.cleanupMutex.RLock()
 	defer fake.cleanupMutex.RUnlock()
+	fake.putFilesMutex.RLock()
+	defer fake.putFilesMutex.RUnlock()
+	fake.runProcessMutex.RLock()
+	defer fake.runProcessMutex.RUnlock()
+	fake.attachMutex.RLock()
+	defer fake.attachMutex.RUnlock()
+	fake.evacuateMutex.RLock()
+	defer fake.evacuateMutex.RUnlock()
+	fake.reserveAllMutex.RLock()
+	defer fake.reserveAllMutex.RUnlock()
+	fake.listPageMutex.RLock()
+	defer fake.listPageMutex.RUnlock()
 	return fake.invocations
 }
 
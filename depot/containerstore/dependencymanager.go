@@ -8,6 +8,7 @@ import (
 	"code.cloudfoundry.org/bytefmt"
 	"code.cloudfoundry.org/cacheddownloader"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
@@ -16,17 +17,17 @@ import (
 //go:generate counterfeiter -o containerstorefakes/fake_bindmounter.go . DependencyManager
 
 type DependencyManager interface {
-	DownloadCachedDependencies(logger lager.Logger, mounts []executor.CachedDependency, logStreamer log_streamer.LogStreamer) (BindMounts, error)
+	DownloadCachedDependencies(logger lager.Logger, guid string, mounts []executor.CachedDependency, logStreamer log_streamer.LogStreamer) (BindMounts, error)
 	ReleaseCachedDependencies(logger lager.Logger, keys []BindMountCacheKey) error
 	Stop(logger lager.Logger)
 }
 
 type dependencyManager struct {
 	cache               cacheddownloader.CachedDownloader
-	downloadRateLimiter chan struct{}
+	downloadRateLimiter limiter.Limiter
 }
 
-func NewDependencyManager(cache cacheddownloader.CachedDownloader, downloadRateLimiter chan struct{}) DependencyManager {
+func NewDependencyManager(cache cacheddownloader.CachedDownloader, downloadRateLimiter limiter.Limiter) DependencyManager {
 	return &dependencyManager{cache, downloadRateLimiter}
 }
 
@@ -39,7 +40,11 @@ func (bm *dependencyManager) Stop(logger lager.Logger) {
 	}
 }
 
-func (bm *dependencyManager) DownloadCachedDependencies(logger lager.Logger, mounts []executor.CachedDependency, streamer log_streamer.LogStreamer) (BindMounts, error) {
+// DownloadCachedDependencies downloads the container's cached dependencies,
+// queueing for the download limiter as the container guid, so one
+// container's dependencies can not hold up every other container's
+// downloads.
+func (bm *dependencyManager) DownloadCachedDependencies(logger lager.Logger, guid string, mounts []executor.CachedDependency, streamer log_streamer.LogStreamer) (BindMounts, error) {
 	logger.Debug("downloading-cached-dependencies")
 	defer logger.Debug("downloading-cached-dependencies-complete")
 
@@ -56,13 +61,16 @@ func (bm *dependencyManager) DownloadCachedDependencies(logger lager.Logger, mou
 	for i := range mounts {
 		go func(mount *executor.CachedDependency) {
 			limiterStart := time.Now()
-			bm.downloadRateLimiter <- struct{}{}
+			err := bm.downloadRateLimiter.Acquire(logger, guid, nil)
+			if err != nil {
+				logger.Error("failed-to-acquire-limiter", err, lager.Data{"cache-key": mount.CacheKey})
+				errChan <- err
+				return
+			}
 			limiterTime := time.Now().Sub(limiterStart)
 			logger.Info("cached-dependency-rate-limiter", lager.Data{"cache-key": mount.CacheKey, "duration-ns": limiterTime})
 
-			defer func() {
-				<-bm.downloadRateLimiter
-			}()
+			defer bm.downloadRateLimiter.Release()
 
 			cachedMount, err := bm.downloadCachedDependency(logger, mount, streamer)
 			if err != nil {
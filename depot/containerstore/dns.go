@@ -0,0 +1,110 @@
+package containerstore
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	resolvConfPath = "/etc/resolv.conf"
+	hostsPath      = "/etc/hosts"
+)
+
+// writeDNSConfig replaces the container's resolv.conf with one using
+// dnsServers, and appends hostsEntries to the hosts file garden wrote for the
+// container.
+func writeDNSConfig(logger lager.Logger, gc garden.Container, dnsServers []string, hostsEntries []executor.HostsEntry) error {
+	logger = logger.Session("write-dns-config")
+
+	if len(dnsServers) > 0 {
+		resolvConf := &bytes.Buffer{}
+		for _, server := range dnsServers {
+			fmt.Fprintf(resolvConf, "nameserver %s\n", server)
+		}
+
+		err := writeFile(gc, resolvConfPath, resolvConf.Bytes())
+		if err != nil {
+			logger.Error("failed-to-write-resolv-conf", err)
+			return err
+		}
+	}
+
+	if len(hostsEntries) > 0 {
+		hosts, err := streamOutFile(gc, hostsPath)
+		if err != nil {
+			logger.Error("failed-to-read-hosts", err)
+			return err
+		}
+
+		buffer := bytes.NewBuffer(hosts)
+		if len(hosts) > 0 && !bytes.HasSuffix(hosts, []byte("\n")) {
+			buffer.WriteString("\n")
+		}
+		for _, entry := range hostsEntries {
+			fmt.Fprintf(buffer, "%s %s\n", entry.IP, strings.Join(entry.Hostnames, " "))
+		}
+
+		err = writeFile(gc, hostsPath, buffer.Bytes())
+		if err != nil {
+			logger.Error("failed-to-write-hosts", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// writeFile overwrites the file at filePath in the container with contents.
+// Garden bind mounts resolv.conf and hosts into the container, and extracting
+// a tar over them would have to replace the mount point, so the file is
+// truncated and written in place by a shell in the container instead.
+func writeFile(gc garden.Container, filePath string, contents []byte) error {
+	stderr := &bytes.Buffer{}
+	process, err := gc.Run(garden.ProcessSpec{
+		Path: "/bin/sh",
+		Args: []string{"-c", `cat > "$0"`, filePath},
+		User: "root",
+	}, garden.ProcessIO{
+		Stdin:  bytes.NewReader(contents),
+		Stderr: stderr,
+	})
+	if err != nil {
+		return err
+	}
+
+	exitStatus, err := process.Wait()
+	if err != nil {
+		return err
+	}
+	if exitStatus != 0 {
+		return fmt.Errorf("writing %s exited with status %d: %s", filePath, exitStatus, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+func streamOutFile(gc garden.Container, filePath string) ([]byte, error) {
+	stream, err := gc.StreamOut(garden.StreamOutSpec{Path: filePath, User: "root"})
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	tarReader := tar.NewReader(stream)
+	_, err = tarReader.Next()
+	if err == io.EOF {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(tarReader)
+}
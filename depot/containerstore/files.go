@@ -0,0 +1,144 @@
+package containerstore
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"path"
+	"strings"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+func hasGlobMeta(p string) bool {
+	return strings.ContainsAny(p, `*?[`)
+}
+
+// globRoot returns the deepest directory of pattern that contains no glob
+// metacharacters, which is what gets streamed out of the container.
+func globRoot(pattern string) string {
+	root := path.Clean(pattern)
+	for hasGlobMeta(root) {
+		root = path.Dir(root)
+	}
+	return root
+}
+
+// matchesGlob reports whether p, or a directory containing it, matches pattern.
+func matchesGlob(pattern, p string) bool {
+	for ; p != "/" && p != "."; p = path.Dir(p) {
+		if matched, _ := path.Match(pattern, p); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// streamOutCombined streams each of sourcePaths out of the container and
+// merges them into a single tar stream. Entries are named by their full path
+// inside the container (without the leading slash), and a FilesManifest is
+// appended as the final entry.
+func streamOutCombined(logger lager.Logger, gc garden.Container, sourcePaths []string) io.ReadCloser {
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(writeCombinedTar(logger, gc, sourcePaths, writer))
+	}()
+
+	return reader
+}
+
+func writeCombinedTar(logger lager.Logger, gc garden.Container, sourcePaths []string, w io.Writer) error {
+	logger = logger.Session("stream-out-combined")
+	tarWriter := tar.NewWriter(w)
+	manifest := executor.FilesManifest{Matched: []string{}, Unmatched: []string{}}
+
+	for _, sourcePath := range sourcePaths {
+		matched, err := copyMatchingEntries(logger, gc, sourcePath, tarWriter)
+		if err != nil {
+			logger.Error("failed-to-stream-out", err, lager.Data{"source-path": sourcePath})
+			return err
+		}
+
+		if len(matched) == 0 {
+			manifest.Unmatched = append(manifest.Unmatched, sourcePath)
+		}
+		manifest.Matched = append(manifest.Matched, matched...)
+	}
+
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.WriteHeader(&tar.Header{
+		Name:     executor.FilesManifestName,
+		Mode:     0644,
+		Size:     int64(len(manifestBytes)),
+		Typeflag: tar.TypeReg,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = tarWriter.Write(manifestBytes)
+	if err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
+
+func copyMatchingEntries(logger lager.Logger, gc garden.Container, sourcePath string, tarWriter *tar.Writer) ([]string, error) {
+	glob := hasGlobMeta(sourcePath)
+
+	root := path.Clean(sourcePath)
+	if glob {
+		root = globRoot(sourcePath)
+	}
+
+	stream, err := gc.StreamOut(garden.StreamOutSpec{Path: root, User: "root"})
+	if err != nil {
+		// a missing path is reported in the manifest rather than failing the
+		// whole request
+		logger.Info("skipping-source-path", lager.Data{"source-path": sourcePath, "error": err.Error()})
+		return nil, nil
+	}
+	defer stream.Close()
+
+	matched := []string{}
+	tarReader := tar.NewReader(stream)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return matched, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		containerPath := path.Join(path.Dir(root), header.Name)
+		if glob && !matchesGlob(sourcePath, containerPath) {
+			continue
+		}
+
+		header.Name = strings.TrimPrefix(containerPath, "/")
+		if header.Typeflag == tar.TypeDir {
+			header.Name += "/"
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = io.Copy(tarWriter, tarReader)
+		if err != nil {
+			return nil, err
+		}
+
+		matched = append(matched, containerPath)
+	}
+}
@@ -0,0 +1,57 @@
+package containerstore
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+)
+
+var ErrNoHelperAssets = errors.New("no helper assets for container stack or architecture")
+
+// HelperAssets describes the host directories holding helper binaries
+// (healthcheck, sshd, init, ...) that are bind mounted into every container.
+//
+// Dirs is keyed by "<stack>/<architecture>", "<stack>" or "<architecture>";
+// the most specific key matching the container wins. Containers that do not
+// declare an architecture are assumed to match DefaultArchitecture.
+type HelperAssets struct {
+	ContainerPath       string
+	DefaultArchitecture string
+	Dirs                map[string]string
+}
+
+func (h HelperAssets) enabled() bool {
+	return len(h.Dirs) > 0
+}
+
+func (h HelperAssets) dirFor(rootFSPath, architecture string) (string, error) {
+	if architecture == "" {
+		architecture = h.DefaultArchitecture
+	}
+
+	stack := stackFromRootFS(rootFSPath)
+
+	keys := []string{architecture}
+	if stack != "" {
+		keys = []string{stack + "/" + architecture, stack, architecture}
+	}
+
+	for _, key := range keys {
+		if dir, ok := h.Dirs[key]; ok {
+			return dir, nil
+		}
+	}
+
+	return "", ErrNoHelperAssets
+}
+
+// stackFromRootFS extracts the stack name from preloaded rootfs URIs such as
+// "preloaded:cflinuxfs3" or "preloaded+layer:cflinuxfs3?layer=...".
+func stackFromRootFS(rootFSPath string) string {
+	rootFSURL, err := url.Parse(rootFSPath)
+	if err != nil || !strings.HasPrefix(rootFSURL.Scheme, "preloaded") {
+		return ""
+	}
+
+	return rootFSURL.Opaque
+}
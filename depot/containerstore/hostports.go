@@ -0,0 +1,85 @@
+package containerstore
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/executor"
+)
+
+// hostPorts tracks the host ports that containers in the store asked for,
+// either explicitly or by allocation from a range, so that no two containers
+// are given the same port.
+type hostPorts struct {
+	lock  sync.Mutex
+	owner map[uint16]string
+}
+
+func newHostPorts() *hostPorts {
+	return &hostPorts{owner: map[uint16]string{}}
+}
+
+// Allocate returns ports with every mapping given a host port. Explicit host
+// ports are claimed as they are, the rest are allocated from hostPortRange.
+// Without a range they are left for garden to assign. Nothing is claimed when
+// any port is unavailable.
+func (h *hostPorts) Allocate(guid string, ports []executor.PortMapping, hostPortRange *executor.PortRange) ([]executor.PortMapping, error) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	allocated := make([]executor.PortMapping, len(ports))
+	claimed := map[uint16]bool{}
+
+	for i, port := range ports {
+		allocated[i] = port
+		if port.HostPort == 0 {
+			continue
+		}
+		if !h.available(guid, port.HostPort) || claimed[port.HostPort] {
+			return nil, executor.ErrHostPortsUnavailable
+		}
+		claimed[port.HostPort] = true
+	}
+
+	if hostPortRange != nil {
+		next := uint32(hostPortRange.Start)
+		for i := range allocated {
+			if allocated[i].HostPort != 0 {
+				continue
+			}
+			for ; next <= uint32(hostPortRange.End); next++ {
+				port := uint16(next)
+				if h.available(guid, port) && !claimed[port] {
+					break
+				}
+			}
+			if next > uint32(hostPortRange.End) {
+				return nil, executor.ErrHostPortsUnavailable
+			}
+			allocated[i].HostPort = uint16(next)
+			claimed[uint16(next)] = true
+		}
+	}
+
+	for port := range claimed {
+		h.owner[port] = guid
+	}
+
+	return allocated, nil
+}
+
+// Release frees the host ports claimed by guid.
+func (h *hostPorts) Release(guid string) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for port, owner := range h.owner {
+		if owner == guid {
+			delete(h.owner, port)
+		}
+	}
+}
+
+func (h *hostPorts) available(guid string, port uint16) bool {
+	owner, ok := h.owner[port]
+	return !ok || owner == guid
+}
@@ -12,6 +12,10 @@ type nodeMap struct {
 	nodes map[string]*storeNode
 	lock  *sync.RWMutex
 
+	// released holds the guids of the nodes whose resources have already
+	// been freed, so removing them does not free the resources again
+	released map[string]struct{}
+
 	remainingResources *executor.ExecutorResources
 }
 
@@ -20,6 +24,7 @@ func newNodeMap(totalCapacity *executor.ExecutorResources) *nodeMap {
 	return &nodeMap{
 		nodes:              make(map[string]*storeNode),
 		lock:               &sync.RWMutex{},
+		released:           make(map[string]struct{}),
 		remainingResources: &capacity,
 	}
 }
@@ -56,6 +61,42 @@ func (n *nodeMap) Add(node *storeNode) error {
 	return nil
 }
 
+// AddAll adds every node, or none of them when any cannot be added. The errors
+// are keyed by the guids of the nodes that could not be added.
+func (n *nodeMap) AddAll(nodes []*storeNode) map[string]error {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+
+	errs := map[string]error{}
+	remainingResources := n.remainingResources.Copy()
+	added := map[string]bool{}
+
+	for _, node := range nodes {
+		info := node.Info()
+		if _, ok := n.nodes[info.Guid]; ok || added[info.Guid] {
+			errs[info.Guid] = executor.ErrContainerGuidNotAvailable
+			continue
+		}
+		added[info.Guid] = true
+
+		ok := remainingResources.Subtract(&info.Resource)
+		if !ok {
+			errs[info.Guid] = executor.ErrInsufficientResourcesAvailable
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+
+	n.remainingResources = &remainingResources
+	for _, node := range nodes {
+		n.nodes[node.Info().Guid] = node
+	}
+
+	return nil
+}
+
 func (n *nodeMap) Remove(guid string) {
 	n.lock.Lock()
 	defer n.lock.Unlock()
@@ -70,7 +111,11 @@ func (n *nodeMap) Remove(guid string) {
 
 func (n *nodeMap) remove(node *storeNode) {
 	info := node.Info()
-	n.remainingResources.Add(&info.Resource)
+	if _, ok := n.released[info.Guid]; ok {
+		delete(n.released, info.Guid)
+	} else {
+		n.remainingResources.Add(&info.Resource)
+	}
 	delete(n.nodes, info.Guid)
 }
 
@@ -97,17 +142,27 @@ func (n *nodeMap) List() []*storeNode {
 	return list
 }
 
-func (n *nodeMap) CompleteExpired(logger lager.Logger, now time.Time) {
+// ReclaimExpired completes the reservations that have outlived the reserved
+// expiration time and frees their resources. The completed containers are
+// kept, with the reason they failed, until they are deleted. It returns the
+// reclaimed containers.
+func (n *nodeMap) ReclaimExpired(logger lager.Logger, now time.Time) []executor.Container {
 	n.lock.Lock()
 	defer n.lock.Unlock()
 
+	reclaimed := []executor.Container{}
 	for i := range n.nodes {
 		node := n.nodes[i]
 		expired := node.Expire(logger, now)
 		if expired {
-			logger.Info("expired-container", lager.Data{"guid": node.Info().Guid})
+			info := node.Info()
+			logger.Info("expired-container", lager.Data{"guid": info.Guid})
+			n.remainingResources.Add(&info.Resource)
+			n.released[info.Guid] = struct{}{}
+			reclaimed = append(reclaimed, info)
 		}
 	}
+	return reclaimed
 }
 
 func (n *nodeMap) CompleteMissing(logger lager.Logger, existingHandles map[string]struct{}) {
@@ -4,22 +4,26 @@ import (
 	"os"
 
 	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
 	"code.cloudfoundry.org/lager"
 )
 
 type registryPruner struct {
-	logger     lager.Logger
-	config     *ContainerConfig
-	clock      clock.Clock
-	containers *nodeMap
+	logger       lager.Logger
+	config       *ContainerConfig
+	clock        clock.Clock
+	containers   *nodeMap
+	eventEmitter event.Hub
 }
 
-func newRegistryPruner(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap) *registryPruner {
+func newRegistryPruner(logger lager.Logger, config *ContainerConfig, clock clock.Clock, containers *nodeMap, eventEmitter event.Hub) *registryPruner {
 	return &registryPruner{
-		logger:     logger,
-		config:     config,
-		clock:      clock,
-		containers: containers,
+		logger:       logger,
+		config:       config,
+		clock:        clock,
+		containers:   containers,
+		eventEmitter: eventEmitter,
 	}
 }
 
@@ -35,7 +39,10 @@ func (r *registryPruner) Run(signals <-chan os.Signal, ready chan<- struct{}) er
 		case <-ticker.C():
 
 			now := r.clock.Now()
-			r.containers.CompleteExpired(logger, now)
+			for _, container := range r.containers.ReclaimExpired(logger, now) {
+				r.eventEmitter.Emit(executor.NewContainerCompleteEvent(container))
+				r.eventEmitter.Emit(executor.NewAllocationExpiredEvent(container))
+			}
 		case <-signals:
 			return nil
 		}
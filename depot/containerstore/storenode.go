@@ -1,6 +1,7 @@
 package containerstore
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -8,8 +9,10 @@ import (
 	"sync"
 	"time"
 
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/server"
@@ -26,6 +29,12 @@ const ContainerMissingMessage = "missing garden container"
 const VolmanMountFailed = "failed to mount volume"
 const BindMountCleanupFailed = "failed to cleanup bindmount artifacts"
 const CredDirFailed = "failed to create credentials directory"
+const HelperAssetsUnavailable = "no helper assets available for container"
+const HostPortsUnavailable = "requested host ports are unavailable"
+
+// OutOfMemoryEvent is the event garden lists in a container's info once the
+// container has run out of memory.
+const OutOfMemoryEvent = "out of memory"
 
 // To be deprecated
 const (
@@ -53,11 +62,35 @@ type storeNode struct {
 	dependencyManager  DependencyManager
 	volumeManager      volman.Manager
 	credManager        CredManager
+	hostPorts          *hostPorts
 	eventEmitter       event.Hub
+	completionObserver CompletionObserver
 	transformer        transformer.Transformer
 	process            ifrit.Process
 	credManagerProcess ifrit.Process
 	config             *ContainerConfig
+	clock              clock.Clock
+
+	healthCheckOutput transformer.HealthCheckOutputReporter
+	stepResults       transformer.StepResultsReporter
+
+	// mountedVolumes are the volumes the volume manager mounted for the
+	// container, and so are the only ones to unmount on destroy
+	mountedVolumes []executor.VolumeMount
+
+	// output receives the output of the container's steps for attached
+	// readers
+	output *log_streamer.Broadcaster
+
+	// stopEscalating is set once the kill escalation for a stop has started
+	stopEscalating bool
+	// stopRequested is closed by the first stop, cancelling pending restarts
+	stopRequested chan struct{}
+
+	// outOfMemoryEvents is how many out of memory events garden listed at the
+	// last check; garden never clears them, so only later ones are new. It is
+	// only used by the goroutine running the container.
+	outOfMemoryEvents int
 }
 
 func newStoreNode(
@@ -67,10 +100,13 @@ func newStoreNode(
 	dependencyManager DependencyManager,
 	volumeManager volman.Manager,
 	credManager CredManager,
+	hostPorts *hostPorts,
 	eventEmitter event.Hub,
+	completionObserver CompletionObserver,
 	transformer transformer.Transformer,
 	hostTrustedCertificatesPath string,
 	metronClient loggregator_v2.Client,
+	clock clock.Clock,
 ) *storeNode {
 	return &storeNode{
 		config:                      config,
@@ -81,11 +117,16 @@ func newStoreNode(
 		dependencyManager:           dependencyManager,
 		volumeManager:               volumeManager,
 		credManager:                 credManager,
+		hostPorts:                   hostPorts,
 		eventEmitter:                eventEmitter,
+		completionObserver:          completionObserver,
 		transformer:                 transformer,
 		modifiedIndex:               0,
 		hostTrustedCertificatesPath: hostTrustedCertificatesPath,
 		metronClient:                metronClient,
+		clock:                       clock,
+		stopRequested:               make(chan struct{}),
+		output:                      log_streamer.NewBroadcaster(),
 	}
 }
 
@@ -107,18 +148,133 @@ func (n *storeNode) Info() executor.Container {
 	return n.info.Copy()
 }
 
-func (n *storeNode) GetFiles(logger lager.Logger, sourcePath string) (io.ReadCloser, error) {
+func (n *storeNode) GetFiles(ctx context.Context, logger lager.Logger, sourcePaths []string) (io.ReadCloser, error) {
 	n.infoLock.Lock()
 	gc := n.gardenContainer
 	n.infoLock.Unlock()
 	if gc == nil {
 		return nil, executor.ErrContainerNotFound
 	}
-	return gc.StreamOut(garden.StreamOutSpec{Path: sourcePath, User: "root"})
+
+	if len(sourcePaths) == 0 {
+		return nil, executor.ErrNoSourcePaths
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(sourcePaths) == 1 && !hasGlobMeta(sourcePaths[0]) {
+		stream, err := gc.StreamOut(garden.StreamOutSpec{Path: sourcePaths[0], User: "root"})
+		if err != nil {
+			return nil, err
+		}
+		return newContextStream(ctx, stream), nil
+	}
+
+	return newContextStream(ctx, streamOutCombined(logger, gc, sourcePaths)), nil
+}
+
+// Attach returns a reader of the output of the container's steps from now
+// until the container completes.
+func (n *storeNode) Attach(logger lager.Logger) (io.ReadCloser, error) {
+	n.infoLock.Lock()
+	defer n.infoLock.Unlock()
+
+	if !n.info.IsCreated() {
+		return nil, executor.ErrContainerNotRunning
+	}
+
+	return n.output.Attach(), nil
+}
+
+// RunProcess waits for the process to exit. If ctx is done first the process
+// is sent SIGTERM and the context's error returned without waiting further.
+func (n *storeNode) RunProcess(ctx context.Context, logger lager.Logger, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error) {
+	n.infoLock.Lock()
+	gc := n.gardenContainer
+	n.infoLock.Unlock()
+	if gc == nil {
+		return 0, executor.ErrContainerNotFound
+	}
+
+	if spec.Path == "" {
+		return 0, executor.ErrProcessPathNotSpecified
+	}
+
+	user := spec.User
+	if user == "" {
+		user = "root"
+	}
+
+	env := make([]string, 0, len(spec.Env))
+	for _, envVar := range spec.Env {
+		env = append(env, envVar.Name+"="+envVar.Value)
+	}
+
+	process, err := gc.Run(garden.ProcessSpec{
+		Path: spec.Path,
+		Args: spec.Args,
+		Env:  env,
+		Dir:  spec.Dir,
+		User: user,
+	}, garden.ProcessIO{
+		Stdin:  processIO.Stdin,
+		Stdout: processIO.Stdout,
+		Stderr: processIO.Stderr,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	type result struct {
+		exitStatus int
+		err        error
+	}
+	results := make(chan result, 1)
+	go func() {
+		exitStatus, err := process.Wait()
+		results <- result{exitStatus, err}
+	}()
+
+	select {
+	case r := <-results:
+		return r.exitStatus, r.err
+	case <-ctx.Done():
+		logger.Info("cancelled", lager.Data{"process-guid": process.ID()})
+		err := process.Signal(garden.SignalTerminate)
+		if err != nil {
+			logger.Error("failed-to-signal-process", err)
+		}
+		return 0, ctx.Err()
+	}
+}
+
+func (n *storeNode) PutFiles(ctx context.Context, logger lager.Logger, destPath string, tarStream io.Reader) error {
+	n.infoLock.Lock()
+	gc := n.gardenContainer
+	n.infoLock.Unlock()
+	if gc == nil {
+		return executor.ErrContainerNotFound
+	}
+
+	if destPath == "" {
+		return executor.ErrNoDestinationPath
+	}
+
+	err := gc.StreamIn(garden.StreamInSpec{
+		Path:      destPath,
+		User:      "root",
+		TarStream: contextReader{ctx: ctx, reader: tarStream},
+	})
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
 }
 
 func (n *storeNode) Initialize(logger lager.Logger, req *executor.RunRequest) error {
-	logger = logger.Session("node-initialize")
+	logger = logger.Session("node-initialize", n.requestLogData())
 	n.infoLock.Lock()
 	defer n.infoLock.Unlock()
 
@@ -131,7 +287,7 @@ func (n *storeNode) Initialize(logger lager.Logger, req *executor.RunRequest) er
 }
 
 func (n *storeNode) Create(logger lager.Logger) error {
-	logger = logger.Session("node-create")
+	logger = logger.Session("node-create", n.requestLogData())
 	n.acquireOpLock(logger)
 	defer n.releaseOpLock(logger)
 
@@ -144,9 +300,17 @@ func (n *storeNode) Create(logger lager.Logger) error {
 		return executor.ErrInvalidTransition
 	}
 
+	ports, err := n.hostPorts.Allocate(info.Guid, info.Ports, info.HostPortRange)
+	if err != nil {
+		logger.Error("failed-to-allocate-host-ports", err)
+		n.complete(logger, true, HostPortsUnavailable)
+		return err
+	}
+	info.Ports = ports
+
 	logStreamer := logStreamerFromLogConfig(info.LogConfig, n.metronClient)
 
-	mounts, err := n.dependencyManager.DownloadCachedDependencies(logger, info.CachedDependencies, logStreamer)
+	mounts, err := n.dependencyManager.DownloadCachedDependencies(logger, info.Guid, info.CachedDependencies, logStreamer)
 	if err != nil {
 		n.complete(logger, true, DownloadCachedDependenciesFailed)
 		return err
@@ -162,6 +326,16 @@ func (n *storeNode) Create(logger lager.Logger) error {
 		mounts.GardenBindMounts = append(mounts.GardenBindMounts, mount)
 	}
 
+	if n.config.HelperAssets.enabled() {
+		helperDir, err := n.config.HelperAssets.dirFor(info.RootFSPath, info.Architecture)
+		if err != nil {
+			logger.Error("failed-to-find-helper-assets", err, lager.Data{"rootfs": info.RootFSPath, "architecture": info.Architecture})
+			n.complete(logger, true, HelperAssetsUnavailable)
+			return err
+		}
+		mounts.GardenBindMounts = append(mounts.GardenBindMounts, newBindMount(helperDir, n.config.HelperAssets.ContainerPath))
+	}
+
 	volumeMounts, err := n.mountVolumes(logger, info)
 	if err != nil {
 		logger.Error("failed-to-mount-volume", err)
@@ -204,6 +378,7 @@ func (n *storeNode) mountVolumes(logger lager.Logger, info executor.Container) (
 		if err != nil {
 			return nil, err
 		}
+		n.mountedVolumes = append(n.mountedVolumes, volume)
 		gardenMounts = append(gardenMounts,
 			garden.BindMount{
 				SrcPath: hostMount.Path,
@@ -223,10 +398,29 @@ func (n *storeNode) gardenProperties(container *executor.Container) garden.Prope
 		}
 	}
 	properties[ContainerOwnerProperty] = n.config.OwnerName
+	if requestID := container.Tags.RequestID(); requestID != "" {
+		properties[ContainerRequestIDProperty] = requestID
+	}
 
 	return properties
 }
 
+func (n *storeNode) requestLogData() lager.Data {
+	n.infoLock.Lock()
+	defer n.infoLock.Unlock()
+
+	return withRequestID(lager.Data{}, n.info.Tags)
+}
+
+// withRequestID adds the request ID from tags, if any, to the log data so a
+// container's log lines can be correlated with the request that made it.
+func withRequestID(data lager.Data, tags executor.Tags) lager.Data {
+	if requestID := tags.RequestID(); requestID != "" {
+		data["request-id"] = requestID
+	}
+	return data
+}
+
 func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Container, mounts []garden.BindMount) (garden.Container, error) {
 	netOutRules, err := convertEgressToNetOut(logger, info.EgressRules)
 	if err != nil {
@@ -241,6 +435,16 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 		}
 	}
 
+	cpuShares := uint64(float64(n.config.MaxCPUShares) * float64(info.CPUWeight) / 100.0)
+
+	var bandwidthLimits garden.BandwidthLimits
+	if info.NetworkBandwidthLimit != nil {
+		bandwidthLimits = garden.BandwidthLimits{
+			RateInBytesPerSecond:      info.NetworkBandwidthLimit.RateInBytesPerSecond,
+			BurstRateInBytesPerSecond: info.NetworkBandwidthLimit.BurstRateInBytesPerSecond,
+		}
+	}
+
 	containerSpec := garden.ContainerSpec{
 		Handle:     info.Guid,
 		Privileged: info.Privileged,
@@ -257,15 +461,15 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 			},
 			Disk: garden.DiskLimits{
 				ByteHard:  uint64(info.DiskMB * 1024 * 1024),
-				InodeHard: n.config.INodeLimit,
+				InodeHard: n.config.inodeLimit(info.InodeLimit),
 				Scope:     convertDiskScope(info.DiskScope),
 			},
 			Pid: garden.PidLimits{
 				Max: uint64(info.MaxPids),
 			},
-			CPU: garden.CPULimits{
-				LimitInShares: uint64(float64(n.config.MaxCPUShares) * float64(info.CPUWeight) / 100.0),
-			},
+			// garden converts shares into a cpu weight itself on cgroup v2 hosts
+			CPU:       garden.CPULimits{LimitInShares: cpuShares},
+			Bandwidth: bandwidthLimits,
 		},
 		Properties: n.gardenProperties(info),
 		NetIn:      netInRules,
@@ -277,8 +481,18 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 		return nil, err
 	}
 
+	logger.Debug("container-info")
 	containerInfo, err := gardenContainer.Info()
 	if err != nil {
+		logger.Error("failed-container-info", err)
+		n.destroyContainer(logger)
+		return nil, err
+	}
+	logger.Debug("container-info-complete")
+
+	err = writeDNSConfig(logger, gardenContainer, info.DNSServers, info.HostsEntries)
+	if err != nil {
+		n.destroyContainer(logger)
 		return nil, err
 	}
 
@@ -287,13 +501,8 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 		info.Ports[i] = executor.PortMapping{HostPort: uint16(portMapping.HostPort), ContainerPort: uint16(portMapping.ContainerPort)}
 	}
 
-	externalIP, containerIP, err := fetchIPs(logger, gardenContainer)
-	if err != nil {
-		n.destroyContainer(logger)
-		return nil, err
-	}
-	info.ExternalIP = externalIP
-	info.InternalIP = containerIP
+	info.ExternalIP = containerInfo.ExternalIP
+	info.InternalIP = containerInfo.ContainerIP
 
 	err = info.TransistionToCreate()
 	if err != nil {
@@ -302,12 +511,13 @@ func (n *storeNode) createGardenContainer(logger lager.Logger, info *executor.Co
 
 	info.MemoryLimit = containerSpec.Limits.Memory.LimitInBytes
 	info.DiskLimit = containerSpec.Limits.Disk.ByteHard
+	info.CPUShares = cpuShares
 
 	return gardenContainer, nil
 }
 
 func (n *storeNode) Run(logger lager.Logger) error {
-	logger = logger.Session("node-run")
+	logger = logger.Session("node-run", n.requestLogData())
 
 	n.acquireOpLock(logger)
 	defer n.releaseOpLock(logger)
@@ -317,13 +527,15 @@ func (n *storeNode) Run(logger lager.Logger) error {
 		return executor.ErrInvalidTransition
 	}
 
-	logStreamer := logStreamerFromLogConfig(n.info.LogConfig, n.metronClient)
+	logStreamer := log_streamer.NewBroadcastingStreamer(logStreamerFromLogConfig(n.info.LogConfig, n.metronClient), n.output)
 
 	runner, err := n.transformer.StepsRunner(logger, n.info, n.gardenContainer, logStreamer)
 	if err != nil {
 		return err
 	}
 
+	n.setReporters(runner)
+
 	credManagerRunner := n.credManager.Runner(logger, n.info)
 
 	n.credManagerProcess = ifrit.Background(credManagerRunner)
@@ -354,64 +566,294 @@ func (n *storeNode) Run(logger lager.Logger) error {
 }
 
 func (n *storeNode) run(logger lager.Logger) {
+	process := n.process
+	for {
+		errorStr, credManagerExited := n.waitForProcess(logger, process)
+		if credManagerExited || !n.shouldRestart(errorStr != "") {
+			n.finish(logger, errorStr)
+			return
+		}
+
+		// an out of memory event from this run must not mark a later one
+		n.outOfMemorySinceLastCheck(logger)
+
+		process = n.restartAfterBackoff(logger)
+		if process == nil {
+			n.finish(logger, errorStr)
+			return
+		}
+	}
+}
+
+func (n *storeNode) waitForProcess(logger lager.Logger, process ifrit.Process) (string, bool) {
 	// wait for container runner to start
 	logger.Debug("execute-process")
-	<-n.process.Ready()
+	<-process.Ready()
 	logger.Debug("healthcheck-passed")
 
 	n.infoLock.Lock()
+	alreadyRunning := n.info.State == executor.StateRunning
 	n.info.State = executor.StateRunning
 	info := n.info.Copy()
 	n.infoLock.Unlock()
-	go n.eventEmitter.Emit(executor.NewContainerRunningEvent(info))
+	if !alreadyRunning {
+		go n.eventEmitter.Emit(executor.NewContainerRunningEvent(info))
+	}
 
-	var errorStr string
 	select {
 	case err := <-n.credManagerProcess.Wait():
+		process.Signal(os.Interrupt)
+		process.Wait()
 		if err != nil {
-			errorStr = "cred-manager-runner exited: " + err.Error()
+			return "cred-manager-runner exited: " + err.Error(), true
 		}
-		n.process.Signal(os.Interrupt)
-		n.process.Wait()
-	case err := <-n.process.Wait():
+		return "", true
+	case err := <-process.Wait():
 		if err != nil {
-			errorStr = err.Error()
+			return err.Error(), false
 		}
-		n.credManagerProcess.Signal(os.Interrupt)
-		n.credManagerProcess.Wait()
+		return "", false
 	}
+}
+
+func (n *storeNode) finish(logger lager.Logger, errorStr string) {
+	n.credManagerProcess.Signal(os.Interrupt)
+	n.credManagerProcess.Wait()
+
+	n.recordStepResults()
 
 	if errorStr != "" {
+		n.recordHealthCheckOutput()
+		n.recordOutOfMemory(logger)
 		n.complete(logger, true, errorStr)
 	} else {
 		n.complete(logger, false, "")
 	}
 }
 
+// recordOutOfMemory marks the run result when garden reports that the
+// container ran out of memory, which is otherwise indistinguishable from the
+// action failing.
+func (n *storeNode) recordOutOfMemory(logger lager.Logger) {
+	if !n.outOfMemorySinceLastCheck(logger) {
+		return
+	}
+
+	logger.Info("container-out-of-memory")
+
+	n.infoLock.Lock()
+	n.info.RunResult.OutOfMemory = true
+	info := n.info.Copy()
+	n.infoLock.Unlock()
+
+	// emitted synchronously so that it precedes the completed event
+	n.eventEmitter.Emit(executor.NewContainerOOMEvent(info))
+}
+
+// outOfMemorySinceLastCheck reports whether garden lists more out of memory
+// events for the container than it did at the last check.
+func (n *storeNode) outOfMemorySinceLastCheck(logger lager.Logger) bool {
+	n.infoLock.Lock()
+	gardenContainer := n.gardenContainer
+	n.infoLock.Unlock()
+
+	containerInfo, err := gardenContainer.Info()
+	if err != nil {
+		logger.Error("failed-to-get-container-info", err)
+		return false
+	}
+
+	seen := n.outOfMemoryEvents
+	n.outOfMemoryEvents = countString(containerInfo.Events, OutOfMemoryEvent)
+	return n.outOfMemoryEvents > seen
+}
+
+func countString(values []string, value string) int {
+	count := 0
+	for _, v := range values {
+		if v == value {
+			count++
+		}
+	}
+	return count
+}
+
+func (n *storeNode) shouldRestart(failed bool) bool {
+	n.infoLock.Lock()
+	defer n.infoLock.Unlock()
+
+	if n.info.RunResult.Stopped {
+		return false
+	}
+	return n.info.RestartPolicy.ShouldRestart(failed, n.info.RunResult.Restarts)
+}
+
+// restartAfterBackoff runs the container's steps again once the restart
+// policy's backoff has elapsed. It returns nil when the container was stopped
+// in the meantime or the steps could not be built.
+func (n *storeNode) restartAfterBackoff(logger lager.Logger) ifrit.Process {
+	n.infoLock.Lock()
+	restarts := n.info.RunResult.Restarts
+	backoff := n.info.RestartPolicy.Backoff(restarts)
+	n.infoLock.Unlock()
+
+	logger.Info("restarting-after-backoff", lager.Data{"restarts": restarts, "backoff": backoff.String()})
+
+	timer := n.clock.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C():
+	case <-n.stopRequested:
+		logger.Info("restart-cancelled")
+		return nil
+	}
+
+	n.infoLock.Lock()
+	info := n.info.Copy()
+	gardenContainer := n.gardenContainer
+	n.infoLock.Unlock()
+
+	logStreamer := log_streamer.NewBroadcastingStreamer(logStreamerFromLogConfig(info.LogConfig, n.metronClient), n.output)
+	runner, err := n.transformer.StepsRunner(logger, info, gardenContainer, logStreamer)
+	if err != nil {
+		logger.Error("failed-to-build-steps-runner", err)
+		return nil
+	}
+
+	n.setReporters(runner)
+
+	n.infoLock.Lock()
+	defer n.infoLock.Unlock()
+
+	if n.info.RunResult.Stopped {
+		return nil
+	}
+
+	n.info.RunResult.Restarts++
+	n.process = ifrit.Background(runner)
+	return n.process
+}
+
+// setReporters keeps the optional reporters implemented by the steps runner.
+func (n *storeNode) setReporters(runner ifrit.Runner) {
+	if reporter, ok := runner.(transformer.HealthCheckOutputReporter); ok {
+		n.healthCheckOutput = reporter
+	}
+	if reporter, ok := runner.(transformer.StepResultsReporter); ok {
+		n.stepResults = reporter
+	}
+}
+
+func (n *storeNode) recordStepResults() {
+	if n.stepResults == nil {
+		return
+	}
+
+	results := n.stepResults.StepResults()
+
+	n.infoLock.Lock()
+	n.info.RunResult.Steps = results
+	n.infoLock.Unlock()
+}
+
+func (n *storeNode) recordHealthCheckOutput() {
+	if n.healthCheckOutput == nil {
+		return
+	}
+
+	output := n.healthCheckOutput.HealthCheckOutput()
+
+	n.infoLock.Lock()
+	n.info.RunResult.HealthCheckOutput = output
+	n.infoLock.Unlock()
+}
+
 func (n *storeNode) Stop(logger lager.Logger) error {
-	logger = logger.Session("node-stop")
+	logger = logger.Session("node-stop", n.requestLogData())
 	n.acquireOpLock(logger)
 	defer n.releaseOpLock(logger)
 
 	return n.stop(logger)
 }
 
+// Evacuate stops the container like Stop, marking its run result so the work
+// is rescheduled elsewhere. The container keeps its stop grace period.
+func (n *storeNode) Evacuate(logger lager.Logger) error {
+	logger = logger.Session("node-evacuate", n.requestLogData())
+	n.acquireOpLock(logger)
+	defer n.releaseOpLock(logger)
+
+	n.infoLock.Lock()
+	n.info.RunResult.Evacuated = true
+	n.infoLock.Unlock()
+
+	return n.stop(logger)
+}
+
 func (n *storeNode) stop(logger lager.Logger) error {
 	n.infoLock.Lock()
+	if !n.info.RunResult.Stopped {
+		close(n.stopRequested)
+	}
 	n.info.RunResult.Stopped = true
+	process := n.process
 	n.infoLock.Unlock()
 
-	if n.process != nil {
-		n.process.Signal(os.Interrupt)
+	if process != nil {
+		process.Signal(os.Interrupt)
 		logger.Debug("signaled-process")
+
+		if !n.stopEscalating {
+			n.stopEscalating = true
+			go n.killAfterGracePeriod(logger, process)
+		}
 	} else {
 		n.complete(logger, true, "stopped-before-running")
 	}
 	return nil
 }
 
+func (n *storeNode) stopGracePeriod() time.Duration {
+	n.infoLock.Lock()
+	defer n.infoLock.Unlock()
+
+	if n.info.StopGracePeriodMs > 0 {
+		return time.Duration(n.info.StopGracePeriodMs) * time.Millisecond
+	}
+	return n.config.StopGracePeriod
+}
+
+func (n *storeNode) killAfterGracePeriod(logger lager.Logger, process ifrit.Process) {
+	gracePeriod := n.stopGracePeriod()
+	if gracePeriod <= 0 {
+		return
+	}
+
+	timer := n.clock.NewTimer(gracePeriod)
+	defer timer.Stop()
+
+	select {
+	case <-process.Wait():
+		return
+	case <-timer.C():
+	}
+
+	logger.Info("stop-grace-period-expired", lager.Data{"grace-period": gracePeriod.String()})
+
+	n.infoLock.Lock()
+	n.info.RunResult.Killed = true
+	gardenContainer := n.gardenContainer
+	n.infoLock.Unlock()
+
+	err := gardenContainer.Stop(true)
+	if err != nil {
+		logger.Error("failed-to-kill-processes", err)
+	}
+}
+
 func (n *storeNode) Destroy(logger lager.Logger) error {
-	logger = logger.Session("node-destroy")
+	logger = logger.Session("node-destroy", n.requestLogData())
 	n.acquireOpLock(logger)
 	defer n.releaseOpLock(logger)
 
@@ -420,8 +862,12 @@ func (n *storeNode) Destroy(logger lager.Logger) error {
 		return err
 	}
 
-	if n.process != nil {
-		<-n.process.Wait()
+	n.infoLock.Lock()
+	process := n.process
+	n.infoLock.Unlock()
+
+	if process != nil {
+		<-process.Wait()
 	}
 
 	logStreamer := logStreamerFromLogConfig(n.info.LogConfig, n.metronClient)
@@ -447,13 +893,16 @@ func (n *storeNode) Destroy(logger lager.Logger) error {
 		bindMountCleanupErr = errors.New(BindMountCleanupFailed)
 	}
 
-	for _, volume := range info.VolumeMounts {
+	for _, volume := range n.mountedVolumes {
 		err = n.volumeManager.Unmount(logger, volume.Driver, volume.VolumeId)
 		if err != nil {
-			logger.Error("failed-to-unmount-volume", err)
+			logger.Error("failed-to-unmount-volume", err, lager.Data{"driver": volume.Driver, "volume-id": volume.VolumeId})
 			bindMountCleanupErr = errors.New(BindMountCleanupFailed)
 		}
 	}
+	n.mountedVolumes = nil
+
+	n.hostPorts.Release(info.Guid)
 	return bindMountCleanupErr
 }
 
@@ -496,8 +945,7 @@ func (n *storeNode) Expire(logger lager.Logger, now time.Time) bool {
 
 	lifespan := now.Sub(time.Unix(0, n.info.AllocatedAt))
 	if lifespan >= n.config.ReservedExpirationTime {
-		n.info.TransitionToComplete(true, ContainerExpirationMessage)
-		go n.eventEmitter.Emit(executor.NewContainerCompleteEvent(n.info))
+		n.transitionToComplete(true, ContainerExpirationMessage)
 		return true
 	}
 
@@ -509,21 +957,34 @@ func (n *storeNode) Reap(logger lager.Logger) bool {
 	defer n.infoLock.Unlock()
 
 	if n.info.IsCreated() {
-		n.info.TransitionToComplete(true, ContainerMissingMessage)
-		go n.eventEmitter.Emit(executor.NewContainerCompleteEvent(n.info))
+		n.transitionToComplete(true, ContainerMissingMessage)
+		n.emitComplete(logger)
 		return true
 	}
 
 	return false
 }
 
+// transitionToComplete must be called with infoLock held.
+func (n *storeNode) transitionToComplete(failed bool, failureReason string) {
+	n.info.TransitionToComplete(failed, failureReason)
+	n.info.CompletedAt = n.clock.Now().UnixNano()
+	n.output.Close()
+}
+
 func (n *storeNode) complete(logger lager.Logger, failed bool, failureReason string) {
 	logger.Debug("node-complete", lager.Data{"failed": failed, "reason": failureReason})
 	n.infoLock.Lock()
 	defer n.infoLock.Unlock()
-	n.info.TransitionToComplete(failed, failureReason)
+	n.transitionToComplete(failed, failureReason)
+	n.emitComplete(logger)
+}
 
+// emitComplete tells the hub and the completion observer that the container
+// completed. It must be called with infoLock held.
+func (n *storeNode) emitComplete(logger lager.Logger) {
 	go n.eventEmitter.Emit(executor.NewContainerCompleteEvent(n.info))
+	go n.completionObserver.ContainerCompleted(logger, n.info)
 }
 
 func sendMetricDuration(logger lager.Logger, metric string, value time.Duration, metronClient loggregator_v2.Client) {
@@ -565,14 +1026,3 @@ func createContainer(logger lager.Logger, spec garden.ContainerSpec, client gard
 	return container, nil
 }
 
-func fetchIPs(logger lager.Logger, gardenContainer garden.Container) (string, string, error) {
-	logger.Debug("container-info")
-	gardenInfo, err := gardenContainer.Info()
-	if err != nil {
-		logger.Error("failed-container-info", err)
-		return "", "", err
-	}
-	logger.Debug("container-info-complete")
-
-	return gardenInfo.ExternalIP, gardenInfo.ContainerIP, nil
-}
@@ -0,0 +1,150 @@
+package containerstore
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+)
+
+// StreamLimits bounds a single GetFiles stream. Zero values disable the
+// corresponding limit.
+type StreamLimits struct {
+	MaxBytes    int64
+	IdleTimeout time.Duration
+	Deadline    time.Duration
+}
+
+func (l StreamLimits) enabled() bool {
+	return l.MaxBytes > 0 || l.IdleTimeout > 0 || l.Deadline > 0
+}
+
+type limitedStream struct {
+	stream io.ReadCloser
+	limits StreamLimits
+
+	readLock  sync.Mutex
+	bytesRead int64
+
+	lock      sync.Mutex
+	err       error
+	idleTimer clock.Timer
+	deadline  clock.Timer
+	done      chan struct{}
+}
+
+// newLimitedStream wraps stream so that reads fail once more than MaxBytes
+// have been read, and the stream is closed if the reader stalls for longer
+// than IdleTimeout or the whole transfer takes longer than Deadline.
+func newLimitedStream(stream io.ReadCloser, limits StreamLimits, clock clock.Clock) io.ReadCloser {
+	if !limits.enabled() {
+		return stream
+	}
+
+	s := &limitedStream{
+		stream: stream,
+		limits: limits,
+		done:   make(chan struct{}),
+	}
+
+	var idleC, deadlineC <-chan time.Time
+	if limits.IdleTimeout > 0 {
+		s.idleTimer = clock.NewTimer(limits.IdleTimeout)
+		idleC = s.idleTimer.C()
+	}
+	if limits.Deadline > 0 {
+		s.deadline = clock.NewTimer(limits.Deadline)
+		deadlineC = s.deadline.C()
+	}
+
+	if idleC != nil || deadlineC != nil {
+		go s.watch(idleC, deadlineC)
+	}
+
+	return s
+}
+
+func (s *limitedStream) watch(idleC, deadlineC <-chan time.Time) {
+	select {
+	case <-idleC:
+		s.fail(executor.ErrFilesIdleTimeout)
+	case <-deadlineC:
+		s.fail(executor.ErrFilesDeadlineExceeded)
+	case <-s.done:
+	}
+}
+
+func (s *limitedStream) fail(err error) {
+	s.lock.Lock()
+	if s.err == nil {
+		s.err = err
+	}
+	s.lock.Unlock()
+
+	// closing the underlying stream unblocks any pending Read
+	s.stream.Close()
+}
+
+func (s *limitedStream) failure() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.err
+}
+
+func (s *limitedStream) Read(p []byte) (int, error) {
+	s.readLock.Lock()
+	defer s.readLock.Unlock()
+
+	if err := s.failure(); err != nil {
+		return 0, err
+	}
+
+	if s.limits.MaxBytes > 0 {
+		remaining := s.limits.MaxBytes - s.bytesRead
+		if remaining <= 0 {
+			// a stream of exactly MaxBytes is fine, so only fail if there is more
+			n, err := s.stream.Read(make([]byte, 1))
+			if n == 0 && err == io.EOF {
+				return 0, io.EOF
+			}
+			s.fail(executor.ErrFilesMaxBytesExceeded)
+			return 0, executor.ErrFilesMaxBytesExceeded
+		}
+		if int64(len(p)) > remaining {
+			p = p[:remaining]
+		}
+	}
+
+	n, err := s.stream.Read(p)
+	s.bytesRead += int64(n)
+
+	if failure := s.failure(); failure != nil {
+		return n, failure
+	}
+
+	if n > 0 && s.idleTimer != nil {
+		s.idleTimer.Reset(s.limits.IdleTimeout)
+	}
+
+	return n, err
+}
+
+func (s *limitedStream) Close() error {
+	s.lock.Lock()
+	select {
+	case <-s.done:
+	default:
+		close(s.done)
+		if s.idleTimer != nil {
+			s.idleTimer.Stop()
+		}
+		if s.deadline != nil {
+			s.deadline.Stop()
+		}
+	}
+	s.lock.Unlock()
+
+	return s.stream.Close()
+}
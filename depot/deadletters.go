@@ -0,0 +1,66 @@
+package depot
+
+import (
+	"sort"
+	"sync"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+)
+
+// deadLetters tracks containers whose Run failed before their steps started,
+// so operators can find and retry or discard them.
+type deadLetters struct {
+	clock   clock.Clock
+	lock    sync.Mutex
+	letters map[string]executor.DeadLetter
+}
+
+func newDeadLetters(clock clock.Clock) *deadLetters {
+	return &deadLetters{clock: clock, letters: map[string]executor.DeadLetter{}}
+}
+
+func (d *deadLetters) record(guid string, err error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	letter := d.letters[guid]
+	letter.Guid = guid
+	letter.Reason = err.Error()
+	letter.FailedAt = d.clock.Now().UnixNano()
+	letter.Attempts++
+	d.letters[guid] = letter
+}
+
+func (d *deadLetters) get(guid string) (executor.DeadLetter, bool) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	letter, ok := d.letters[guid]
+	return letter, ok
+}
+
+func (d *deadLetters) remove(guid string) bool {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	_, ok := d.letters[guid]
+	delete(d.letters, guid)
+	return ok
+}
+
+func (d *deadLetters) list() []executor.DeadLetter {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	letters := make([]executor.DeadLetter, 0, len(d.letters))
+	for _, letter := range d.letters {
+		letters = append(letters, letter)
+	}
+
+	sort.Slice(letters, func(i, j int) bool {
+		return letters[i].FailedAt < letters[j].FailedAt
+	})
+
+	return letters
+}
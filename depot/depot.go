@@ -1,13 +1,19 @@
 package depot
 
 import (
+	"context"
 	"io"
 	"sync"
+	"time"
 
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/volman"
 	"code.cloudfoundry.org/workpool"
@@ -21,13 +27,26 @@ type client struct {
 	gardenClient     garden.Client
 	volmanClient     volman.Manager
 	eventHub         event.Hub
-	creationWorkPool *workpool.WorkPool
-	deletionWorkPool *workpool.WorkPool
+	creationWorkPool *queuedWorkPool
+	deletionWorkPool *queuedWorkPool
 	readWorkPool     *workpool.WorkPool
 	metricsWorkPool  *workpool.WorkPool
 
-	healthyLock sync.RWMutex
-	healthy     bool
+	cachedDownloader    cacheddownloader.CachedDownloader
+	downloadRateLimiter limiter.Limiter
+
+	// workDir holds the chunks spooled by PutFilesChunked
+	workDir string
+
+	healthyLock         sync.RWMutex
+	healthy             bool
+	draining            bool
+	rejectWhenUnhealthy bool
+
+	deadLetters  *deadLetters
+	healthchecks *healthcheckHistory
+
+	clock clock.Clock
 }
 
 func NewClient(
@@ -37,13 +56,19 @@ func NewClient(
 	volmanClient volman.Manager,
 	eventHub event.Hub,
 	workPoolSettings executor.WorkPoolSettings,
+	rejectWhenUnhealthy bool,
+	cachedDownloader cacheddownloader.CachedDownloader,
+	downloadRateLimiter limiter.Limiter,
+	workDir string,
+	metronClient loggregator_v2.Client,
+	clock clock.Clock,
 ) executor.Client {
 	// A misconfigured WorkPool is non-recoverable, so we panic here
-	creationWorkPool, err := workpool.NewWorkPool(workPoolSettings.CreateWorkPoolSize)
+	creationWorkPool, err := newQueuedWorkPool(workPoolSettings.CreateWorkPoolSize, CreateWorkPoolQueueDepth, metronClient)
 	if err != nil {
 		panic(err)
 	}
-	deletionWorkPool, err := workpool.NewWorkPool(workPoolSettings.DeleteWorkPoolSize)
+	deletionWorkPool, err := newQueuedWorkPool(workPoolSettings.DeleteWorkPoolSize, DeleteWorkPoolQueueDepth, metronClient)
 	if err != nil {
 		panic(err)
 	}
@@ -57,16 +82,23 @@ func NewClient(
 	}
 
 	return &client{
-		totalCapacity:    totalCapacity,
-		containerStore:   containerStore,
-		gardenClient:     gardenClient,
-		volmanClient:     volmanClient,
-		eventHub:         eventHub,
-		creationWorkPool: creationWorkPool,
-		deletionWorkPool: deletionWorkPool,
-		readWorkPool:     readWorkPool,
-		metricsWorkPool:  metricsWorkPool,
-		healthy:          true,
+		totalCapacity:       totalCapacity,
+		containerStore:      containerStore,
+		gardenClient:        gardenClient,
+		volmanClient:        volmanClient,
+		eventHub:            eventHub,
+		creationWorkPool:    creationWorkPool,
+		deletionWorkPool:    deletionWorkPool,
+		readWorkPool:        readWorkPool,
+		metricsWorkPool:     metricsWorkPool,
+		cachedDownloader:    cachedDownloader,
+		downloadRateLimiter: downloadRateLimiter,
+		workDir:             workDir,
+		healthy:             true,
+		rejectWhenUnhealthy: rejectWhenUnhealthy,
+		deadLetters:         newDeadLetters(clock),
+		healthchecks:        newHealthcheckHistory(),
+		clock:               clock,
 	}
 }
 
@@ -82,8 +114,18 @@ func (c *client) AllocateContainers(logger lager.Logger, requests []executor.All
 	logger = logger.Session("allocate-containers")
 	failures := make([]executor.AllocationFailure, 0)
 
+	rejectErr := c.allocationRejection()
+	if rejectErr != nil {
+		logger.Info("rejected-allocations", lager.Data{"reason": rejectErr.Error()})
+	}
+
 	for i := range requests {
 		req := &requests[i]
+		if rejectErr != nil {
+			failures = append(failures, executor.NewAllocationFailure(req, rejectErr.Error()))
+			continue
+		}
+
 		err := req.Validate()
 		if err != nil {
 			logger.Error("invalid-request", err)
@@ -102,6 +144,48 @@ func (c *client) AllocateContainers(logger lager.Logger, requests []executor.All
 	return failures, nil
 }
 
+// AllocateContainersAtomically allocates all of the requested containers, or
+// none of them. When any request is invalid or does not fit, nothing is
+// allocated and the failures name the requests responsible.
+func (c *client) AllocateContainersAtomically(logger lager.Logger, requests []executor.AllocationRequest) ([]executor.AllocationFailure, error) {
+	logger = logger.Session("allocate-containers-atomically")
+	failures := make([]executor.AllocationFailure, 0)
+
+	if rejectErr := c.allocationRejection(); rejectErr != nil {
+		logger.Info("rejected-allocations", lager.Data{"reason": rejectErr.Error()})
+		for i := range requests {
+			failures = append(failures, executor.NewAllocationFailure(&requests[i], rejectErr.Error()))
+		}
+		return failures, nil
+	}
+
+	reqs := make([]*executor.AllocationRequest, 0, len(requests))
+	for i := range requests {
+		req := &requests[i]
+		err := req.Validate()
+		if err != nil {
+			logger.Error("invalid-request", err)
+			failures = append(failures, executor.NewAllocationFailure(req, err.Error()))
+			continue
+		}
+		reqs = append(reqs, req)
+	}
+
+	if len(failures) > 0 {
+		return failures, nil
+	}
+
+	errs := c.containerStore.ReserveAll(logger, reqs)
+	for _, req := range reqs {
+		if err, ok := errs[req.Guid]; ok {
+			logger.Error("failed-to-allocate-container", err, lager.Data{"guid": req.Guid})
+			failures = append(failures, executor.NewAllocationFailure(req, err.Error()))
+		}
+	}
+
+	return failures, nil
+}
+
 func (c *client) GetContainer(logger lager.Logger, guid string) (executor.Container, error) {
 	logger = logger.Session("get-container", lager.Data{
 		"guid": guid,
@@ -115,20 +199,99 @@ func (c *client) GetContainer(logger lager.Logger, guid string) (executor.Contai
 	return container, err
 }
 
+// WatchContainerPollInterval is how often WatchContainer rereads a container
+// that has emitted no events, as some transitions, like from initializing to
+// created, emit none.
+const WatchContainerPollInterval = time.Second
+
+// WatchContainer blocks until the container leaves knownState, or timeout
+// elapses, and returns the container as it then is. It wakes on the
+// container's lifecycle events, and every WatchContainerPollInterval for the
+// transitions that emit none. If ctx is done first it returns the context's
+// error.
+func (c *client) WatchContainer(ctx context.Context, logger lager.Logger, guid string, knownState executor.State, timeout time.Duration) (executor.Container, error) {
+	logger = logger.Session("watch-container", lager.Data{"guid": guid, "known-state": knownState})
+
+	source, err := c.SubscribeToFilteredEvents(logger, executor.EventFilter{Guids: []string{guid}})
+	if err != nil {
+		logger.Error("failed-to-subscribe-to-events", err)
+		return executor.Container{}, err
+	}
+	defer source.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	events := make(chan executor.Event)
+	go func() {
+		defer close(events)
+		for {
+			ev, err := source.Next()
+			if err != nil {
+				return
+			}
+
+			select {
+			case events <- ev:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	timer := c.clock.NewTimer(timeout)
+	defer timer.Stop()
+
+	poll := c.clock.NewTicker(WatchContainerPollInterval)
+	defer poll.Stop()
+
+	for {
+		container, err := c.containerStore.Get(logger, guid)
+		if err != nil || container.State != knownState {
+			return container, err
+		}
+
+		select {
+		case _, ok := <-events:
+			if !ok {
+				return c.containerStore.Get(logger, guid)
+			}
+		case <-poll.C():
+		case <-timer.C():
+			logger.Debug("timed-out")
+			return container, nil
+		case <-ctx.Done():
+			logger.Debug("cancelled")
+			return container, ctx.Err()
+		}
+	}
+}
+
 func (c *client) RunContainer(logger lager.Logger, request *executor.RunRequest) error {
 	logger = logger.Session("run-container", lager.Data{
 		"guid": request.Guid,
 	})
 
+	if c.isDraining() {
+		logger.Info("rejected-while-draining")
+		return executor.ErrExecutorDraining
+	}
+
+	err := request.Validate()
+	if err != nil {
+		logger.Error("invalid-run-request", err)
+		return err
+	}
+
 	logger.Debug("initializing-container")
-	err := c.containerStore.Initialize(logger, request)
+	err = c.containerStore.Initialize(logger, request)
 	if err != nil {
 		logger.Error("failed-initializing-container", err)
 		return err
 	}
 	logger.Debug("succeeded-initializing-container")
 
-	c.creationWorkPool.Submit(c.newRunContainerWorker(logger, request.Guid))
+	c.creationWorkPool.Submit(logger, c.newRunContainerWorker(logger, request.Guid))
 	return nil
 }
 
@@ -142,13 +305,55 @@ func (c *client) newRunContainerWorker(logger lager.Logger, guid string) func()
 		}
 		logger.Info("succeeded-creating-container-in-garden")
 
-		logger.Info("running-container-in-garden")
-		err = c.containerStore.Run(logger, guid)
-		if err != nil {
-			logger.Error("failed-running-container-in-garden", err)
-		}
-		logger.Info("succeeded-running-container-in-garden")
+		c.runContainer(logger, guid)
+	}
+}
+
+func (c *client) runContainer(logger lager.Logger, guid string) {
+	logger.Info("running-container-in-garden")
+	err := c.containerStore.Run(logger, guid)
+	if err != nil {
+		logger.Error("failed-running-container-in-garden", err)
+		c.deadLetters.record(guid, err)
+		return
 	}
+	c.deadLetters.remove(guid)
+	logger.Info("succeeded-running-container-in-garden")
+}
+
+func (c *client) ListDeadLetters(logger lager.Logger) ([]executor.DeadLetter, error) {
+	return c.deadLetters.list(), nil
+}
+
+func (c *client) RetryDeadLetter(logger lager.Logger, guid string) error {
+	logger = logger.Session("retry-dead-letter", lager.Data{"guid": guid})
+
+	if _, ok := c.deadLetters.get(guid); !ok {
+		logger.Error("dead-letter-not-found", executor.ErrDeadLetterNotFound)
+		return executor.ErrDeadLetterNotFound
+	}
+
+	c.creationWorkPool.Submit(logger, func() {
+		c.runContainer(logger, guid)
+	})
+	return nil
+}
+
+func (c *client) DiscardDeadLetter(logger lager.Logger, guid string) error {
+	logger = logger.Session("discard-dead-letter", lager.Data{"guid": guid})
+
+	if !c.deadLetters.remove(guid) {
+		logger.Error("dead-letter-not-found", executor.ErrDeadLetterNotFound)
+		return executor.ErrDeadLetterNotFound
+	}
+
+	err := c.containerStore.Stop(logger, guid)
+	if err != nil && err != executor.ErrContainerNotFound {
+		logger.Error("failed-to-stop-container", err)
+		return err
+	}
+
+	return nil
 }
 
 func tagsMatch(needles, haystack executor.Tags) bool {
@@ -165,6 +370,10 @@ func (c *client) ListContainers(logger lager.Logger) ([]executor.Container, erro
 	return c.containerStore.List(logger), nil
 }
 
+func (c *client) ListContainersPage(logger lager.Logger, opts executor.ContainerListOptions) (executor.ContainerPage, error) {
+	return c.containerStore.ListPage(logger, opts), nil
+}
+
 func (c *client) GetBulkMetrics(logger lager.Logger) (map[string]executor.Metrics, error) {
 	errChannel := make(chan error, 1)
 	metricsChannel := make(chan map[string]executor.Metrics, 1)
@@ -222,7 +431,7 @@ func (c *client) DeleteContainer(logger lager.Logger, guid string) error {
 	defer logger.Info("complete")
 
 	errChannel := make(chan error, 1)
-	c.deletionWorkPool.Submit(func() {
+	c.deletionWorkPool.Submit(logger, func() {
 		errChannel <- c.containerStore.Destroy(logger, guid)
 	})
 
@@ -230,9 +439,54 @@ func (c *client) DeleteContainer(logger lager.Logger, guid string) error {
 
 	if err != nil {
 		logger.Error("failed-to-delete-garden-container", err)
+		return err
+	}
+
+	c.deadLetters.remove(guid)
+	return nil
+}
+
+// DeleteContainers stops and destroys every container with all of the given
+// tags, through the deletion work pool. The result holds the outcome for each
+// guid, with a nil error for those deleted. Without tags nothing is deleted.
+func (c *client) DeleteContainers(logger lager.Logger, tags executor.Tags) map[string]error {
+	logger = logger.Session("delete-containers", lager.Data{"tags": tags})
+
+	if len(tags) == 0 {
+		logger.Info("no-tags-given")
+		return map[string]error{}
 	}
 
-	return err
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	guids := []string{}
+	for _, container := range c.containerStore.List(logger) {
+		if container.HasTags(tags) {
+			guids = append(guids, container.Guid)
+		}
+	}
+
+	type result struct {
+		guid string
+		err  error
+	}
+
+	results := make(chan result, len(guids))
+	for _, guid := range guids {
+		guid := guid
+		go func() {
+			results <- result{guid, c.DeleteContainer(logger, guid)}
+		}()
+	}
+
+	errs := make(map[string]error, len(guids))
+	for range guids {
+		r := <-results
+		errs[r.guid] = r.err
+	}
+
+	return errs
 }
 
 func (c *client) RemainingResources(logger lager.Logger) (executor.ExecutorResources, error) {
@@ -254,7 +508,52 @@ func (c *client) TotalResources(logger lager.Logger) (executor.ExecutorResources
 	}, nil
 }
 
-func (c *client) GetFiles(logger lager.Logger, guid, sourcePath string) (io.ReadCloser, error) {
+func (c *client) Capacity(logger lager.Logger) (executor.ExecutorCapacity, error) {
+	logger = logger.Session("capacity")
+
+	total, err := c.TotalResources(logger)
+	if err != nil {
+		logger.Error("failed-to-get-total-resources", err)
+		return executor.ExecutorCapacity{}, err
+	}
+
+	remaining := c.containerStore.RemainingResources(logger)
+
+	capacity := executor.ExecutorCapacity{
+		Total: total,
+		Allocated: executor.ExecutorResources{
+			MemoryMB:   total.MemoryMB - remaining.MemoryMB,
+			DiskMB:     total.DiskMB - remaining.DiskMB,
+			Containers: total.Containers - remaining.Containers,
+		},
+	}
+
+	errChannel := make(chan error, 1)
+	c.metricsWorkPool.Submit(func() {
+		metrics, err := c.containerStore.Metrics(logger)
+		if err != nil {
+			errChannel <- err
+			return
+		}
+
+		for _, metric := range metrics {
+			capacity.InUse.MemoryInBytes += metric.MemoryUsageInBytes
+			capacity.InUse.DiskInBytes += metric.DiskUsageInBytes
+			capacity.InUse.Containers++
+		}
+		errChannel <- nil
+	})
+
+	err = <-errChannel
+	if err != nil {
+		logger.Error("failed-to-get-metrics", err)
+		return executor.ExecutorCapacity{}, err
+	}
+
+	return capacity, nil
+}
+
+func (c *client) GetFiles(ctx context.Context, logger lager.Logger, guid string, sourcePaths ...string) (io.ReadCloser, error) {
 	logger = logger.Session("get-files", lager.Data{
 		"guid": guid,
 	})
@@ -262,7 +561,7 @@ func (c *client) GetFiles(logger lager.Logger, guid, sourcePath string) (io.Read
 	errChannel := make(chan error, 1)
 	readChannel := make(chan io.ReadCloser, 1)
 	c.readWorkPool.Submit(func() {
-		readCloser, err := c.containerStore.GetFiles(logger, guid, sourcePath)
+		readCloser, err := c.containerStore.GetFiles(ctx, logger, guid, sourcePaths...)
 		if err != nil {
 			errChannel <- err
 		} else {
@@ -276,10 +575,45 @@ func (c *client) GetFiles(logger lager.Logger, guid, sourcePath string) (io.Read
 	case readCloser = <-readChannel:
 		err = nil
 	case err = <-errChannel:
+	case <-ctx.Done():
+		// the stream, if it is still opened, is closed along with ctx
+		err = ctx.Err()
 	}
 	return readCloser, err
 }
 
+// PutFiles streams the tar archive tarStream into the container at destPath.
+// It does not use a work pool, since the stream is consumed for as long as
+// the caller keeps writing to it.
+func (c *client) PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error {
+	logger = logger.Session("put-files", lager.Data{
+		"guid": guid,
+	})
+
+	return c.containerStore.PutFiles(ctx, logger, guid, destPath, tarStream)
+}
+
+// RunProcess runs an ad-hoc process in the container and returns its exit
+// status once it exits. Like PutFiles, it does not use a work pool.
+func (c *client) RunProcess(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error) {
+	logger = logger.Session("run-process", lager.Data{
+		"guid": guid,
+	})
+
+	return c.containerStore.RunProcess(ctx, logger, guid, spec, processIO)
+}
+
+// AttachContainer returns a reader of the combined stdout and stderr of the
+// container's steps, which reaches EOF when the container completes. Any
+// number of readers may be attached at once.
+func (c *client) AttachContainer(logger lager.Logger, guid string) (io.ReadCloser, error) {
+	logger = logger.Session("attach-container", lager.Data{
+		"guid": guid,
+	})
+
+	return c.containerStore.Attach(logger, guid)
+}
+
 func (c *client) VolumeDrivers(logger lager.Logger) ([]string, error) {
 	logger = logger.Session("volume-drivers")
 
@@ -300,10 +634,29 @@ func (c *client) SubscribeToEvents(logger lager.Logger) (executor.EventSource, e
 	return c.eventHub.Subscribe()
 }
 
+// SubscribeToFilteredEvents subscribes to the events matching filter. Container
+// metrics events are only delivered when filter asks for them by type.
+func (c *client) SubscribeToFilteredEvents(logger lager.Logger, filter executor.EventFilter) (executor.EventSource, error) {
+	opts := event.SubscribeOptions{}
+	for _, eventType := range filter.EventTypes {
+		if eventType == executor.EventTypeContainerMetrics {
+			opts.ContainerMetrics = true
+		}
+	}
+
+	source, err := c.eventHub.SubscribeWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return event.NewFilteredSource(source, filter), nil
+}
+
+// Healthy reports whether garden is healthy and the client is not draining.
 func (c *client) Healthy(logger lager.Logger) bool {
 	c.healthyLock.RLock()
 	defer c.healthyLock.RUnlock()
-	return c.healthy
+	return c.healthy && !c.draining
 }
 
 func (c *client) SetHealthy(logger lager.Logger, healthy bool) {
@@ -311,3 +664,70 @@ func (c *client) SetHealthy(logger lager.Logger, healthy bool) {
 	defer c.healthyLock.Unlock()
 	c.healthy = healthy
 }
+
+func (c *client) RecordHealthcheck(logger lager.Logger, result executor.HealthcheckResult) {
+	c.healthchecks.record(result)
+}
+
+// HealthcheckHistory returns the last HealthcheckHistorySize garden health
+// check results, oldest first.
+func (c *client) HealthcheckHistory(logger lager.Logger) []executor.HealthcheckResult {
+	return c.healthchecks.list()
+}
+
+// Drain stops the client accepting new containers ahead of shutdown, and
+// reports it unhealthy so callers stop sending work, whatever the garden
+// health check finds. Requests already in flight and existing containers are
+// unaffected.
+func (c *client) Drain(logger lager.Logger) {
+	logger.Session("drain").Info("draining")
+	c.healthyLock.Lock()
+	defer c.healthyLock.Unlock()
+	c.draining = true
+}
+
+// Evacuate drains the client and stops every container that has not already
+// completed, marking them evacuated so their work is rescheduled elsewhere.
+func (c *client) Evacuate(logger lager.Logger) error {
+	logger = logger.Session("evacuate")
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	c.Drain(logger)
+
+	var evacuateErr error
+	for _, container := range c.containerStore.List(logger) {
+		if container.State == executor.StateCompleted {
+			continue
+		}
+
+		err := c.containerStore.Evacuate(logger, container.Guid)
+		if err != nil {
+			logger.Error("failed-to-evacuate-container", err, lager.Data{"guid": container.Guid})
+			evacuateErr = err
+		}
+	}
+
+	return evacuateErr
+}
+
+func (c *client) isDraining() bool {
+	c.healthyLock.RLock()
+	defer c.healthyLock.RUnlock()
+	return c.draining
+}
+
+// allocationRejection returns the error new allocations fail with, or nil
+// when the client is accepting them.
+func (c *client) allocationRejection() error {
+	c.healthyLock.RLock()
+	defer c.healthyLock.RUnlock()
+
+	if c.draining {
+		return executor.ErrExecutorDraining
+	}
+	if c.rejectWhenUnhealthy && !c.healthy {
+		return executor.ErrUnhealthy
+	}
+	return nil
+}
@@ -1,15 +1,28 @@
 package depot_test
 
 import (
+	"archive/tar"
+	"bytes"
+	"context"
 	"errors"
 	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"strings"
 	"time"
 
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/cacheddownloader/cacheddownloaderfakes"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/clock/fakeclock"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot"
 	"code.cloudfoundry.org/executor/depot/containerstore/containerstorefakes"
 	efakes "code.cloudfoundry.org/executor/depot/event/fakes"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/fakes"
+	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
 	"code.cloudfoundry.org/lager"
 	"code.cloudfoundry.org/lager/lagertest"
 	"code.cloudfoundry.org/volman"
@@ -22,15 +35,20 @@ import (
 
 var _ = Describe("Depot", func() {
 	var (
-		depotClient      executor.Client
-		logger           lager.Logger
-		eventHub         *efakes.FakeHub
-		gardenClient     *fakes.FakeGardenClient
-		volmanClient     *volmanfakes.FakeManager
-		containerStore   *containerstorefakes.FakeContainerStore
-		resources        executor.ExecutorResources
-		volumeDrivers    []string
-		workPoolSettings executor.WorkPoolSettings
+		depotClient         executor.Client
+		logger              lager.Logger
+		eventHub            *efakes.FakeHub
+		gardenClient        *fakes.FakeGardenClient
+		volmanClient        *volmanfakes.FakeManager
+		containerStore      *containerstorefakes.FakeContainerStore
+		resources           executor.ExecutorResources
+		volumeDrivers       []string
+		workPoolSettings    executor.WorkPoolSettings
+		rejectWhenUnhealthy bool
+		metronClient        *mfakes.FakeClient
+		cachedDownloader    *cacheddownloaderfakes.FakeCachedDownloader
+		fakeClock           *fakeclock.FakeClock
+		workDir             string
 	)
 
 	BeforeEach(func() {
@@ -39,6 +57,14 @@ var _ = Describe("Depot", func() {
 		gardenClient = new(fakes.FakeGardenClient)
 		volmanClient = new(volmanfakes.FakeManager)
 		containerStore = new(containerstorefakes.FakeContainerStore)
+		metronClient = new(mfakes.FakeClient)
+		cachedDownloader = new(cacheddownloaderfakes.FakeCachedDownloader)
+		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 456))
+		rejectWhenUnhealthy = true
+
+		var err error
+		workDir, err = ioutil.TempDir("", "depot-work")
+		Expect(err).NotTo(HaveOccurred())
 
 		resources = executor.ExecutorResources{
 			MemoryMB:   1024,
@@ -55,7 +81,12 @@ var _ = Describe("Depot", func() {
 	})
 
 	JustBeforeEach(func() {
-		depotClient = depot.NewClient(resources, containerStore, gardenClient, volmanClient, eventHub, workPoolSettings)
+		downloadRateLimiter := limiter.New(5, limiter.DownloadQueueWaitDuration, limiter.DownloadQueueDepth, metronClient, clock.NewClock())
+		depotClient = depot.NewClient(resources, containerStore, gardenClient, volmanClient, eventHub, workPoolSettings, rejectWhenUnhealthy, cachedDownloader, downloadRateLimiter, workDir, metronClient, fakeClock)
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(workDir)
 	})
 
 	Describe("AllocateContainers", func() {
@@ -169,6 +200,59 @@ var _ = Describe("Depot", func() {
 		})
 	})
 
+	Describe("AllocateContainersAtomically", func() {
+		var requests []executor.AllocationRequest
+
+		BeforeEach(func() {
+			requests = []executor.AllocationRequest{
+				newAllocationRequest("guid-1"),
+				newAllocationRequest("guid-2"),
+			}
+		})
+
+		It("reserves all of the containers together", func() {
+			failures, err := depotClient.AllocateContainersAtomically(logger, requests)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(BeEmpty())
+
+			Expect(containerStore.ReserveAllCallCount()).To(Equal(1))
+			_, reqs := containerStore.ReserveAllArgsForCall(0)
+			Expect(reqs).To(HaveLen(2))
+			Expect(*reqs[0]).To(Equal(requests[0]))
+			Expect(*reqs[1]).To(Equal(requests[1]))
+		})
+
+		Context("when some of the containers do not fit", func() {
+			BeforeEach(func() {
+				containerStore.ReserveAllReturns(map[string]error{
+					"guid-2": executor.ErrInsufficientResourcesAvailable,
+				})
+			})
+
+			It("returns failures for them", func() {
+				failures, err := depotClient.AllocateContainersAtomically(logger, requests)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(failures).To(Equal([]executor.AllocationFailure{
+					executor.NewAllocationFailure(&requests[1], executor.ErrInsufficientResourcesAvailable.Error()),
+				}))
+			})
+		})
+
+		Context("when a request is invalid", func() {
+			BeforeEach(func() {
+				requests[1].Guid = ""
+			})
+
+			It("reserves nothing", func() {
+				failures, err := depotClient.AllocateContainersAtomically(logger, requests)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(failures).To(HaveLen(1))
+				Expect(failures[0].ErrorMsg).To(Equal(executor.ErrGuidNotSpecified.Error()))
+				Expect(containerStore.ReserveAllCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Describe("RunContainer", func() {
 		var (
 			containerGuid string
@@ -201,6 +285,55 @@ var _ = Describe("Depot", func() {
 				_, guid = containerStore.RunArgsForCall(0)
 				Expect(guid).To(Equal(containerGuid))
 			})
+
+			It("reports the creation queue depth", func() {
+				err := depotClient.RunContainer(logger, runRequest)
+				Expect(err).NotTo(HaveOccurred())
+
+				Eventually(metronClient.SendMetricCallCount).Should(Equal(2))
+				name, depth := metronClient.SendMetricArgsForCall(0)
+				Expect(name).To(Equal(depot.CreateWorkPoolQueueDepth))
+				Expect(depth).To(Equal(1))
+				name, depth = metronClient.SendMetricArgsForCall(1)
+				Expect(name).To(Equal(depot.CreateWorkPoolQueueDepth))
+				Expect(depth).To(Equal(0))
+			})
+		})
+
+		Context("when the stop signal is invalid", func() {
+			BeforeEach(func() {
+				runRequest.StopSignal = executor.StopSignal{Signal: "HUP"}
+			})
+
+			It("returns an error without initializing the container", func() {
+				err := depotClient.RunContainer(logger, runRequest)
+				Expect(err).To(Equal(executor.ErrStopSignalInvalid))
+				Expect(containerStore.InitializeCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the stop signal is one garden cannot deliver", func() {
+			BeforeEach(func() {
+				runRequest.StopSignal = executor.StopSignal{Signal: "QUIT"}
+			})
+
+			It("returns an error without initializing the container", func() {
+				err := depotClient.RunContainer(logger, runRequest)
+				Expect(err).To(Equal(executor.ErrStopSignalInvalid))
+				Expect(containerStore.InitializeCallCount()).To(Equal(0))
+			})
+		})
+
+		Context("when the stop signal is sent to the process group", func() {
+			BeforeEach(func() {
+				runRequest.StopSignal = executor.StopSignal{ProcessGroup: true}
+			})
+
+			It("returns an error without initializing the container", func() {
+				err := depotClient.RunContainer(logger, runRequest)
+				Expect(err).To(Equal(executor.ErrStopSignalInvalid))
+				Expect(containerStore.InitializeCallCount()).To(Equal(0))
+			})
 		})
 
 		Context("when the container fails to initialize", func() {
@@ -363,7 +496,7 @@ var _ = Describe("Depot", func() {
 			BeforeEach(func() {
 				throttleChan = make(chan struct{}, numRequests)
 				doneChan = make(chan struct{})
-				containerStore.GetFilesStub = func(logger lager.Logger, guid string, sourcePath string) (io.ReadCloser, error) {
+				containerStore.GetFilesStub = func(ctx context.Context, logger lager.Logger, guid string, sourcePaths ...string) (io.ReadCloser, error) {
 					throttleChan <- struct{}{}
 					<-doneChan
 					return nil, nil
@@ -379,7 +512,7 @@ var _ = Describe("Depot", func() {
 				getFilesCount := 0
 				for i := 0; i < numRequests; i++ {
 					getFilesCount++
-					go depotClient.GetFiles(logger, containerGuid, "/some/path")
+					go depotClient.GetFiles(context.Background(), logger, containerGuid, "/some/path")
 				}
 
 				Eventually(throttleChan).Should(HaveLen(workPoolSettings.ReadWorkPoolSize))
@@ -575,6 +708,379 @@ var _ = Describe("Depot", func() {
 		})
 	})
 
+	Describe("DeadLetters", func() {
+		var containerGuid string
+
+		BeforeEach(func() {
+			containerGuid = "container-guid"
+			containerStore.RunReturns(errors.New("some-error"))
+		})
+
+		JustBeforeEach(func() {
+			err := depotClient.RunContainer(logger, newRunRequest(containerGuid))
+			Expect(err).NotTo(HaveOccurred())
+			Eventually(containerStore.RunCallCount).Should(Equal(1))
+		})
+
+		It("records containers that failed to run", func() {
+			Eventually(func() ([]executor.DeadLetter, error) {
+				return depotClient.ListDeadLetters(logger)
+			}).Should(HaveLen(1))
+
+			deadLetters, err := depotClient.ListDeadLetters(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(deadLetters[0].Guid).To(Equal(containerGuid))
+			Expect(deadLetters[0].Reason).To(Equal("some-error"))
+			Expect(deadLetters[0].Attempts).To(Equal(1))
+			Expect(deadLetters[0].FailedAt).To(Equal(fakeClock.Now().UnixNano()))
+		})
+
+		Describe("RetryDeadLetter", func() {
+			JustBeforeEach(func() {
+				Eventually(func() ([]executor.DeadLetter, error) {
+					return depotClient.ListDeadLetters(logger)
+				}).Should(HaveLen(1))
+			})
+
+			Context("when the run succeeds", func() {
+				BeforeEach(func() {
+					containerStore.RunStub = func(lager.Logger, string) error {
+						if containerStore.RunCallCount() == 1 {
+							return errors.New("some-error")
+						}
+						return nil
+					}
+				})
+
+				It("runs the container again and removes the dead letter", func() {
+					Expect(depotClient.RetryDeadLetter(logger, containerGuid)).To(Succeed())
+
+					Eventually(containerStore.RunCallCount).Should(Equal(2))
+					_, guid := containerStore.RunArgsForCall(1)
+					Expect(guid).To(Equal(containerGuid))
+
+					Eventually(func() ([]executor.DeadLetter, error) {
+						return depotClient.ListDeadLetters(logger)
+					}).Should(BeEmpty())
+				})
+			})
+
+			Context("when the run fails again", func() {
+				It("counts the attempt", func() {
+					Expect(depotClient.RetryDeadLetter(logger, containerGuid)).To(Succeed())
+
+					Eventually(func() int {
+						deadLetters, _ := depotClient.ListDeadLetters(logger)
+						return deadLetters[0].Attempts
+					}).Should(Equal(2))
+				})
+			})
+
+			Context("when there is no dead letter for the container", func() {
+				It("returns an error", func() {
+					err := depotClient.RetryDeadLetter(logger, "unknown-guid")
+					Expect(err).To(Equal(executor.ErrDeadLetterNotFound))
+				})
+			})
+		})
+
+		Describe("DiscardDeadLetter", func() {
+			JustBeforeEach(func() {
+				Eventually(func() ([]executor.DeadLetter, error) {
+					return depotClient.ListDeadLetters(logger)
+				}).Should(HaveLen(1))
+			})
+
+			It("removes the dead letter and stops the container", func() {
+				Expect(depotClient.DiscardDeadLetter(logger, containerGuid)).To(Succeed())
+
+				Expect(depotClient.ListDeadLetters(logger)).To(BeEmpty())
+				Expect(containerStore.StopCallCount()).To(Equal(1))
+				_, guid := containerStore.StopArgsForCall(0)
+				Expect(guid).To(Equal(containerGuid))
+			})
+
+			Context("when the container no longer exists", func() {
+				BeforeEach(func() {
+					containerStore.StopReturns(executor.ErrContainerNotFound)
+				})
+
+				It("succeeds", func() {
+					Expect(depotClient.DiscardDeadLetter(logger, containerGuid)).To(Succeed())
+				})
+			})
+
+			Context("when there is no dead letter for the container", func() {
+				It("returns an error", func() {
+					err := depotClient.DiscardDeadLetter(logger, "unknown-guid")
+					Expect(err).To(Equal(executor.ErrDeadLetterNotFound))
+				})
+			})
+		})
+	})
+
+	Describe("SubscribeToFilteredEvents", func() {
+		var fakeSource *fakes.FakeEventSource
+
+		BeforeEach(func() {
+			events := []executor.Event{
+				executor.NewContainerRunningEvent(executor.Container{Guid: "other-guid"}),
+				executor.NewContainerRunningEvent(executor.Container{Guid: "some-guid"}),
+			}
+
+			fakeSource = new(fakes.FakeEventSource)
+			fakeSource.NextStub = func() (executor.Event, error) {
+				if len(events) == 0 {
+					return nil, errors.New("closed")
+				}
+				ev := events[0]
+				events = events[1:]
+				return ev, nil
+			}
+			eventHub.SubscribeWithOptionsReturns(fakeSource, nil)
+		})
+
+		It("only returns events matching the filter", func() {
+			source, err := depotClient.SubscribeToFilteredEvents(logger, executor.EventFilter{Guids: []string{"some-guid"}})
+			Expect(err).NotTo(HaveOccurred())
+
+			ev, err := source.Next()
+			Expect(err).NotTo(HaveOccurred())
+			Expect(ev.(executor.ContainerRunningEvent).Container().Guid).To(Equal("some-guid"))
+
+			Expect(source.Close()).To(Succeed())
+			Expect(fakeSource.CloseCallCount()).To(Equal(1))
+		})
+
+		It("does not subscribe to container metrics unless the filter asks for them", func() {
+			_, err := depotClient.SubscribeToFilteredEvents(logger, executor.EventFilter{Guids: []string{"some-guid"}})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(eventHub.SubscribeWithOptionsArgsForCall(0).ContainerMetrics).To(BeFalse())
+
+			_, err = depotClient.SubscribeToFilteredEvents(logger, executor.EventFilter{
+				EventTypes: []executor.EventType{executor.EventTypeContainerMetrics},
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(eventHub.SubscribeWithOptionsArgsForCall(1).ContainerMetrics).To(BeTrue())
+		})
+
+		Context("when subscribing to the hub fails", func() {
+			BeforeEach(func() {
+				eventHub.SubscribeWithOptionsReturns(nil, errors.New("boom"))
+			})
+
+			It("returns the error", func() {
+				_, err := depotClient.SubscribeToFilteredEvents(logger, executor.EventFilter{})
+				Expect(err).To(MatchError("boom"))
+			})
+		})
+	})
+
+	Describe("WatchContainer", func() {
+		var (
+			fakeSource *fakes.FakeEventSource
+			events     chan executor.Event
+		)
+
+		BeforeEach(func() {
+			events = make(chan executor.Event, 1)
+			fakeSource = new(fakes.FakeEventSource)
+			fakeSource.NextStub = func() (executor.Event, error) {
+				ev, ok := <-events
+				if !ok {
+					return nil, errors.New("closed")
+				}
+				return ev, nil
+			}
+			eventHub.SubscribeWithOptionsReturns(fakeSource, nil)
+
+			containerStore.GetReturns(executor.Container{Guid: "some-guid", State: executor.StateReserved}, nil)
+		})
+
+		AfterEach(func() {
+			close(events)
+		})
+
+		It("returns as soon as the container leaves the known state", func() {
+			running := executor.Container{Guid: "some-guid", State: executor.StateRunning}
+			containerStore.GetReturnsOnCall(1, running, nil)
+
+			watched := make(chan executor.Container)
+			go func() {
+				defer GinkgoRecover()
+				container, err := depotClient.WatchContainer(context.Background(), logger, "some-guid", executor.StateReserved, time.Minute)
+				Expect(err).NotTo(HaveOccurred())
+				watched <- container
+			}()
+
+			Consistently(watched).ShouldNot(Receive())
+
+			events <- executor.NewContainerRunningEvent(running)
+			Eventually(watched).Should(Receive(Equal(running)))
+			Eventually(fakeSource.CloseCallCount).Should(Equal(1))
+		})
+
+		It("returns immediately when the container is not in the known state", func() {
+			container, err := depotClient.WatchContainer(context.Background(), logger, "some-guid", executor.StateRunning, time.Minute)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(container.State).To(Equal(executor.StateReserved))
+		})
+
+		It("returns the unchanged container when the timeout elapses", func() {
+			watched := make(chan executor.Container)
+			go func() {
+				defer GinkgoRecover()
+				container, err := depotClient.WatchContainer(context.Background(), logger, "some-guid", executor.StateReserved, 10*time.Millisecond)
+				Expect(err).NotTo(HaveOccurred())
+				watched <- container
+			}()
+
+			fakeClock.WaitForNWatchersAndIncrement(10*time.Millisecond, 2)
+			Eventually(watched).Should(Receive(Equal(executor.Container{Guid: "some-guid", State: executor.StateReserved})))
+		})
+
+		It("rereads the container for transitions that emit no events", func() {
+			created := executor.Container{Guid: "some-guid", State: executor.StateCreated}
+			containerStore.GetReturnsOnCall(1, created, nil)
+
+			watched := make(chan executor.Container)
+			go func() {
+				defer GinkgoRecover()
+				container, err := depotClient.WatchContainer(context.Background(), logger, "some-guid", executor.StateReserved, time.Minute)
+				Expect(err).NotTo(HaveOccurred())
+				watched <- container
+			}()
+
+			Consistently(watched).ShouldNot(Receive())
+
+			fakeClock.WaitForNWatchersAndIncrement(depot.WatchContainerPollInterval, 2)
+			Eventually(watched).Should(Receive(Equal(created)))
+		})
+
+		It("returns the context's error when it is cancelled first", func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			container, err := depotClient.WatchContainer(ctx, logger, "some-guid", executor.StateReserved, time.Minute)
+			Expect(err).To(Equal(context.DeadlineExceeded))
+			Expect(container.State).To(Equal(executor.StateReserved))
+		})
+
+		Context("when the container goes away", func() {
+			BeforeEach(func() {
+				containerStore.GetReturns(executor.Container{}, executor.ErrContainerNotFound)
+			})
+
+			It("returns the error", func() {
+				_, err := depotClient.WatchContainer(context.Background(), logger, "some-guid", executor.StateReserved, time.Minute)
+				Expect(err).To(Equal(executor.ErrContainerNotFound))
+			})
+		})
+	})
+
+	Describe("PutFilesChunked", func() {
+		var (
+			tarStream *bytes.Buffer
+			uploaded  [][]string
+			progress  []executor.UploadProgress
+			opts      executor.ChunkedUploadOptions
+		)
+
+		BeforeEach(func() {
+			tarStream = new(bytes.Buffer)
+			writer := tar.NewWriter(tarStream)
+			for _, name := range []string{"a", "b", "c"} {
+				Expect(writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: 100})).To(Succeed())
+				_, err := writer.Write(bytes.Repeat([]byte(name), 100))
+				Expect(err).NotTo(HaveOccurred())
+			}
+			Expect(writer.Close()).To(Succeed())
+
+			uploaded = nil
+			progress = nil
+			opts = executor.ChunkedUploadOptions{
+				ChunkSize:  1,
+				MaxRetries: 1,
+				Progress: func(p executor.UploadProgress) {
+					progress = append(progress, p)
+				},
+			}
+
+			failures := map[string]int{"b": 1}
+			containerStore.PutFilesStub = func(ctx context.Context, logger lager.Logger, guid string, destPath string, chunk io.Reader) error {
+				names := []string{}
+				reader := tar.NewReader(chunk)
+				for {
+					header, err := reader.Next()
+					if err == io.EOF {
+						break
+					}
+					Expect(err).NotTo(HaveOccurred())
+					names = append(names, header.Name)
+				}
+
+				if failures[names[0]] > 0 {
+					failures[names[0]]--
+					return errors.New("connection reset")
+				}
+				uploaded = append(uploaded, names)
+				return nil
+			}
+		})
+
+		It("streams each chunk in on its own, resending the ones that fail", func() {
+			err := depotClient.PutFilesChunked(context.Background(), logger, "some-guid", "/some/dir", tarStream, opts)
+			Expect(err).NotTo(HaveOccurred())
+
+			Expect(uploaded).To(Equal([][]string{{"a"}, {"b"}, {"c"}}))
+			Expect(containerStore.PutFilesCallCount()).To(Equal(4))
+
+			_, _, guid, destPath, _ := containerStore.PutFilesArgsForCall(0)
+			Expect(guid).To(Equal("some-guid"))
+			Expect(destPath).To(Equal("/some/dir"))
+
+			Expect(progress).To(HaveLen(3))
+			Expect(progress[2].ChunksSent).To(Equal(3))
+			Expect(progress[2].BytesSent).To(BeNumerically(">", 300))
+		})
+
+		It("spools the chunks in the work directory", func() {
+			err := depotClient.PutFilesChunked(context.Background(), logger, "some-guid", "/some/dir", tarStream, opts)
+			Expect(err).NotTo(HaveOccurred())
+
+			_, _, _, _, chunk := containerStore.PutFilesArgsForCall(0)
+			Expect(chunk.(*os.File).Name()).To(HavePrefix(workDir))
+		})
+
+		It("gives up once a chunk has failed more than the retries allow", func() {
+			opts.MaxRetries = 0
+
+			err := depotClient.PutFilesChunked(context.Background(), logger, "some-guid", "/some/dir", tarStream, opts)
+			Expect(err).To(MatchError("connection reset"))
+			Expect(uploaded).To(Equal([][]string{{"a"}}))
+			Expect(progress).To(HaveLen(1))
+		})
+
+		It("does not resend a chunk that failed with an error that is not retryable", func() {
+			containerStore.PutFilesStub = nil
+			containerStore.PutFilesReturns(executor.ErrContainerNotFound)
+
+			err := depotClient.PutFilesChunked(context.Background(), logger, "some-guid", "/some/dir", tarStream, opts)
+			Expect(err).To(Equal(executor.ErrContainerNotFound))
+			Expect(containerStore.PutFilesCallCount()).To(Equal(1))
+		})
+
+		It("skips the chunks already sent when resuming", func() {
+			opts.SkipChunks = 2
+
+			err := depotClient.PutFilesChunked(context.Background(), logger, "some-guid", "/some/dir", tarStream, opts)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(uploaded).To(Equal([][]string{{"c"}}))
+			Expect(progress).To(HaveLen(3))
+			Expect(progress[2].ChunksSent).To(Equal(3))
+		})
+	})
+
 	Describe("StopContainer", func() {
 		var stopError error
 		var stopGuid string
@@ -605,6 +1111,77 @@ var _ = Describe("Depot", func() {
 		})
 	})
 
+	Describe("Evacuate", func() {
+		BeforeEach(func() {
+			containerStore.ListReturns([]executor.Container{
+				{Guid: "running-guid", State: executor.StateRunning},
+				{Guid: "completed-guid", State: executor.StateCompleted},
+				{Guid: "reserved-guid", State: executor.StateReserved},
+			})
+		})
+
+		It("drains the executor and evacuates the containers that have not completed", func() {
+			Expect(depotClient.Evacuate(logger)).To(Succeed())
+			Expect(depotClient.Healthy(logger)).To(BeFalse())
+
+			Expect(containerStore.EvacuateCallCount()).To(Equal(2))
+			_, guid := containerStore.EvacuateArgsForCall(0)
+			Expect(guid).To(Equal("running-guid"))
+			_, guid = containerStore.EvacuateArgsForCall(1)
+			Expect(guid).To(Equal("reserved-guid"))
+		})
+
+		Context("when evacuating a container fails", func() {
+			BeforeEach(func() {
+				containerStore.EvacuateReturns(errors.New("boom!"))
+			})
+
+			It("still evacuates the rest and returns the error", func() {
+				Expect(depotClient.Evacuate(logger)).To(MatchError("boom!"))
+				Expect(containerStore.EvacuateCallCount()).To(Equal(2))
+			})
+		})
+	})
+
+	Describe("DeleteContainers", func() {
+		var tags executor.Tags
+
+		BeforeEach(func() {
+			tags = executor.Tags{"app": "some-app"}
+			containerStore.ListReturns([]executor.Container{
+				{Guid: "guid-1", Tags: executor.Tags{"app": "some-app", "index": "0"}},
+				{Guid: "guid-2", Tags: executor.Tags{"app": "other-app"}},
+				{Guid: "guid-3", Tags: executor.Tags{"app": "some-app", "index": "1"}},
+			})
+			containerStore.DestroyStub = func(logger lager.Logger, guid string) error {
+				if guid == "guid-3" {
+					return errors.New("boom!")
+				}
+				return nil
+			}
+		})
+
+		It("destroys the matching containers and reports each result", func() {
+			errs := depotClient.DeleteContainers(logger, tags)
+			Expect(errs).To(Equal(map[string]error{
+				"guid-1": nil,
+				"guid-3": errors.New("boom!"),
+			}))
+			Expect(containerStore.DestroyCallCount()).To(Equal(2))
+		})
+
+		Context("when no tags are given", func() {
+			BeforeEach(func() {
+				tags = executor.Tags{}
+			})
+
+			It("destroys nothing", func() {
+				Expect(depotClient.DeleteContainers(logger, tags)).To(BeEmpty())
+				Expect(containerStore.DestroyCallCount()).To(Equal(0))
+			})
+		})
+	})
+
 	Describe("GetContainer", func() {
 		var container executor.Container
 
@@ -658,6 +1235,41 @@ var _ = Describe("Depot", func() {
 		})
 	})
 
+	Describe("Capacity", func() {
+		BeforeEach(func() {
+			containerStore.RemainingResourcesReturns(executor.NewExecutorResources(256, 512, 1))
+			containerStore.MetricsReturns(map[string]executor.ContainerMetrics{
+				"a-guid": {MemoryUsageInBytes: 100, DiskUsageInBytes: 200},
+				"b-guid": {MemoryUsageInBytes: 300, DiskUsageInBytes: 400},
+			}, nil)
+		})
+
+		It("reports the total, allocated and in use resources", func() {
+			capacity, err := depotClient.Capacity(logger)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(capacity).To(Equal(executor.ExecutorCapacity{
+				Total:     resources,
+				Allocated: executor.NewExecutorResources(768, 512, 2),
+				InUse: executor.ResourceUsage{
+					MemoryInBytes: 400,
+					DiskInBytes:   600,
+					Containers:    2,
+				},
+			}))
+		})
+
+		Context("when getting the container metrics fails", func() {
+			BeforeEach(func() {
+				containerStore.MetricsReturns(nil, errors.New("boom"))
+			})
+
+			It("returns the error", func() {
+				_, err := depotClient.Capacity(logger)
+				Expect(err).To(MatchError("boom"))
+			})
+		})
+	})
+
 	Describe("VolumeDrivers", func() {
 		Context("when getting volume drivers succeeds", func() {
 			BeforeEach(func() {
@@ -687,6 +1299,128 @@ var _ = Describe("Depot", func() {
 			})
 		})
 	})
+
+	Describe("PrewarmCache", func() {
+		It("fetches each asset into the cache in the form it will be used", func() {
+			cachedDownloader.FetchReturns(ioutil.NopCloser(strings.NewReader("buildpack")), 9, nil)
+			cachedDownloader.FetchAsDirectoryReturns("/cache/lifecycle", 5, nil)
+
+			errs := depotClient.PrewarmCache(logger, []executor.CacheAsset{
+				{From: "http://example.com/buildpack.zip", CacheKey: "buildpack", ChecksumAlgorithm: "sha256", ChecksumValue: "abc"},
+				{From: "http://example.com/lifecycle.tgz", CacheKey: "lifecycle", AsDirectory: true},
+			})
+			Expect(errs).To(Equal(map[string]error{"buildpack": nil, "lifecycle": nil}))
+
+			Expect(cachedDownloader.FetchCallCount()).To(Equal(1))
+			_, fetchURL, cacheKey, checksum, _ := cachedDownloader.FetchArgsForCall(0)
+			Expect(fetchURL).To(Equal(&url.URL{Scheme: "http", Host: "example.com", Path: "/buildpack.zip"}))
+			Expect(cacheKey).To(Equal("buildpack"))
+			Expect(checksum).To(Equal(cacheddownloader.ChecksumInfoType{Algorithm: "sha256", Value: "abc"}))
+
+			Expect(cachedDownloader.FetchAsDirectoryCallCount()).To(Equal(1))
+			Expect(cachedDownloader.CloseDirectoryCallCount()).To(Equal(1))
+			_, cacheKey, dir := cachedDownloader.CloseDirectoryArgsForCall(0)
+			Expect(cacheKey).To(Equal("lifecycle"))
+			Expect(dir).To(Equal("/cache/lifecycle"))
+		})
+
+		It("reports the assets that failed", func() {
+			cachedDownloader.FetchReturns(nil, 0, errors.New("boom"))
+
+			errs := depotClient.PrewarmCache(logger, []executor.CacheAsset{
+				{From: "http://example.com/buildpack.zip", CacheKey: "buildpack"},
+				{From: "not a url", CacheKey: "invalid"},
+			})
+			Expect(errs).To(HaveLen(2))
+			Expect(errs["buildpack"]).To(MatchError("boom"))
+			Expect(errs["invalid"]).To(Equal(executor.ErrCacheAssetInvalid))
+		})
+	})
+
+	Describe("HealthcheckHistory", func() {
+		It("keeps the most recent health check results, oldest first", func() {
+			for i := 0; i < depot.HealthcheckHistorySize+2; i++ {
+				depotClient.RecordHealthcheck(logger, executor.HealthcheckResult{CheckedAt: int64(i)})
+			}
+
+			history := depotClient.HealthcheckHistory(logger)
+			Expect(history).To(HaveLen(depot.HealthcheckHistorySize))
+			Expect(history[0].CheckedAt).To(Equal(int64(2)))
+			Expect(history[depot.HealthcheckHistorySize-1].CheckedAt).To(Equal(int64(depot.HealthcheckHistorySize + 1)))
+		})
+	})
+
+	Describe("allocating while unhealthy", func() {
+		var requests []executor.AllocationRequest
+
+		BeforeEach(func() {
+			requests = []executor.AllocationRequest{newAllocationRequest("guid-1")}
+		})
+
+		JustBeforeEach(func() {
+			depotClient.SetHealthy(logger, false)
+		})
+
+		It("fails new allocations", func() {
+			failures, err := depotClient.AllocateContainers(logger, requests)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0].ErrorMsg).To(Equal(executor.ErrUnhealthy.Error()))
+			Expect(containerStore.ReserveCallCount()).To(Equal(0))
+		})
+
+		It("fails new atomic allocations", func() {
+			failures, err := depotClient.AllocateContainersAtomically(logger, requests)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0].ErrorMsg).To(Equal(executor.ErrUnhealthy.Error()))
+			Expect(containerStore.ReserveAllCallCount()).To(Equal(0))
+		})
+
+		Context("when rejecting allocations while unhealthy is disabled", func() {
+			BeforeEach(func() {
+				rejectWhenUnhealthy = false
+			})
+
+			It("allocates the containers", func() {
+				failures, err := depotClient.AllocateContainers(logger, requests)
+				Expect(err).NotTo(HaveOccurred())
+				Expect(failures).To(BeEmpty())
+				Expect(containerStore.ReserveCallCount()).To(Equal(1))
+			})
+		})
+	})
+
+	Describe("Drain", func() {
+		JustBeforeEach(func() {
+			depotClient.Drain(logger)
+		})
+
+		It("reports the executor as unhealthy", func() {
+			Expect(depotClient.Healthy(logger)).To(BeFalse())
+		})
+
+		It("stays unhealthy when the garden health check passes", func() {
+			depotClient.SetHealthy(logger, true)
+			Expect(depotClient.Healthy(logger)).To(BeFalse())
+		})
+
+		It("fails new allocations", func() {
+			failures, err := depotClient.AllocateContainers(logger, []executor.AllocationRequest{
+				newAllocationRequest("guid-1"),
+			})
+			Expect(err).NotTo(HaveOccurred())
+			Expect(failures).To(HaveLen(1))
+			Expect(failures[0].ErrorMsg).To(Equal(executor.ErrExecutorDraining.Error()))
+			Expect(containerStore.ReserveCallCount()).To(Equal(0))
+		})
+
+		It("rejects new runs", func() {
+			err := depotClient.RunContainer(logger, newRunRequest("guid-1"))
+			Expect(err).To(Equal(executor.ErrExecutorDraining))
+			Expect(containerStore.InitializeCallCount()).To(Equal(0))
+		})
+	})
 })
 
 func convertSliceToMap(containers []executor.Container) map[string]executor.Container {
@@ -0,0 +1,77 @@
+package downloadcache
+
+import (
+	"io"
+	"net/url"
+	"sync"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	DownloadCacheHits   = "DownloadCacheHits"
+	DownloadCacheMisses = "DownloadCacheMisses"
+)
+
+type instrumentedCachedDownloader struct {
+	cacheddownloader.CachedDownloader
+	metronClient loggregator_v2.Client
+
+	lock   sync.Mutex
+	hits   int
+	misses int
+}
+
+// NewInstrumented wraps cachedDownloader so that the number of fetches served
+// from the cache and the number that had to download the asset are sent as
+// DownloadCacheHits and DownloadCacheMisses. The cached downloader reports a
+// downloaded size of zero when nothing was transferred, which is counted as a
+// hit.
+//
+// The size limit, least-recently-used eviction and reference counting of
+// entries in use are left to the cache the cached downloader was built with.
+func NewInstrumented(cachedDownloader cacheddownloader.CachedDownloader, metronClient loggregator_v2.Client) cacheddownloader.CachedDownloader {
+	return &instrumentedCachedDownloader{
+		CachedDownloader: cachedDownloader,
+		metronClient:     metronClient,
+	}
+}
+
+func (c *instrumentedCachedDownloader) Fetch(logger lager.Logger, urlToFetch *url.URL, cacheKey string, checksum cacheddownloader.ChecksumInfoType, cancelChan <-chan struct{}) (io.ReadCloser, int64, error) {
+	stream, size, err := c.CachedDownloader.Fetch(logger, urlToFetch, cacheKey, checksum, cancelChan)
+	if err == nil {
+		c.record(logger, cacheKey, size)
+	}
+	return stream, size, err
+}
+
+func (c *instrumentedCachedDownloader) FetchAsDirectory(logger lager.Logger, urlToFetch *url.URL, cacheKey string, checksum cacheddownloader.ChecksumInfoType, cancelChan <-chan struct{}) (string, int64, error) {
+	dir, size, err := c.CachedDownloader.FetchAsDirectory(logger, urlToFetch, cacheKey, checksum, cancelChan)
+	if err == nil {
+		c.record(logger, cacheKey, size)
+	}
+	return dir, size, err
+}
+
+func (c *instrumentedCachedDownloader) record(logger lager.Logger, cacheKey string, size int64) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	// uncached downloads have no key and never hit the cache
+	if cacheKey == "" || size != 0 {
+		c.misses++
+		err := c.metronClient.SendMetric(DownloadCacheMisses, c.misses)
+		if err != nil {
+			logger.Error("failed-to-send-download-cache-misses-metric", err)
+		}
+		return
+	}
+
+	c.hits++
+	err := c.metronClient.SendMetric(DownloadCacheHits, c.hits)
+	if err != nil {
+		logger.Error("failed-to-send-download-cache-hits-metric", err)
+	}
+}
@@ -0,0 +1,77 @@
+package downloadcache_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"strings"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/cacheddownloader/cacheddownloaderfakes"
+	"code.cloudfoundry.org/executor/depot/downloadcache"
+	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Instrumented CachedDownloader", func() {
+	var (
+		logger           *lagertest.TestLogger
+		fakeCache        *cacheddownloaderfakes.FakeCachedDownloader
+		fakeMetronClient *mfakes.FakeClient
+		cache            cacheddownloader.CachedDownloader
+		downloadURL      *url.URL
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeCache = new(cacheddownloaderfakes.FakeCachedDownloader)
+		fakeMetronClient = new(mfakes.FakeClient)
+		cache = downloadcache.NewInstrumented(fakeCache, fakeMetronClient)
+
+		var err error
+		downloadURL, err = url.Parse("http://example.com/droplet.tgz")
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	It("counts fetches that downloaded the asset as misses", func() {
+		fakeCache.FetchReturns(ioutil.NopCloser(strings.NewReader("droplet")), 7, nil)
+
+		_, size, err := cache.Fetch(logger, downloadURL, "droplet", cacheddownloader.ChecksumInfoType{}, nil)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(BeEquivalentTo(7))
+
+		Expect(fakeMetronClient.SendMetricCallCount()).To(Equal(1))
+		name, value := fakeMetronClient.SendMetricArgsForCall(0)
+		Expect(name).To(Equal(downloadcache.DownloadCacheMisses))
+		Expect(value).To(Equal(1))
+	})
+
+	It("counts fetches served from the cache as hits", func() {
+		fakeCache.FetchAsDirectoryReturns("/some/dir", 0, nil)
+
+		cache.FetchAsDirectory(logger, downloadURL, "lifecycle", cacheddownloader.ChecksumInfoType{}, nil)
+		cache.FetchAsDirectory(logger, downloadURL, "lifecycle", cacheddownloader.ChecksumInfoType{}, nil)
+
+		Expect(fakeMetronClient.SendMetricCallCount()).To(Equal(2))
+		name, value := fakeMetronClient.SendMetricArgsForCall(1)
+		Expect(name).To(Equal(downloadcache.DownloadCacheHits))
+		Expect(value).To(Equal(2))
+	})
+
+	It("does not count failed fetches", func() {
+		fakeCache.FetchReturns(nil, 0, errors.New("boom"))
+
+		_, _, err := cache.Fetch(logger, downloadURL, "droplet", cacheddownloader.ChecksumInfoType{}, nil)
+		Expect(err).To(MatchError("boom"))
+		Expect(fakeMetronClient.SendMetricCallCount()).To(BeZero())
+	})
+
+	It("passes other calls through", func() {
+		err := cache.CloseDirectory(logger, "lifecycle", "/some/dir")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeCache.CloseDirectoryCallCount()).To(Equal(1))
+	})
+})
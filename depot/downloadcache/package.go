@@ -0,0 +1 @@
+package downloadcache // import "code.cloudfoundry.org/executor/depot/downloadcache"
@@ -27,6 +27,21 @@ type FakeHub struct {
 	closeReturns     struct {
 		result1 error
 	}
+	DroppedEventsStub        func() uint64
+	droppedEventsMutex       sync.RWMutex
+	droppedEventsArgsForCall []struct{}
+	droppedEventsReturns     struct {
+		result1 uint64
+	}
+	SubscribeWithOptionsStub        func(arg1 event.SubscribeOptions) (executor.EventSource, error)
+	subscribeWithOptionsMutex       sync.RWMutex
+	subscribeWithOptionsArgsForCall []struct {
+		arg1 event.SubscribeOptions
+	}
+	subscribeWithOptionsReturns struct {
+		result1 executor.EventSource
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -106,6 +121,65 @@ func (fake *FakeHub) CloseReturns(result1 error) {
 	}{result1}
 }
 
+func (fake *FakeHub) DroppedEvents() uint64 {
+	fake.droppedEventsMutex.Lock()
+	fake.droppedEventsArgsForCall = append(fake.droppedEventsArgsForCall, struct{}{})
+	fake.recordInvocation("DroppedEvents", []interface{}{})
+	fake.droppedEventsMutex.Unlock()
+	if fake.DroppedEventsStub != nil {
+		return fake.DroppedEventsStub()
+	} else {
+		return fake.droppedEventsReturns.result1
+	}
+}
+
+func (fake *FakeHub) DroppedEventsCallCount() int {
+	fake.droppedEventsMutex.RLock()
+	defer fake.droppedEventsMutex.RUnlock()
+	return len(fake.droppedEventsArgsForCall)
+}
+
+func (fake *FakeHub) DroppedEventsReturns(result1 uint64) {
+	fake.DroppedEventsStub = nil
+	fake.droppedEventsReturns = struct {
+		result1 uint64
+	}{result1}
+}
+
+func (fake *FakeHub) SubscribeWithOptions(arg1 event.SubscribeOptions) (executor.EventSource, error) {
+	fake.subscribeWithOptionsMutex.Lock()
+	fake.subscribeWithOptionsArgsForCall = append(fake.subscribeWithOptionsArgsForCall, struct {
+		arg1 event.SubscribeOptions
+	}{arg1})
+	fake.recordInvocation("SubscribeWithOptions", []interface{}{arg1})
+	fake.subscribeWithOptionsMutex.Unlock()
+	if fake.SubscribeWithOptionsStub != nil {
+		return fake.SubscribeWithOptionsStub(arg1)
+	} else {
+		return fake.subscribeWithOptionsReturns.result1, fake.subscribeWithOptionsReturns.result2
+	}
+}
+
+func (fake *FakeHub) SubscribeWithOptionsCallCount() int {
+	fake.subscribeWithOptionsMutex.RLock()
+	defer fake.subscribeWithOptionsMutex.RUnlock()
+	return len(fake.subscribeWithOptionsArgsForCall)
+}
+
+func (fake *FakeHub) SubscribeWithOptionsArgsForCall(i int) event.SubscribeOptions {
+	fake.subscribeWithOptionsMutex.RLock()
+	defer fake.subscribeWithOptionsMutex.RUnlock()
+	return fake.subscribeWithOptionsArgsForCall[i].arg1
+}
+
+func (fake *FakeHub) SubscribeWithOptionsReturns(result1 executor.EventSource, result2 error) {
+	fake.SubscribeWithOptionsStub = nil
+	fake.subscribeWithOptionsReturns = struct {
+		result1 executor.EventSource
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeHub) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -115,6 +189,10 @@ func (fake *FakeHub) Invocations() map[string][][]interface{} {
 	defer fake.subscribeMutex.RUnlock()
 	fake.closeMutex.RLock()
 	defer fake.closeMutex.RUnlock()
+	fake.droppedEventsMutex.RLock()
+	defer fake.droppedEventsMutex.RUnlock()
+	fake.subscribeWithOptionsMutex.RLock()
+	defer fake.subscribeWithOptionsMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -1,59 +1,233 @@
 package event
 
 import (
-	"code.cloudfoundry.org/eventhub"
+	"errors"
+	"sync"
+	"sync/atomic"
+
 	"code.cloudfoundry.org/executor"
 )
 
 const SUBSCRIBER_BUFFER = 1024
 
+var (
+	ErrReadFromClosedSource  = errors.New("read from closed source")
+	ErrSubscribedToClosedHub = errors.New("subscribed to closed hub")
+	ErrSlowConsumer          = errors.New("slow consumer")
+)
+
+// OverflowPolicy decides what happens when an event is emitted to a
+// subscriber whose buffer is full. Either way the event the subscriber
+// misses is counted as dropped. The zero value disconnects the subscriber.
+type OverflowPolicy string
+
+const (
+	// OverflowDisconnect closes the subscriber; its Next returns
+	// ErrSlowConsumer once it has read the events already buffered.
+	OverflowDisconnect OverflowPolicy = "disconnect"
+	// OverflowDropOldest discards the subscriber's oldest buffered event to
+	// make room.
+	OverflowDropOldest OverflowPolicy = "drop-oldest"
+)
+
 //go:generate counterfeiter -o fakes/fake_hub.go . Hub
 type Hub interface {
 	Emit(executor.Event)
 	Subscribe() (executor.EventSource, error)
+	SubscribeWithOptions(SubscribeOptions) (executor.EventSource, error)
 	Close() error
+	DroppedEvents() uint64
 }
 
+// SubscribeOptions tune a single subscription. An empty OverflowPolicy uses
+// the hub's, so a subscriber that can live with gaps, rather than having to
+// resync after being disconnected, opts into OverflowDropOldest here.
+//
+// Container metrics events are frequent and only of interest to a few
+// consumers, so they are delivered only to subscribers that set
+// ContainerMetrics.
+type SubscribeOptions struct {
+	OverflowPolicy   OverflowPolicy
+	ContainerMetrics bool
+}
+
+// NewHub returns a hub that buffers SUBSCRIBER_BUFFER events for each
+// subscriber and disconnects subscribers that fall further behind.
 func NewHub() Hub {
+	return NewBoundedHub(SUBSCRIBER_BUFFER, OverflowDisconnect)
+}
+
+// NewBoundedHub returns a hub that buffers bufferSize events for each
+// subscriber, applying policy when a buffer is full unless the subscriber
+// chose its own. Emit never blocks on a slow subscriber.
+func NewBoundedHub(bufferSize int, policy OverflowPolicy) Hub {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
 	return &hub{
-		rawHub: eventhub.NewNonBlocking(SUBSCRIBER_BUFFER),
+		bufferSize:  bufferSize,
+		policy:      policy,
+		subscribers: map[*source]struct{}{},
 	}
 }
 
 type hub struct {
-	rawHub eventhub.Hub
+	bufferSize int
+	policy     OverflowPolicy
+	dropped    uint64
+
+	lock        sync.Mutex
+	subscribers map[*source]struct{}
+	closed      bool
 }
 
 func (hub *hub) Subscribe() (executor.EventSource, error) {
-	rawSource, err := hub.rawHub.Subscribe()
-	if err != nil {
-		return nil, err
+	return hub.SubscribeWithOptions(SubscribeOptions{})
+}
+
+func (hub *hub) SubscribeWithOptions(opts SubscribeOptions) (executor.EventSource, error) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	if hub.closed {
+		return nil, ErrSubscribedToClosedHub
+	}
+
+	policy := opts.OverflowPolicy
+	if policy == "" {
+		policy = hub.policy
+	}
+
+	sub := &source{
+		hub:              hub,
+		policy:           policy,
+		containerMetrics: opts.ContainerMetrics,
+		events:           make(chan executor.Event, hub.bufferSize),
 	}
+	hub.subscribers[sub] = struct{}{}
 
-	return executorSource{rawSource}, nil
+	return sub, nil
 }
 
 func (hub *hub) Emit(ev executor.Event) {
-	hub.rawHub.Emit(ev)
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	_, isMetrics := ev.(executor.ContainerMetricsEvent)
+
+	for sub := range hub.subscribers {
+		if isMetrics && !sub.containerMetrics {
+			continue
+		}
+
+		select {
+		case sub.events <- ev:
+			continue
+		default:
+		}
+
+		atomic.AddUint64(&hub.dropped, 1)
+
+		switch sub.policy {
+		case OverflowDropOldest:
+			select {
+			case <-sub.events:
+			default:
+			}
+
+			select {
+			case sub.events <- ev:
+			default:
+			}
+
+		default:
+			hub.remove(sub, ErrSlowConsumer)
+		}
+	}
 }
 
 func (hub *hub) Close() error {
-	return hub.rawHub.Close()
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	if hub.closed {
+		return nil
+	}
+
+	hub.closed = true
+	for sub := range hub.subscribers {
+		hub.remove(sub, ErrReadFromClosedSource)
+	}
+	return nil
+}
+
+// DroppedEvents returns the number of events subscribers have missed because
+// their buffers were full.
+func (hub *hub) DroppedEvents() uint64 {
+	return atomic.LoadUint64(&hub.dropped)
+}
+
+// remove must be called with the hub's lock held.
+func (hub *hub) remove(sub *source, err error) {
+	if _, ok := hub.subscribers[sub]; !ok {
+		return
+	}
+
+	delete(hub.subscribers, sub)
+	sub.err.Store(err)
+	close(sub.events)
 }
 
-type executorSource struct {
-	rawSource eventhub.Source
+type source struct {
+	hub              *hub
+	policy           OverflowPolicy
+	containerMetrics bool
+	events           chan executor.Event
+	err              atomic.Value
 }
 
-func (source executorSource) Next() (executor.Event, error) {
-	ev, err := source.rawSource.Next()
-	if err != nil {
-		return nil, err
+func (source *source) Next() (executor.Event, error) {
+	ev, ok := <-source.events
+	if !ok {
+		return nil, source.err.Load().(error)
 	}
 
-	return ev.(executor.Event), nil
+	return ev, nil
+}
+
+func (source *source) Close() error {
+	source.hub.lock.Lock()
+	defer source.hub.lock.Unlock()
+
+	source.hub.remove(source, ErrReadFromClosedSource)
+	return nil
+}
+
+// NewFilteredSource returns a source that only yields the events of source
+// matching filter.
+func NewFilteredSource(source executor.EventSource, filter executor.EventFilter) executor.EventSource {
+	return filteredSource{source: source, filter: filter}
+}
+
+type filteredSource struct {
+	source executor.EventSource
+	filter executor.EventFilter
+}
+
+func (source filteredSource) Next() (executor.Event, error) {
+	for {
+		ev, err := source.source.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		if source.filter.Matches(ev) {
+			return ev, nil
+		}
+	}
 }
 
-func (source executorSource) Close() error {
-	return source.rawSource.Close()
+func (source filteredSource) Close() error {
+	return source.source.Close()
 }
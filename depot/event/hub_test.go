@@ -0,0 +1,157 @@
+package event_test
+
+import (
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Hub", func() {
+	var (
+		policy event.OverflowPolicy
+		hub    event.Hub
+		source executor.EventSource
+	)
+
+	eventFor := func(guid string) executor.Event {
+		return executor.NewContainerReservedEvent(executor.Container{Guid: guid})
+	}
+
+	JustBeforeEach(func() {
+		hub = event.NewBoundedHub(2, policy)
+
+		var err error
+		source, err = hub.Subscribe()
+		Expect(err).NotTo(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		hub.Close()
+	})
+
+	Context("when a subscriber keeps up", func() {
+		BeforeEach(func() {
+			policy = event.OverflowDisconnect
+		})
+
+		It("delivers every event", func() {
+			hub.Emit(eventFor("guid-1"))
+			hub.Emit(eventFor("guid-2"))
+
+			Expect(source.Next()).To(Equal(eventFor("guid-1")))
+			Expect(source.Next()).To(Equal(eventFor("guid-2")))
+			Expect(hub.DroppedEvents()).To(BeZero())
+		})
+	})
+
+	Context("when a subscriber falls behind and the policy is disconnect", func() {
+		BeforeEach(func() {
+			policy = event.OverflowDisconnect
+		})
+
+		It("disconnects it after the buffered events and counts the drop", func() {
+			hub.Emit(eventFor("guid-1"))
+			hub.Emit(eventFor("guid-2"))
+			hub.Emit(eventFor("guid-3"))
+
+			Expect(source.Next()).To(Equal(eventFor("guid-1")))
+			Expect(source.Next()).To(Equal(eventFor("guid-2")))
+			_, err := source.Next()
+			Expect(err).To(Equal(event.ErrSlowConsumer))
+			Expect(hub.DroppedEvents()).To(BeEquivalentTo(1))
+		})
+	})
+
+	Context("when a subscriber falls behind and the policy is drop-oldest", func() {
+		BeforeEach(func() {
+			policy = event.OverflowDropOldest
+		})
+
+		It("discards its oldest event and counts the drop", func() {
+			hub.Emit(eventFor("guid-1"))
+			hub.Emit(eventFor("guid-2"))
+			hub.Emit(eventFor("guid-3"))
+
+			Expect(source.Next()).To(Equal(eventFor("guid-2")))
+			Expect(source.Next()).To(Equal(eventFor("guid-3")))
+			Expect(hub.DroppedEvents()).To(BeEquivalentTo(1))
+		})
+	})
+
+	Context("when a subscriber falls behind and the policy is unset", func() {
+		BeforeEach(func() {
+			policy = ""
+		})
+
+		It("disconnects it", func() {
+			hub.Emit(eventFor("guid-1"))
+			hub.Emit(eventFor("guid-2"))
+			hub.Emit(eventFor("guid-3"))
+
+			Expect(source.Next()).To(Equal(eventFor("guid-1")))
+			Expect(source.Next()).To(Equal(eventFor("guid-2")))
+			_, err := source.Next()
+			Expect(err).To(Equal(event.ErrSlowConsumer))
+		})
+	})
+
+	Context("when a subscriber that opted into drop-oldest falls behind", func() {
+		BeforeEach(func() {
+			policy = event.OverflowDisconnect
+		})
+
+		It("discards its oldest event and leaves the other subscribers to the hub's policy", func() {
+			droppingSource, err := hub.SubscribeWithOptions(event.SubscribeOptions{OverflowPolicy: event.OverflowDropOldest})
+			Expect(err).NotTo(HaveOccurred())
+
+			hub.Emit(eventFor("guid-1"))
+			hub.Emit(eventFor("guid-2"))
+			hub.Emit(eventFor("guid-3"))
+
+			Expect(droppingSource.Next()).To(Equal(eventFor("guid-2")))
+			Expect(droppingSource.Next()).To(Equal(eventFor("guid-3")))
+
+			Expect(source.Next()).To(Equal(eventFor("guid-1")))
+			Expect(source.Next()).To(Equal(eventFor("guid-2")))
+			_, err = source.Next()
+			Expect(err).To(Equal(event.ErrSlowConsumer))
+		})
+	})
+
+	Context("when a container metrics event is emitted", func() {
+		BeforeEach(func() {
+			policy = event.OverflowDisconnect
+		})
+
+		It("delivers it only to subscribers that asked for container metrics", func() {
+			metricsSource, err := hub.SubscribeWithOptions(event.SubscribeOptions{ContainerMetrics: true})
+			Expect(err).NotTo(HaveOccurred())
+
+			metricsEvent := executor.NewContainerMetricsEvent("guid-1", executor.Metrics{}, 0)
+			hub.Emit(metricsEvent)
+			hub.Emit(eventFor("guid-2"))
+
+			Expect(metricsSource.Next()).To(Equal(metricsEvent))
+			Expect(metricsSource.Next()).To(Equal(eventFor("guid-2")))
+			Expect(source.Next()).To(Equal(eventFor("guid-2")))
+		})
+	})
+
+	Context("when the hub is closed", func() {
+		BeforeEach(func() {
+			policy = event.OverflowDisconnect
+		})
+
+		It("closes its subscribers and refuses new ones", func() {
+			Expect(hub.Close()).To(Succeed())
+
+			_, err := source.Next()
+			Expect(err).To(Equal(event.ErrReadFromClosedSource))
+
+			_, err = hub.Subscribe()
+			Expect(err).To(Equal(event.ErrSubscribedToClosedHub))
+		})
+	})
+})
@@ -0,0 +1,274 @@
+package event
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	defaultJournalReadLimit = 100
+	maxJournalReadLimit     = 1000
+)
+
+// JournalEntry is one line of the event journal. Time is in nanoseconds since
+// the epoch.
+type JournalEntry struct {
+	Time  int64              `json:"time"`
+	Type  executor.EventType `json:"type"`
+	Guid  string             `json:"guid"`
+	Event json.RawMessage    `json:"event"`
+}
+
+// Journal appends every lifecycle event emitted on the hub to a file, one
+// JSON line per event, for post-mortem analysis. Events are journaled with
+// their containers' run info redacted. Once the file would grow past maxBytes
+// it is moved aside to path.1, replacing any earlier one, and a new file is
+// started.
+//
+// Journal must be run to collect events from the hub.
+type Journal struct {
+	logger   lager.Logger
+	hub      Hub
+	clock    clock.Clock
+	path     string
+	maxBytes int64
+
+	lock sync.Mutex
+	file *os.File
+	size int64
+
+	// rotateLock is held for reading while Events reads the files, and for
+	// writing while they are rotated.
+	rotateLock sync.RWMutex
+}
+
+func NewJournal(logger lager.Logger, hub Hub, clock clock.Clock, path string, maxBytes int64) (*Journal, error) {
+	j := &Journal{
+		logger:   logger.Session("event-journal", lager.Data{"path": path}),
+		hub:      hub,
+		clock:    clock,
+		path:     path,
+		maxBytes: maxBytes,
+	}
+
+	err := j.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func (j *Journal) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	defer j.close()
+
+	// the journal is best effort, so it would rather miss events than be
+	// disconnected
+	sub, err := subscribe(j.logger, j.hub, j.clock, SubscribeOptions{OverflowPolicy: OverflowDropOldest})
+	if err != nil {
+		j.logger.Error("failed-to-subscribe", err)
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sub.run(func(ev executor.Event) {
+			lifecycleEvent, ok := ev.(executor.LifecycleEvent)
+			if !ok {
+				return
+			}
+
+			err := j.append(ev, lifecycleEvent.Container().Guid)
+			if err != nil {
+				j.logger.Error("failed-to-journal-event", err)
+			}
+		})
+	}()
+
+	close(ready)
+
+	<-signals
+	sub.close()
+	<-done
+
+	return nil
+}
+
+func (j *Journal) append(ev executor.Event, guid string) error {
+	payload, err := json.Marshal(Redact(ev))
+	if err != nil {
+		return err
+	}
+
+	line, err := json.Marshal(JournalEntry{
+		Time:  j.clock.Now().UnixNano(),
+		Type:  ev.EventType(),
+		Guid:  guid,
+		Event: payload,
+	})
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	if j.size > 0 && j.size+int64(len(line)) > j.maxBytes {
+		err = j.rotate()
+		if err != nil {
+			return err
+		}
+	}
+
+	n, err := j.file.Write(line)
+	j.size += int64(n)
+	return err
+}
+
+// Events returns the last limit journaled events for guid, oldest first.
+// Events are appended while the files are read, and only rotation waits for
+// the read to finish.
+func (j *Journal) Events(guid string, limit int) ([]JournalEntry, error) {
+	j.rotateLock.RLock()
+	defer j.rotateLock.RUnlock()
+
+	entries := []JournalEntry{}
+	for _, path := range []string{j.rotatedPath(), j.path} {
+		var err error
+		entries, err = readJournal(path, guid, limit, entries)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return entries, nil
+}
+
+// ServeHTTP responds with the journaled events for the guid query parameter,
+// limited to the last limit of them (100 by default, and at most 1000).
+func (j *Journal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := j.logger.Session("serve")
+
+	guid := r.URL.Query().Get("guid")
+	if guid == "" {
+		http.Error(w, "missing guid", http.StatusBadRequest)
+		return
+	}
+
+	limit := defaultJournalReadLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit < 1 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if limit > maxJournalReadLimit {
+		limit = maxJournalReadLimit
+	}
+
+	entries, err := j.Events(guid, limit)
+	if err != nil {
+		logger.Error("failed-to-read-journal", err, lager.Data{"guid": guid})
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
+
+// readJournal appends the entries for guid in the file at path to entries,
+// keeping only the last limit of them.
+func readJournal(path, guid string, limit int, entries []JournalEntry) ([]JournalEntry, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return entries, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry JournalEntry
+		err := json.Unmarshal(scanner.Bytes(), &entry)
+		if err != nil {
+			// a torn line from a crash mid-write; skip it
+			continue
+		}
+		if entry.Guid != guid {
+			continue
+		}
+
+		entries = append(entries, entry)
+		if len(entries) > limit {
+			entries = entries[1:]
+		}
+	}
+
+	return entries, scanner.Err()
+}
+
+func (j *Journal) rotatedPath() string {
+	return j.path + ".1"
+}
+
+// rotate must be called with the lock held. It waits for any reads of the
+// files to finish.
+func (j *Journal) rotate() error {
+	j.rotateLock.Lock()
+	defer j.rotateLock.Unlock()
+
+	err := j.file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(j.path, j.rotatedPath())
+	openErr := j.open()
+	if err != nil {
+		return err
+	}
+	return openErr
+}
+
+func (j *Journal) open() error {
+	file, err := os.OpenFile(j.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	j.file = file
+	j.size = info.Size()
+	return nil
+}
+
+func (j *Journal) close() {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+
+	err := j.file.Close()
+	if err != nil {
+		j.logger.Error("failed-to-close-journal", err)
+	}
+}
@@ -0,0 +1,191 @@
+package event_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Journal", func() {
+	var (
+		dir       string
+		path      string
+		maxBytes  int64
+		hub       event.Hub
+		fakeClock *fakeclock.FakeClock
+		logger    *lagertest.TestLogger
+		journal   *event.Journal
+		process   ifrit.Process
+	)
+
+	BeforeEach(func() {
+		var err error
+		dir, err = ioutil.TempDir("", "event-journal")
+		Expect(err).NotTo(HaveOccurred())
+
+		path = filepath.Join(dir, "events.log")
+		maxBytes = 1024 * 1024
+		hub = event.NewHub()
+		fakeClock = fakeclock.NewFakeClock(time.Unix(0, 123))
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	JustBeforeEach(func() {
+		var err error
+		journal, err = event.NewJournal(logger, hub, fakeClock, path, maxBytes)
+		Expect(err).NotTo(HaveOccurred())
+		process = ginkgomon.Invoke(journal)
+	})
+
+	AfterEach(func() {
+		ginkgomon.Interrupt(process)
+		hub.Close()
+		os.RemoveAll(dir)
+	})
+
+	guidsOf := func(entries []event.JournalEntry) []string {
+		guids := []string{}
+		for _, entry := range entries {
+			guids = append(guids, entry.Guid)
+		}
+		return guids
+	}
+
+	It("journals lifecycle events and reads back the last ones for a guid", func() {
+		hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: "guid-1"}))
+		hub.Emit(executor.NewContainerMetricsEvent("guid-1", executor.Metrics{}, 0))
+		hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: "guid-2"}))
+		hub.Emit(executor.NewContainerRunningEvent(executor.Container{Guid: "guid-1"}))
+		hub.Emit(executor.NewContainerCompleteEvent(executor.Container{Guid: "guid-1"}))
+
+		Eventually(func() ([]event.JournalEntry, error) {
+			return journal.Events("guid-1", 10)
+		}).Should(HaveLen(3))
+
+		entries, err := journal.Events("guid-1", 2)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(HaveLen(2))
+		Expect(entries[0].Type).To(Equal(executor.EventTypeContainerRunning))
+		Expect(entries[1].Type).To(Equal(executor.EventTypeContainerComplete))
+		Expect(string(entries[1].Event)).To(HavePrefix(`{"container":{"guid":"guid-1"`))
+		Expect(entries[1].Time).To(BeEquivalentTo(123))
+	})
+
+	It("redacts the run info of the containers", func() {
+		hub.Emit(executor.NewContainerReservedEvent(executor.Container{
+			Guid:    "guid-1",
+			RunInfo: executor.RunInfo{ImagePassword: "secret"},
+		}))
+
+		Eventually(func() ([]event.JournalEntry, error) {
+			return journal.Events("guid-1", 10)
+		}).Should(HaveLen(1))
+
+		entries, err := journal.Events("guid-1", 10)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(string(entries[0].Event)).NotTo(ContainSubstring("secret"))
+	})
+
+	It("serves at most 1000 events however large the limit asked for", func() {
+		for i := 0; i < 1001; i++ {
+			hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: "guid-1"}))
+		}
+
+		Eventually(func() ([]event.JournalEntry, error) {
+			return journal.Events("guid-1", 2000)
+		}).Should(HaveLen(1001))
+
+		recorder := httptest.NewRecorder()
+		request, err := http.NewRequest("GET", "/events/journal?guid=guid-1&limit=5000", nil)
+		Expect(err).NotTo(HaveOccurred())
+		journal.ServeHTTP(recorder, request)
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		var entries []event.JournalEntry
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &entries)).To(Succeed())
+		Expect(entries).To(HaveLen(1000))
+	})
+
+	Context("when the hub closes the subscription", func() {
+		var disconnecting *disconnectingHub
+
+		BeforeEach(func() {
+			disconnecting = &disconnectingHub{Hub: hub}
+			hub = disconnecting
+		})
+
+		It("resubscribes and keeps journaling", func() {
+			Eventually(logger).Should(gbytes.Say("event-source-closed"))
+			Consistently(process.Wait()).ShouldNot(Receive())
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(disconnecting.Subscriptions).Should(BeEquivalentTo(2))
+
+			hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: "guid-1"}))
+			Eventually(func() ([]event.JournalEntry, error) {
+				return journal.Events("guid-1", 10)
+			}).Should(HaveLen(1))
+		})
+	})
+
+	Context("when the journal grows past its maximum size", func() {
+		BeforeEach(func() {
+			maxBytes = 1
+		})
+
+		It("rotates the file and still reads back across both", func() {
+			hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: "guid-1"}))
+			hub.Emit(executor.NewContainerRunningEvent(executor.Container{Guid: "guid-1"}))
+			hub.Emit(executor.NewContainerCompleteEvent(executor.Container{Guid: "guid-1"}))
+
+			Eventually(func() ([]event.JournalEntry, error) {
+				return journal.Events("guid-1", 10)
+			}).Should(HaveLen(2))
+
+			Expect(path + ".1").To(BeAnExistingFile())
+			entries, err := journal.Events("guid-1", 10)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(guidsOf(entries)).To(Equal([]string{"guid-1", "guid-1"}))
+			Expect(entries[1].Type).To(Equal(executor.EventTypeContainerComplete))
+		})
+	})
+})
+
+// disconnectingHub closes the first subscription as soon as it is made, as
+// the hub does to a subscriber that falls behind.
+type disconnectingHub struct {
+	event.Hub
+	subscriptions int32
+}
+
+func (h *disconnectingHub) Subscribe() (executor.EventSource, error) {
+	return h.SubscribeWithOptions(event.SubscribeOptions{})
+}
+
+func (h *disconnectingHub) SubscribeWithOptions(opts event.SubscribeOptions) (executor.EventSource, error) {
+	source, err := h.Hub.SubscribeWithOptions(opts)
+	if err == nil && atomic.AddInt32(&h.subscriptions, 1) == 1 {
+		source.Close()
+	}
+	return source, err
+}
+
+func (h *disconnectingHub) Subscriptions() int32 {
+	return atomic.LoadInt32(&h.subscriptions)
+}
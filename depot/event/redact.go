@@ -0,0 +1,38 @@
+package event
+
+import "code.cloudfoundry.org/executor"
+
+// Redact returns ev with the run info of its container cleared, for serving
+// outside the executor. Run info carries image credentials, environment
+// variables and download URLs, and clients that need it can fetch the
+// container from the API. Events without a container are returned as they
+// are.
+func Redact(ev executor.Event) executor.Event {
+	switch e := ev.(type) {
+	case executor.ContainerCompleteEvent:
+		e.RawContainer = redactContainer(e.RawContainer)
+		return e
+	case executor.ContainerRunningEvent:
+		e.RawContainer = redactContainer(e.RawContainer)
+		return e
+	case executor.ContainerReservedEvent:
+		e.RawContainer = redactContainer(e.RawContainer)
+		return e
+	case executor.ContainerDestroyedEvent:
+		e.RawContainer = redactContainer(e.RawContainer)
+		return e
+	case executor.ContainerOOMEvent:
+		e.RawContainer = redactContainer(e.RawContainer)
+		return e
+	case executor.AllocationExpiredEvent:
+		e.RawContainer = redactContainer(e.RawContainer)
+		return e
+	default:
+		return ev
+	}
+}
+
+func redactContainer(container executor.Container) executor.Container {
+	container.RunInfo = executor.RunInfo{}
+	return container
+}
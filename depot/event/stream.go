@@ -0,0 +1,235 @@
+package event
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+// HeartbeatEvent is the SSE event type of the keepalives sent on idle
+// streams. Heartbeats carry no ID, so they do not move a client's
+// Last-Event-ID.
+const HeartbeatEvent = "heartbeat"
+
+var ErrInvalidEventID = errors.New("invalid event ID")
+
+// FormatEventID returns the SSE ID of the seq'th event of the stream started
+// at epoch.
+func FormatEventID(epoch int64, seq uint64) string {
+	return fmt.Sprintf("%d-%d", epoch, seq)
+}
+
+// ParseEventID splits an SSE ID returned by FormatEventID.
+func ParseEventID(id string) (int64, uint64, error) {
+	i := strings.Index(id, "-")
+	if i < 0 {
+		return 0, 0, ErrInvalidEventID
+	}
+
+	epoch, err := strconv.ParseInt(id[:i], 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidEventID
+	}
+
+	seq, err := strconv.ParseUint(id[i+1:], 10, 64)
+	if err != nil {
+		return 0, 0, ErrInvalidEventID
+	}
+
+	return epoch, seq, nil
+}
+
+type sequencedEvent struct {
+	id    uint64
+	event executor.Event
+}
+
+// Stream serves the hub's events as Server-Sent Events, with their
+// containers' run info redacted. Each event is given an increasing ID and the
+// last bufferSize are kept, so a client that reconnects with a Last-Event-ID
+// header is sent the events it missed, as long as they are still buffered.
+//
+// IDs are prefixed with the time the stream was created, so a Last-Event-ID
+// from before the executor restarted is recognized, and the client is sent
+// every buffered event instead.
+//
+// When heartbeatInterval is positive, a heartbeat event is sent on any stream
+// that has been idle that long, so clients behind proxies and NATs can tell a
+// quiet stream from a dead one.
+//
+// Stream must be run to collect events from the hub.
+type Stream struct {
+	logger            lager.Logger
+	hub               Hub
+	clock             clock.Clock
+	bufferSize        int
+	heartbeatInterval time.Duration
+	epoch             int64
+
+	lock    sync.Mutex
+	lastID  uint64
+	buffer  []sequencedEvent
+	updated chan struct{}
+}
+
+func NewStream(logger lager.Logger, hub Hub, clock clock.Clock, bufferSize int, heartbeatInterval time.Duration) *Stream {
+	if bufferSize < 1 {
+		bufferSize = 1
+	}
+
+	return &Stream{
+		logger:            logger.Session("event-stream"),
+		hub:               hub,
+		clock:             clock,
+		bufferSize:        bufferSize,
+		heartbeatInterval: heartbeatInterval,
+		epoch:             clock.Now().UnixNano(),
+		updated:           make(chan struct{}),
+	}
+}
+
+func (s *Stream) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
+	sub, err := subscribe(s.logger, s.hub, s.clock, SubscribeOptions{})
+	if err != nil {
+		s.logger.Error("failed-to-subscribe", err)
+		return err
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		sub.run(s.append)
+	}()
+
+	close(ready)
+
+	<-signals
+	sub.close()
+	<-done
+
+	return nil
+}
+
+func (s *Stream) append(ev executor.Event) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.lastID++
+	s.buffer = append(s.buffer, sequencedEvent{id: s.lastID, event: ev})
+	if len(s.buffer) > s.bufferSize {
+		s.buffer = s.buffer[len(s.buffer)-s.bufferSize:]
+	}
+
+	close(s.updated)
+	s.updated = make(chan struct{})
+}
+
+// since returns the buffered events after lastID, and a channel closed when
+// more arrive.
+func (s *Stream) since(lastID uint64) ([]sequencedEvent, <-chan struct{}) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	events := []sequencedEvent{}
+	for _, ev := range s.buffer {
+		if ev.id > lastID {
+			events = append(events, ev)
+		}
+	}
+	return events, s.updated
+}
+
+// currentID returns the ID of the last event received.
+func (s *Stream) currentID() uint64 {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.lastID
+}
+
+func (s *Stream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := s.logger.Session("serve", lager.Data{"remote-addr": r.RemoteAddr})
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	lastID := s.currentID()
+	if header := r.Header.Get("Last-Event-ID"); header != "" {
+		epoch, seq, err := ParseEventID(header)
+		if err != nil {
+			http.Error(w, "invalid Last-Event-ID", http.StatusBadRequest)
+			return
+		}
+
+		if epoch == s.epoch && seq <= lastID {
+			lastID = seq
+		} else {
+			logger.Info("resetting-stream", lager.Data{"last-event-id": header})
+			lastID = 0
+		}
+	}
+
+	logger.Info("starting", lager.Data{"last-event-id": lastID})
+	defer logger.Info("complete")
+
+	var heartbeats <-chan time.Time
+	var heartbeatTimer clock.Timer
+	if s.heartbeatInterval > 0 {
+		heartbeatTimer = s.clock.NewTimer(s.heartbeatInterval)
+		defer heartbeatTimer.Stop()
+		heartbeats = heartbeatTimer.C()
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		events, updated := s.since(lastID)
+		for _, ev := range events {
+			lastID = ev.id
+			payload, err := json.Marshal(Redact(ev.event))
+			if err != nil {
+				logger.Error("failed-to-marshal-event", err, lager.Data{"event-id": ev.id})
+				continue
+			}
+
+			_, err = fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", FormatEventID(s.epoch, ev.id), ev.event.EventType(), payload)
+			if err != nil {
+				logger.Error("failed-to-write-event", err)
+				return
+			}
+		}
+		flusher.Flush()
+
+		if len(events) > 0 && heartbeatTimer != nil {
+			heartbeatTimer.Reset(s.heartbeatInterval)
+		}
+
+		select {
+		case <-updated:
+		case <-heartbeats:
+			_, err := fmt.Fprintf(w, "event: %s\ndata: {}\n\n", HeartbeatEvent)
+			if err != nil {
+				logger.Error("failed-to-write-heartbeat", err)
+				return
+			}
+			heartbeatTimer.Reset(s.heartbeatInterval)
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
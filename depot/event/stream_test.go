@@ -0,0 +1,160 @@
+package event_test
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Stream", func() {
+	var (
+		hub       event.Hub
+		fakeClock *fakeclock.FakeClock
+		process   ifrit.Process
+		server    *httptest.Server
+		epoch     int64
+	)
+
+	BeforeEach(func() {
+		hub = event.NewHub()
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		epoch = fakeClock.Now().UnixNano()
+		stream := event.NewStream(lagertest.NewTestLogger("test"), hub, fakeClock, 2, 10*time.Second)
+		process = ginkgomon.Invoke(stream)
+		server = httptest.NewServer(stream)
+	})
+
+	AfterEach(func() {
+		server.Close()
+		ginkgomon.Interrupt(process)
+		hub.Close()
+	})
+
+	id := func(seq int) string {
+		return fmt.Sprintf("id: %d-%d\n", epoch, seq)
+	}
+
+	emit := func(guid string) {
+		hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: guid}))
+	}
+
+	connect := func(lastEventID string) (*http.Response, *bufio.Reader) {
+		request, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		if lastEventID != "" {
+			request.Header.Set("Last-Event-ID", lastEventID)
+		}
+
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(response.StatusCode).To(Equal(http.StatusOK))
+		Expect(response.Header.Get("Content-Type")).To(Equal("text/event-stream"))
+		return response, bufio.NewReader(response.Body)
+	}
+
+	readEvent := func(reader *bufio.Reader) []string {
+		lines := []string{}
+		for {
+			line, err := reader.ReadString('\n')
+			Expect(err).NotTo(HaveOccurred())
+			if line == "\n" {
+				return lines
+			}
+			lines = append(lines, line)
+		}
+	}
+
+	It("streams new events with increasing IDs", func() {
+		response, reader := connect("")
+		defer response.Body.Close()
+
+		emit("guid-1")
+		lines := readEvent(reader)
+		Expect(lines).To(HaveLen(3))
+		Expect(lines[0]).To(Equal(id(1)))
+		Expect(lines[1]).To(Equal("event: container_reserved\n"))
+		Expect(lines[2]).To(HavePrefix(`data: {"container":{"guid":"guid-1"`))
+
+		emit("guid-2")
+		Expect(readEvent(reader)[0]).To(Equal(id(2)))
+	})
+
+	It("replays the buffered events after the Last-Event-ID", func() {
+		emit("guid-1")
+		emit("guid-2")
+		emit("guid-3")
+
+		response, reader := connect(event.FormatEventID(epoch, 1))
+		defer response.Body.Close()
+		Expect(readEvent(reader)[0]).To(Equal(id(2)))
+		Expect(readEvent(reader)[0]).To(Equal(id(3)))
+	})
+
+	It("replays all the buffered events after a Last-Event-ID from an earlier epoch", func() {
+		emit("guid-1")
+		emit("guid-2")
+		emit("guid-3")
+
+		response, reader := connect(event.FormatEventID(epoch-1, 500))
+		defer response.Body.Close()
+		Expect(readEvent(reader)[0]).To(Equal(id(2)))
+		Expect(readEvent(reader)[0]).To(Equal(id(3)))
+	})
+
+	It("redacts the run info of the containers", func() {
+		response, reader := connect("")
+		defer response.Body.Close()
+
+		hub.Emit(executor.NewContainerRunningEvent(executor.Container{
+			Guid: "guid-1",
+			RunInfo: executor.RunInfo{
+				ImageUsername: "user",
+				ImagePassword: "secret",
+				Env:           []executor.EnvironmentVariable{{Name: "TOKEN", Value: "secret"}},
+			},
+		}))
+
+		lines := readEvent(reader)
+		Expect(lines[2]).To(HavePrefix(`data: {"container":{"guid":"guid-1"`))
+		Expect(lines[2]).NotTo(ContainSubstring("secret"))
+		Expect(lines[2]).To(ContainSubstring(`"image_username":""`))
+	})
+
+	It("sends heartbeats on an idle stream without moving the event ID", func() {
+		response, reader := connect("")
+		defer response.Body.Close()
+
+		fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+		Expect(readEvent(reader)).To(Equal([]string{"event: heartbeat\n", "data: {}\n"}))
+
+		fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+		Expect(readEvent(reader)).To(Equal([]string{"event: heartbeat\n", "data: {}\n"}))
+
+		emit("guid-1")
+		Expect(readEvent(reader)[0]).To(Equal(id(1)))
+	})
+
+	It("rejects an invalid Last-Event-ID", func() {
+		request, err := http.NewRequest("GET", server.URL, nil)
+		Expect(err).NotTo(HaveOccurred())
+		request.Header.Set("Last-Event-ID", "nope")
+
+		response, err := http.DefaultClient.Do(request)
+		Expect(err).NotTo(HaveOccurred())
+		defer response.Body.Close()
+		Expect(response.StatusCode).To(Equal(http.StatusBadRequest))
+	})
+})
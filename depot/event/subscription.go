@@ -0,0 +1,123 @@
+package event
+
+import (
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+const resubscribeInterval = time.Second
+
+// subscription reads the hub's events until it is closed. The hub closes
+// sources that fall behind, so a closed source is replaced with a new one,
+// retrying every resubscribeInterval while the hub refuses it.
+type subscription struct {
+	logger lager.Logger
+	hub    Hub
+	clock  clock.Clock
+	opts   SubscribeOptions
+	stop   chan struct{}
+
+	lock   sync.Mutex
+	source executor.EventSource
+}
+
+func subscribe(logger lager.Logger, hub Hub, clock clock.Clock, opts SubscribeOptions) (*subscription, error) {
+	source, err := hub.SubscribeWithOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &subscription{
+		logger: logger,
+		hub:    hub,
+		clock:  clock,
+		opts:   opts,
+		stop:   make(chan struct{}),
+		source: source,
+	}, nil
+}
+
+// run calls handle with each event until the subscription is closed.
+func (s *subscription) run(handle func(executor.Event)) {
+	source := s.currentSource()
+	for {
+		ev, err := source.Next()
+		if err == nil {
+			handle(ev)
+			continue
+		}
+
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		s.logger.Error("event-source-closed", err)
+
+		source = s.resubscribe()
+		if source == nil {
+			return
+		}
+		s.logger.Info("resubscribed")
+	}
+}
+
+func (s *subscription) resubscribe() executor.EventSource {
+	for attempts := 1; ; attempts++ {
+		timer := s.clock.NewTimer(resubscribeInterval)
+		select {
+		case <-timer.C():
+		case <-s.stop:
+			timer.Stop()
+			return nil
+		}
+
+		source, err := s.hub.SubscribeWithOptions(s.opts)
+		if err != nil {
+			s.logger.Error("failed-to-resubscribe", err, lager.Data{"attempts": attempts})
+			continue
+		}
+
+		if !s.setSource(source) {
+			return nil
+		}
+		return source
+	}
+}
+
+// setSource records the current source, so it can be closed to unblock run.
+// It returns false, closing source, if the subscription is already closed.
+func (s *subscription) setSource(source executor.EventSource) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	select {
+	case <-s.stop:
+		source.Close()
+		return false
+	default:
+	}
+
+	s.source = source
+	return true
+}
+
+func (s *subscription) currentSource() executor.EventSource {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.source
+}
+
+// close stops the subscription, unblocking run.
+func (s *subscription) close() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	close(s.stop)
+	s.source.Close()
+}
@@ -0,0 +1,42 @@
+package depot
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/executor"
+)
+
+// HealthcheckHistorySize is the number of garden health check results the
+// client keeps.
+const HealthcheckHistorySize = 10
+
+// healthcheckHistory keeps the most recent garden health check results, so
+// operators can see why a cell went unhealthy.
+type healthcheckHistory struct {
+	lock    sync.Mutex
+	results []executor.HealthcheckResult
+}
+
+func newHealthcheckHistory() *healthcheckHistory {
+	return &healthcheckHistory{}
+}
+
+func (h *healthcheckHistory) record(result executor.HealthcheckResult) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.results = append(h.results, result)
+	if len(h.results) > HealthcheckHistorySize {
+		h.results = h.results[len(h.results)-HealthcheckHistorySize:]
+	}
+}
+
+// list returns the recorded results, oldest first.
+func (h *healthcheckHistory) list() []executor.HealthcheckResult {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	results := make([]executor.HealthcheckResult, len(h.results))
+	copy(results, h.results)
+	return results
+}
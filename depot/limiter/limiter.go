@@ -0,0 +1,195 @@
+package limiter
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
+	"code.cloudfoundry.org/lager"
+)
+
+const (
+	DownloadQueueWaitDuration = "DownloadQueueWaitDuration"
+	DownloadQueueDepth        = "DownloadQueueDepth"
+	UploadQueueWaitDuration   = "UploadQueueWaitDuration"
+	UploadQueueDepth          = "UploadQueueDepth"
+)
+
+var ErrCancelled = errors.New("cancelled while waiting for limiter")
+
+// Limiter bounds the number of concurrent operations across all containers.
+//
+// When every slot is in use, waiters are queued per owner (typically a
+// container guid) and released slots are handed to the owners in round-robin
+// order, so a single container with many queued transfers cannot starve the
+// others.
+type Limiter interface {
+	// Acquire blocks until a slot is available or cancel is closed, in which
+	// case ErrCancelled is returned.
+	Acquire(logger lager.Logger, owner string, cancel <-chan struct{}) error
+
+	// Release frees a slot obtained by a successful Acquire.
+	Release()
+}
+
+type waiter struct {
+	owner   string
+	granted chan struct{}
+}
+
+type limiter struct {
+	maxConcurrent int
+	waitMetric    string
+	depthMetric   string
+	metronClient  loggregator_v2.Client
+	clock         clock.Clock
+
+	lock   sync.Mutex
+	inUse  int
+	queued int
+	queues map[string][]*waiter
+	owners []string
+}
+
+// New constructs a Limiter allowing maxConcurrent simultaneous holders.
+//
+// The time each caller spends queued is sent as waitMetric and the number of
+// queued callers is sent as depthMetric whenever it changes.
+func New(
+	maxConcurrent int,
+	waitMetric string,
+	depthMetric string,
+	metronClient loggregator_v2.Client,
+	clock clock.Clock,
+) Limiter {
+	return &limiter{
+		maxConcurrent: maxConcurrent,
+		waitMetric:    waitMetric,
+		depthMetric:   depthMetric,
+		metronClient:  metronClient,
+		clock:         clock,
+		queues:        make(map[string][]*waiter),
+	}
+}
+
+func (l *limiter) Acquire(logger lager.Logger, owner string, cancel <-chan struct{}) error {
+	startTime := l.clock.Now()
+
+	l.lock.Lock()
+	if l.queued == 0 && l.inUse < l.maxConcurrent {
+		l.inUse++
+		l.lock.Unlock()
+		l.sendWaitDuration(logger, 0)
+		return nil
+	}
+
+	w := &waiter{owner: owner, granted: make(chan struct{})}
+	if len(l.queues[owner]) == 0 {
+		l.owners = append(l.owners, owner)
+	}
+	l.queues[owner] = append(l.queues[owner], w)
+	l.queued++
+	depth := l.queued
+	l.lock.Unlock()
+
+	l.sendQueueDepth(logger, depth)
+
+	select {
+	case <-w.granted:
+		// Release has no logger to report with, so the waiter it handed the
+		// slot to reports the shorter queue
+		l.lock.Lock()
+		depth = l.queued
+		l.lock.Unlock()
+
+		l.sendQueueDepth(logger, depth)
+		l.sendWaitDuration(logger, l.clock.Now().Sub(startTime))
+		return nil
+
+	case <-cancel:
+		l.lock.Lock()
+		select {
+		case <-w.granted:
+			// the slot was handed to us while we were giving up, so pass it on
+			l.release()
+		default:
+			l.dequeue(w)
+		}
+		depth = l.queued
+		l.lock.Unlock()
+
+		l.sendQueueDepth(logger, depth)
+		return ErrCancelled
+	}
+}
+
+func (l *limiter) Release() {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	l.release()
+}
+
+// release must be called with the lock held
+func (l *limiter) release() {
+	if l.queued == 0 {
+		l.inUse--
+		return
+	}
+
+	owner := l.owners[0]
+	queue := l.queues[owner]
+	w := queue[0]
+
+	l.owners = l.owners[1:]
+	if len(queue) == 1 {
+		delete(l.queues, owner)
+	} else {
+		l.queues[owner] = queue[1:]
+		l.owners = append(l.owners, owner)
+	}
+	l.queued--
+
+	close(w.granted)
+}
+
+// dequeue must be called with the lock held
+func (l *limiter) dequeue(w *waiter) {
+	queue := l.queues[w.owner]
+	for i := range queue {
+		if queue[i] == w {
+			queue = append(queue[:i], queue[i+1:]...)
+			l.queued--
+			break
+		}
+	}
+
+	if len(queue) > 0 {
+		l.queues[w.owner] = queue
+		return
+	}
+
+	delete(l.queues, w.owner)
+	for i := range l.owners {
+		if l.owners[i] == w.owner {
+			l.owners = append(l.owners[:i], l.owners[i+1:]...)
+			break
+		}
+	}
+}
+
+func (l *limiter) sendWaitDuration(logger lager.Logger, wait time.Duration) {
+	err := l.metronClient.SendDuration(l.waitMetric, wait)
+	if err != nil {
+		logger.Error("failed-to-send-queue-wait-duration-metric", err, lager.Data{"metric": l.waitMetric})
+	}
+}
+
+func (l *limiter) sendQueueDepth(logger lager.Logger, depth int) {
+	err := l.metronClient.SendMetric(l.depthMetric, depth)
+	if err != nil {
+		logger.Error("failed-to-send-queue-depth-metric", err, lager.Data{"metric": l.depthMetric})
+	}
+}
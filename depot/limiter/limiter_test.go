@@ -0,0 +1,133 @@
+package limiter_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/limiter"
+	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Limiter", func() {
+	var (
+		logger           *lagertest.TestLogger
+		fakeClock        *fakeclock.FakeClock
+		fakeMetronClient *mfakes.FakeClient
+		l                limiter.Limiter
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeMetronClient = new(mfakes.FakeClient)
+		l = limiter.New(2, "WaitMetric", "DepthMetric", fakeMetronClient, fakeClock)
+	})
+
+	acquire := func(owner string, cancel <-chan struct{}) <-chan error {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- l.Acquire(logger, owner, cancel)
+		}()
+		return errCh
+	}
+
+	It("allows up to the maximum number of concurrent holders", func() {
+		Eventually(acquire("guid-1", nil)).Should(Receive(BeNil()))
+		Eventually(acquire("guid-1", nil)).Should(Receive(BeNil()))
+
+		third := acquire("guid-1", nil)
+		Consistently(third).ShouldNot(Receive())
+
+		l.Release()
+		Eventually(third).Should(Receive(BeNil()))
+	})
+
+	It("hands released slots to owners in round-robin order", func() {
+		Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+		Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+
+		greedy1 := acquire("greedy", nil)
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(1))
+		greedy2 := acquire("greedy", nil)
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(2))
+		polite := acquire("polite", nil)
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(3))
+
+		l.Release()
+		Eventually(greedy1).Should(Receive(BeNil()))
+
+		l.Release()
+		Eventually(polite).Should(Receive(BeNil()))
+		Consistently(greedy2).ShouldNot(Receive())
+
+		l.Release()
+		Eventually(greedy2).Should(Receive(BeNil()))
+	})
+
+	Context("when the wait is cancelled", func() {
+		It("returns ErrCancelled and gives up its place in the queue", func() {
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+
+			cancel := make(chan struct{})
+			cancelled := acquire("guid-2", cancel)
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(1))
+
+			close(cancel)
+			Eventually(cancelled).Should(Receive(Equal(limiter.ErrCancelled)))
+
+			l.Release()
+			Eventually(acquire("guid-3", nil)).Should(Receive(BeNil()))
+		})
+	})
+
+	Describe("metrics", func() {
+		It("emits the queue depth when callers queue up", func() {
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+
+			acquire("guid-2", nil)
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(1))
+
+			name, value := fakeMetronClient.SendMetricArgsForCall(0)
+			Expect(name).To(Equal("DepthMetric"))
+			Expect(value).To(Equal(1))
+		})
+
+		It("emits the queue depth when a queued caller is handed a slot", func() {
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+
+			waiting := acquire("guid-2", nil)
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(1))
+
+			l.Release()
+			Eventually(waiting).Should(Receive(BeNil()))
+
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(2))
+			name, value := fakeMetronClient.SendMetricArgsForCall(1)
+			Expect(name).To(Equal("DepthMetric"))
+			Expect(value).To(Equal(0))
+		})
+
+		It("emits the time spent waiting for a slot", func() {
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+			Expect(l.Acquire(logger, "guid-1", nil)).To(Succeed())
+
+			waiting := acquire("guid-2", nil)
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(1))
+
+			fakeClock.Increment(time.Second)
+			l.Release()
+			Eventually(waiting).Should(Receive(BeNil()))
+
+			Eventually(fakeMetronClient.SendDurationCallCount).Should(Equal(3))
+			name, value := fakeMetronClient.SendDurationArgsForCall(2)
+			Expect(name).To(Equal("WaitMetric"))
+			Expect(value).To(Equal(time.Second))
+		})
+	})
+})
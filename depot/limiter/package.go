@@ -0,0 +1 @@
+package limiter // import "code.cloudfoundry.org/executor/depot/limiter"
@@ -0,0 +1,132 @@
+package log_streamer
+
+import (
+	"io"
+	"sync"
+)
+
+// maxBufferedWrites is how many writes an attached reader may fall behind
+// before it starts missing output.
+const maxBufferedWrites = 256
+
+// Broadcaster copies everything written to it to each attached reader. Slow
+// readers miss output instead of blocking the writer.
+type Broadcaster struct {
+	lock     sync.Mutex
+	watchers map[*watcher]struct{}
+	closed   bool
+}
+
+func NewBroadcaster() *Broadcaster {
+	return &Broadcaster{watchers: map[*watcher]struct{}{}}
+}
+
+func (b *Broadcaster) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if len(b.watchers) == 0 {
+		return len(p), nil
+	}
+
+	chunk := append([]byte(nil), p...)
+	for w := range b.watchers {
+		select {
+		case w.chunks <- chunk:
+		default:
+		}
+	}
+
+	return len(p), nil
+}
+
+// Attach returns a reader of everything written from now on. It reads io.EOF
+// once the broadcaster is closed.
+func (b *Broadcaster) Attach() io.ReadCloser {
+	w := &watcher{broadcaster: b, chunks: make(chan []byte, maxBufferedWrites)}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		close(w.chunks)
+	} else {
+		b.watchers[w] = struct{}{}
+	}
+
+	return w
+}
+
+// Close detaches every reader.
+func (b *Broadcaster) Close() {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if b.closed {
+		return
+	}
+
+	b.closed = true
+	for w := range b.watchers {
+		close(w.chunks)
+	}
+	b.watchers = nil
+}
+
+func (b *Broadcaster) detach(w *watcher) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	if _, ok := b.watchers[w]; ok {
+		delete(b.watchers, w)
+		close(w.chunks)
+	}
+}
+
+type watcher struct {
+	broadcaster *Broadcaster
+	chunks      chan []byte
+	pending     []byte
+}
+
+func (w *watcher) Read(p []byte) (int, error) {
+	if len(w.pending) == 0 {
+		chunk, ok := <-w.chunks
+		if !ok {
+			return 0, io.EOF
+		}
+		w.pending = chunk
+	}
+
+	n := copy(p, w.pending)
+	w.pending = w.pending[n:]
+	return n, nil
+}
+
+func (w *watcher) Close() error {
+	w.broadcaster.detach(w)
+	return nil
+}
+
+type broadcastingStreamer struct {
+	LogStreamer
+	broadcaster *Broadcaster
+}
+
+// NewBroadcastingStreamer returns a LogStreamer that also writes its stdout
+// and stderr to broadcaster.
+func NewBroadcastingStreamer(streamer LogStreamer, broadcaster *Broadcaster) LogStreamer {
+	return &broadcastingStreamer{LogStreamer: streamer, broadcaster: broadcaster}
+}
+
+func (s *broadcastingStreamer) Stdout() io.Writer {
+	return io.MultiWriter(s.LogStreamer.Stdout(), s.broadcaster)
+}
+
+func (s *broadcastingStreamer) Stderr() io.Writer {
+	return io.MultiWriter(s.LogStreamer.Stderr(), s.broadcaster)
+}
+
+func (s *broadcastingStreamer) WithSource(sourceName string) LogStreamer {
+	return NewBroadcastingStreamer(s.LogStreamer.WithSource(sourceName), s.broadcaster)
+}
@@ -0,0 +1,65 @@
+package log_streamer_test
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"code.cloudfoundry.org/executor/depot/log_streamer"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("Broadcaster", func() {
+	var broadcaster *log_streamer.Broadcaster
+
+	BeforeEach(func() {
+		broadcaster = log_streamer.NewBroadcaster()
+	})
+
+	It("copies writes to every attached reader", func() {
+		reader1 := broadcaster.Attach()
+		reader2 := broadcaster.Attach()
+
+		fmt.Fprint(broadcaster, "hello")
+		broadcaster.Close()
+
+		Expect(ioutil.ReadAll(reader1)).To(Equal([]byte("hello")))
+		Expect(ioutil.ReadAll(reader2)).To(Equal([]byte("hello")))
+	})
+
+	It("does not replay writes made before a reader attached", func() {
+		fmt.Fprint(broadcaster, "before")
+		reader := broadcaster.Attach()
+		fmt.Fprint(broadcaster, "after")
+		broadcaster.Close()
+
+		Expect(ioutil.ReadAll(reader)).To(Equal([]byte("after")))
+	})
+
+	It("stops delivering to readers once they are closed", func() {
+		reader := broadcaster.Attach()
+		Expect(reader.Close()).To(Succeed())
+
+		fmt.Fprint(broadcaster, "hello")
+		Expect(ioutil.ReadAll(reader)).To(BeEmpty())
+	})
+
+	It("returns readers that are already at EOF once closed", func() {
+		broadcaster.Close()
+		Expect(ioutil.ReadAll(broadcaster.Attach())).To(BeEmpty())
+	})
+
+	Describe("NewBroadcastingStreamer", func() {
+		It("writes the streamer's stdout and stderr to the broadcaster", func() {
+			reader := broadcaster.Attach()
+			buffer := gbytes.BufferReader(reader)
+
+			streamer := log_streamer.NewBroadcastingStreamer(log_streamer.NewNoopStreamer(), broadcaster)
+			fmt.Fprint(streamer.Stdout(), "out ")
+			fmt.Fprint(streamer.WithSource("other").Stderr(), "err")
+
+			Eventually(buffer).Should(gbytes.Say("out err"))
+		})
+	})
+})
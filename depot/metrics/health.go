@@ -0,0 +1,74 @@
+package metrics
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+type HealthSource interface {
+	Ping(lager.Logger) error
+	Healthy(lager.Logger) bool
+	HealthcheckHistory(lager.Logger) []executor.HealthcheckResult
+	RemainingResources(lager.Logger) (executor.ExecutorResources, error)
+	TotalResources(lager.Logger) (executor.ExecutorResources, error)
+}
+
+// HealthReport is the body served by HealthHandler.
+type HealthReport struct {
+	Healthy           bool                         `json:"healthy"`
+	GardenReachable   bool                         `json:"garden_reachable"`
+	GardenError       string                       `json:"garden_error,omitempty"`
+	TotalCapacity     executor.ExecutorResources   `json:"total_capacity"`
+	RemainingCapacity executor.ExecutorResources   `json:"remaining_capacity"`
+	Version           string                       `json:"version"`
+	Healthchecks      []executor.HealthcheckResult `json:"healthchecks,omitempty"`
+}
+
+// HealthHandler reports whether the executor is healthy and garden can be
+// reached, along with its capacity, version and recent garden health checks. It responds with 503 when the
+// executor is unhealthy or garden is unreachable, so it can back a load
+// balancer check.
+type HealthHandler struct {
+	ExecutorSource HealthSource
+	Logger         lager.Logger
+}
+
+func (h *HealthHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.Session("health")
+
+	report := HealthReport{
+		Healthy:         h.ExecutorSource.Healthy(logger),
+		GardenReachable: true,
+		Version:         executor.Version,
+		Healthchecks:    h.ExecutorSource.HealthcheckHistory(logger),
+	}
+
+	err := h.ExecutorSource.Ping(logger)
+	if err != nil {
+		logger.Error("failed-to-ping-garden", err)
+		report.GardenReachable = false
+		report.GardenError = err.Error()
+	}
+
+	report.TotalCapacity, err = h.ExecutorSource.TotalResources(logger)
+	if err != nil {
+		logger.Error("failed-total-resources", err)
+	}
+
+	report.RemainingCapacity, err = h.ExecutorSource.RemainingResources(logger)
+	if err != nil {
+		logger.Error("failed-remaining-resources", err)
+	}
+
+	status := http.StatusOK
+	if !report.Healthy || !report.GardenReachable {
+		status = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,93 @@
+package metrics_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/metrics"
+	"code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("HealthHandler", func() {
+	var (
+		executorClient *fakes.FakeClient
+		handler        *metrics.HealthHandler
+		recorder       *httptest.ResponseRecorder
+		report         metrics.HealthReport
+	)
+
+	BeforeEach(func() {
+		executorClient = new(fakes.FakeClient)
+		executorClient.HealthyReturns(true)
+		executorClient.TotalResourcesReturns(executor.NewExecutorResources(1024, 2048, 10), nil)
+		executorClient.RemainingResourcesReturns(executor.NewExecutorResources(128, 256, 7), nil)
+
+		handler = &metrics.HealthHandler{
+			ExecutorSource: executorClient,
+			Logger:         lagertest.NewTestLogger("test"),
+		}
+		recorder = httptest.NewRecorder()
+	})
+
+	JustBeforeEach(func() {
+		request, err := http.NewRequest("GET", "/health", nil)
+		Expect(err).NotTo(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+
+		report = metrics.HealthReport{}
+		Expect(json.Unmarshal(recorder.Body.Bytes(), &report)).To(Succeed())
+	})
+
+	It("reports the executor as healthy with its capacity and version", func() {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+		Expect(report).To(Equal(metrics.HealthReport{
+			Healthy:           true,
+			GardenReachable:   true,
+			TotalCapacity:     executor.NewExecutorResources(1024, 2048, 10),
+			RemainingCapacity: executor.NewExecutorResources(128, 256, 7),
+			Version:           executor.Version,
+		}))
+	})
+
+	Context("when garden cannot be reached", func() {
+		BeforeEach(func() {
+			executorClient.PingReturns(errors.New("connection refused"))
+		})
+
+		It("responds with service unavailable", func() {
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(report.GardenReachable).To(BeFalse())
+			Expect(report.GardenError).To(Equal("connection refused"))
+		})
+	})
+
+	Context("when the executor is unhealthy", func() {
+		BeforeEach(func() {
+			executorClient.HealthyReturns(false)
+			executorClient.HealthcheckHistoryReturns([]executor.HealthcheckResult{
+				{CheckedAt: 1, Duration: time.Second},
+				{CheckedAt: 2, Duration: time.Minute, Error: "garden healthcheck timed out"},
+			})
+		})
+
+		It("responds with service unavailable", func() {
+			Expect(recorder.Code).To(Equal(http.StatusServiceUnavailable))
+			Expect(report.Healthy).To(BeFalse())
+		})
+
+		It("reports the recent health checks", func() {
+			Expect(report.Healthchecks).To(Equal([]executor.HealthcheckResult{
+				{CheckedAt: 1, Duration: time.Second},
+				{CheckedAt: 2, Duration: time.Minute, Error: "garden healthcheck timed out"},
+			}))
+		})
+	})
+})
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+var containerStates = []executor.State{
+	executor.StateReserved,
+	executor.StateInitializing,
+	executor.StateCreated,
+	executor.StateRunning,
+	executor.StateCompleted,
+}
+
+type PrometheusSource interface {
+	ExecutorSource
+	Healthy(lager.Logger) bool
+}
+
+// PrometheusHandler serves the executor's capacity, containers by state and
+// health in the Prometheus text exposition format.
+type PrometheusHandler struct {
+	ExecutorSource PrometheusSource
+	Logger         lager.Logger
+}
+
+func (h *PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := h.Logger.Session("prometheus-metrics")
+
+	totalCapacity, err := h.ExecutorSource.TotalResources(logger)
+	if err != nil {
+		logger.Error("failed-total-resources", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	remainingCapacity, err := h.ExecutorSource.RemainingResources(logger)
+	if err != nil {
+		logger.Error("failed-remaining-resources", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	containers, err := h.ExecutorSource.ListContainers(logger)
+	if err != nil {
+		logger.Error("failed-to-list-containers", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	containersByState := map[executor.State]int{}
+	for _, container := range containers {
+		containersByState[container.State]++
+	}
+
+	healthy := 0
+	if h.ExecutorSource.Healthy(logger) {
+		healthy = 1
+	}
+
+	body := &bytes.Buffer{}
+
+	writeGauge(body, "executor_capacity_total_memory_mb", "Total memory the executor can allocate, in MiB.", totalCapacity.MemoryMB)
+	writeGauge(body, "executor_capacity_total_disk_mb", "Total disk the executor can allocate, in MiB.", totalCapacity.DiskMB)
+	writeGauge(body, "executor_capacity_total_containers", "Total number of containers the executor can allocate.", totalCapacity.Containers)
+	writeGauge(body, "executor_capacity_remaining_memory_mb", "Memory not allocated to containers, in MiB.", remainingCapacity.MemoryMB)
+	writeGauge(body, "executor_capacity_remaining_disk_mb", "Disk not allocated to containers, in MiB.", remainingCapacity.DiskMB)
+	writeGauge(body, "executor_capacity_remaining_containers", "Number of containers that can still be allocated.", remainingCapacity.Containers)
+
+	fmt.Fprintln(body, "# HELP executor_containers Number of containers by state.")
+	fmt.Fprintln(body, "# TYPE executor_containers gauge")
+	for _, state := range containerStates {
+		fmt.Fprintf(body, "executor_containers{state=%q} %d\n", state, containersByState[state])
+	}
+
+	writeGauge(body, "executor_healthy", "Whether the executor is healthy (1) or not (0).", healthy)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(body.Bytes())
+}
+
+func writeGauge(body *bytes.Buffer, name, help string, value int) {
+	fmt.Fprintf(body, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(body, "# TYPE %s gauge\n", name)
+	fmt.Fprintf(body, "%s %d\n", name, value)
+}
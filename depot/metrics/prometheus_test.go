@@ -0,0 +1,70 @@
+package metrics_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/metrics"
+	"code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+)
+
+var _ = Describe("PrometheusHandler", func() {
+	var (
+		executorClient *fakes.FakeClient
+		handler        *metrics.PrometheusHandler
+		recorder       *httptest.ResponseRecorder
+	)
+
+	BeforeEach(func() {
+		executorClient = new(fakes.FakeClient)
+		executorClient.TotalResourcesReturns(executor.NewExecutorResources(1024, 2048, 10), nil)
+		executorClient.RemainingResourcesReturns(executor.NewExecutorResources(128, 256, 7), nil)
+		executorClient.ListContainersReturns([]executor.Container{
+			{Guid: "container-1", State: executor.StateRunning},
+			{Guid: "container-2", State: executor.StateRunning},
+			{Guid: "container-3", State: executor.StateReserved},
+		}, nil)
+		executorClient.HealthyReturns(true)
+
+		handler = &metrics.PrometheusHandler{
+			ExecutorSource: executorClient,
+			Logger:         lagertest.NewTestLogger("test"),
+		}
+		recorder = httptest.NewRecorder()
+	})
+
+	JustBeforeEach(func() {
+		request, err := http.NewRequest("GET", "/metrics", nil)
+		Expect(err).NotTo(HaveOccurred())
+		handler.ServeHTTP(recorder, request)
+	})
+
+	It("serves the capacity, containers by state and health", func() {
+		Expect(recorder.Code).To(Equal(http.StatusOK))
+
+		body := recorder.Body.String()
+		Expect(body).To(ContainSubstring("# TYPE executor_capacity_total_memory_mb gauge\nexecutor_capacity_total_memory_mb 1024\n"))
+		Expect(body).To(ContainSubstring("executor_capacity_remaining_disk_mb 256\n"))
+		Expect(body).To(ContainSubstring("executor_capacity_remaining_containers 7\n"))
+		Expect(body).To(ContainSubstring(`executor_containers{state="running"} 2` + "\n"))
+		Expect(body).To(ContainSubstring(`executor_containers{state="reserved"} 1` + "\n"))
+		Expect(body).To(ContainSubstring(`executor_containers{state="completed"} 0` + "\n"))
+		Expect(body).To(ContainSubstring("executor_healthy 1\n"))
+	})
+
+	Context("when listing the containers fails", func() {
+		BeforeEach(func() {
+			executorClient.ListContainersReturns(nil, errors.New("oh no!"))
+		})
+
+		It("responds with an internal server error", func() {
+			Expect(recorder.Code).To(Equal(http.StatusInternalServerError))
+		})
+	})
+})
@@ -20,17 +20,34 @@ const (
 	remainingContainers = "CapacityRemainingContainers"
 
 	containerCount = "ContainerCount"
+
+	droppedEvents = "DroppedEvents"
 )
 
+var containerCountByState = map[executor.State]string{
+	executor.StateReserved:     "ReservedContainerCount",
+	executor.StateInitializing: "InitializingContainerCount",
+	executor.StateCreated:      "CreatedContainerCount",
+	executor.StateRunning:      "RunningContainerCount",
+	executor.StateCompleted:    "CompletedContainerCount",
+}
+
 type ExecutorSource interface {
 	RemainingResources(lager.Logger) (executor.ExecutorResources, error)
 	TotalResources(lager.Logger) (executor.ExecutorResources, error)
 	ListContainers(lager.Logger) ([]executor.Container, error)
 }
 
+type EventSource interface {
+	DroppedEvents() uint64
+}
+
+// Reporter periodically sends the executor's capacity and container counts,
+// and, when EventSource is set, how many events its subscribers have missed.
 type Reporter struct {
 	Interval       time.Duration
 	ExecutorSource ExecutorSource
+	EventSource    EventSource
 	Clock          clock.Clock
 	Logger         lager.Logger
 	MetronClient   loggregator_v2.Client
@@ -66,12 +83,19 @@ func (reporter *Reporter) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 			}
 
 			var nContainers int
+			nContainersByState := map[executor.State]int{}
 			containers, err := reporter.ExecutorSource.ListContainers(logger)
 			if err != nil {
 				reporter.Logger.Error("failed-to-list-containers", err)
 				nContainers = -1
+				for _, state := range containerStates {
+					nContainersByState[state] = -1
+				}
 			} else {
 				nContainers = len(containers)
+				for _, container := range containers {
+					nContainersByState[container.State]++
+				}
 			}
 
 			err = reporter.MetronClient.SendMebiBytes(totalMemory, totalCapacity.MemoryMB)
@@ -105,6 +129,20 @@ func (reporter *Reporter) Run(signals <-chan os.Signal, ready chan<- struct{}) e
 				logger.Error("failed-to-send-container-count-metric", err)
 			}
 
+			for _, state := range containerStates {
+				err = reporter.MetronClient.SendMetric(containerCountByState[state], nContainersByState[state])
+				if err != nil {
+					logger.Error("failed-to-send-container-count-by-state-metric", err, lager.Data{"state": state})
+				}
+			}
+
+			if reporter.EventSource != nil {
+				err = reporter.MetronClient.SendMetric(droppedEvents, int(reporter.EventSource.DroppedEvents()))
+				if err != nil {
+					logger.Error("failed-to-send-dropped-events-metric", err)
+				}
+			}
+
 			timer.Reset(reporter.Interval)
 		}
 	}
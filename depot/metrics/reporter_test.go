@@ -11,6 +11,7 @@ import (
 
 	"code.cloudfoundry.org/clock/fakeclock"
 	"code.cloudfoundry.org/executor"
+	efakes "code.cloudfoundry.org/executor/depot/event/fakes"
 	"code.cloudfoundry.org/executor/depot/metrics"
 	"code.cloudfoundry.org/executor/fakes"
 	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
@@ -24,6 +25,7 @@ var _ = Describe("Reporter", func() {
 		executorClient   *fakes.FakeClient
 		fakeClock        *fakeclock.FakeClock
 		fakeMetronClient *mfakes.FakeClient
+		eventSource      metrics.EventSource
 
 		reporter  ifrit.Process
 		logger    *lagertest.TestLogger
@@ -38,6 +40,7 @@ var _ = Describe("Reporter", func() {
 
 		fakeClock = fakeclock.NewFakeClock(time.Now())
 		fakeMetronClient = new(mfakes.FakeClient)
+		eventSource = nil
 
 		executorClient.TotalResourcesReturns(executor.ExecutorResources{
 			MemoryMB:   1024,
@@ -52,9 +55,9 @@ var _ = Describe("Reporter", func() {
 		}, nil)
 
 		executorClient.ListContainersReturns([]executor.Container{
-			{Guid: "container-1"},
-			{Guid: "container-2"},
-			{Guid: "container-3"},
+			{Guid: "container-1", State: executor.StateRunning},
+			{Guid: "container-2", State: executor.StateRunning},
+			{Guid: "container-3", State: executor.StateReserved},
 		}, nil)
 
 		m = sync.RWMutex{}
@@ -77,6 +80,7 @@ var _ = Describe("Reporter", func() {
 
 		reporter = ifrit.Invoke(&metrics.Reporter{
 			ExecutorSource: executorClient,
+			EventSource:    eventSource,
 			Interval:       reportInterval,
 			Clock:          fakeClock,
 			Logger:         logger,
@@ -93,7 +97,7 @@ var _ = Describe("Reporter", func() {
 
 	It("reports the current capacity on the given interval", func() {
 		Eventually(fakeMetronClient.SendMebiBytesCallCount).Should(Equal(4))
-		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(3))
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(8))
 
 		m.RLock()
 		Eventually(metricMap["CapacityTotalMemory"]).Should(Equal(1024))
@@ -105,6 +109,9 @@ var _ = Describe("Reporter", func() {
 		Eventually(metricMap["CapacityRemainingContainers"]).Should(Equal(512))
 
 		Eventually(metricMap["ContainerCount"]).Should(Equal(3))
+		Eventually(metricMap["ReservedContainerCount"]).Should(Equal(1))
+		Eventually(metricMap["RunningContainerCount"]).Should(Equal(2))
+		Eventually(metricMap["CompletedContainerCount"]).Should(Equal(0))
 
 		executorClient.RemainingResourcesReturns(executor.ExecutorResources{
 			MemoryMB:   129,
@@ -113,8 +120,8 @@ var _ = Describe("Reporter", func() {
 		}, nil)
 
 		executorClient.ListContainersReturns([]executor.Container{
-			{Guid: "container-1"},
-			{Guid: "container-2"},
+			{Guid: "container-1", State: executor.StateRunning},
+			{Guid: "container-2", State: executor.StateCompleted},
 		}, nil)
 
 		fakeClock.WaitForWatcherAndIncrement(reportInterval)
@@ -122,13 +129,15 @@ var _ = Describe("Reporter", func() {
 		m.RUnlock()
 
 		Eventually(fakeMetronClient.SendMebiBytesCallCount).Should(Equal(8))
-		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(6))
+		Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(16))
 
 		m.RLock()
 		Eventually(metricMap["CapacityRemainingMemory"]).Should(Equal(129))
 		Eventually(metricMap["CapacityRemainingDisk"]).Should(Equal(257))
 		Eventually(metricMap["CapacityRemainingContainers"]).Should(Equal(513))
 		Eventually(metricMap["ContainerCount"]).Should(Equal(2))
+		Eventually(metricMap["RunningContainerCount"]).Should(Equal(1))
+		Eventually(metricMap["CompletedContainerCount"]).Should(Equal(1))
 
 		m.RUnlock()
 	})
@@ -172,10 +181,27 @@ var _ = Describe("Reporter", func() {
 
 		It("reports garden.containers as -1", func() {
 			logger.Info("checking this stuff")
-			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(3))
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(8))
 
 			m.RLock()
 			Eventually(metricMap["ContainerCount"]).Should(Equal(-1))
+			Eventually(metricMap["RunningContainerCount"]).Should(Equal(-1))
+			m.RUnlock()
+		})
+	})
+
+	Context("when an event source is set", func() {
+		BeforeEach(func() {
+			hub := new(efakes.FakeHub)
+			hub.DroppedEventsReturns(3)
+			eventSource = hub
+		})
+
+		It("reports the number of dropped events", func() {
+			Eventually(fakeMetronClient.SendMetricCallCount).Should(Equal(9))
+
+			m.RLock()
+			Expect(metricMap["DroppedEvents"]).To(Equal(3))
 			m.RUnlock()
 		})
 	})
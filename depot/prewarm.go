@@ -0,0 +1,82 @@
+package depot
+
+import (
+	"net/url"
+
+	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+// PrewarmCache fetches each asset into the download cache, so that the first
+// containers to use it on this cell do not wait on the download. Fetches take
+// a slot from the download limiter like any other download, and the cache is
+// free to evict the assets again once it needs the space. The returned map
+// holds the result of each asset by cache key.
+func (c *client) PrewarmCache(logger lager.Logger, assets []executor.CacheAsset) map[string]error {
+	logger = logger.Session("prewarm-cache", lager.Data{"assets": len(assets)})
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	type result struct {
+		cacheKey string
+		err      error
+	}
+
+	results := make(chan result, len(assets))
+	for _, asset := range assets {
+		asset := asset
+		go func() {
+			results <- result{asset.CacheKey, c.prewarm(logger, asset)}
+		}()
+	}
+
+	errs := make(map[string]error, len(assets))
+	for range assets {
+		r := <-results
+		errs[r.cacheKey] = r.err
+	}
+
+	return errs
+}
+
+func (c *client) prewarm(logger lager.Logger, asset executor.CacheAsset) error {
+	logger = logger.Session("prewarm", lager.Data{"cache-key": asset.CacheKey})
+
+	if asset.CacheKey == "" {
+		logger.Error("invalid-asset", executor.ErrCacheAssetInvalid)
+		return executor.ErrCacheAssetInvalid
+	}
+
+	downloadURL, err := url.ParseRequestURI(asset.From)
+	if err != nil {
+		logger.Error("failed-to-parse-url", err)
+		return executor.ErrCacheAssetInvalid
+	}
+
+	// prewarming is not cancellable, so Acquire cannot fail
+	c.downloadRateLimiter.Acquire(logger, "", nil)
+	defer c.downloadRateLimiter.Release()
+
+	checksum := cacheddownloader.ChecksumInfoType{
+		Algorithm: asset.ChecksumAlgorithm,
+		Value:     asset.ChecksumValue,
+	}
+
+	if asset.AsDirectory {
+		dir, _, err := c.cachedDownloader.FetchAsDirectory(logger.Session("downloader"), downloadURL, asset.CacheKey, checksum, nil)
+		if err != nil {
+			logger.Error("failed-to-fetch", err)
+			return err
+		}
+		return c.cachedDownloader.CloseDirectory(logger, asset.CacheKey, dir)
+	}
+
+	stream, _, err := c.cachedDownloader.Fetch(logger.Session("downloader"), downloadURL, asset.CacheKey, checksum, nil)
+	if err != nil {
+		logger.Error("failed-to-fetch", err)
+		return err
+	}
+	return stream.Close()
+}
@@ -0,0 +1,51 @@
+package depot
+
+import (
+	"sync/atomic"
+
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/workpool"
+)
+
+const (
+	CreateWorkPoolQueueDepth = "CreateWorkPoolQueueDepth"
+	DeleteWorkPoolQueueDepth = "DeleteWorkPoolQueueDepth"
+)
+
+// queuedWorkPool is a work pool that sends the number of submitted jobs still
+// waiting for a worker as depthMetric whenever it changes.
+type queuedWorkPool struct {
+	*workpool.WorkPool
+	depthMetric  string
+	metronClient loggregator_v2.Client
+	queued       int32
+}
+
+func newQueuedWorkPool(size int, depthMetric string, metronClient loggregator_v2.Client) (*queuedWorkPool, error) {
+	pool, err := workpool.NewWorkPool(size)
+	if err != nil {
+		return nil, err
+	}
+
+	return &queuedWorkPool{
+		WorkPool:     pool,
+		depthMetric:  depthMetric,
+		metronClient: metronClient,
+	}, nil
+}
+
+func (p *queuedWorkPool) Submit(logger lager.Logger, work func()) {
+	p.sendQueueDepth(logger, atomic.AddInt32(&p.queued, 1))
+	p.WorkPool.Submit(func() {
+		p.sendQueueDepth(logger, atomic.AddInt32(&p.queued, -1))
+		work()
+	})
+}
+
+func (p *queuedWorkPool) sendQueueDepth(logger lager.Logger, depth int32) {
+	err := p.metronClient.SendMetric(p.depthMetric, int(depth))
+	if err != nil {
+		logger.Error("failed-to-send-queue-depth-metric", err, lager.Data{"metric": p.depthMetric})
+	}
+}
@@ -0,0 +1,154 @@
+package depot
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrAttemptTimedOut = errors.New("timed out waiting for the client call")
+
+// retryingClient retries the idempotent reads of the client it wraps
+// according to its policy. Every other call is passed straight through.
+type retryingClient struct {
+	executor.Client
+	policy executor.RetryPolicy
+	clock  clock.Clock
+}
+
+func NewRetryingClient(client executor.Client, policy executor.RetryPolicy, clock clock.Clock) executor.Client {
+	return &retryingClient{
+		Client: client,
+		policy: policy,
+		clock:  clock,
+	}
+}
+
+func (c *retryingClient) GetContainer(logger lager.Logger, guid string) (executor.Container, error) {
+	result, err := c.retry(context.Background(), logger, "get-container", func() (interface{}, error) {
+		return c.Client.GetContainer(logger, guid)
+	})
+	if err != nil {
+		return executor.Container{}, err
+	}
+	return result.(executor.Container), nil
+}
+
+func (c *retryingClient) ListContainers(logger lager.Logger) ([]executor.Container, error) {
+	result, err := c.retry(context.Background(), logger, "list-containers", func() (interface{}, error) {
+		return c.Client.ListContainers(logger)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.([]executor.Container), nil
+}
+
+func (c *retryingClient) ListContainersPage(logger lager.Logger, opts executor.ContainerListOptions) (executor.ContainerPage, error) {
+	result, err := c.retry(context.Background(), logger, "list-containers-page", func() (interface{}, error) {
+		return c.Client.ListContainersPage(logger, opts)
+	})
+	if err != nil {
+		return executor.ContainerPage{}, err
+	}
+	return result.(executor.ContainerPage), nil
+}
+
+func (c *retryingClient) GetFiles(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error) {
+	result, err := c.retry(ctx, logger, "get-files", func() (interface{}, error) {
+		return c.Client.GetFiles(ctx, logger, guid, paths...)
+	})
+	if err != nil {
+		return nil, err
+	}
+	stream, _ := result.(io.ReadCloser)
+	return stream, nil
+}
+
+func (c *retryingClient) SubscribeToEvents(logger lager.Logger) (executor.EventSource, error) {
+	result, err := c.retry(context.Background(), logger, "subscribe-to-events", func() (interface{}, error) {
+		return c.Client.SubscribeToEvents(logger)
+	})
+	if err != nil {
+		return nil, err
+	}
+	source, _ := result.(executor.EventSource)
+	return source, nil
+}
+
+func (c *retryingClient) SubscribeToFilteredEvents(logger lager.Logger, filter executor.EventFilter) (executor.EventSource, error) {
+	result, err := c.retry(context.Background(), logger, "subscribe-to-filtered-events", func() (interface{}, error) {
+		return c.Client.SubscribeToFilteredEvents(logger, filter)
+	})
+	if err != nil {
+		return nil, err
+	}
+	source, _ := result.(executor.EventSource)
+	return source, nil
+}
+
+func (c *retryingClient) retry(ctx context.Context, logger lager.Logger, name string, call func() (interface{}, error)) (interface{}, error) {
+	logger = logger.Session("retrying-"+name, lager.Data{"max-attempts": c.policy.MaxAttempts})
+
+	for attempt := 1; ; attempt++ {
+		result, err := c.attempt(call)
+		if err == nil {
+			return result, nil
+		}
+
+		if attempt >= c.policy.MaxAttempts || !c.policy.ShouldRetry(err) {
+			return nil, err
+		}
+
+		backoff := c.policy.Backoff(attempt - 1)
+		logger.Error("attempt-failed", err, lager.Data{"attempt": attempt, "backoff": backoff.String()})
+
+		timer := c.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		}
+	}
+}
+
+type attemptResult struct {
+	value interface{}
+	err   error
+}
+
+// attempt runs call, giving up on it after the policy's AttemptTimeout. The
+// abandoned call is left to finish in the background, and any stream or
+// event source it opens is closed.
+func (c *retryingClient) attempt(call func() (interface{}, error)) (interface{}, error) {
+	if c.policy.AttemptTimeout <= 0 {
+		return call()
+	}
+
+	results := make(chan attemptResult, 1)
+	go func() {
+		value, err := call()
+		results <- attemptResult{value, err}
+	}()
+
+	timer := c.clock.NewTimer(c.policy.AttemptTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-timer.C():
+		go func() {
+			r := <-results
+			if closer, ok := r.value.(io.Closer); ok && r.err == nil {
+				closer.Close()
+			}
+		}()
+		return nil, ErrAttemptTimedOut
+	}
+}
@@ -0,0 +1,181 @@
+package depot_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot"
+	"code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/lager"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("RetryingClient", func() {
+	var (
+		logger         *lagertest.TestLogger
+		fakeClient     *fakes.FakeClient
+		fakeClock      *fakeclock.FakeClock
+		policy         executor.RetryPolicy
+		retryingClient executor.Client
+		transientErr   error
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClient = new(fakes.FakeClient)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		policy = executor.RetryPolicy{
+			MaxAttempts:    3,
+			InitialBackoff: time.Second,
+			MaxBackoff:     time.Minute,
+		}
+		transientErr = errors.New("connection reset")
+	})
+
+	JustBeforeEach(func() {
+		retryingClient = depot.NewRetryingClient(fakeClient, policy, fakeClock)
+	})
+
+	It("retries failed reads after the backoff", func() {
+		fakeClient.GetContainerStub = func(logger lager.Logger, guid string) (executor.Container, error) {
+			if fakeClient.GetContainerCallCount() < 3 {
+				return executor.Container{}, transientErr
+			}
+			return executor.Container{Guid: guid}, nil
+		}
+
+		containers := make(chan executor.Container)
+		go func() {
+			defer GinkgoRecover()
+			container, err := retryingClient.GetContainer(logger, "some-guid")
+			Expect(err).NotTo(HaveOccurred())
+			containers <- container
+		}()
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		Consistently(containers).ShouldNot(Receive())
+		fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+		Eventually(containers).Should(Receive(Equal(executor.Container{Guid: "some-guid"})))
+		Expect(fakeClient.GetContainerCallCount()).To(Equal(3))
+	})
+
+	It("gives up after the maximum number of attempts", func() {
+		fakeClient.ListContainersReturns(nil, transientErr)
+
+		errs := make(chan error)
+		go func() {
+			_, err := retryingClient.ListContainers(logger)
+			errs <- err
+		}()
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		fakeClock.WaitForWatcherAndIncrement(2 * time.Second)
+
+		Eventually(errs).Should(Receive(Equal(transientErr)))
+		Expect(fakeClient.ListContainersCallCount()).To(Equal(3))
+	})
+
+	It("does not retry requests the executor rejected", func() {
+		fakeClient.GetContainerReturns(executor.Container{}, executor.ErrContainerNotFound)
+
+		_, err := retryingClient.GetContainer(logger, "some-guid")
+		Expect(err).To(Equal(executor.ErrContainerNotFound))
+		Expect(fakeClient.GetContainerCallCount()).To(Equal(1))
+	})
+
+	It("stops waiting to retry once the context is done", func() {
+		fakeClient.GetFilesReturns(nil, transientErr)
+		ctx, cancel := context.WithCancel(context.Background())
+
+		errs := make(chan error)
+		go func() {
+			_, err := retryingClient.GetFiles(ctx, logger, "some-guid", "/some/path")
+			errs <- err
+		}()
+
+		Eventually(fakeClient.GetFilesCallCount).Should(Equal(1))
+		cancel()
+
+		Eventually(errs).Should(Receive(Equal(context.Canceled)))
+		Expect(fakeClient.GetFilesCallCount()).To(Equal(1))
+	})
+
+	It("passes other calls straight through", func() {
+		fakeClient.DeleteContainerReturns(transientErr)
+
+		err := retryingClient.DeleteContainer(logger, "some-guid")
+		Expect(err).To(Equal(transientErr))
+		Expect(fakeClient.DeleteContainerCallCount()).To(Equal(1))
+	})
+
+	Context("with an attempt timeout", func() {
+		var (
+			release    chan struct{}
+			lateStream *closeRecorder
+		)
+
+		BeforeEach(func() {
+			policy.AttemptTimeout = 10 * time.Second
+			release = make(chan struct{})
+			lateStream = &closeRecorder{Reader: strings.NewReader("late")}
+
+			fakeClient.GetFilesStub = func(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error) {
+				if fakeClient.GetFilesCallCount() == 1 {
+					<-release
+					return lateStream, nil
+				}
+				return ioutil.NopCloser(strings.NewReader("on time")), nil
+			}
+		})
+
+		It("abandons a slow attempt, retries, and closes the stream it opens late", func() {
+			streams := make(chan io.ReadCloser)
+			go func() {
+				defer GinkgoRecover()
+				stream, err := retryingClient.GetFiles(context.Background(), logger, "some-guid", "/some/path")
+				Expect(err).NotTo(HaveOccurred())
+				streams <- stream
+			}()
+
+			fakeClock.WaitForWatcherAndIncrement(10 * time.Second)
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+			var stream io.ReadCloser
+			Eventually(streams).Should(Receive(&stream))
+			Expect(ioutil.ReadAll(stream)).To(Equal([]byte("on time")))
+
+			close(release)
+			Eventually(lateStream.Closed).Should(BeTrue())
+		})
+	})
+})
+
+type closeRecorder struct {
+	io.Reader
+	lock   sync.Mutex
+	closed bool
+}
+
+func (r *closeRecorder) Close() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *closeRecorder) Closed() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.closed
+}
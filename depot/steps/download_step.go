@@ -1,24 +1,29 @@
 package steps
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"net/url"
+	"os"
 
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bytefmt"
 	"code.cloudfoundry.org/cacheddownloader"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/lager"
 )
 
+var ErrInsufficientDisk = errors.New("insufficient disk for download")
+
 type downloadStep struct {
 	container        garden.Container
 	model            models.DownloadAction
 	cachedDownloader cacheddownloader.CachedDownloader
 	streamer         log_streamer.LogStreamer
-	rateLimiter      chan struct{}
+	rateLimiter      limiter.Limiter
 
 	logger lager.Logger
 
@@ -29,7 +34,7 @@ func NewDownload(
 	container garden.Container,
 	model models.DownloadAction,
 	cachedDownloader cacheddownloader.CachedDownloader,
-	rateLimiter chan struct{},
+	rateLimiter limiter.Limiter,
 	streamer log_streamer.LogStreamer,
 	logger lager.Logger,
 ) *downloadStep {
@@ -53,17 +58,14 @@ func NewDownload(
 
 func (step *downloadStep) Perform() error {
 	step.logger.Info("acquiring-limiter")
-	select {
-	case step.rateLimiter <- struct{}{}:
-	case <-step.Cancelled():
+	err := step.rateLimiter.Acquire(step.logger, step.container.Handle(), step.Cancelled())
+	if err != nil {
 		return ErrCancelled
 	}
-	defer func() {
-		<-step.rateLimiter
-	}()
+	defer step.rateLimiter.Release()
 	step.logger.Info("acquired-limiter")
 
-	err := step.perform()
+	err = step.perform()
 	if err != nil {
 		select {
 		case <-step.Cancelled():
@@ -79,11 +81,26 @@ func (step *downloadStep) Perform() error {
 func (step *downloadStep) perform() error {
 	step.emit("Downloading %s...\n", step.model.Artifact)
 
+	err := checkDiskSpace(step.logger, step.container, step.streamer, step.model.Artifact, 0)
+	if err != nil {
+		return err
+	}
+
 	downloadedFile, downloadedSize, err := step.fetch()
 	if err != nil {
+		if _, ok := err.(*cacheddownloader.ChecksumFailedError); ok {
+			step.emitError("Checksum verification failed: %v\n", err)
+			return NewEmittableError(err, "Downloading failed: checksum verification failed")
+		}
 		return NewEmittableError(err, "Downloading failed")
 	}
 
+	err = checkDiskSpace(step.logger, step.container, step.streamer, step.model.Artifact, archiveSize(downloadedFile, downloadedSize))
+	if err != nil {
+		downloadedFile.Close()
+		return err
+	}
+
 	err = step.streamIn(step.model.To, downloadedFile)
 	if err != nil {
 		step.emitError("Copying into the container failed: %v", err)
@@ -126,6 +143,59 @@ func (step *downloadStep) fetch() (io.ReadCloser, int64, error) {
 	return tarStream, downloadedSize, nil
 }
 
+// checkDiskSpace fails with ErrInsufficientDisk if container's disk quota is
+// used up, or extracting size bytes would take it over. Containers without a
+// quota, and those whose usage cannot be determined, are let through.
+func checkDiskSpace(logger lager.Logger, container garden.Container, streamer log_streamer.LogStreamer, artifact string, size int64) error {
+	limits, err := container.CurrentDiskLimits()
+	if err != nil {
+		logger.Error("failed-to-get-disk-limits", err)
+		return nil
+	}
+	if limits.ByteHard == 0 {
+		return nil
+	}
+
+	metrics, err := container.Metrics()
+	if err != nil {
+		logger.Error("failed-to-get-disk-usage", err)
+		return nil
+	}
+
+	used := metrics.DiskStat.ExclusiveBytesUsed
+	if limits.Scope == garden.DiskLimitScopeTotal {
+		used = metrics.DiskStat.TotalBytesUsed
+	}
+
+	var remaining uint64
+	if used < limits.ByteHard {
+		remaining = limits.ByteHard - used
+	}
+
+	if remaining == 0 || uint64(size) > remaining {
+		logger.Error("insufficient-disk", ErrInsufficientDisk, lager.Data{"size": size, "remaining": remaining})
+		fmt.Fprintf(streamer.Stderr(), "Insufficient disk to download %s: needs %s, %s remaining\n", artifact, bytefmt.ByteSize(uint64(size)), bytefmt.ByteSize(remaining))
+		return NewEmittableError(ErrInsufficientDisk, "Downloading failed: insufficient disk")
+	}
+
+	return nil
+}
+
+// archiveSize is the size of the tar archive that will be extracted into the
+// container. The cached downloader hands back its cached file, whose size is
+// known even when nothing had to be downloaded.
+func archiveSize(archive io.ReadCloser, downloadedSize int64) int64 {
+	if file, ok := archive.(interface {
+		Stat() (os.FileInfo, error)
+	}); ok {
+		info, err := file.Stat()
+		if err == nil {
+			return info.Size()
+		}
+	}
+	return downloadedSize
+}
+
 func (step *downloadStep) streamIn(destination string, reader io.ReadCloser) error {
 	step.logger.Info("stream-in-starting")
 
@@ -18,6 +18,7 @@ import (
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/garden"
 
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/log_streamer/fake_log_streamer"
 	"code.cloudfoundry.org/executor/depot/steps"
 	"code.cloudfoundry.org/executor/fakes"
@@ -37,7 +38,7 @@ var _ = Describe("DownloadAction", func() {
 		gardenClient   *fakes.FakeGardenClient
 		fakeStreamer   *fake_log_streamer.FakeLogStreamer
 		logger         *lagertest.TestLogger
-		rateLimiter    chan struct{}
+		rateLimiter    limiter.Limiter
 	)
 
 	handle := "some-container-handle"
@@ -58,7 +59,7 @@ var _ = Describe("DownloadAction", func() {
 		fakeStreamer = newFakeStreamer()
 		logger = lagertest.NewTestLogger("test")
 
-		rateLimiter = make(chan struct{}, 1)
+		rateLimiter = newLimiter(1)
 	})
 
 	Describe("Perform", func() {
@@ -293,6 +294,58 @@ var _ = Describe("DownloadAction", func() {
 
 			})
 		})
+
+		Context("when the container's disk quota cannot hold the archive", func() {
+			BeforeEach(func() {
+				gardenClient.Connection.CurrentDiskLimitsReturns(garden.DiskLimits{ByteHard: 100, Scope: garden.DiskLimitScopeExclusive}, nil)
+				gardenClient.Connection.MetricsReturns(garden.Metrics{DiskStat: garden.ContainerDiskStat{ExclusiveBytesUsed: 90}}, nil)
+			})
+
+			It("fails with an insufficient disk error", func() {
+				Expect(stepErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+				Expect(stepErr.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrInsufficientDisk))
+
+				stderr := fakeStreamer.Stderr().(*gbytes.Buffer)
+				Expect(stderr).To(gbytes.Say("needs 42B, 10B remaining"))
+			})
+
+			It("does not stream anything into the container", func() {
+				Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the container's disk quota is used up", func() {
+			BeforeEach(func() {
+				gardenClient.Connection.CurrentDiskLimitsReturns(garden.DiskLimits{ByteHard: 100, Scope: garden.DiskLimitScopeTotal}, nil)
+				gardenClient.Connection.MetricsReturns(garden.Metrics{DiskStat: garden.ContainerDiskStat{TotalBytesUsed: 100}}, nil)
+			})
+
+			It("fails before fetching the archive", func() {
+				Expect(stepErr).To(MatchError("Downloading failed: insufficient disk"))
+				Expect(cache.FetchCallCount()).To(BeZero())
+			})
+		})
+
+		Context("when the downloaded file does not match its checksum", func() {
+			BeforeEach(func() {
+				downloadAction.ChecksumAlgorithm = "sha256"
+				downloadAction.ChecksumValue = "expected-checksum"
+				cache.FetchReturns(nil, 0, cacheddownloader.NewChecksumFailedError("sha256", "expected-checksum", "actual-checksum"))
+			})
+
+			It("returns a checksum error", func() {
+				Expect(stepErr.Error()).To(Equal("Downloading failed: checksum verification failed"))
+			})
+
+			It("emits the mismatch", func() {
+				stderr := fakeStreamer.Stderr().(*gbytes.Buffer)
+				Expect(stderr).To(gbytes.Say("Checksum verification failed"))
+			})
+
+			It("does not stream anything into the container", func() {
+				Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+			})
+		})
 	})
 
 	Describe("Cancel", func() {
@@ -318,7 +371,7 @@ var _ = Describe("DownloadAction", func() {
 
 		Context("when waiting on the rate limiter", func() {
 			JustBeforeEach(func() {
-				rateLimiter <- struct{}{}
+				Expect(rateLimiter.Acquire(logger, handle, nil)).To(Succeed())
 				go func() { result <- step.Perform() }()
 			})
 
@@ -414,7 +467,7 @@ var _ = Describe("DownloadAction", func() {
 		})
 
 		It("allows only N concurrent downloads", func() {
-			rateLimiter := make(chan struct{}, 2)
+			rateLimiter := newLimiter(2)
 
 			downloadAction1 := models.DownloadAction{
 				From: "http://mr_jones1",
@@ -0,0 +1,402 @@
+package steps
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/executor/depot/limiter"
+	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+// GitURLPrefix marks a download action's From as a git repository to clone,
+// e.g. git+https://example.com/app.git#ref=v1.2.0&depth=1. Only http and
+// https repositories are allowed. The fragment is optional: ref defaults to
+// the remote's HEAD and depth to 1. The full history can not be fetched, so
+// a depth of at least 1 is required.
+const GitURLPrefix = "git+"
+
+const defaultGitDepth = 1
+
+// ErrGitChecksumUnsupported is returned for git downloads that carry a
+// checksum. A checkout is not an archive with a fixed digest, so the
+// checksum could not be verified.
+var ErrGitChecksumUnsupported = errors.New("checksums are not supported for git downloads")
+
+// gitConfig restricts git to the http and https transports, so a URL can not
+// make it run commands through transports like ext::.
+var gitConfig = []string{
+	"-c", "protocol.allow=never",
+	"-c", "protocol.https.allow=always",
+	"-c", "protocol.http.allow=always",
+}
+
+func IsGitURL(from string) bool {
+	return strings.HasPrefix(from, GitURLPrefix)
+}
+
+type gitDownloadStep struct {
+	container   garden.Container
+	model       models.DownloadAction
+	tempDir     string
+	proxyEnv    []string
+	streamer    log_streamer.LogStreamer
+	rateLimiter limiter.Limiter
+
+	logger lager.Logger
+
+	*canceller
+}
+
+// NewGitDownload clones the repository named by model.From on the host and
+// streams the checkout, including its .git directory, into the container at
+// model.To. It shares the download limiter with other downloads. proxyEnv
+// holds the proxy variables git is run with, over those of the executor's
+// environment.
+func NewGitDownload(
+	container garden.Container,
+	model models.DownloadAction,
+	tempDir string,
+	proxyEnv []string,
+	rateLimiter limiter.Limiter,
+	streamer log_streamer.LogStreamer,
+	logger lager.Logger,
+) *gitDownloadStep {
+	logger = logger.Session("git-download-step", lager.Data{
+		"to":   model.To,
+		"user": model.User,
+	})
+
+	return &gitDownloadStep{
+		container:   container,
+		model:       model,
+		tempDir:     tempDir,
+		proxyEnv:    proxyEnv,
+		streamer:    streamer,
+		rateLimiter: rateLimiter,
+		logger:      logger,
+
+		canceller: newCanceller(),
+	}
+}
+
+func (step *gitDownloadStep) Perform() error {
+	step.logger.Info("acquiring-limiter")
+	err := step.rateLimiter.Acquire(step.logger, step.container.Handle(), step.Cancelled())
+	if err != nil {
+		return ErrCancelled
+	}
+	defer step.rateLimiter.Release()
+	step.logger.Info("acquired-limiter")
+
+	err = step.perform()
+	if err != nil {
+		select {
+		case <-step.Cancelled():
+			return ErrCancelled
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (step *gitDownloadStep) perform() error {
+	step.emit("Downloading %s...\n", step.model.Artifact)
+
+	if step.model.ChecksumAlgorithm != "" || step.model.ChecksumValue != "" {
+		step.logger.Error("checksum-unsupported", ErrGitChecksumUnsupported)
+		return NewEmittableError(ErrGitChecksumUnsupported, "Downloading failed: checksums are not supported for git URLs")
+	}
+
+	repository, ref, depth, err := parseGitURL(step.model.From)
+	if err != nil {
+		// Do not log or emit the URL in case it holds credentials
+		step.logger.Error("failed-to-parse-git-url", nil)
+		return NewEmittableError(err, "Downloading failed: invalid git URL")
+	}
+
+	err = checkDiskSpace(step.logger, step.container, step.streamer, step.model.Artifact, 0)
+	if err != nil {
+		return err
+	}
+
+	workDir, err := ioutil.TempDir(step.tempDir, "git-download")
+	if err != nil {
+		step.logger.Error("failed-to-create-clone-dir", err)
+		return NewEmittableError(err, "Downloading failed")
+	}
+	defer os.RemoveAll(workDir)
+
+	// git is run with an empty HOME, so no user config applies to it
+	homeDir := filepath.Join(workDir, "home")
+	cloneDir := filepath.Join(workDir, "clone")
+	for _, dir := range []string{homeDir, cloneDir} {
+		err = os.Mkdir(dir, 0700)
+		if err != nil {
+			step.logger.Error("failed-to-create-clone-dir", err)
+			return NewEmittableError(err, "Downloading failed")
+		}
+	}
+
+	step.logger.Info("clone-starting", lager.Data{"ref": ref, "depth": depth})
+	err = step.clone(repository, ref, depth, cloneDir, homeDir)
+	if err != nil {
+		step.logger.Error("clone-failed", err)
+		return NewEmittableError(err, "Downloading failed: could not clone %s", ref)
+	}
+	step.logger.Info("clone-complete")
+
+	size, err := treeSize(cloneDir)
+	if err != nil {
+		step.logger.Error("failed-to-measure-clone", err)
+		return NewEmittableError(err, "Downloading failed")
+	}
+
+	err = checkDiskSpace(step.logger, step.container, step.streamer, step.model.Artifact, size)
+	if err != nil {
+		return err
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(writeTar(cloneDir, writer))
+	}()
+
+	// StreamIn will close the reader
+	err = step.container.StreamIn(garden.StreamInSpec{Path: step.model.To, TarStream: reader, User: step.model.User})
+	if err != nil {
+		reader.CloseWithError(err)
+		step.logger.Error("stream-in-failed", err)
+		return NewEmittableError(err, "Copying into the container failed")
+	}
+
+	step.emit("Downloaded %s\n", step.model.Artifact)
+	return nil
+}
+
+func (step *gitDownloadStep) clone(repository, ref string, depth int, dir, homeDir string) error {
+	fetchArgs := []string{"fetch", "--quiet", "--depth", strconv.Itoa(depth), "--", repository, ref}
+
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		fetchArgs,
+		{"checkout", "--quiet", "FETCH_HEAD", "--"},
+	} {
+		err := step.git(dir, homeDir, repository, args...)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// git runs a git subcommand in dir. Its error includes git's stderr, with
+// the repository URL and its credentials redacted.
+func (step *gitDownloadStep) git(dir, homeDir, repository string, args ...string) error {
+	stderr := &bytes.Buffer{}
+	cmd := exec.Command("git", append(gitConfig, args...)...)
+	cmd.Dir = dir
+	cmd.Env = append(gitEnv(homeDir), step.proxyEnv...)
+	cmd.Stderr = stderr
+
+	err := cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	select {
+	case err = <-done:
+	case <-step.Cancelled():
+		cmd.Process.Kill()
+		<-done
+		return ErrCancelled
+	}
+
+	if err != nil {
+		// the arguments are left out as they include the repository URL
+		return fmt.Errorf("git %s failed: %s: %s", args[0], err, redactGitOutput(stderr.String(), repository))
+	}
+	return nil
+}
+
+// gitEnv is the executor's environment without any GIT_ variables, which
+// could point git at other config or commands, and with no system or user
+// config and no prompts.
+func gitEnv(homeDir string) []string {
+	env := []string{}
+	for _, variable := range os.Environ() {
+		if !strings.HasPrefix(variable, "GIT_") && !strings.HasPrefix(variable, "HOME=") && !strings.HasPrefix(variable, "XDG_CONFIG_HOME=") {
+			env = append(env, variable)
+		}
+	}
+
+	return append(env,
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_CONFIG_NOSYSTEM=1",
+		"HOME="+homeDir,
+	)
+}
+
+// redactGitOutput removes the repository URL, and any credentials in it,
+// from git's output.
+func redactGitOutput(output, repository string) string {
+	output = strings.TrimSpace(output)
+
+	repositoryURL, err := url.Parse(repository)
+	if err != nil {
+		return "<redacted>"
+	}
+
+	secrets := []string{repository}
+	if repositoryURL.User != nil {
+		secrets = append(secrets, repositoryURL.User.String(), repositoryURL.User.Username())
+		if password, ok := repositoryURL.User.Password(); ok {
+			secrets = append(secrets, password)
+		}
+	}
+
+	for _, secret := range secrets {
+		if secret != "" {
+			output = strings.Replace(output, secret, "<redacted>", -1)
+		}
+	}
+	return output
+}
+
+func (step *gitDownloadStep) emit(format string, a ...interface{}) {
+	if step.model.Artifact != "" {
+		fmt.Fprintf(step.streamer.Stdout(), format, a...)
+	}
+}
+
+// parseGitURL splits a git+ URL into the repository to fetch from, and the
+// ref and depth given in its fragment.
+func parseGitURL(from string) (string, string, int, error) {
+	repositoryURL, err := url.Parse(strings.TrimPrefix(from, GitURLPrefix))
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	if repositoryURL.Scheme != "https" && repositoryURL.Scheme != "http" {
+		return "", "", 0, fmt.Errorf("unsupported git URL scheme %q", repositoryURL.Scheme)
+	}
+	if repositoryURL.Host == "" {
+		return "", "", 0, errors.New("git URL has no host")
+	}
+
+	options, err := url.ParseQuery(repositoryURL.Fragment)
+	if err != nil {
+		return "", "", 0, err
+	}
+	repositoryURL.Fragment = ""
+
+	ref := options.Get("ref")
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if strings.HasPrefix(ref, "-") {
+		return "", "", 0, fmt.Errorf("invalid ref %q", ref)
+	}
+
+	depth := defaultGitDepth
+	if options.Get("depth") != "" {
+		depth, err = strconv.Atoi(options.Get("depth"))
+		if err != nil || depth < 1 {
+			return "", "", 0, fmt.Errorf("invalid depth %q", options.Get("depth"))
+		}
+	}
+
+	return repositoryURL.String(), ref, depth, nil
+}
+
+// treeSize is the total size of the regular files under dir, which is what
+// extracting them takes up in the container.
+func treeSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
+
+// writeTar writes the contents of dir to w as a tar archive, with paths
+// relative to dir.
+func writeTar(dir string, w io.Writer) error {
+	tarWriter := tar.NewWriter(w)
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relative, err := filepath.Rel(dir, path)
+		if err != nil || relative == "." {
+			return err
+		}
+
+		link := ""
+		if info.Mode()&os.ModeSymlink != 0 {
+			link, err = os.Readlink(path)
+			if err != nil {
+				return err
+			}
+		}
+
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(relative)
+		if info.IsDir() {
+			header.Name += "/"
+		}
+
+		err = tarWriter.WriteHeader(header)
+		if err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		_, err = io.Copy(tarWriter, file)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tarWriter.Close()
+}
@@ -0,0 +1,269 @@
+package steps_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http/cgi"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/executor/depot/log_streamer/fake_log_streamer"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("GitDownloadStep", func() {
+	var (
+		repoDir        string
+		serverDir      string
+		server         *httptest.Server
+		tempDir        string
+		proxyEnv       []string
+		downloadAction models.DownloadAction
+		gardenClient   *fakes.FakeGardenClient
+		fakeStreamer   *fake_log_streamer.FakeLogStreamer
+		streamedIn     *bytes.Buffer
+		stepErr        error
+	)
+
+	git := func(args ...string) {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		cmd.Env = append(os.Environ(),
+			"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+			"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		)
+		output, err := cmd.CombinedOutput()
+		Expect(err).NotTo(HaveOccurred(), string(output))
+	}
+
+	BeforeEach(func() {
+		gitPath, err := exec.LookPath("git")
+		if err != nil {
+			Skip("git is not installed")
+		}
+
+		repoDir, err = ioutil.TempDir("", "git-download-repo")
+		Expect(err).NotTo(HaveOccurred())
+		serverDir, err = ioutil.TempDir("", "git-download-server")
+		Expect(err).NotTo(HaveOccurred())
+		tempDir, err = ioutil.TempDir("", "git-download-tmp")
+		Expect(err).NotTo(HaveOccurred())
+
+		git("init", "--quiet")
+		Expect(ioutil.WriteFile(filepath.Join(repoDir, "app.rb"), []byte("v1"), 0644)).To(Succeed())
+		git("add", ".")
+		git("commit", "--quiet", "-m", "v1")
+		git("tag", "v1")
+		Expect(ioutil.WriteFile(filepath.Join(repoDir, "app.rb"), []byte("v2"), 0644)).To(Succeed())
+		git("commit", "--quiet", "-am", "v2")
+		git("clone", "--quiet", "--bare", repoDir, filepath.Join(serverDir, "app.git"))
+
+		server = httptest.NewServer(&cgi.Handler{
+			Path:       gitPath,
+			Args:       []string{"http-backend"},
+			Env:        []string{"GIT_PROJECT_ROOT=" + serverDir, "GIT_HTTP_EXPORT_ALL=1"},
+			InheritEnv: []string{"PATH"},
+		})
+
+		proxyEnv = nil
+		downloadAction = models.DownloadAction{
+			From: "git+" + server.URL + "/app.git#ref=v1",
+			To:   "/home/vcap/app",
+			User: "vcap",
+		}
+
+		gardenClient = fakes.NewGardenClient()
+		fakeStreamer = newFakeStreamer()
+		streamedIn = &bytes.Buffer{}
+		gardenClient.Connection.StreamInStub = func(handle string, spec garden.StreamInSpec) error {
+			Expect(spec.Path).To(Equal("/home/vcap/app"))
+			Expect(spec.User).To(Equal("vcap"))
+			_, err := io.Copy(streamedIn, spec.TarStream)
+			return err
+		}
+	})
+
+	AfterEach(func() {
+		if server != nil {
+			server.Close()
+		}
+		os.RemoveAll(repoDir)
+		os.RemoveAll(serverDir)
+		os.RemoveAll(tempDir)
+	})
+
+	JustBeforeEach(func() {
+		container, err := gardenClient.Create(garden.ContainerSpec{Handle: "some-handle"})
+		Expect(err).NotTo(HaveOccurred())
+
+		step := steps.NewGitDownload(container, downloadAction, tempDir, proxyEnv, newLimiter(1), fakeStreamer, lagertest.NewTestLogger("test"))
+		stepErr = step.Perform()
+	})
+
+	It("streams a checkout of the ref into the container", func() {
+		Expect(stepErr).NotTo(HaveOccurred())
+
+		files := map[string]string{}
+		tarReader := tar.NewReader(streamedIn)
+		for {
+			header, err := tarReader.Next()
+			if err == io.EOF {
+				break
+			}
+			Expect(err).NotTo(HaveOccurred())
+			contents, err := ioutil.ReadAll(tarReader)
+			Expect(err).NotTo(HaveOccurred())
+			files[header.Name] = string(contents)
+		}
+
+		Expect(files).To(HaveKeyWithValue("app.rb", "v1"))
+		Expect(files).To(HaveKey(".git/"))
+	})
+
+	It("cleans up the clone", func() {
+		entries, err := ioutil.ReadDir(tempDir)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(entries).To(BeEmpty())
+	})
+
+	Context("when a proxy is configured", func() {
+		BeforeEach(func() {
+			proxyEnv = []string{"http_proxy=http://127.0.0.1:1"}
+		})
+
+		It("clones through it", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: could not clone v1"))
+			Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when the ref does not exist", func() {
+		BeforeEach(func() {
+			downloadAction.From = "git+" + server.URL + "/app.git#ref=missing"
+		})
+
+		It("fails without streaming anything in", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: could not clone missing"))
+			Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when the repository does not exist", func() {
+		BeforeEach(func() {
+			downloadAction.From = "git+" + strings.Replace(server.URL, "http://", "http://user:secret@", 1) + "/missing.git"
+		})
+
+		It("fails with git's output, without the credentials", func() {
+			Expect(stepErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+			wrapped := stepErr.(*steps.EmittableError).WrappedError()
+			Expect(wrapped.Error()).To(ContainSubstring("not found"))
+			Expect(wrapped.Error()).NotTo(ContainSubstring("secret"))
+		})
+	})
+
+	Context("when the depth is invalid", func() {
+		BeforeEach(func() {
+			downloadAction.From = "git+" + server.URL + "/app.git#depth=deep"
+		})
+
+		It("fails", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: invalid git URL"))
+		})
+	})
+
+	Context("when the depth is 0", func() {
+		BeforeEach(func() {
+			downloadAction.From = "git+" + server.URL + "/app.git#depth=0"
+		})
+
+		It("fails", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: invalid git URL"))
+		})
+	})
+
+	Context("when the action has a checksum", func() {
+		BeforeEach(func() {
+			downloadAction.ChecksumAlgorithm = "sha256"
+			downloadAction.ChecksumValue = "some-checksum"
+		})
+
+		It("fails without streaming anything in", func() {
+			Expect(stepErr).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+			Expect(stepErr.(*steps.EmittableError).WrappedError()).To(Equal(steps.ErrGitChecksumUnsupported))
+			Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+		})
+	})
+
+	Context("when the container's disk quota cannot hold the checkout", func() {
+		BeforeEach(func() {
+			gardenClient.Connection.CurrentDiskLimitsReturns(garden.DiskLimits{ByteHard: 100, Scope: garden.DiskLimitScopeExclusive}, nil)
+			gardenClient.Connection.MetricsReturns(garden.Metrics{DiskStat: garden.ContainerDiskStat{ExclusiveBytesUsed: 99}}, nil)
+		})
+
+		It("fails without streaming anything in", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: insufficient disk"))
+			Expect(gardenClient.Connection.StreamInCallCount()).To(BeZero())
+		})
+
+		It("cleans up the clone", func() {
+			entries, err := ioutil.ReadDir(tempDir)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(entries).To(BeEmpty())
+		})
+	})
+
+	Context("when the container's disk quota is used up", func() {
+		BeforeEach(func() {
+			gardenClient.Connection.CurrentDiskLimitsReturns(garden.DiskLimits{ByteHard: 100, Scope: garden.DiskLimitScopeTotal}, nil)
+			gardenClient.Connection.MetricsReturns(garden.Metrics{DiskStat: garden.ContainerDiskStat{TotalBytesUsed: 100}}, nil)
+			server.Close()
+			server = nil
+		})
+
+		It("fails before cloning", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: insufficient disk"))
+		})
+	})
+
+	Context("when the ref looks like an option", func() {
+		BeforeEach(func() {
+			downloadAction.From = "git+" + server.URL + "/app.git#ref=--upload-pack=touch%20pwned"
+		})
+
+		It("fails", func() {
+			Expect(stepErr).To(MatchError("Downloading failed: invalid git URL"))
+		})
+	})
+
+	Context("when the URL is not http or https", func() {
+		for _, from := range []string{
+			"git+file:///tmp/repo.git",
+			"git+ext::sh -c touch% /tmp/pwned",
+			"git+ssh://example.com/repo.git",
+		} {
+			from := from
+
+			Context(from, func() {
+				BeforeEach(func() {
+					downloadAction.From = from
+				})
+
+				It("fails", func() {
+					Expect(stepErr).To(MatchError("Downloading failed: invalid git URL"))
+				})
+			})
+		}
+	})
+})
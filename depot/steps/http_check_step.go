@@ -0,0 +1,83 @@
+package steps
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+const DefaultHTTPCheckTimeout = time.Second
+
+type httpCheckStep struct {
+	check  executor.HTTPCheck
+	url    string
+	client *http.Client
+	logger lager.Logger
+
+	*canceller
+}
+
+// NewHTTPCheck returns a step that performs a single HTTPCheck against the
+// container at address.
+func NewHTTPCheck(check executor.HTTPCheck, address string, logger lager.Logger) Step {
+	timeout := DefaultHTTPCheckTimeout
+	if check.TimeoutMs > 0 {
+		timeout = time.Duration(check.TimeoutMs) * time.Millisecond
+	}
+
+	path := check.Path
+	if len(path) == 0 || path[0] != '/' {
+		path = "/" + path
+	}
+
+	return &httpCheckStep{
+		check: check,
+		url:   "http://" + net.JoinHostPort(address, strconv.Itoa(int(check.Port))) + path,
+		client: &http.Client{
+			Timeout: timeout,
+			// redirects are judged by their status like any other response
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+		logger: logger.Session("http-check-step"),
+
+		canceller: newCanceller(),
+	}
+}
+
+func (step *httpCheckStep) Perform() error {
+	logger := step.logger.WithData(lager.Data{"url": step.url})
+
+	request, err := http.NewRequest("GET", step.url, nil)
+	if err != nil {
+		logger.Error("failed-to-build-request", err)
+		return err
+	}
+	request.Cancel = step.Cancelled()
+
+	response, err := step.client.Do(request)
+	select {
+	case <-step.Cancelled():
+		return ErrCancelled
+	default:
+	}
+
+	if err != nil {
+		logger.Debug("request-failed", lager.Data{"error": err.Error()})
+		return NewEmittableError(err, "Failed to make HTTP request to '%s' on port %d", step.check.Path, step.check.Port)
+	}
+	response.Body.Close()
+
+	min, max := step.check.StatusRange()
+	if response.StatusCode < min || response.StatusCode > max {
+		logger.Debug("unexpected-status", lager.Data{"status": response.StatusCode})
+		return NewEmittableError(nil, "HTTP health check received status code %d", response.StatusCode)
+	}
+
+	return nil
+}
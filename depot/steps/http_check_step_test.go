@@ -0,0 +1,157 @@
+package steps_test
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HTTPCheckStep", func() {
+	var (
+		server      *httptest.Server
+		status      int
+		requestPath string
+		delay       chan struct{}
+		check       executor.HTTPCheck
+		address     string
+		logger      *lagertest.TestLogger
+		step        steps.Step
+	)
+
+	BeforeEach(func() {
+		status = http.StatusOK
+		delay = nil
+		server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestPath = r.URL.Path
+			if delay != nil {
+				<-delay
+			}
+			if status >= 300 && status < 400 {
+				w.Header().Set("Location", "/elsewhere")
+			}
+			w.WriteHeader(status)
+		}))
+
+		host, port, err := net.SplitHostPort(server.Listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		portNum, err := strconv.Atoi(port)
+		Expect(err).NotTo(HaveOccurred())
+
+		address = host
+		check = executor.HTTPCheck{Path: "/health", Port: uint16(portNum)}
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	JustBeforeEach(func() {
+		step = steps.NewHTTPCheck(check, address, logger)
+	})
+
+	It("succeeds when the endpoint responds with a passing status", func() {
+		Expect(step.Perform()).To(Succeed())
+		Expect(requestPath).To(Equal("/health"))
+	})
+
+	Context("when the path is not rooted", func() {
+		BeforeEach(func() {
+			check.Path = "health"
+		})
+
+		It("requests the rooted path", func() {
+			Expect(step.Perform()).To(Succeed())
+			Expect(requestPath).To(Equal("/health"))
+		})
+	})
+
+	Context("when the endpoint responds with a failing status", func() {
+		BeforeEach(func() {
+			status = http.StatusServiceUnavailable
+		})
+
+		It("fails with an emittable error", func() {
+			err := step.Perform()
+			Expect(err).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+			Expect(err).To(MatchError("HTTP health check received status code 503"))
+		})
+	})
+
+	Context("when a custom status range is given", func() {
+		BeforeEach(func() {
+			status = http.StatusUnauthorized
+			check.MinStatus = 200
+			check.MaxStatus = 499
+		})
+
+		It("passes for statuses in the range", func() {
+			Expect(step.Perform()).To(Succeed())
+		})
+	})
+
+	Context("when the endpoint redirects", func() {
+		BeforeEach(func() {
+			status = http.StatusFound
+		})
+
+		It("judges the redirect by its status without following it", func() {
+			Expect(step.Perform()).To(Succeed())
+			Expect(requestPath).To(Equal("/health"))
+		})
+
+		Context("and redirects are outside the status range", func() {
+			BeforeEach(func() {
+				check.MaxStatus = 299
+			})
+
+			It("fails", func() {
+				Expect(step.Perform()).To(MatchError("HTTP health check received status code 302"))
+			})
+		})
+	})
+
+	Context("when the endpoint does not respond within the timeout", func() {
+		BeforeEach(func() {
+			delay = make(chan struct{})
+			check.TimeoutMs = 10
+		})
+
+		AfterEach(func() {
+			close(delay)
+		})
+
+		It("fails", func() {
+			Expect(step.Perform()).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+		})
+	})
+
+	Context("when cancelled while the request is in flight", func() {
+		BeforeEach(func() {
+			delay = make(chan struct{})
+		})
+
+		AfterEach(func() {
+			close(delay)
+		})
+
+		It("returns ErrCancelled", func() {
+			errCh := make(chan error)
+			go func() {
+				errCh <- step.Perform()
+			}()
+
+			Consistently(errCh, 100*time.Millisecond).ShouldNot(Receive())
+			step.Cancel()
+			Eventually(errCh).Should(Receive(Equal(steps.ErrCancelled)))
+		})
+	})
+})
@@ -18,6 +18,7 @@ const timeoutMessage = "Timed out after %s: health check never passed.\n"
 
 type monitorStep struct {
 	checkFunc         func() Step
+	startupCheckFunc  func() Step
 	hasStartedRunning chan<- struct{}
 
 	logger      lager.Logger
@@ -27,13 +28,21 @@ type monitorStep struct {
 	startTimeout      time.Duration
 	healthyInterval   time.Duration
 	unhealthyInterval time.Duration
+	successThreshold  int
+	failureThreshold  int
 	workPool          *workpool.WorkPool
 
 	*canceller
 }
 
+// NewMonitor returns a step that runs startupCheckFunc's check every
+// unhealthyInterval until successThreshold consecutive checks pass, then
+// checkFunc's check every healthyInterval until failureThreshold consecutive
+// checks fail. When startupCheckFunc is nil checkFunc's check is used for both
+// phases.
 func NewMonitor(
 	checkFunc func() Step,
+	startupCheckFunc func() Step,
 	hasStartedRunning chan<- struct{},
 	logger lager.Logger,
 	clock clock.Clock,
@@ -41,12 +50,27 @@ func NewMonitor(
 	startTimeout time.Duration,
 	healthyInterval time.Duration,
 	unhealthyInterval time.Duration,
+	successThreshold int,
+	failureThreshold int,
 	workPool *workpool.WorkPool,
 ) Step {
 	logger = logger.Session("monitor-step")
 
+	if startupCheckFunc == nil {
+		startupCheckFunc = checkFunc
+	}
+
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+
 	return &monitorStep{
 		checkFunc:         checkFunc,
+		startupCheckFunc:  startupCheckFunc,
 		hasStartedRunning: hasStartedRunning,
 		logger:            logger,
 		clock:             clock,
@@ -54,6 +78,8 @@ func NewMonitor(
 		startTimeout:      startTimeout,
 		healthyInterval:   healthyInterval,
 		unhealthyInterval: unhealthyInterval,
+		successThreshold:  successThreshold,
+		failureThreshold:  failureThreshold,
 
 		canceller: newCanceller(),
 		workPool:  workPool,
@@ -70,6 +96,7 @@ func (step *monitorStep) Perform() error {
 	}
 
 	healthy := false
+	successes, failures := 0, 0
 	interval := step.unhealthyInterval
 
 	var startBy *time.Time
@@ -88,7 +115,12 @@ func (step *monitorStep) Perform() error {
 		case now := <-timer.C():
 			stepResult := make(chan error)
 
-			check := step.checkFunc()
+			var check Step
+			if healthy {
+				check = step.checkFunc()
+			} else {
+				check = step.startupCheckFunc()
+			}
 
 			step.workPool.Submit(func() {
 				stepResult <- check.Perform()
@@ -99,24 +131,42 @@ func (step *monitorStep) Perform() error {
 				nowHealthy := stepErr == nil
 
 				if healthy && !nowHealthy {
-					step.logger.Info("transitioned-to-unhealthy")
+					failures++
+					if failures >= step.failureThreshold {
+						step.logger.Info("transitioned-to-unhealthy")
 
-					fmt.Fprint(step.logStreamer.Stdout(), "Container became unhealthy\n")
+						fmt.Fprint(step.logStreamer.Stdout(), "Container became unhealthy\n")
 
-					return stepErr
-				} else if !healthy && nowHealthy {
-					step.logger.Info("transitioned-to-healthy")
-					healthy = true
-					step.hasStartedRunning <- struct{}{}
-
-					fmt.Fprint(step.logStreamer.Stdout(), "Container became healthy\n")
+						return stepErr
+					}
 
-					interval = step.healthyInterval
-					startBy = nil
+					step.logger.Info("health-check-failed", lager.Data{"failures": failures})
+				} else if healthy {
+					failures = 0
+				} else if !nowHealthy {
+					successes = 0
+				} else {
+					successes++
+					if successes < step.successThreshold {
+						step.logger.Info("health-check-passed", lager.Data{"successes": successes})
+					} else {
+						step.logger.Info("transitioned-to-healthy")
+						healthy = true
+						step.hasStartedRunning <- struct{}{}
+
+						fmt.Fprint(step.logStreamer.Stdout(), "Container became healthy\n")
+
+						interval = step.healthyInterval
+						startBy = nil
+					}
 				}
 
 				if startBy != nil && now.After(*startBy) {
 					if !healthy {
+						if stepErr == nil {
+							stepErr = NewEmittableError(nil, "health check passed %d of %d required consecutive times", successes, step.successThreshold)
+						}
+
 						fmt.Fprintf(step.logStreamer.Stderr(), timeoutMessage, step.startTimeout)
 
 						step.logger.Info("timed-out-before-healthy", lager.Data{
@@ -130,8 +180,11 @@ func (step *monitorStep) Perform() error {
 				}
 
 			case <-step.Cancelled():
+				// the check may fail in any way once cancelled, so its result
+				// is only waited for
 				check.Cancel()
-				return <-stepResult
+				<-stepResult
+				return ErrCancelled
 			}
 
 		case <-step.Cancelled():
@@ -26,6 +26,7 @@ var _ = Describe("MonitorStep", func() {
 		checkSteps chan *fakes.FakeStep
 
 		checkFunc        func() steps.Step
+		startupCheckFunc func() steps.Step
 		hasBecomeHealthy <-chan struct{}
 		clock            *fakeclock.FakeClock
 		fakeStreamer     *fake_log_streamer.FakeLogStreamer
@@ -33,6 +34,8 @@ var _ = Describe("MonitorStep", func() {
 		startTimeout      time.Duration
 		healthyInterval   time.Duration
 		unhealthyInterval time.Duration
+		successThreshold  int
+		failureThreshold  int
 
 		step   steps.Step
 		logger *lagertest.TestLogger
@@ -44,6 +47,8 @@ var _ = Describe("MonitorStep", func() {
 		startTimeout = 0
 		healthyInterval = 1 * time.Second
 		unhealthyInterval = 500 * time.Millisecond
+		successThreshold = 1
+		failureThreshold = 1
 
 		fakeStep1 = new(fakes.FakeStep)
 		fakeStep2 = new(fakes.FakeStep)
@@ -59,6 +64,7 @@ var _ = Describe("MonitorStep", func() {
 		checkFunc = func() steps.Step {
 			return <-checkSteps
 		}
+		startupCheckFunc = nil
 
 		logger = lagertest.NewTestLogger("test")
 	})
@@ -72,6 +78,7 @@ var _ = Describe("MonitorStep", func() {
 
 		step = steps.NewMonitor(
 			checkFunc,
+			startupCheckFunc,
 			hasBecomeHealthyChannel,
 			logger,
 			clock,
@@ -79,6 +86,8 @@ var _ = Describe("MonitorStep", func() {
 			startTimeout,
 			healthyInterval,
 			unhealthyInterval,
+			successThreshold,
+			failureThreshold,
 			workPool,
 		)
 	})
@@ -93,6 +102,98 @@ var _ = Describe("MonitorStep", func() {
 		Eventually(fakeStep.PerformCallCount).Should(Equal(previousCheckCount + 1))
 	}
 
+	Describe("separate startup and liveness checks", func() {
+		var (
+			startupStep  *fakes.FakeStep
+			livenessStep *fakes.FakeStep
+			performErr   chan error
+		)
+
+		BeforeEach(func() {
+			startupStep = new(fakes.FakeStep)
+			livenessStep = new(fakes.FakeStep)
+
+			startupCheckFunc = func() steps.Step {
+				return startupStep
+			}
+			checkFunc = func() steps.Step {
+				return livenessStep
+			}
+		})
+
+		JustBeforeEach(func() {
+			performErr = make(chan error, 1)
+			go func() {
+				performErr <- step.Perform()
+			}()
+		})
+
+		AfterEach(func() {
+			step.Cancel()
+		})
+
+		It("runs the startup check until it passes and the liveness check after", func() {
+			startupStep.PerformReturns(errors.New("not yet"))
+			expectCheckAfterInterval(startupStep, unhealthyInterval)
+			Expect(livenessStep.PerformCallCount()).To(Equal(0))
+
+			startupStep.PerformReturns(nil)
+			expectCheckAfterInterval(startupStep, unhealthyInterval)
+			Eventually(hasBecomeHealthy).Should(Receive())
+
+			expectCheckAfterInterval(livenessStep, healthyInterval)
+			Expect(startupStep.PerformCallCount()).To(Equal(2))
+		})
+
+		Context("when a startup success threshold is set", func() {
+			BeforeEach(func() {
+				successThreshold = 2
+			})
+
+			It("only becomes healthy after that many consecutive passing checks", func() {
+				expectCheckAfterInterval(startupStep, unhealthyInterval)
+				Consistently(hasBecomeHealthy).ShouldNot(Receive())
+
+				startupStep.PerformReturns(errors.New("blip"))
+				expectCheckAfterInterval(startupStep, unhealthyInterval)
+
+				startupStep.PerformReturns(nil)
+				expectCheckAfterInterval(startupStep, unhealthyInterval)
+				Consistently(hasBecomeHealthy).ShouldNot(Receive())
+
+				expectCheckAfterInterval(startupStep, unhealthyInterval)
+				Eventually(hasBecomeHealthy).Should(Receive())
+			})
+		})
+
+		Context("when a liveness failure threshold is set", func() {
+			BeforeEach(func() {
+				failureThreshold = 2
+			})
+
+			It("only becomes unhealthy after that many consecutive failures", func() {
+				disaster := errors.New("oh no!")
+
+				expectCheckAfterInterval(startupStep, unhealthyInterval)
+				Eventually(hasBecomeHealthy).Should(Receive())
+
+				livenessStep.PerformReturns(disaster)
+				expectCheckAfterInterval(livenessStep, healthyInterval)
+				Consistently(performErr).ShouldNot(Receive())
+
+				livenessStep.PerformReturns(nil)
+				expectCheckAfterInterval(livenessStep, healthyInterval)
+
+				livenessStep.PerformReturns(disaster)
+				expectCheckAfterInterval(livenessStep, healthyInterval)
+				Consistently(performErr).ShouldNot(Receive())
+
+				expectCheckAfterInterval(livenessStep, healthyInterval)
+				Eventually(performErr).Should(Receive(Equal(disaster)))
+			})
+		})
+	})
+
 	Describe("Throttling", func() {
 		var (
 			throttleChan chan struct{}
@@ -344,18 +445,22 @@ var _ = Describe("MonitorStep", func() {
 		})
 
 		Context("while checking", func() {
-			var performing chan struct{}
+			var (
+				performing   chan struct{}
+				cancelledErr error
+			)
 
 			BeforeEach(func() {
 				performing = make(chan struct{})
 				cancelled := make(chan struct{})
+				cancelledErr = steps.ErrCancelled
 
 				fakeStep1.PerformStub = func() error {
 					close(performing)
 
 					select {
 					case <-cancelled:
-						return steps.ErrCancelled
+						return cancelledErr
 					}
 				}
 
@@ -377,6 +482,26 @@ var _ = Describe("MonitorStep", func() {
 
 				Eventually(performResult).Should(Receive(Equal(steps.ErrCancelled)))
 			})
+
+			Context("when the check fails some other way once cancelled", func() {
+				BeforeEach(func() {
+					cancelledErr = errors.New("connection refused")
+				})
+
+				It("still reports that it was cancelled", func() {
+					performResult := make(chan error)
+
+					go func() { performResult <- step.Perform() }()
+
+					expectCheckAfterInterval(fakeStep1, unhealthyInterval)
+
+					Eventually(performing).Should(BeClosed())
+
+					step.Cancel()
+
+					Eventually(performResult).Should(Receive(Equal(steps.ErrCancelled)))
+				})
+			})
 		})
 	})
 })
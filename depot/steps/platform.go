@@ -0,0 +1,85 @@
+package steps
+
+import (
+	"path"
+	"strings"
+)
+
+// Platform is the operating system of the containers created by the Garden
+// backend, which is not necessarily the platform the executor runs on.
+type Platform string
+
+const (
+	PlatformLinux   Platform = "linux"
+	PlatformWindows Platform = "windows"
+
+	windowsSystemDrive   = "C:"
+	windowsExecutableExt = ".exe"
+)
+
+func (p Platform) Valid() bool {
+	return p == PlatformLinux || p == PlatformWindows
+}
+
+// SupportsTerminate reports whether processes can be asked to shut down
+// gracefully. Garden-Windows has no equivalent of SIGTERM, so processes there
+// can only be killed.
+func (p Platform) SupportsTerminate() bool {
+	return p != PlatformWindows
+}
+
+// ContainerPath converts a slash-separated path from an action into the form
+// expected by the container's platform. Rooted paths are placed on the system
+// drive on Windows; paths that already name a drive are left alone.
+func (p Platform) ContainerPath(containerPath string) string {
+	if p != PlatformWindows || containerPath == "" {
+		return containerPath
+	}
+
+	if hasDriveLetter(containerPath) {
+		return strings.Replace(containerPath, "/", `\`, -1)
+	}
+
+	if strings.HasPrefix(containerPath, "/") {
+		containerPath = windowsSystemDrive + path.Clean(containerPath)
+	}
+
+	return strings.Replace(containerPath, "/", `\`, -1)
+}
+
+// ExecutablePath is ContainerPath for the program of a run action. On Windows
+// an extensionless path (e.g. the healthcheck binary) refers to its .exe.
+func (p Platform) ExecutablePath(executablePath string) string {
+	if p == PlatformWindows && strings.ContainsAny(executablePath, `/\`) && path.Ext(strings.Replace(executablePath, `\`, "/", -1)) == "" {
+		executablePath += windowsExecutableExt
+	}
+
+	return p.ContainerPath(executablePath)
+}
+
+// normalizeEnv removes variables overridden later in env. Windows variable
+// names are case-insensitive, so PATH and Path refer to the same variable.
+func (p Platform) normalizeEnv(env []string) []string {
+	if p != PlatformWindows {
+		return env
+	}
+
+	seen := map[string]int{}
+	normalized := []string{}
+	for _, e := range env {
+		name := strings.ToUpper(strings.SplitN(e, "=", 2)[0])
+		if i, ok := seen[name]; ok {
+			normalized[i] = e
+			continue
+		}
+		seen[name] = len(normalized)
+		normalized = append(normalized, e)
+	}
+
+	return normalized
+}
+
+func hasDriveLetter(p string) bool {
+	return len(p) >= 2 && p[1] == ':' &&
+		(('a' <= p[0] && p[0] <= 'z') || ('A' <= p[0] && p[0] <= 'Z'))
+}
@@ -0,0 +1,43 @@
+package steps_test
+
+import (
+	"code.cloudfoundry.org/executor/depot/steps"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Platform", func() {
+	Describe("ContainerPath", func() {
+		It("leaves linux paths untouched", func() {
+			Expect(steps.PlatformLinux.ContainerPath("/home/vcap/app")).To(Equal("/home/vcap/app"))
+		})
+
+		It("places rooted windows paths on the system drive", func() {
+			Expect(steps.PlatformWindows.ContainerPath("/Users/vcap/app/")).To(Equal(`C:\Users\vcap\app`))
+		})
+
+		It("keeps an explicit windows drive", func() {
+			Expect(steps.PlatformWindows.ContainerPath("D:/data")).To(Equal(`D:\data`))
+		})
+
+		It("converts relative windows paths", func() {
+			Expect(steps.PlatformWindows.ContainerPath("app/logs")).To(Equal(`app\logs`))
+		})
+	})
+
+	Describe("ExecutablePath", func() {
+		It("leaves linux executables untouched", func() {
+			Expect(steps.PlatformLinux.ExecutablePath("/etc/cf-assets/healthcheck/healthcheck")).To(Equal("/etc/cf-assets/healthcheck/healthcheck"))
+		})
+
+		It("selects the .exe of extensionless windows executables", func() {
+			Expect(steps.PlatformWindows.ExecutablePath("/etc/cf-assets/healthcheck/healthcheck")).To(Equal(`C:\etc\cf-assets\healthcheck\healthcheck.exe`))
+		})
+
+		It("keeps existing extensions and bare command names", func() {
+			Expect(steps.PlatformWindows.ExecutablePath("/tmp/lifecycle/launcher.bat")).To(Equal(`C:\tmp\lifecycle\launcher.bat`))
+			Expect(steps.PlatformWindows.ExecutablePath("powershell")).To(Equal("powershell"))
+		})
+	})
+})
@@ -0,0 +1,64 @@
+package steps
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/lager"
+)
+
+const DefaultPortCheckTimeout = time.Second
+
+type portCheckStep struct {
+	address string
+	ports   []uint16
+	timeout time.Duration
+	logger  lager.Logger
+
+	*canceller
+}
+
+// NewPortCheck returns a step that succeeds when a TCP connection can be
+// opened to each of ports at address.
+func NewPortCheck(address string, ports []uint16, timeout time.Duration, logger lager.Logger) Step {
+	if timeout <= 0 {
+		timeout = DefaultPortCheckTimeout
+	}
+
+	return &portCheckStep{
+		address: address,
+		ports:   ports,
+		timeout: timeout,
+		logger:  logger.Session("port-check-step"),
+
+		canceller: newCanceller(),
+	}
+}
+
+func (step *portCheckStep) Perform() error {
+	for _, port := range step.ports {
+		dialer := net.Dialer{
+			Timeout: step.timeout,
+			Cancel:  step.Cancelled(),
+		}
+
+		conn, err := dialer.Dial("tcp", net.JoinHostPort(step.address, strconv.Itoa(int(port))))
+		select {
+		case <-step.Cancelled():
+			if err == nil {
+				conn.Close()
+			}
+			return ErrCancelled
+		default:
+		}
+
+		if err != nil {
+			step.logger.Debug("failed-to-connect", lager.Data{"port": port, "error": err.Error()})
+			return NewEmittableError(err, "Failed to connect to port %d", port)
+		}
+		conn.Close()
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package steps_test
+
+import (
+	"net"
+	"strconv"
+	"time"
+
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/lager/lagertest"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("PortCheckStep", func() {
+	var (
+		listener net.Listener
+		address  string
+		port     uint16
+		ports    []uint16
+		logger   *lagertest.TestLogger
+		step     steps.Step
+	)
+
+	BeforeEach(func() {
+		var err error
+		listener, err = net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).NotTo(HaveOccurred())
+
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				conn.Close()
+			}
+		}()
+
+		host, portStr, err := net.SplitHostPort(listener.Addr().String())
+		Expect(err).NotTo(HaveOccurred())
+		portNum, err := strconv.Atoi(portStr)
+		Expect(err).NotTo(HaveOccurred())
+
+		address = host
+		port = uint16(portNum)
+		ports = []uint16{port}
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	AfterEach(func() {
+		listener.Close()
+	})
+
+	JustBeforeEach(func() {
+		step = steps.NewPortCheck(address, ports, 100*time.Millisecond, logger)
+	})
+
+	It("succeeds when the ports accept connections", func() {
+		Expect(step.Perform()).To(Succeed())
+	})
+
+	Context("when a port is not listening", func() {
+		BeforeEach(func() {
+			closed, err := net.Listen("tcp", "127.0.0.1:0")
+			Expect(err).NotTo(HaveOccurred())
+			_, closedPort, err := net.SplitHostPort(closed.Addr().String())
+			Expect(err).NotTo(HaveOccurred())
+			closed.Close()
+
+			closedPortNum, err := strconv.Atoi(closedPort)
+			Expect(err).NotTo(HaveOccurred())
+			ports = append(ports, uint16(closedPortNum))
+		})
+
+		It("fails with an emittable error naming the port", func() {
+			err := step.Perform()
+			Expect(err).To(BeAssignableToTypeOf(&steps.EmittableError{}))
+			Expect(err.Error()).To(ContainSubstring("Failed to connect to port"))
+		})
+	})
+
+	Context("when cancelled before performing", func() {
+		It("returns ErrCancelled", func() {
+			step.Cancel()
+			Expect(step.Perform()).To(Equal(steps.ErrCancelled))
+		})
+	})
+})
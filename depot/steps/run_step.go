@@ -29,6 +29,8 @@ type runStep struct {
 	internalIP           string
 	portMappings         []executor.PortMapping
 	exportNetworkEnvVars bool
+	platform             Platform
+	stopSignal           executor.StopSignal
 	clock                clock.Clock
 
 	*canceller
@@ -43,6 +45,8 @@ func NewRun(
 	internalIP string,
 	portMappings []executor.PortMapping,
 	exportNetworkEnvVars bool,
+	platform Platform,
+	stopSignal executor.StopSignal,
 	clock clock.Clock,
 ) *runStep {
 	logger = logger.Session("run-step")
@@ -55,6 +59,8 @@ func NewRun(
 		internalIP:           internalIP,
 		portMappings:         portMappings,
 		exportNetworkEnvVars: exportNetworkEnvVars,
+		platform:             platform,
+		stopSignal:           stopSignal,
 		clock:                clock,
 
 		canceller: newCanceller(),
@@ -70,6 +76,8 @@ func (step *runStep) Perform() error {
 		envVars = append(envVars, step.networkingEnvVars()...)
 	}
 
+	envVars = step.platform.normalizeEnv(envVars)
+
 	cancel := step.Cancelled()
 
 	select {
@@ -196,16 +204,23 @@ func (step *runStep) Perform() error {
 			return err
 
 		case <-cancel:
-			logger.Debug("signalling-terminate")
-			err := process.Signal(garden.SignalTerminate)
-			if err != nil {
-				logger.Error("signalling-terminate-failed", err)
-			}
-
-			logger.Debug("signalling-terminate-success")
 			cancel = nil
+			terminateTimeout := TerminateTimeout
+
+			if step.platform.SupportsTerminate() {
+				logger.Debug("signalling-terminate")
+				err := process.Signal(gardenStopSignal(step.stopSignal))
+				if err != nil {
+					logger.Error("signalling-terminate-failed", err)
+				}
+
+				logger.Debug("signalling-terminate-success")
+			} else {
+				logger.Debug("platform-does-not-support-terminate", lager.Data{"platform": step.platform})
+				terminateTimeout = 0
+			}
 
-			killTimer := step.clock.NewTimer(TerminateTimeout)
+			killTimer := step.clock.NewTimer(terminateTimeout)
 			defer killTimer.Stop()
 
 			killSwitch = killTimer.C()
@@ -34,6 +34,8 @@ var _ = Describe("RunAction", func() {
 		externalIP, internalIP              string
 		portMappings                        []executor.PortMapping
 		exportNetworkEnvVars                bool
+		platform                            steps.Platform
+		stopSignal                          executor.StopSignal
 		fakeClock                           *fakeclock.FakeClock
 
 		spawnedProcess *gardenfakes.FakeProcess
@@ -76,6 +78,8 @@ var _ = Describe("RunAction", func() {
 		internalIP = "internal-ip"
 		portMappings = nil
 		exportNetworkEnvVars = false
+		platform = steps.PlatformLinux
+		stopSignal = executor.StopSignal{}
 		fakeClock = fakeclock.NewFakeClock(time.Unix(123, 456))
 	})
 
@@ -96,6 +100,8 @@ var _ = Describe("RunAction", func() {
 			internalIP,
 			portMappings,
 			exportNetworkEnvVars,
+			platform,
+			stopSignal,
 			fakeClock,
 		)
 	})
@@ -263,6 +269,30 @@ var _ = Describe("RunAction", func() {
 			})
 		})
 
+		Context("when a stop signal is configured", func() {
+			BeforeEach(func() {
+				stopSignal = executor.StopSignal{Signal: executor.SignalTerm}
+			})
+
+			It("runs the process unmodified", func() {
+				_, spec, _ := gardenClient.Connection.RunArgsForCall(0)
+				Expect(spec.Path).To(Equal("sudo"))
+				Expect(spec.Args).To(Equal([]string{"reboot"}))
+			})
+		})
+
+		Context("when the container platform is windows", func() {
+			BeforeEach(func() {
+				platform = steps.PlatformWindows
+				runAction.Env = append(runAction.Env, &models.EnvironmentVariable{Name: "a", Value: "3"})
+			})
+
+			It("treats environment variable names case-insensitively", func() {
+				_, spec, _ := gardenClient.Connection.RunArgsForCall(0)
+				Expect(spec.Env).To(Equal([]string{"a=3", "B=2"}))
+			})
+		})
+
 		Context("when resource limits are not configured", func() {
 			BeforeEach(func() {
 				runAction.ResourceLimits = nil
@@ -493,6 +523,17 @@ var _ = Describe("RunAction", func() {
 				Expect(spawnedProcess.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
 			})
 
+			Context("when TERM is configured as the stop signal", func() {
+				BeforeEach(func() {
+					stopSignal = executor.StopSignal{Signal: executor.SignalTerm}
+				})
+
+				It("sends a terminate signal through garden", func() {
+					Eventually(spawnedProcess.SignalCallCount).Should(Equal(1))
+					Expect(spawnedProcess.SignalArgsForCall(0)).To(Equal(garden.SignalTerminate))
+				})
+			})
+
 			Context("when the process exits", func() {
 				It("completes the perform without having sent kill", func() {
 					Eventually(spawnedProcess.SignalCallCount).Should(Equal(1))
@@ -546,6 +587,36 @@ var _ = Describe("RunAction", func() {
 
 		})
 
+		Context("when the container platform is windows", func() {
+			BeforeEach(func() {
+				platform = steps.PlatformWindows
+			})
+
+			JustBeforeEach(func() {
+				go func() {
+					performErr <- step.Perform()
+					close(performErr)
+				}()
+
+				Eventually(waiting).Should(BeClosed())
+				step.Cancel()
+			})
+
+			AfterEach(func() {
+				close(waitExited)
+				Eventually(performErr).Should(BeClosed())
+			})
+
+			It("kills the process without sending a terminate signal", func() {
+				Eventually(spawnedProcess.SignalCallCount).Should(Equal(1))
+				Expect(spawnedProcess.SignalArgsForCall(0)).To(Equal(garden.SignalKill))
+
+				waitExited <- 1
+
+				Eventually(performErr).Should(Receive(Equal(steps.ErrCancelled)))
+			})
+		})
+
 		Context("when Garden hangs on spawning a process", func() {
 			var hangChan chan struct{}
 			BeforeEach(func() {
@@ -0,0 +1,69 @@
+package steps
+
+import "errors"
+
+var ErrSidecarExited = errors.New("Sidecar exited")
+
+type sidecarStep struct {
+	main     Step
+	sidecars []Step
+}
+
+// NewSidecars returns a step that performs main alongside sidecars. The
+// sidecars are cancelled once main exits, and main is cancelled as soon as a
+// sidecar exits, which is an error even when the sidecar succeeded.
+func NewSidecars(main Step, sidecars []Step) *sidecarStep {
+	return &sidecarStep{
+		main:     main,
+		sidecars: sidecars,
+	}
+}
+
+func (step *sidecarStep) Perform() error {
+	mainErr := make(chan error, 1)
+	go func() {
+		mainErr <- step.main.Perform()
+	}()
+
+	sidecarErrs := make(chan error, len(step.sidecars))
+	for _, sidecar := range step.sidecars {
+		go func(sidecar Step) {
+			sidecarErrs <- sidecar.Perform()
+		}(sidecar)
+	}
+
+	var err error
+	remaining := len(step.sidecars)
+
+	select {
+	case err = <-mainErr:
+		step.cancelSidecars()
+
+	case err = <-sidecarErrs:
+		remaining--
+		if err == nil {
+			err = ErrSidecarExited
+		}
+
+		step.main.Cancel()
+		step.cancelSidecars()
+		<-mainErr
+	}
+
+	for ; remaining > 0; remaining-- {
+		<-sidecarErrs
+	}
+
+	return err
+}
+
+func (step *sidecarStep) Cancel() {
+	step.main.Cancel()
+	step.cancelSidecars()
+}
+
+func (step *sidecarStep) cancelSidecars() {
+	for _, sidecar := range step.sidecars {
+		sidecar.Cancel()
+	}
+}
@@ -0,0 +1,97 @@
+package steps_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/steps/fakes"
+)
+
+var _ = Describe("SidecarStep", func() {
+	var (
+		step    steps.Step
+		main    *fakes.FakeStep
+		sidecar *fakes.FakeStep
+
+		mainExit      chan error
+		sidecarExit   chan error
+		mainCancel    chan struct{}
+		sidecarCancel chan struct{}
+	)
+
+	BeforeEach(func() {
+		mainExit = make(chan error, 1)
+		sidecarExit = make(chan error, 1)
+		mainCancel = make(chan struct{}, 1)
+		sidecarCancel = make(chan struct{}, 1)
+
+		main = &fakes.FakeStep{
+			PerformStub: func() error {
+				select {
+				case err := <-mainExit:
+					return err
+				case <-mainCancel:
+					return steps.ErrCancelled
+				}
+			},
+			CancelStub: func() {
+				mainCancel <- struct{}{}
+			},
+		}
+
+		sidecar = &fakes.FakeStep{
+			PerformStub: func() error {
+				select {
+				case err := <-sidecarExit:
+					return err
+				case <-sidecarCancel:
+					return steps.ErrCancelled
+				}
+			},
+			CancelStub: func() {
+				sidecarCancel <- struct{}{}
+			},
+		}
+
+		step = steps.NewSidecars(main, []steps.Step{sidecar})
+	})
+
+	Context("when the main step exits", func() {
+		It("cancels the sidecars and returns the main step's result", func() {
+			mainExit <- nil
+			Expect(step.Perform()).To(Succeed())
+			Expect(sidecar.CancelCallCount()).To(Equal(1))
+		})
+
+		It("returns the main step's error", func() {
+			disaster := errors.New("oh no!")
+			mainExit <- disaster
+			Expect(step.Perform()).To(Equal(disaster))
+		})
+	})
+
+	Context("when a sidecar exits", func() {
+		It("cancels the main step and fails", func() {
+			sidecarExit <- nil
+			Expect(step.Perform()).To(Equal(steps.ErrSidecarExited))
+			Expect(main.CancelCallCount()).To(Equal(1))
+		})
+
+		It("returns the sidecar's error", func() {
+			disaster := errors.New("oh no!")
+			sidecarExit <- disaster
+			Expect(step.Perform()).To(Equal(disaster))
+		})
+	})
+
+	Describe("Cancel", func() {
+		It("cancels the main step and the sidecars", func() {
+			step.Cancel()
+			Expect(main.CancelCallCount()).To(Equal(1))
+			Expect(sidecar.CancelCallCount()).To(Equal(1))
+		})
+	})
+})
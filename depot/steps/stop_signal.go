@@ -0,0 +1,14 @@
+package steps
+
+import (
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/garden"
+)
+
+// gardenStopSignal is the signal garden sends a process to stop it. The
+// signal is delivered by garden itself, so the process runs unmodified and
+// needs nothing from the container's rootfs. Garden's process API can only
+// carry TERM and KILL, so TERM is the only valid stop signal.
+func gardenStopSignal(stopSignal executor.StopSignal) garden.Signal {
+	return garden.SignalTerminate
+}
@@ -11,6 +11,7 @@ import (
 	"code.cloudfoundry.org/archiver/compressor"
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/bytefmt"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/executor/depot/uploader"
 	"code.cloudfoundry.org/garden"
@@ -24,7 +25,7 @@ type uploadStep struct {
 	compressor  compressor.Compressor
 	tempDir     string
 	streamer    log_streamer.LogStreamer
-	rateLimiter chan struct{}
+	rateLimiter limiter.Limiter
 	logger      lager.Logger
 
 	*canceller
@@ -37,7 +38,7 @@ func NewUpload(
 	compressor compressor.Compressor,
 	tempDir string,
 	streamer log_streamer.LogStreamer,
-	rateLimiter chan struct{},
+	rateLimiter limiter.Limiter,
 	logger lager.Logger,
 ) *uploadStep {
 	logger = logger.Session("upload-step", lager.Data{
@@ -67,10 +68,11 @@ const (
 )
 
 func (step *uploadStep) Perform() (err error) {
-	step.rateLimiter <- struct{}{}
-	defer func() {
-		<-step.rateLimiter
-	}()
+	err = step.rateLimiter.Acquire(step.logger, step.container.Handle(), step.Cancelled())
+	if err != nil {
+		return ErrCancelled
+	}
+	defer step.rateLimiter.Release()
 
 	step.logger.Info("upload-starting")
 	step.emit("Uploading %s...\n", step.model.Artifact)
@@ -19,11 +19,14 @@ import (
 	"github.com/onsi/gomega/gbytes"
 
 	Compressor "code.cloudfoundry.org/archiver/compressor"
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/log_streamer/fake_log_streamer"
 	"code.cloudfoundry.org/executor/depot/steps"
 	Uploader "code.cloudfoundry.org/executor/depot/uploader"
 	"code.cloudfoundry.org/executor/depot/uploader/fake_uploader"
 	"code.cloudfoundry.org/executor/fakes"
+	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
 	"code.cloudfoundry.org/lager/lagertest"
 )
 
@@ -49,6 +52,10 @@ func newFakeStreamer() *fake_log_streamer.FakeLogStreamer {
 	return fakeStreamer
 }
 
+func newLimiter(maxConcurrent int) limiter.Limiter {
+	return limiter.New(maxConcurrent, "QueueWaitDuration", "QueueDepth", new(mfakes.FakeClient), clock.NewClock())
+}
+
 var _ = Describe("UploadStep", func() {
 	var (
 		step steps.Step
@@ -118,7 +125,7 @@ var _ = Describe("UploadStep", func() {
 			compressor,
 			tempDir,
 			fakeStreamer,
-			make(chan struct{}, 1),
+			newLimiter(1),
 			logger,
 		)
 	})
@@ -369,7 +376,7 @@ var _ = Describe("UploadStep", func() {
 		})
 
 		It("allows only N concurrent uploads", func() {
-			rateLimiter := make(chan struct{}, 2)
+			rateLimiter := newLimiter(2)
 
 			ready := make(chan struct{}, 3)
 			barrier := make(chan struct{})
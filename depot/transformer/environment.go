@@ -0,0 +1,49 @@
+package transformer
+
+import (
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/executor"
+)
+
+// defaultEnvironment returns the operator configured environment without the
+// variables the container sets itself. Container level variables are set on
+// the garden container, so they would otherwise be shadowed by the process
+// environment.
+func (t *transformer) defaultEnvironment(containerEnv []executor.EnvironmentVariable) []*models.EnvironmentVariable {
+	overridden := map[string]bool{}
+	for _, envVar := range containerEnv {
+		overridden[envVar.Name] = true
+	}
+
+	defaults := []*models.EnvironmentVariable{}
+	for _, envVar := range t.environment {
+		if overridden[envVar.Name] {
+			continue
+		}
+		defaults = append(defaults, &models.EnvironmentVariable{Name: envVar.Name, Value: envVar.Value})
+	}
+
+	return defaults
+}
+
+// mergeEnvironment places defaults before env, dropping any default that env
+// sets itself.
+func mergeEnvironment(defaults, env []*models.EnvironmentVariable) []*models.EnvironmentVariable {
+	if len(defaults) == 0 {
+		return env
+	}
+
+	overridden := map[string]bool{}
+	for _, envVar := range env {
+		overridden[envVar.Name] = true
+	}
+
+	merged := make([]*models.EnvironmentVariable, 0, len(defaults)+len(env))
+	for _, envVar := range defaults {
+		if !overridden[envVar.Name] {
+			merged = append(merged, envVar)
+		}
+	}
+
+	return append(merged, env...)
+}
@@ -41,6 +41,14 @@ type FakeTransformer struct {
 		result1 ifrit.Runner
 		result2 error
 	}
+	ValidateActionsStub        func(arg1 executor.RunInfo) error
+	validateActionsMutex       sync.RWMutex
+	validateActionsArgsForCall []struct {
+		arg1 executor.RunInfo
+	}
+	validateActionsReturns struct {
+		result1 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -126,6 +134,39 @@ func (fake *FakeTransformer) StepsRunnerReturns(result1 ifrit.Runner, result2 er
 	}{result1, result2}
 }
 
+func (fake *FakeTransformer) ValidateActions(arg1 executor.RunInfo) error {
+	fake.validateActionsMutex.Lock()
+	fake.validateActionsArgsForCall = append(fake.validateActionsArgsForCall, struct {
+		arg1 executor.RunInfo
+	}{arg1})
+	fake.recordInvocation("ValidateActions", []interface{}{arg1})
+	fake.validateActionsMutex.Unlock()
+	if fake.ValidateActionsStub != nil {
+		return fake.ValidateActionsStub(arg1)
+	} else {
+		return fake.validateActionsReturns.result1
+	}
+}
+
+func (fake *FakeTransformer) ValidateActionsCallCount() int {
+	fake.validateActionsMutex.RLock()
+	defer fake.validateActionsMutex.RUnlock()
+	return len(fake.validateActionsArgsForCall)
+}
+
+func (fake *FakeTransformer) ValidateActionsArgsForCall(i int) executor.RunInfo {
+	fake.validateActionsMutex.RLock()
+	defer fake.validateActionsMutex.RUnlock()
+	return fake.validateActionsArgsForCall[i].arg1
+}
+
+func (fake *FakeTransformer) ValidateActionsReturns(result1 error) {
+	fake.ValidateActionsStub = nil
+	fake.validateActionsReturns = struct {
+		result1 error
+	}{result1}
+}
+
 func (fake *FakeTransformer) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -133,6 +174,8 @@ func (fake *FakeTransformer) Invocations() map[string][][]interface{} {
 	defer fake.stepForMutex.RUnlock()
 	fake.stepsRunnerMutex.RLock()
 	defer fake.stepsRunnerMutex.RUnlock()
+	fake.validateActionsMutex.RLock()
+	defer fake.validateActionsMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -0,0 +1,112 @@
+package transformer
+
+import (
+	"io"
+	"sync"
+
+	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/executor/depot/steps"
+)
+
+// MaxHealthCheckOutput is the number of trailing bytes of a failed health
+// check's output that are kept.
+const MaxHealthCheckOutput = 4096
+
+// HealthCheckOutputReporter is implemented by runners that keep the output of
+// the last health check, if it failed. It is empty once a check passes.
+type HealthCheckOutputReporter interface {
+	HealthCheckOutput() string
+}
+
+type healthCheckOutput struct {
+	lock   sync.Mutex
+	output string
+}
+
+func (o *healthCheckOutput) set(output string) {
+	o.lock.Lock()
+	o.output = output
+	o.lock.Unlock()
+}
+
+func (o *healthCheckOutput) get() string {
+	o.lock.Lock()
+	defer o.lock.Unlock()
+	return o.output
+}
+
+// tailBuffer keeps the last MaxHealthCheckOutput bytes written to it.
+type tailBuffer struct {
+	lock sync.Mutex
+	data []byte
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	b.data = append(b.data, p...)
+	if len(b.data) > MaxHealthCheckOutput {
+		b.data = b.data[len(b.data)-MaxHealthCheckOutput:]
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) String() string {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	return string(b.data)
+}
+
+// capturingStreamer copies everything written to its streams into buffer.
+// Run actions given a capturingStreamer have their output captured even when
+// they suppress log output, in which case it is only captured.
+type capturingStreamer struct {
+	log_streamer.LogStreamer
+	buffer *tailBuffer
+}
+
+func newCapturingStreamer(streamer log_streamer.LogStreamer) *capturingStreamer {
+	return &capturingStreamer{LogStreamer: streamer, buffer: &tailBuffer{}}
+}
+
+func (s *capturingStreamer) Stdout() io.Writer {
+	return io.MultiWriter(s.LogStreamer.Stdout(), s.buffer)
+}
+
+func (s *capturingStreamer) Stderr() io.Writer {
+	return io.MultiWriter(s.LogStreamer.Stderr(), s.buffer)
+}
+
+func (s *capturingStreamer) WithSource(sourceName string) log_streamer.LogStreamer {
+	return &capturingStreamer{LogStreamer: s.LogStreamer.WithSource(sourceName), buffer: s.buffer}
+}
+
+func (s *capturingStreamer) captureOnly() log_streamer.LogStreamer {
+	return &capturingStreamer{LogStreamer: log_streamer.NewNoopStreamer(), buffer: s.buffer}
+}
+
+// capturedStep records the output of its substep in output when it fails,
+// and clears it when it passes. Steps that print nothing, like HTTP checks,
+// record their error instead.
+type capturedStep struct {
+	steps.Step
+	streamer *capturingStreamer
+	output   *healthCheckOutput
+}
+
+func (s *capturedStep) Perform() error {
+	err := s.Step.Perform()
+	switch err {
+	case nil:
+		s.output.set("")
+	case steps.ErrCancelled:
+	default:
+		output := s.streamer.buffer.String()
+		if output == "" {
+			output = err.Error()
+		}
+		s.output.set(output)
+	}
+	return err
+}
@@ -0,0 +1,124 @@
+package transformer
+
+import (
+	"errors"
+	"sync"
+
+	"code.cloudfoundry.org/bbs/models"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrActionTypeRegistered = errors.New("a step is already registered for this action type")
+
+// StepContext is what a StepFactory is given to build a step with, besides
+// the action itself.
+type StepContext struct {
+	LogStreamer log_streamer.LogStreamer
+	Container   garden.Container
+	ExternalIP  string
+	InternalIP  string
+	Ports       []executor.PortMapping
+	Logger      lager.Logger
+}
+
+// StepFactory builds the step that performs action.
+type StepFactory func(action models.ActionInterface, context StepContext) steps.Step
+
+// ActionValidator checks an action before the container that runs it is
+// accepted, so that a bad action is rejected up front instead of failing the
+// container later.
+type ActionValidator func(action models.ActionInterface) error
+
+type registration struct {
+	factory  StepFactory
+	validate ActionValidator
+}
+
+// Registry lets programs embedding the executor choose the step that
+// performs an action type, in place of the transformer's own. Action types
+// are those returned by the action's ActionType, e.g. "download".
+type Registry struct {
+	lock          sync.RWMutex
+	registrations map[string]registration
+}
+
+func NewRegistry() *Registry {
+	return &Registry{
+		registrations: map[string]registration{},
+	}
+}
+
+// Register has actions of actionType performed by the steps factory builds.
+// validate is optional. Each action type can be registered once.
+func (r *Registry) Register(actionType string, factory StepFactory, validate ActionValidator) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.registrations[actionType]; ok {
+		return ErrActionTypeRegistered
+	}
+
+	r.registrations[actionType] = registration{factory: factory, validate: validate}
+	return nil
+}
+
+func (r *Registry) lookup(action interface{}) (models.ActionInterface, registration, bool) {
+	if r == nil {
+		return nil, registration{}, false
+	}
+
+	actionModel, ok := action.(models.ActionInterface)
+	if !ok {
+		return nil, registration{}, false
+	}
+
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	reg, ok := r.registrations[actionModel.ActionType()]
+	return actionModel, reg, ok
+}
+
+// validate runs the registered validators over action and every action
+// nested in it.
+func (r *Registry) validate(action *models.Action) error {
+	if r == nil || action == nil {
+		return nil
+	}
+
+	a := action.GetValue()
+	if actionModel, reg, ok := r.lookup(a); ok {
+		if reg.validate == nil {
+			return nil
+		}
+		return reg.validate(actionModel)
+	}
+
+	var nested []*models.Action
+	switch actionModel := a.(type) {
+	case *models.EmitProgressAction:
+		nested = []*models.Action{actionModel.Action}
+	case *models.TimeoutAction:
+		nested = []*models.Action{actionModel.Action}
+	case *models.TryAction:
+		nested = []*models.Action{actionModel.Action}
+	case *models.ParallelAction:
+		nested = actionModel.Actions
+	case *models.CodependentAction:
+		nested = actionModel.Actions
+	case *models.SerialAction:
+		nested = actionModel.Actions
+	}
+
+	for _, action := range nested {
+		err := r.validate(action)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
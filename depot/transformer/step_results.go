@@ -0,0 +1,77 @@
+package transformer
+
+import (
+	"sync"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/steps"
+)
+
+// StepResultsReporter is implemented by runners that record how each of the
+// container's top level steps ran.
+type StepResultsReporter interface {
+	StepResults() []executor.StepResult
+}
+
+type stepResults struct {
+	lock    sync.Mutex
+	results []executor.StepResult
+}
+
+func (r *stepResults) start(name string, startedAt int64) int {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.results = append(r.results, executor.StepResult{Name: name, StartedAt: startedAt})
+	return len(r.results) - 1
+}
+
+func (r *stepResults) finish(i int, finishedAt int64, err error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.results[i].FinishedAt = finishedAt
+	if err != nil {
+		// steps are cancelled when a step they run alongside exits, or when
+		// the container is stopped, neither of which is their failure
+		r.results[i].Failed = err != steps.ErrCancelled
+		r.results[i].Error = err.Error()
+	}
+}
+
+func (r *stepResults) get() []executor.StepResult {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if len(r.results) == 0 {
+		return nil
+	}
+
+	results := make([]executor.StepResult, len(r.results))
+	copy(results, r.results)
+	return results
+}
+
+// timedStep records the start, end and error of its substep under name.
+type timedStep struct {
+	steps.Step
+	name    string
+	clock   clock.Clock
+	results *stepResults
+}
+
+func newTimedStep(step steps.Step, name string, clock clock.Clock, results *stepResults) steps.Step {
+	if step == nil {
+		return nil
+	}
+
+	return &timedStep{Step: step, name: name, clock: clock, results: results}
+}
+
+func (s *timedStep) Perform() error {
+	i := s.results.start(s.name, s.clock.Now().UnixNano())
+	err := s.Step.Perform()
+	s.results.finish(i, s.clock.Now().UnixNano(), err)
+	return err
+}
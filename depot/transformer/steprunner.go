@@ -3,16 +3,35 @@ package transformer
 import (
 	"os"
 
+	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/steps"
 )
 
 type StepRunner struct {
 	action            steps.Step
 	healthCheckPassed <-chan struct{}
+	healthCheckOutput *healthCheckOutput
+	stepResults       *stepResults
 }
 
-func newStepRunner(action steps.Step, healthCheckPassed <-chan struct{}) *StepRunner {
-	return &StepRunner{action: action, healthCheckPassed: healthCheckPassed}
+func newStepRunner(action steps.Step, healthCheckPassed <-chan struct{}, healthCheckOutput *healthCheckOutput, stepResults *stepResults) *StepRunner {
+	return &StepRunner{
+		action:            action,
+		healthCheckPassed: healthCheckPassed,
+		healthCheckOutput: healthCheckOutput,
+		stepResults:       stepResults,
+	}
+}
+
+// StepResults returns how each of the container's top level steps has run so
+// far.
+func (p *StepRunner) StepResults() []executor.StepResult {
+	return p.stepResults.get()
+}
+
+// HealthCheckOutput returns the output of the last failed health check.
+func (p *StepRunner) HealthCheckOutput() string {
+	return p.healthCheckOutput.get()
 }
 
 func (p *StepRunner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
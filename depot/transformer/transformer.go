@@ -11,6 +11,7 @@ import (
 	"code.cloudfoundry.org/cacheddownloader"
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
 	"code.cloudfoundry.org/executor/depot/steps"
 	"code.cloudfoundry.org/executor/depot/uploader"
@@ -27,6 +28,36 @@ var ErrNoCheck = errors.New("no check configured")
 type Transformer interface {
 	StepFor(log_streamer.LogStreamer, *models.Action, garden.Container, string, string, []executor.PortMapping, lager.Logger) steps.Step
 	StepsRunner(lager.Logger, executor.Container, garden.Container, log_streamer.LogStreamer) (ifrit.Runner, error)
+	ValidateActions(executor.RunInfo) error
+}
+
+// ProcessLimits caps the rlimits of the processes run actions start. Run
+// actions that set no limit get the cap, and larger limits are lowered to it.
+// Zero leaves the limit to the action.
+type ProcessLimits struct {
+	MaxNofile uint64
+	MaxNproc  uint64
+}
+
+func (l ProcessLimits) apply(limits *models.ResourceLimits) *models.ResourceLimits {
+	if l.MaxNofile == 0 && l.MaxNproc == 0 {
+		return limits
+	}
+
+	applied := &models.ResourceLimits{}
+	if limits != nil {
+		*applied = *limits
+	}
+	applied.Nofile = capLimit(applied.Nofile, l.MaxNofile)
+	applied.Nproc = capLimit(applied.Nproc, l.MaxNproc)
+	return applied
+}
+
+func capLimit(limit *uint64, max uint64) *uint64 {
+	if max == 0 || (limit != nil && *limit <= max) {
+		return limit
+	}
+	return &max
 }
 
 type transformer struct {
@@ -34,18 +65,29 @@ type transformer struct {
 	uploader             uploader.Uploader
 	extractor            extractor.Extractor
 	compressor           compressor.Compressor
-	downloadLimiter      chan struct{}
-	uploadLimiter        chan struct{}
+	downloadLimiter      limiter.Limiter
+	uploadLimiter        limiter.Limiter
 	tempDir              string
+	proxyEnv             []string
 	exportNetworkEnvVars bool
+	platform             steps.Platform
+	environment          []executor.EnvironmentVariable
 	clock                clock.Clock
 
 	postSetupHook []string
 	postSetupUser string
 
+	// defaultUser runs, downloads and uploads for actions that do not name
+	// a user
+	defaultUser string
+
+	processLimits ProcessLimits
+
 	healthyMonitoringInterval   time.Duration
 	unhealthyMonitoringInterval time.Duration
 	healthCheckWorkPool         *workpool.WorkPool
+
+	registry *Registry
 }
 
 func NewTransformer(
@@ -53,9 +95,10 @@ func NewTransformer(
 	uploader uploader.Uploader,
 	extractor extractor.Extractor,
 	compressor compressor.Compressor,
-	downloadLimiter chan struct{},
-	uploadLimiter chan struct{},
+	downloadLimiter limiter.Limiter,
+	uploadLimiter limiter.Limiter,
 	tempDir string,
+	proxyEnv []string,
 	exportNetworkEnvVars bool,
 	healthyMonitoringInterval time.Duration,
 	unhealthyMonitoringInterval time.Duration,
@@ -63,6 +106,11 @@ func NewTransformer(
 	clock clock.Clock,
 	postSetupHook []string,
 	postSetupUser string,
+	defaultUser string,
+	processLimits ProcessLimits,
+	platform steps.Platform,
+	environment []executor.EnvironmentVariable,
+	registry *Registry,
 ) *transformer {
 	return &transformer{
 		cachedDownloader:            cachedDownloader,
@@ -72,6 +120,7 @@ func NewTransformer(
 		downloadLimiter:             downloadLimiter,
 		uploadLimiter:               uploadLimiter,
 		tempDir:                     tempDir,
+		proxyEnv:                    proxyEnv,
 		exportNetworkEnvVars:        exportNetworkEnvVars,
 		healthyMonitoringInterval:   healthyMonitoringInterval,
 		unhealthyMonitoringInterval: unhealthyMonitoringInterval,
@@ -79,9 +128,21 @@ func NewTransformer(
 		clock:                       clock,
 		postSetupHook:               postSetupHook,
 		postSetupUser:               postSetupUser,
+		defaultUser:                 defaultUser,
+		processLimits:               processLimits,
+		platform:                    platform,
+		environment:                 environment,
+		registry:                    registry,
 	}
 }
 
+func (t *transformer) userFor(user string) string {
+	if user == "" {
+		return t.defaultUser
+	}
+	return user
+}
+
 func (t *transformer) StepFor(
 	logStreamer log_streamer.LogStreamer,
 	action *models.Action,
@@ -90,26 +151,80 @@ func (t *transformer) StepFor(
 	internalIP string,
 	ports []executor.PortMapping,
 	logger lager.Logger,
+) steps.Step {
+	return t.stepFor(logStreamer, action, container, externalIP, internalIP, ports, executor.StopSignal{}, t.defaultEnvironment(nil), logger)
+}
+
+func (t *transformer) stepFor(
+	logStreamer log_streamer.LogStreamer,
+	action *models.Action,
+	container garden.Container,
+	externalIP string,
+	internalIP string,
+	ports []executor.PortMapping,
+	stopSignal executor.StopSignal,
+	defaultEnv []*models.EnvironmentVariable,
+	logger lager.Logger,
 ) steps.Step {
 	a := action.GetValue()
+	if actionModel, reg, ok := t.registry.lookup(a); ok {
+		return reg.factory(actionModel, StepContext{
+			LogStreamer: logStreamer,
+			Container:   container,
+			ExternalIP:  externalIP,
+			InternalIP:  internalIP,
+			Ports:       ports,
+			Logger:      logger,
+		})
+	}
+
 	switch actionModel := a.(type) {
 	case *models.RunAction:
+		runAction := *actionModel
+		runAction.Path = t.platform.ExecutablePath(runAction.Path)
+		runAction.Dir = t.platform.ContainerPath(runAction.Dir)
+		runAction.Env = mergeEnvironment(defaultEnv, runAction.Env)
+		runAction.User = t.userFor(runAction.User)
+		runAction.ResourceLimits = t.processLimits.apply(runAction.ResourceLimits)
+
+		streamer := logStreamer.WithSource(actionModel.LogSource)
+		if capturing, ok := streamer.(*capturingStreamer); ok && runAction.SuppressLogOutput {
+			runAction.SuppressLogOutput = false
+			streamer = capturing.captureOnly()
+		}
+
 		return steps.NewRun(
 			container,
-			*actionModel,
-			logStreamer.WithSource(actionModel.LogSource),
+			runAction,
+			streamer,
 			logger,
 			externalIP,
 			internalIP,
 			ports,
 			t.exportNetworkEnvVars,
+			t.platform,
+			stopSignal,
 			t.clock,
 		)
 
 	case *models.DownloadAction:
+		downloadAction := *actionModel
+		downloadAction.To = t.platform.ContainerPath(downloadAction.To)
+		downloadAction.User = t.userFor(downloadAction.User)
+		if steps.IsGitURL(downloadAction.From) {
+			return steps.NewGitDownload(
+				container,
+				downloadAction,
+				t.tempDir,
+				t.proxyEnv,
+				t.downloadLimiter,
+				logStreamer.WithSource(actionModel.LogSource),
+				logger,
+			)
+		}
 		return steps.NewDownload(
 			container,
-			*actionModel,
+			downloadAction,
 			t.cachedDownloader,
 			t.downloadLimiter,
 			logStreamer.WithSource(actionModel.LogSource),
@@ -117,9 +232,12 @@ func (t *transformer) StepFor(
 		)
 
 	case *models.UploadAction:
+		uploadAction := *actionModel
+		uploadAction.From = t.platform.ContainerPath(uploadAction.From)
+		uploadAction.User = t.userFor(uploadAction.User)
 		return steps.NewUpload(
 			container,
-			*actionModel,
+			uploadAction,
 			t.uploader,
 			t.compressor,
 			t.tempDir,
@@ -130,13 +248,15 @@ func (t *transformer) StepFor(
 
 	case *models.EmitProgressAction:
 		return steps.NewEmitProgress(
-			t.StepFor(
+			t.stepFor(
 				logStreamer,
 				actionModel.Action,
 				container,
 				externalIP,
 				internalIP,
 				ports,
+				stopSignal,
+				defaultEnv,
 				logger,
 			),
 			actionModel.StartMessage,
@@ -148,13 +268,15 @@ func (t *transformer) StepFor(
 
 	case *models.TimeoutAction:
 		return steps.NewTimeout(
-			t.StepFor(
+			t.stepFor(
 				logStreamer.WithSource(actionModel.LogSource),
 				actionModel.Action,
 				container,
 				externalIP,
 				internalIP,
 				ports,
+				stopSignal,
+				defaultEnv,
 				logger,
 			),
 			time.Duration(actionModel.TimeoutMs)*time.Millisecond,
@@ -163,13 +285,15 @@ func (t *transformer) StepFor(
 
 	case *models.TryAction:
 		return steps.NewTry(
-			t.StepFor(
+			t.stepFor(
 				logStreamer.WithSource(actionModel.LogSource),
 				actionModel.Action,
 				container,
 				externalIP,
 				internalIP,
 				ports,
+				stopSignal,
+				defaultEnv,
 				logger,
 			),
 			logger,
@@ -178,13 +302,15 @@ func (t *transformer) StepFor(
 	case *models.ParallelAction:
 		subSteps := make([]steps.Step, len(actionModel.Actions))
 		for i, action := range actionModel.Actions {
-			subSteps[i] = t.StepFor(
+			subSteps[i] = t.stepFor(
 				logStreamer.WithSource(actionModel.LogSource),
 				action,
 				container,
 				externalIP,
 				internalIP,
 				ports,
+				stopSignal,
+				defaultEnv,
 				logger,
 			)
 		}
@@ -193,13 +319,15 @@ func (t *transformer) StepFor(
 	case *models.CodependentAction:
 		subSteps := make([]steps.Step, len(actionModel.Actions))
 		for i, action := range actionModel.Actions {
-			subSteps[i] = t.StepFor(
+			subSteps[i] = t.stepFor(
 				logStreamer.WithSource(actionModel.LogSource),
 				action,
 				container,
 				externalIP,
 				internalIP,
 				ports,
+				stopSignal,
+				defaultEnv,
 				logger,
 			)
 		}
@@ -209,13 +337,15 @@ func (t *transformer) StepFor(
 	case *models.SerialAction:
 		subSteps := make([]steps.Step, len(actionModel.Actions))
 		for i, action := range actionModel.Actions {
-			subSteps[i] = t.StepFor(
+			subSteps[i] = t.stepFor(
 				logStreamer,
 				action,
 				container,
 				externalIP,
 				internalIP,
 				ports,
+				stopSignal,
+				defaultEnv,
 				logger,
 			)
 		}
@@ -225,6 +355,23 @@ func (t *transformer) StepFor(
 	panic(fmt.Sprintf("unknown action: %T", action))
 }
 
+// ValidateActions runs the validators registered for the action types used
+// anywhere in runInfo's actions.
+func (t *transformer) ValidateActions(runInfo executor.RunInfo) error {
+	actions := []*models.Action{runInfo.Setup, runInfo.Action, runInfo.Monitor, runInfo.StartupMonitor}
+	for _, sidecar := range runInfo.Sidecars {
+		actions = append(actions, sidecar.Action)
+	}
+
+	for _, action := range actions {
+		err := t.registry.validate(action)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func (t *transformer) StepsRunner(
 	logger lager.Logger,
 	container executor.Container,
@@ -232,23 +379,28 @@ func (t *transformer) StepsRunner(
 	logStreamer log_streamer.LogStreamer,
 ) (ifrit.Runner, error) {
 	var setup, action, postSetup, monitor steps.Step
+	defaultEnv := t.defaultEnvironment(container.Env)
+
 	if container.Setup != nil {
-		setup = t.StepFor(
+		setup = t.stepFor(
 			logStreamer,
 			container.Setup,
 			gardenContainer,
 			container.ExternalIP,
 			container.InternalIP,
 			container.Ports,
+			executor.StopSignal{},
+			defaultEnv,
 			logger.Session("setup"),
 		)
 	}
 
 	if len(t.postSetupHook) > 0 {
 		actionModel := models.RunAction{
-			Path: t.postSetupHook[0],
+			Path: t.platform.ExecutablePath(t.postSetupHook[0]),
 			Args: t.postSetupHook[1:],
 			User: t.postSetupUser,
+			Env:  defaultEnv,
 		}
 		postSetup = steps.NewRun(
 			gardenContainer,
@@ -259,6 +411,8 @@ func (t *transformer) StepsRunner(
 			container.InternalIP,
 			container.Ports,
 			t.exportNetworkEnvVars,
+			t.platform,
+			executor.StopSignal{},
 			t.clock,
 		)
 	}
@@ -269,42 +423,123 @@ func (t *transformer) StepsRunner(
 		return nil, err
 	}
 
-	action = t.StepFor(
+	action = t.stepFor(
 		logStreamer,
 		container.Action,
 		gardenContainer,
 		container.ExternalIP,
 		container.InternalIP,
 		container.Ports,
+		container.StopSignal,
+		defaultEnv,
 		logger.Session("action"),
 	)
 
+	sidecars := make([]steps.Step, 0, len(container.Sidecars))
+	for _, sidecar := range container.Sidecars {
+		logSource := sidecar.LogSource
+		if logSource == "" {
+			logSource = executor.DefaultSidecarLogSource
+		}
+
+		sidecars = append(sidecars, t.stepFor(
+			logStreamer.WithSource(logSource),
+			sidecar.Action,
+			gardenContainer,
+			container.ExternalIP,
+			container.InternalIP,
+			container.Ports,
+			container.StopSignal,
+			defaultEnv,
+			logger.Session("sidecar", lager.Data{"name": sidecar.Name}),
+		))
+	}
+
 	hasStartedRunning := make(chan struct{}, 1)
 
+	var check func(log_streamer.LogStreamer) steps.Step
 	if container.Monitor != nil {
+		check = func(streamer log_streamer.LogStreamer) steps.Step {
+			return t.stepFor(
+				streamer,
+				container.Monitor,
+				gardenContainer,
+				container.ExternalIP,
+				container.InternalIP,
+				container.Ports,
+				executor.StopSignal{},
+				defaultEnv,
+				logger.Session("monitor-run"),
+			)
+		}
+	} else if container.HTTPCheck != nil {
+		check = func(log_streamer.LogStreamer) steps.Step {
+			return steps.NewHTTPCheck(*container.HTTPCheck, container.InternalIP, logger.Session("monitor-run"))
+		}
+	} else if container.PortCheck != nil {
+		ports := container.PortCheck.CheckedPorts(container.Ports)
+		timeout := time.Duration(container.PortCheck.TimeoutMs) * time.Millisecond
+		check = func(log_streamer.LogStreamer) steps.Step {
+			return steps.NewPortCheck(container.InternalIP, ports, timeout, logger.Session("monitor-run"))
+		}
+	}
+
+	var startupCheck func(log_streamer.LogStreamer) steps.Step
+	if container.StartupMonitor != nil {
+		startupCheck = func(streamer log_streamer.LogStreamer) steps.Step {
+			return t.stepFor(
+				streamer,
+				container.StartupMonitor,
+				gardenContainer,
+				container.ExternalIP,
+				container.InternalIP,
+				container.Ports,
+				executor.StopSignal{},
+				defaultEnv,
+				logger.Session("startup-monitor-run"),
+			)
+		}
+	}
+
+	output := &healthCheckOutput{}
+	checkFunc := captureOutput(check, logStreamer, output)
+	startupCheckFunc := captureOutput(startupCheck, logStreamer, output)
+
+	if checkFunc != nil {
+		policy := container.HealthCheckPolicy
+
+		healthyInterval := t.healthyMonitoringInterval
+		if policy.LivenessIntervalMs > 0 {
+			healthyInterval = time.Duration(policy.LivenessIntervalMs) * time.Millisecond
+		}
+
+		unhealthyInterval := t.unhealthyMonitoringInterval
+		if policy.StartupIntervalMs > 0 {
+			unhealthyInterval = time.Duration(policy.StartupIntervalMs) * time.Millisecond
+		}
+
 		monitor = steps.NewMonitor(
-			func() steps.Step {
-				return t.StepFor(
-					logStreamer,
-					container.Monitor,
-					gardenContainer,
-					container.ExternalIP,
-					container.InternalIP,
-					container.Ports,
-					logger.Session("monitor-run"),
-				)
-			},
+			checkFunc,
+			startupCheckFunc,
 			hasStartedRunning,
 			logger.Session("monitor"),
 			t.clock,
 			logStreamer,
 			time.Duration(container.StartTimeoutMs)*time.Millisecond,
-			t.healthyMonitoringInterval,
-			t.unhealthyMonitoringInterval,
+			healthyInterval,
+			unhealthyInterval,
+			int(policy.StartupSuccessThreshold),
+			int(policy.LivenessFailureThreshold),
 			t.healthCheckWorkPool,
 		)
 	}
 
+	results := &stepResults{}
+	setup = newTimedStep(setup, "setup", t.clock, results)
+	postSetup = newTimedStep(postSetup, "post-setup", t.clock, results)
+	action = newTimedStep(action, "action", t.clock, results)
+	monitor = newTimedStep(monitor, "monitor", t.clock, results)
+
 	var longLivedAction steps.Step
 	if monitor != nil {
 		longLivedAction = steps.NewCodependent([]steps.Step{action, monitor}, false)
@@ -315,6 +550,10 @@ func (t *transformer) StepsRunner(
 		hasStartedRunning <- struct{}{}
 	}
 
+	if len(sidecars) > 0 {
+		longLivedAction = steps.NewSidecars(longLivedAction, sidecars)
+	}
+
 	var step steps.Step
 	if setup == nil {
 		step = longLivedAction
@@ -326,5 +565,18 @@ func (t *transformer) StepsRunner(
 		}
 	}
 
-	return newStepRunner(step, hasStartedRunning), nil
+	return newStepRunner(step, hasStartedRunning, output, results), nil
+}
+
+// captureOutput returns a func building check's step with its output captured
+// into output when it fails, or nil when check is nil.
+func captureOutput(check func(log_streamer.LogStreamer) steps.Step, logStreamer log_streamer.LogStreamer, output *healthCheckOutput) func() steps.Step {
+	if check == nil {
+		return nil
+	}
+
+	return func() steps.Step {
+		streamer := newCapturingStreamer(logStreamer)
+		return &capturedStep{Step: check(streamer), streamer: streamer, output: output}
+	}
 }
@@ -2,13 +2,20 @@ package transformer_test
 
 import (
 	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strconv"
+	"sync/atomic"
 	"time"
 
 	"code.cloudfoundry.org/bbs/models"
 	"code.cloudfoundry.org/clock/fakeclock"
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/depot/log_streamer"
+	"code.cloudfoundry.org/executor/depot/steps"
+	"code.cloudfoundry.org/executor/depot/steps/fakes"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/garden"
 	"code.cloudfoundry.org/garden/gardenfakes"
@@ -31,6 +38,10 @@ var _ = Describe("Transformer", func() {
 			gardenContainer  *gardenfakes.FakeContainer
 			clock            *fakeclock.FakeClock
 			fakeMetronClient *mfakes.FakeClient
+			environment      []executor.EnvironmentVariable
+			defaultUser      string
+			processLimits    transformer.ProcessLimits
+			registry         *transformer.Registry
 		)
 
 		BeforeEach(func() {
@@ -39,26 +50,11 @@ var _ = Describe("Transformer", func() {
 			logger = lagertest.NewTestLogger("test-container-store")
 			fakeMetronClient = &mfakes.FakeClient{}
 			logStreamer = log_streamer.New("test", "test", 1, fakeMetronClient)
-
-			healthyMonitoringInterval := 1 * time.Millisecond
-			unhealthyMonitoringInterval := 1 * time.Millisecond
-
-			healthCheckWoorkPool, err := workpool.NewWorkPool(1)
-			Expect(err).NotTo(HaveOccurred())
-
 			clock = fakeclock.NewFakeClock(time.Now())
-
-			optimusPrime = transformer.NewTransformer(
-				nil, nil, nil, nil, nil, nil,
-				os.TempDir(),
-				false,
-				healthyMonitoringInterval,
-				unhealthyMonitoringInterval,
-				healthCheckWoorkPool,
-				clock,
-				[]string{"/post-setup/path", "-x", "argument"},
-				"jim",
-			)
+			environment = nil
+			defaultUser = ""
+			processLimits = transformer.ProcessLimits{}
+			registry = nil
 
 			container = executor.Container{
 				RunInfo: executor.RunInfo{
@@ -81,6 +77,32 @@ var _ = Describe("Transformer", func() {
 			}
 		})
 
+		JustBeforeEach(func() {
+			healthyMonitoringInterval := 1 * time.Millisecond
+			unhealthyMonitoringInterval := 1 * time.Millisecond
+
+			healthCheckWoorkPool, err := workpool.NewWorkPool(1)
+			Expect(err).NotTo(HaveOccurred())
+
+			optimusPrime = transformer.NewTransformer(
+				nil, nil, nil, nil, nil, nil,
+				os.TempDir(),
+				nil,
+				false,
+				healthyMonitoringInterval,
+				unhealthyMonitoringInterval,
+				healthCheckWoorkPool,
+				clock,
+				[]string{"/post-setup/path", "-x", "argument"},
+				"jim",
+				defaultUser,
+				processLimits,
+				steps.PlatformLinux,
+				environment,
+				registry,
+			)
+		})
+
 		Context("when there is no run action", func() {
 			BeforeEach(func() {
 				container.Action = nil
@@ -190,6 +212,269 @@ var _ = Describe("Transformer", func() {
 			})
 		})
 
+		Context("when an environment is configured for all containers", func() {
+			BeforeEach(func() {
+				environment = []executor.EnvironmentVariable{
+					{Name: "HTTP_PROXY", Value: "http://proxy"},
+					{Name: "SSL_CERT_DIR", Value: "/etc/ssl/certs"},
+					{Name: "LANG", Value: "C"},
+				}
+
+				container.Env = []executor.EnvironmentVariable{
+					{Name: "LANG", Value: "en_US.UTF-8"},
+				}
+				container.Action.RunAction.Env = []*models.EnvironmentVariable{
+					{Name: "HTTP_PROXY", Value: "http://other-proxy"},
+				}
+				container.Monitor = nil
+			})
+
+			It("adds the variables the container and its actions do not set themselves", func() {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(process.Ready()).Should(BeClosed())
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(3))
+
+				setupSpec, _ := gardenContainer.RunArgsForCall(0)
+				Expect(setupSpec.Env).To(Equal([]string{
+					"HTTP_PROXY=http://proxy",
+					"SSL_CERT_DIR=/etc/ssl/certs",
+				}))
+
+				actionSpec, _ := gardenContainer.RunArgsForCall(2)
+				Expect(actionSpec.Env).To(Equal([]string{
+					"SSL_CERT_DIR=/etc/ssl/certs",
+					"HTTP_PROXY=http://other-proxy",
+				}))
+			})
+		})
+
+		Context("when a default user is configured", func() {
+			BeforeEach(func() {
+				defaultUser = "vcap"
+				container.Action.RunAction.User = "app-user"
+				container.Monitor = nil
+			})
+
+			It("runs the actions that do not name a user as the default user", func() {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(process.Ready()).Should(BeClosed())
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(3))
+
+				setupSpec, _ := gardenContainer.RunArgsForCall(0)
+				Expect(setupSpec.User).To(Equal("vcap"))
+
+				postSetupSpec, _ := gardenContainer.RunArgsForCall(1)
+				Expect(postSetupSpec.User).To(Equal("jim"))
+
+				actionSpec, _ := gardenContainer.RunArgsForCall(2)
+				Expect(actionSpec.User).To(Equal("app-user"))
+			})
+		})
+
+		Context("when process limits are configured", func() {
+			BeforeEach(func() {
+				processLimits = transformer.ProcessLimits{MaxNofile: 1024, MaxNproc: 512}
+
+				nofile := uint64(4096)
+				nproc := uint64(256)
+				container.Action.RunAction.ResourceLimits = &models.ResourceLimits{Nofile: &nofile, Nproc: &nproc}
+				container.Monitor = nil
+			})
+
+			It("caps the limits of the run actions", func() {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(process.Ready()).Should(BeClosed())
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(3))
+
+				setupSpec, _ := gardenContainer.RunArgsForCall(0)
+				Expect(*setupSpec.Limits.Nofile).To(BeEquivalentTo(1024))
+				Expect(*setupSpec.Limits.Nproc).To(BeEquivalentTo(512))
+
+				actionSpec, _ := gardenContainer.RunArgsForCall(2)
+				Expect(*actionSpec.Limits.Nofile).To(BeEquivalentTo(1024))
+				Expect(*actionSpec.Limits.Nproc).To(BeEquivalentTo(256))
+			})
+		})
+
+		Context("when a failed health check is followed by a passing one", func() {
+			BeforeEach(func() {
+				container.Setup = nil
+				container.Monitor.RunAction.SuppressLogOutput = true
+
+				var monitorRuns int32
+				gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+					process := &gardenfakes.FakeProcess{}
+					if processSpec.Path == "/monitor/path" {
+						if atomic.AddInt32(&monitorRuns, 1) == 1 {
+							processIO.Stdout.Write([]byte("connection refused\n"))
+							process.WaitReturns(1, nil)
+						}
+					} else {
+						exited := make(chan struct{})
+						process.SignalStub = func(garden.Signal) error {
+							close(exited)
+							return nil
+						}
+						process.WaitStub = func() (int, error) {
+							<-exited
+							return 143, nil
+						}
+					}
+					return process, nil
+				}
+			})
+
+			It("clears the output of the failed check", func() {
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+				reporter := runner.(transformer.HealthCheckOutputReporter)
+
+				process := ifrit.Background(runner)
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+				clock.WaitForWatcherAndIncrement(1 * time.Second)
+				Eventually(reporter.HealthCheckOutput).Should(ContainSubstring("connection refused"))
+
+				clock.WaitForWatcherAndIncrement(1 * time.Second)
+				Eventually(process.Ready()).Should(BeClosed())
+				Expect(reporter.HealthCheckOutput()).To(BeEmpty())
+
+				process.Signal(os.Interrupt)
+				Eventually(process.Wait()).Should(Receive())
+			})
+		})
+
+		Context("when the monitor fails", func() {
+			BeforeEach(func() {
+				container.Setup = nil
+				container.StartTimeoutMs = 1
+				container.Monitor.RunAction.SuppressLogOutput = true
+
+				gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+					process := &gardenfakes.FakeProcess{}
+					if processSpec.Path == "/monitor/path" {
+						processIO.Stdout.Write([]byte("connection refused\n"))
+						process.WaitReturns(1, nil)
+					} else {
+						exited := make(chan struct{})
+						process.SignalStub = func(garden.Signal) error {
+							close(exited)
+							return nil
+						}
+						process.WaitStub = func() (int, error) {
+							<-exited
+							return 143, nil
+						}
+					}
+					return process, nil
+				}
+			})
+
+			runUntilMonitorFails := func() ifrit.Runner {
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(1))
+				clock.WaitForWatcherAndIncrement(1 * time.Second)
+				Eventually(gardenContainer.RunCallCount).Should(Equal(2))
+
+				Eventually(process.Wait()).Should(Receive(HaveOccurred()))
+				return runner
+			}
+
+			It("reports the output of the failed check", func() {
+				runner := runUntilMonitorFails()
+
+				reporter, ok := runner.(transformer.HealthCheckOutputReporter)
+				Expect(ok).To(BeTrue())
+				Expect(reporter.HealthCheckOutput()).To(ContainSubstring("connection refused"))
+			})
+
+			It("reports that the monitor failed and the action was cancelled", func() {
+				runner := runUntilMonitorFails()
+
+				reporter, ok := runner.(transformer.StepResultsReporter)
+				Expect(ok).To(BeTrue())
+
+				// the action and monitor start together, in either order
+				results := map[string]executor.StepResult{}
+				for _, result := range reporter.StepResults() {
+					results[result.Name] = result
+				}
+				Expect(results).To(HaveLen(2))
+
+				Expect(results["action"].Failed).To(BeFalse())
+				Expect(results["action"].Error).To(Equal(steps.ErrCancelled.Error()))
+
+				Expect(results["monitor"].Failed).To(BeTrue())
+				Expect(results["monitor"].Error).NotTo(BeEmpty())
+				Expect(results["monitor"].StartedAt).To(Equal(clock.Now().Add(-time.Second).UnixNano()))
+				Expect(results["monitor"].FinishedAt).To(Equal(clock.Now().UnixNano()))
+			})
+		})
+
+		Context("when there is an HTTP check instead of a monitor action", func() {
+			var server *httptest.Server
+
+			BeforeEach(func() {
+				server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+
+				_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+				Expect(err).NotTo(HaveOccurred())
+				portNum, err := strconv.Atoi(port)
+				Expect(err).NotTo(HaveOccurred())
+
+				container.Setup = nil
+				container.Monitor = nil
+				container.InternalIP = "127.0.0.1"
+				container.HTTPCheck = &executor.HTTPCheck{Path: "/", Port: uint16(portNum)}
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("becomes ready once the HTTP check passes", func() {
+				gardenContainer.RunReturns(&gardenfakes.FakeProcess{}, nil)
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Consistently(process.Ready()).ShouldNot(BeClosed())
+
+				clock.Increment(1 * time.Second)
+				Eventually(process.Ready()).Should(BeClosed())
+				Expect(gardenContainer.RunCallCount()).To(Equal(1))
+
+				process.Signal(os.Interrupt)
+				clock.Increment(1 * time.Second)
+				Eventually(process.Wait()).Should(Receive(nil))
+			})
+		})
+
 		Context("when there is no monitor", func() {
 			BeforeEach(func() {
 				container.Monitor = nil
@@ -210,5 +495,123 @@ var _ = Describe("Transformer", func() {
 				Consistently(gardenContainer.RunCallCount).Should(Equal(3))
 			})
 		})
+
+		Context("when there are sidecars", func() {
+			BeforeEach(func() {
+				container.Setup = nil
+				container.Monitor = nil
+				container.Sidecars = []executor.Sidecar{{
+					Name: "proxy",
+					Action: &models.Action{
+						RunAction: &models.RunAction{
+							Path: "/sidecar/path",
+						},
+					},
+				}}
+			})
+
+			It("runs them alongside the action and fails when one exits", func() {
+				sidecarExited := make(chan struct{})
+				gardenContainer.RunStub = func(processSpec garden.ProcessSpec, processIO garden.ProcessIO) (garden.Process, error) {
+					process := &gardenfakes.FakeProcess{}
+					if processSpec.Path == "/sidecar/path" {
+						process.WaitStub = func() (int, error) {
+							<-sidecarExited
+							return 0, nil
+						}
+					} else {
+						exited := make(chan struct{})
+						process.SignalStub = func(garden.Signal) error {
+							close(exited)
+							return nil
+						}
+						process.WaitStub = func() (int, error) {
+							<-exited
+							return 143, nil
+						}
+					}
+					return process, nil
+				}
+
+				runner, err := optimusPrime.StepsRunner(logger, container, gardenContainer, logStreamer)
+				Expect(err).NotTo(HaveOccurred())
+
+				process := ifrit.Background(runner)
+				Eventually(process.Ready()).Should(BeClosed())
+
+				Eventually(gardenContainer.RunCallCount).Should(Equal(2))
+				var paths []string
+				for i := 0; i < gardenContainer.RunCallCount(); i++ {
+					processSpec, _ := gardenContainer.RunArgsForCall(i)
+					paths = append(paths, processSpec.Path)
+				}
+				Expect(paths).To(ConsistOf("/action/path", "/sidecar/path"))
+
+				close(sidecarExited)
+				Eventually(process.Wait()).Should(Receive(Equal(steps.ErrSidecarExited)))
+			})
+		})
+
+		Context("with a step registry", func() {
+			var (
+				customStep     *fakes.FakeStep
+				factoryActions []models.ActionInterface
+				downloadAction *models.Action
+			)
+
+			BeforeEach(func() {
+				customStep = new(fakes.FakeStep)
+				factoryActions = nil
+				downloadAction = &models.Action{
+					DownloadAction: &models.DownloadAction{From: "custom://artifact", To: "/tmp"},
+				}
+
+				registry = transformer.NewRegistry()
+				err := registry.Register(
+					"download",
+					func(action models.ActionInterface, context transformer.StepContext) steps.Step {
+						factoryActions = append(factoryActions, action)
+						return customStep
+					},
+					func(action models.ActionInterface) error {
+						if action.(*models.DownloadAction).To == "" {
+							return executor.ErrStepsInvalid
+						}
+						return nil
+					},
+				)
+				Expect(err).NotTo(HaveOccurred())
+			})
+
+			It("builds registered action types with their factory", func() {
+				step := optimusPrime.StepFor(logStreamer, downloadAction, gardenContainer, "", "", nil, logger)
+				Expect(step).To(BeIdenticalTo(customStep))
+				Expect(factoryActions).To(Equal([]models.ActionInterface{downloadAction.DownloadAction}))
+			})
+
+			It("does not allow an action type to be registered twice", func() {
+				err := registry.Register("download", nil, nil)
+				Expect(err).To(Equal(transformer.ErrActionTypeRegistered))
+			})
+
+			It("validates registered actions nested anywhere in the run info", func() {
+				downloadAction.DownloadAction.To = ""
+				container.Setup = &models.Action{
+					SerialAction: &models.SerialAction{
+						Actions: []*models.Action{
+							{RunAction: &models.RunAction{Path: "/setup/path"}},
+							downloadAction,
+						},
+					},
+				}
+
+				Expect(optimusPrime.ValidateActions(container.RunInfo)).To(Equal(executor.ErrStepsInvalid))
+			})
+
+			It("accepts valid actions", func() {
+				container.Setup = downloadAction
+				Expect(optimusPrime.ValidateActions(container.RunInfo)).To(Succeed())
+			})
+		})
 	})
 })
@@ -0,0 +1,156 @@
+package depot
+
+import (
+	"archive/tar"
+	"context"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/lager"
+)
+
+const DefaultUploadChunkSize = 64 * 1024 * 1024
+
+// PutFilesChunked streams the tar archive tarStream into the container at
+// destPath in chunks. Each chunk is spooled to a file in the client's work
+// directory so that it can be sent again if streaming it in fails with a
+// retryable error; chunks already in the container are not resent. Like
+// PutFiles, it does not use a work pool.
+func (c *client) PutFilesChunked(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader, opts executor.ChunkedUploadOptions) error {
+	logger = logger.Session("put-files-chunked", lager.Data{
+		"guid":      guid,
+		"dest-path": destPath,
+	})
+
+	logger.Info("starting")
+	defer logger.Info("complete")
+
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultUploadChunkSize
+	}
+
+	chunkFile, err := ioutil.TempFile(c.workDir, "executor-upload")
+	if err != nil {
+		logger.Error("failed-to-create-chunk-file", err)
+		return err
+	}
+	defer os.Remove(chunkFile.Name())
+	defer chunkFile.Close()
+
+	reader := tar.NewReader(tarStream)
+	progress := executor.UploadProgress{}
+	for {
+		size, more, err := spoolChunk(reader, chunkFile, opts.ChunkSize)
+		if err != nil {
+			logger.Error("failed-to-read-tar-stream", err, lager.Data{"chunk": progress.ChunksSent})
+			return err
+		}
+		if size == 0 && !more {
+			return nil
+		}
+
+		if progress.ChunksSent >= opts.SkipChunks {
+			err = c.putChunk(ctx, logger, guid, destPath, chunkFile, opts.MaxRetries)
+			if err != nil {
+				logger.Error("failed-to-put-chunk", err, lager.Data{"chunk": progress.ChunksSent})
+				return err
+			}
+		}
+
+		progress.ChunksSent++
+		progress.BytesSent += size
+		if opts.Progress != nil {
+			opts.Progress(progress)
+		}
+
+		if !more {
+			return nil
+		}
+	}
+}
+
+func (c *client) putChunk(ctx context.Context, logger lager.Logger, guid, destPath string, chunkFile *os.File, maxRetries int) error {
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		_, err = chunkFile.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		err = c.containerStore.PutFiles(ctx, logger, guid, destPath, chunkFile)
+		if err == nil || ctx.Err() != nil || !executor.IsRetryable(err) {
+			return err
+		}
+
+		logger.Error("failed-to-stream-in-chunk", err, lager.Data{"attempt": attempt + 1})
+	}
+	return err
+}
+
+// spoolChunk copies whole entries from reader into a tar archive in
+// chunkFile, replacing its contents, until the archive holds at least
+// chunkSize bytes. It returns the archive's size, and whether the stream has
+// more entries.
+func spoolChunk(reader *tar.Reader, chunkFile *os.File, chunkSize int64) (int64, bool, error) {
+	err := chunkFile.Truncate(0)
+	if err != nil {
+		return 0, false, err
+	}
+	_, err = chunkFile.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, false, err
+	}
+
+	writer := tar.NewWriter(chunkFile)
+	entries := 0
+	more := true
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			more = false
+			break
+		}
+		if err != nil {
+			return 0, false, err
+		}
+
+		err = writer.WriteHeader(header)
+		if err != nil {
+			return 0, false, err
+		}
+		_, err = io.Copy(writer, reader)
+		if err != nil {
+			return 0, false, err
+		}
+		entries++
+
+		err = writer.Flush()
+		if err != nil {
+			return 0, false, err
+		}
+		offset, err := chunkFile.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return 0, false, err
+		}
+		if offset >= chunkSize {
+			break
+		}
+	}
+
+	if entries == 0 {
+		return 0, more, nil
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return 0, false, err
+	}
+
+	size, err := chunkFile.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, false, err
+	}
+	return size, more, nil
+}
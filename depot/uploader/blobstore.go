@@ -0,0 +1,358 @@
+package uploader
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrMissingObjectKey = errors.New("upload destination has no bucket or object key")
+
+const (
+	// DefaultPartSize is the part size used for multipart uploads when the
+	// blobstore does not set one. Objects no larger than a part are put in a
+	// single request.
+	DefaultPartSize = 64 * 1024 * 1024
+
+	// MinPartSize is the smallest part S3 accepts, other than the last.
+	MinPartSize = 5 * 1024 * 1024
+
+	signingAlgorithm = "AWS4-HMAC-SHA256"
+	signingService   = "s3"
+	amzDateFormat    = "20060102T150405Z"
+	scopeDateFormat  = "20060102"
+)
+
+// Blobstore is an S3-compatible object store. Google Cloud Storage can be used
+// through its XML API with an HMAC key, an endpoint of
+// https://storage.googleapis.com and a region of auto.
+type Blobstore struct {
+	Endpoint        string `json:"endpoint"`
+	Region          string `json:"region"`
+	AccessKeyID     string `json:"access_key_id"`
+	SecretAccessKey string `json:"secret_access_key"`
+	PartSize        int64  `json:"part_size,omitempty"`
+}
+
+type blobstoreUploader struct {
+	blobstore  Blobstore
+	httpClient *http.Client
+	transport  *http.Transport
+	clock      clock.Clock
+	logger     lager.Logger
+}
+
+// NewBlobstoreUploader uploads to destinations of the form
+// <scheme>://<bucket>/<key> by putting the object into the bucket on the
+// blobstore's endpoint, signing each request with AWS signature version 4.
+// Objects larger than the blobstore's part size are sent as a multipart
+// upload, so that a failed part can be retried on its own. Requests go
+// through the proxy chosen by proxy, or by http.ProxyFromEnvironment when it
+// is nil.
+func NewBlobstoreUploader(logger lager.Logger, timeout time.Duration, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error), blobstore Blobstore, clock clock.Clock) Uploader {
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
+	transport := &http.Transport{
+		Proxy: proxy,
+		Dial: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).Dial,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+
+	return &blobstoreUploader{
+		blobstore: blobstore,
+		httpClient: &http.Client{
+			Transport: transport,
+			Timeout:   timeout,
+		},
+		transport: transport,
+		clock:     clock,
+		logger:    logger.Session("blobstore-uploader", lager.Data{"endpoint": blobstore.Endpoint}),
+	}
+}
+
+func (uploader *blobstoreUploader) Upload(fileLocation string, destinationUrl *url.URL, cancel <-chan struct{}) (int64, error) {
+	bucket := destinationUrl.Host
+	key := strings.TrimPrefix(destinationUrl.Path, "/")
+	logger := uploader.logger.WithData(lager.Data{"fileLocation": fileLocation, "bucket": bucket, "key": key})
+
+	if bucket == "" || key == "" {
+		logger.Error("invalid-destination", ErrMissingObjectKey)
+		return 0, ErrMissingObjectKey
+	}
+
+	sourceFile, err := os.Open(fileLocation)
+	if err != nil {
+		logger.Error("failed-open", err)
+		return 0, err
+	}
+	defer sourceFile.Close()
+
+	fileInfo, err := sourceFile.Stat()
+	if err != nil {
+		logger.Error("failed-stat", err)
+		return 0, err
+	}
+
+	partSize := uploader.blobstore.PartSize
+	if partSize <= 0 {
+		partSize = DefaultPartSize
+	}
+	if fileInfo.Size() > partSize {
+		err = uploader.uploadMultipart(logger, sourceFile, fileInfo.Size(), partSize, bucket, key, cancel)
+		if err != nil {
+			return 0, err
+		}
+		return fileInfo.Size(), nil
+	}
+
+	err = uploader.withRetries(logger, func() error {
+		return uploader.putObject(sourceFile, fileInfo.Size(), bucket, key, cancel)
+	})
+	if err != nil {
+		logger.Error("failed-all-upload-attempts", err)
+		return 0, err
+	}
+
+	return fileInfo.Size(), nil
+}
+
+// withRetries makes up to three attempts at upload, giving up early if it is
+// cancelled.
+func (uploader *blobstoreUploader) withRetries(logger lager.Logger, upload func() error) error {
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		logger := logger.WithData(lager.Data{"attempt": attempt})
+		logger.Info("uploading")
+
+		err = upload()
+		if err == nil {
+			logger.Info("succeeded-uploading")
+			return nil
+		}
+		if err == ErrUploadCancelled {
+			logger.Info("cancelled-uploading")
+			return err
+		}
+		logger.Error("failed-uploading", err)
+	}
+	return err
+}
+
+func (uploader *blobstoreUploader) putObject(sourceFile *os.File, size int64, bucket, key string, cancel <-chan struct{}) error {
+	resp, err := uploader.send("PUT", bucket, key, nil, io.NewSectionReader(sourceFile, 0, size), size, cancel)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	return nil
+}
+
+// send makes a signed request for the object, reading size bytes of body from
+// the start. Responses with an error status are closed and returned as an
+// error.
+func (uploader *blobstoreUploader) send(
+	method string,
+	bucket string,
+	key string,
+	query url.Values,
+	body io.ReadSeeker,
+	size int64,
+	cancelCh <-chan struct{},
+) (*http.Response, error) {
+	contentMD5, contentSHA256, err := hashContent(body)
+	if err != nil {
+		return nil, err
+	}
+	_, err = body.Seek(0, io.SeekStart)
+	if err != nil {
+		return nil, err
+	}
+
+	objectURL := strings.TrimSuffix(uploader.blobstore.Endpoint, "/") + "/" + uriEncode(bucket, true) + "/" + uriEncode(key, false)
+	if len(query) > 0 {
+		objectURL += "?" + canonicalQuery(query)
+	}
+	// a request with a body but no length would be sent chunked, which the
+	// blobstore rejects
+	var requestBody io.Reader
+	if size > 0 {
+		requestBody = ioutil.NopCloser(body)
+	}
+	request, err := http.NewRequest(method, objectURL, requestBody)
+	if err != nil {
+		return nil, err
+	}
+
+	request.ContentLength = size
+	request.Header.Set("Content-Type", "application/octet-stream")
+	request.Header.Set("Content-MD5", contentMD5)
+	uploader.sign(request, query, contentSHA256)
+
+	var resp *http.Response
+	reqComplete := make(chan error)
+	go func() {
+		var err error
+		resp, err = uploader.httpClient.Do(request)
+		reqComplete <- err
+	}()
+
+	select {
+	case <-cancelCh:
+		uploader.transport.CancelRequest(request)
+		<-reqComplete
+		return nil, ErrUploadCancelled
+	case err := <-reqComplete:
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// access to resp has been syncronized via reqComplete
+	if resp.StatusCode >= 400 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Upload failed: Status code %d", resp.StatusCode)
+	}
+
+	return resp, nil
+}
+
+// sign adds the x-amz-date, x-amz-content-sha256 and Authorization headers
+// for an AWS signature version 4 over every header already set on request.
+func (uploader *blobstoreUploader) sign(request *http.Request, query url.Values, payloadSHA256 string) {
+	now := uploader.clock.Now().UTC()
+	request.Header.Set("X-Amz-Date", now.Format(amzDateFormat))
+	request.Header.Set("X-Amz-Content-Sha256", payloadSHA256)
+
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(query),
+		canonicalHeaders,
+		signedHeaders,
+		payloadSHA256,
+	}, "\n")
+
+	scope := strings.Join([]string{now.Format(scopeDateFormat), uploader.blobstore.Region, signingService, "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		signingAlgorithm,
+		now.Format(amzDateFormat),
+		scope,
+		hexSHA256([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+uploader.blobstore.SecretAccessKey), now.Format(scopeDateFormat))
+	signingKey = hmacSHA256(signingKey, uploader.blobstore.Region)
+	signingKey = hmacSHA256(signingKey, signingService)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		signingAlgorithm,
+		uploader.blobstore.AccessKeyID,
+		scope,
+		signedHeaders,
+		signature,
+	))
+}
+
+func hashContent(content io.Reader) (string, string, error) {
+	md5Hash := md5.New()
+	sha256Hash := sha256.New()
+	_, err := io.Copy(io.MultiWriter(md5Hash, sha256Hash), content)
+	if err != nil {
+		return "", "", err
+	}
+	return base64.StdEncoding.EncodeToString(md5Hash.Sum(nil)), hex.EncodeToString(sha256Hash.Sum(nil)), nil
+}
+
+// canonicalQuery encodes query sorted by key, with every key and value
+// percent-encoded, as required for canonical requests.
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := []string{}
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key, true)+"="+uriEncode(value, true))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func hexSHA256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// uriEncode percent-encodes every byte of s outside the unreserved set, as
+// required for canonical requests. Slashes are left alone unless encodeSlash
+// is set.
+func uriEncode(s string, encodeSlash bool) string {
+	var encoded bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			encoded.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			encoded.WriteByte(c)
+		default:
+			fmt.Fprintf(&encoded, "%%%02X", c)
+		}
+	}
+	return encoded.String()
+}
@@ -0,0 +1,234 @@
+package uploader_test
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/depot/uploader"
+	"code.cloudfoundry.org/executor/depot/uploader/fake_uploader"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("BlobstoreUploader", func() {
+	var (
+		upldr       uploader.Uploader
+		testServer  *httptest.Server
+		requests    chan *http.Request
+		bodies      chan []byte
+		statusCodes []int
+		lock        sync.Mutex
+		file        *os.File
+		content     string
+		partSize    int64
+		handler     http.HandlerFunc
+	)
+
+	BeforeEach(func() {
+		requests = make(chan *http.Request, 3)
+		bodies = make(chan []byte, 3)
+		statusCodes = []int{http.StatusOK}
+
+		partSize = 0
+		handler = func(w http.ResponseWriter, r *http.Request) {
+			body, _ := ioutil.ReadAll(r.Body)
+			requests <- r
+			bodies <- body
+
+			lock.Lock()
+			status := statusCodes[0]
+			if len(statusCodes) > 1 {
+				statusCodes = statusCodes[1:]
+			}
+			lock.Unlock()
+			w.WriteHeader(status)
+		}
+		testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handler(w, r)
+		}))
+
+		content = "droplet contents"
+		var err error
+		file, err = ioutil.TempFile("", "blobstore")
+		Expect(err).NotTo(HaveOccurred())
+		_, err = file.WriteString(content)
+		Expect(err).NotTo(HaveOccurred())
+		file.Close()
+	})
+
+	JustBeforeEach(func() {
+		upldr = uploader.NewBlobstoreUploader(
+			lagertest.NewTestLogger("test"),
+			time.Minute,
+			nil,
+			nil,
+			uploader.Blobstore{
+				Endpoint:        testServer.URL,
+				Region:          "us-east-1",
+				AccessKeyID:     "some-access-key",
+				SecretAccessKey: "some-secret",
+				PartSize:        partSize,
+			},
+			fakeclock.NewFakeClock(time.Date(2026, 1, 15, 10, 30, 0, 0, time.UTC)),
+		)
+	})
+
+	AfterEach(func() {
+		testServer.Close()
+		os.Remove(file.Name())
+	})
+
+	upload := func(destination string) (int64, error) {
+		destinationURL, err := url.Parse(destination)
+		Expect(err).NotTo(HaveOccurred())
+		return upldr.Upload(file.Name(), destinationURL, nil)
+	}
+
+	It("puts the object into the bucket with a signed request", func() {
+		size, err := upload("s3://droplets/app%20guid/droplet.tgz")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(size).To(BeEquivalentTo(len(content)))
+
+		var request *http.Request
+		Eventually(requests).Should(Receive(&request))
+		Expect(request.Method).To(Equal("PUT"))
+		Expect(request.URL.EscapedPath()).To(Equal("/droplets/app%20guid/droplet.tgz"))
+		Eventually(bodies).Should(Receive(Equal([]byte(content))))
+
+		payloadHash := sha256.Sum256([]byte(content))
+		Expect(request.Header.Get("X-Amz-Content-Sha256")).To(Equal(hex.EncodeToString(payloadHash[:])))
+		Expect(request.Header.Get("X-Amz-Date")).To(Equal("20260115T103000Z"))
+		Expect(request.Header.Get("Authorization")).To(MatchRegexp(
+			`^AWS4-HMAC-SHA256 Credential=some-access-key/20260115/us-east-1/s3/aws4_request, ` +
+				`SignedHeaders=content-md5;content-type;host;x-amz-content-sha256;x-amz-date, Signature=[0-9a-f]{64}$`,
+		))
+	})
+
+	It("retries failed attempts", func() {
+		statusCodes = []int{http.StatusInternalServerError, http.StatusOK}
+
+		_, err := upload("s3://droplets/droplet.tgz")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(requests).To(HaveLen(2))
+	})
+
+	It("fails when the destination has no object key", func() {
+		_, err := upload("s3://droplets")
+		Expect(err).To(Equal(uploader.ErrMissingObjectKey))
+		Expect(requests).To(BeEmpty())
+	})
+
+	Context("when the object is larger than a part", func() {
+		var (
+			parts          map[string]string
+			partAttempts   map[string]int
+			completeBody   string
+			aborted        bool
+			failPartOnce   string
+			failPartAlways string
+		)
+
+		BeforeEach(func() {
+			partSize = 6
+			parts = map[string]string{}
+			partAttempts = map[string]int{}
+			completeBody = ""
+			aborted = false
+			failPartOnce = ""
+			failPartAlways = ""
+
+			handler = func(w http.ResponseWriter, r *http.Request) {
+				body, _ := ioutil.ReadAll(r.Body)
+				query := r.URL.Query()
+
+				lock.Lock()
+				defer lock.Unlock()
+
+				switch {
+				case r.Method == "POST" && query["uploads"] != nil:
+					w.Write([]byte(`<InitiateMultipartUploadResult><UploadId>some-upload</UploadId></InitiateMultipartUploadResult>`))
+				case r.Method == "PUT":
+					part := query.Get("partNumber")
+					partAttempts[part]++
+					if part == failPartAlways || (part == failPartOnce && partAttempts[part] == 1) {
+						w.WriteHeader(http.StatusInternalServerError)
+						return
+					}
+					parts[part] = string(body)
+					w.Header().Set("ETag", `"etag-`+part+`"`)
+				case r.Method == "POST":
+					completeBody = string(body)
+					w.Write([]byte(`<CompleteMultipartUploadResult></CompleteMultipartUploadResult>`))
+				case r.Method == "DELETE":
+					aborted = true
+					w.WriteHeader(http.StatusNoContent)
+				}
+			}
+		})
+
+		It("uploads the object in parts", func() {
+			size, err := upload("s3://droplets/droplet.tgz")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(size).To(BeEquivalentTo(len(content)))
+
+			Expect(parts).To(Equal(map[string]string{"1": "drople", "2": "t cont", "3": "ents"}))
+			Expect(completeBody).To(ContainSubstring("<Part><PartNumber>3</PartNumber><ETag>&#34;etag-3&#34;</ETag></Part>"))
+		})
+
+		It("retries only the part that failed", func() {
+			failPartOnce = "2"
+
+			_, err := upload("s3://droplets/droplet.tgz")
+			Expect(err).NotTo(HaveOccurred())
+			Expect(partAttempts).To(Equal(map[string]int{"1": 1, "2": 2, "3": 1}))
+		})
+
+		It("aborts the upload when a part keeps failing", func() {
+			failPartAlways = "2"
+
+			_, err := upload("s3://droplets/droplet.tgz")
+			Expect(err).To(HaveOccurred())
+			Expect(partAttempts["2"]).To(Equal(3))
+			Expect(partAttempts).NotTo(HaveKey("3"))
+			Expect(aborted).To(BeTrue())
+		})
+	})
+})
+
+var _ = Describe("SchemeUploader", func() {
+	var (
+		defaultUploader *fake_uploader.FakeUploader
+		s3Uploader      *fake_uploader.FakeUploader
+		upldr           uploader.Uploader
+	)
+
+	BeforeEach(func() {
+		defaultUploader = new(fake_uploader.FakeUploader)
+		s3Uploader = new(fake_uploader.FakeUploader)
+		upldr = uploader.NewSchemeUploader(defaultUploader, map[string]uploader.Uploader{"s3": s3Uploader})
+	})
+
+	It("uploads with the backend registered for the destination's scheme", func() {
+		destination, _ := url.Parse("s3://droplets/droplet.tgz")
+		upldr.Upload("/some/file", destination, nil)
+		Expect(s3Uploader.UploadCallCount()).To(Equal(1))
+		Expect(defaultUploader.UploadCallCount()).To(BeZero())
+	})
+
+	It("uploads to other schemes with the default uploader", func() {
+		destination, _ := url.Parse("https://cc.example.com/droplets")
+		upldr.Upload("/some/file", destination, nil)
+		Expect(defaultUploader.UploadCallCount()).To(Equal(1))
+		Expect(s3Uploader.UploadCallCount()).To(BeZero())
+	})
+})
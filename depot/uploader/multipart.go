@@ -0,0 +1,168 @@
+package uploader
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"strconv"
+
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrMultipartUploadFailed = errors.New("blobstore failed to complete the multipart upload")
+
+type initiateMultipartUploadResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type completeMultipartUpload struct {
+	XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+	Parts   []completedPart `xml:"Part"`
+}
+
+// uploadMultipart sends sourceFile in parts of partSize bytes. Each part is
+// retried on its own, so a transient failure only costs that part. If the
+// upload cannot be completed it is aborted, so the blobstore discards the
+// parts it already holds.
+func (uploader *blobstoreUploader) uploadMultipart(
+	logger lager.Logger,
+	sourceFile *os.File,
+	size int64,
+	partSize int64,
+	bucket string,
+	key string,
+	cancel <-chan struct{},
+) error {
+	logger = logger.Session("multipart", lager.Data{"size": size, "part-size": partSize})
+
+	var uploadID string
+	err := uploader.withRetries(logger, func() error {
+		var err error
+		uploadID, err = uploader.initiateMultipart(bucket, key, cancel)
+		return err
+	})
+	if err != nil {
+		logger.Error("failed-to-initiate", err)
+		return err
+	}
+	logger = logger.WithData(lager.Data{"upload-id": uploadID})
+
+	parts := []completedPart{}
+	for offset := int64(0); offset < size; offset += partSize {
+		partNumber := len(parts) + 1
+		length := partSize
+		if offset+length > size {
+			length = size - offset
+		}
+
+		var etag string
+		err = uploader.withRetries(logger.WithData(lager.Data{"part": partNumber}), func() error {
+			var err error
+			etag, err = uploader.uploadPart(sourceFile, offset, length, partNumber, uploadID, bucket, key, cancel)
+			return err
+		})
+		if err != nil {
+			logger.Error("failed-to-upload-part", err, lager.Data{"part": partNumber})
+			uploader.abortMultipart(logger, uploadID, bucket, key)
+			return err
+		}
+
+		parts = append(parts, completedPart{PartNumber: partNumber, ETag: etag})
+	}
+
+	err = uploader.withRetries(logger, func() error {
+		return uploader.completeMultipart(parts, uploadID, bucket, key, cancel)
+	})
+	if err != nil {
+		logger.Error("failed-to-complete", err)
+		uploader.abortMultipart(logger, uploadID, bucket, key)
+		return err
+	}
+
+	return nil
+}
+
+func (uploader *blobstoreUploader) initiateMultipart(bucket, key string, cancel <-chan struct{}) (string, error) {
+	resp, err := uploader.send("POST", bucket, key, url.Values{"uploads": {""}}, bytes.NewReader(nil), 0, cancel)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var result initiateMultipartUploadResult
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", ErrMultipartUploadFailed
+	}
+	return result.UploadID, nil
+}
+
+func (uploader *blobstoreUploader) uploadPart(
+	sourceFile *os.File,
+	offset int64,
+	length int64,
+	partNumber int,
+	uploadID string,
+	bucket string,
+	key string,
+	cancel <-chan struct{},
+) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}
+	resp, err := uploader.send("PUT", bucket, key, query, io.NewSectionReader(sourceFile, offset, length), length, cancel)
+	if err != nil {
+		return "", err
+	}
+	resp.Body.Close()
+
+	return resp.Header.Get("ETag"), nil
+}
+
+func (uploader *blobstoreUploader) completeMultipart(parts []completedPart, uploadID, bucket, key string, cancel <-chan struct{}) error {
+	body, err := xml.Marshal(completeMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := uploader.send("POST", bucket, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(body), int64(len(body)), cancel)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// the blobstore may report a failure to assemble the parts in the body of
+	// a successful response
+	var result struct {
+		XMLName xml.Name
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&result)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	if result.XMLName.Local == "Error" {
+		return ErrMultipartUploadFailed
+	}
+	return nil
+}
+
+func (uploader *blobstoreUploader) abortMultipart(logger lager.Logger, uploadID, bucket, key string) {
+	resp, err := uploader.send("DELETE", bucket, key, url.Values{"uploadId": {uploadID}}, bytes.NewReader(nil), 0, nil)
+	if err != nil {
+		logger.Error("failed-to-abort", err)
+		return
+	}
+	resp.Body.Close()
+}
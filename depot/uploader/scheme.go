@@ -0,0 +1,25 @@
+package uploader
+
+import "net/url"
+
+type schemeUploader struct {
+	defaultUploader Uploader
+	backends        map[string]Uploader
+}
+
+// NewSchemeUploader hands each upload to the backend registered for its
+// destination's URL scheme, and uploads to any other scheme with
+// defaultUploader.
+func NewSchemeUploader(defaultUploader Uploader, backends map[string]Uploader) Uploader {
+	return &schemeUploader{
+		defaultUploader: defaultUploader,
+		backends:        backends,
+	}
+}
+
+func (uploader *schemeUploader) Upload(fileLocation string, destinationUrl *url.URL, cancel <-chan struct{}) (int64, error) {
+	if backend, ok := uploader.backends[destinationUrl.Scheme]; ok {
+		return backend.Upload(fileLocation, destinationUrl, cancel)
+	}
+	return uploader.defaultUploader.Upload(fileLocation, destinationUrl, cancel)
+}
@@ -30,9 +30,16 @@ type URLUploader struct {
 	logger     lager.Logger
 }
 
-func New(logger lager.Logger, timeout time.Duration, tlsConfig *tls.Config) Uploader {
+// New returns an uploader that puts files to http and https URLs. Requests go
+// through the proxy chosen by proxy, or by http.ProxyFromEnvironment when it
+// is nil.
+func New(logger lager.Logger, timeout time.Duration, tlsConfig *tls.Config, proxy func(*http.Request) (*url.URL, error)) Uploader {
+	if proxy == nil {
+		proxy = http.ProxyFromEnvironment
+	}
+
 	transport := &http.Transport{
-		Proxy: http.ProxyFromEnvironment,
+		Proxy: proxy,
 		Dial: (&net.Dialer{
 			Timeout:   10 * time.Second,
 			KeepAlive: 30 * time.Second,
@@ -62,7 +62,7 @@ var _ = Describe("Uploader", func() {
 
 	Describe("Insecure Upload", func() {
 		BeforeEach(func() {
-			upldr = uploader.New(logger, 100*time.Millisecond, nil)
+			upldr = uploader.New(logger, 100*time.Millisecond, nil, nil)
 		})
 
 		Context("when the upload is successful", func() {
@@ -109,6 +109,26 @@ var _ = Describe("Uploader", func() {
 			})
 		})
 
+		Context("with a proxy", func() {
+			BeforeEach(func() {
+				testServer = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					serverRequests = append(serverRequests, r)
+					ioutil.ReadAll(r.Body)
+				}))
+
+				upldr = uploader.New(logger, 100*time.Millisecond, nil, proxyTo(testServer.URL))
+				url, _ = url.Parse("http://upload.example.com/somepath")
+			})
+
+			It("sends the upload through the proxy", func() {
+				_, err := upldr.Upload(file.Name(), url, nil)
+				Expect(err).NotTo(HaveOccurred())
+
+				Expect(serverRequests).To(HaveLen(1))
+				Expect(serverRequests[0].Host).To(Equal("upload.example.com"))
+			})
+		})
+
 		Context("when the upload is canceled", func() {
 			var flushRequests chan struct{}
 			var requestsInFlight *sync.WaitGroup
@@ -132,7 +152,7 @@ var _ = Describe("Uploader", func() {
 			})
 
 			It("interrupts the client and returns an error", func() {
-				upldrWithoutTimeout := uploader.New(logger, 0, nil)
+				upldrWithoutTimeout := uploader.New(logger, 0, nil, nil)
 
 				cancel := make(chan struct{})
 				errs := make(chan error)
@@ -269,7 +289,7 @@ var _ = Describe("Uploader", func() {
 				})
 
 				It("uploads the file to the url", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).NotTo(HaveOccurred())
 
@@ -286,7 +306,7 @@ var _ = Describe("Uploader", func() {
 				})
 
 				It("returns the number of bytes written", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).NotTo(HaveOccurred())
 
@@ -316,7 +336,7 @@ var _ = Describe("Uploader", func() {
 				})
 
 				It("can communicate with the fileserver via one-sided TLS", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).NotTo(HaveOccurred())
 				})
@@ -324,7 +344,7 @@ var _ = Describe("Uploader", func() {
 
 			Context("when the client has incorrect certs", func() {
 				It("fails when no certs are provided", func() {
-					upldr = uploader.New(logger, 100*time.Millisecond, nil)
+					upldr = uploader.New(logger, 100*time.Millisecond, nil, nil)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).To(HaveOccurred())
 				})
@@ -336,7 +356,7 @@ var _ = Describe("Uploader", func() {
 						"fixtures/correct/server-ca.crt",
 					)
 					Expect(err).NotTo(HaveOccurred())
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).To(HaveOccurred())
 				})
@@ -348,7 +368,7 @@ var _ = Describe("Uploader", func() {
 						"fixtures/incorrect/server-ca.crt",
 					)
 					Expect(err).NotTo(HaveOccurred())
-					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig)
+					upldr = uploader.New(logger, 100*time.Millisecond, tlsConfig, nil)
 					numBytes, err = upldr.Upload(file.Name(), url, nil)
 					Expect(err).To(HaveOccurred())
 				})
@@ -356,3 +376,9 @@ var _ = Describe("Uploader", func() {
 		})
 	})
 })
+
+func proxyTo(proxyURL string) func(*http.Request) (*url.URL, error) {
+	return func(*http.Request) (*url.URL, error) {
+		return url.Parse(proxyURL)
+	}
+}
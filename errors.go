@@ -47,4 +47,24 @@ var (
 	ErrFailureToCheckSpace            = registerError("ErrFailureToCheckSpace", "failed to check available space", http.StatusInternalServerError)
 	ErrInvalidSecurityGroup           = registerError("ErrInvalidSecurityGroup", "security group has invalid values", http.StatusBadRequest)
 	ErrNoProcessToStop                = registerError("ErrNoProcessToStop", "failed to find a process to stop", http.StatusNotFound)
+	ErrNoSourcePaths                  = registerError("NoSourcePaths", "no source paths specified", http.StatusBadRequest)
+	ErrFilesMaxBytesExceeded          = registerError("FilesMaxBytesExceeded", "file stream exceeded the maximum number of bytes", http.StatusRequestEntityTooLarge)
+	ErrFilesIdleTimeout               = registerError("FilesIdleTimeout", "file stream was idle for too long", http.StatusRequestTimeout)
+	ErrFilesDeadlineExceeded          = registerError("FilesDeadlineExceeded", "file stream did not complete in time", http.StatusGatewayTimeout)
+	ErrStopSignalInvalid              = registerError("StopSignalInvalid", "stop signal invalid", http.StatusBadRequest)
+	ErrDeadLetterNotFound             = registerError("DeadLetterNotFound", "dead letter not found", http.StatusNotFound)
+	ErrHealthCheckInvalid             = registerError("HealthCheckInvalid", "health check invalid", http.StatusBadRequest)
+	ErrContainerNotRunning            = registerError("ContainerNotRunning", "container is not running", http.StatusConflict)
+	ErrProcessPathNotSpecified        = registerError("ProcessPathNotSpecified", "process path not specified", http.StatusBadRequest)
+	ErrNoDestinationPath              = registerError("NoDestinationPath", "no destination path specified", http.StatusBadRequest)
+	ErrVolumeMountInvalid             = registerError("VolumeMountInvalid", "volume mount invalid", http.StatusBadRequest)
+	ErrRestartPolicyInvalid           = registerError("RestartPolicyInvalid", "restart policy invalid", http.StatusBadRequest)
+	ErrPortMappingsInvalid            = registerError("PortMappingsInvalid", "port mappings invalid", http.StatusBadRequest)
+	ErrHostPortsUnavailable           = registerError("HostPortsUnavailable", "requested host ports are unavailable", http.StatusConflict)
+	ErrDNSConfigInvalid               = registerError("DNSConfigInvalid", "dns servers or hosts entries invalid", http.StatusBadRequest)
+	ErrPrivilegedNotAllowed           = registerError("PrivilegedNotAllowed", "privileged containers are not allowed", http.StatusForbidden)
+	ErrExecutorDraining               = registerError("ExecutorDraining", "executor is draining and not accepting new work", http.StatusServiceUnavailable)
+	ErrUnhealthy                      = registerError("Unhealthy", "executor is unhealthy and not accepting new allocations", http.StatusServiceUnavailable)
+	ErrCacheAssetInvalid              = registerError("CacheAssetInvalid", "cache asset must have a url and a cache key", http.StatusBadRequest)
+	ErrRateLimited                    = registerError("RateLimited", "too many requests", http.StatusTooManyRequests)
 )
@@ -0,0 +1,241 @@
+package eventclient
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/lager"
+)
+
+var ErrSourceClosed = errors.New("event source closed")
+
+// MinBackoff is the shortest wait between reconnection attempts, so an
+// executor that is down is not retried in a busy loop.
+const MinBackoff = 100 * time.Millisecond
+
+type ConnectionState string
+
+const (
+	StateConnected    ConnectionState = "connected"
+	StateDisconnected ConnectionState = "disconnected"
+	// StateReset is reported when the executor restarted while the stream
+	// was lost. Events emitted before the restart may have been missed, so
+	// the subscriber should resync, e.g. by listing the containers.
+	StateReset ConnectionState = "reset"
+)
+
+// StateCallback is told each time the stream connects, each time it is lost
+// along with the error that lost it, and each time it is reset.
+type StateCallback func(state ConnectionState, err error)
+
+// SubscribeToEventsWithReconnect reads the executor's Server-Sent Event
+// stream at url. Whenever the stream fails it is reestablished, waiting
+// minBackoff (at least MinBackoff) after the first failure and doubling up to
+// maxBackoff after each further one, and resumed from the last event ID
+// received. Events already received are skipped if the executor replays them.
+// If the executor restarted in the meantime, the stream restarts from its
+// first buffered event and StateReset is reported.
+//
+// onStateChange may be nil.
+func SubscribeToEventsWithReconnect(
+	logger lager.Logger,
+	httpClient *http.Client,
+	url string,
+	clock clock.Clock,
+	minBackoff time.Duration,
+	maxBackoff time.Duration,
+	onStateChange StateCallback,
+) executor.EventSource {
+	if minBackoff < MinBackoff {
+		minBackoff = MinBackoff
+	}
+	if maxBackoff < minBackoff {
+		maxBackoff = minBackoff
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	source := &reconnectingSource{
+		logger:        logger.Session("event-subscription", lager.Data{"url": url}),
+		httpClient:    httpClient,
+		url:           url,
+		clock:         clock,
+		minBackoff:    minBackoff,
+		maxBackoff:    maxBackoff,
+		onStateChange: onStateChange,
+		ctx:           ctx,
+		cancel:        cancel,
+		events:        make(chan executor.Event),
+	}
+
+	go source.run()
+
+	return source
+}
+
+type reconnectingSource struct {
+	logger        lager.Logger
+	httpClient    *http.Client
+	url           string
+	clock         clock.Clock
+	minBackoff    time.Duration
+	maxBackoff    time.Duration
+	onStateChange StateCallback
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	events chan executor.Event
+
+	lastID    string
+	lastEpoch int64
+	lastSeq   uint64
+}
+
+func (s *reconnectingSource) Next() (executor.Event, error) {
+	select {
+	case ev := <-s.events:
+		return ev, nil
+	case <-s.ctx.Done():
+		return nil, ErrSourceClosed
+	}
+}
+
+func (s *reconnectingSource) Close() error {
+	s.cancel()
+	return nil
+}
+
+func (s *reconnectingSource) run() {
+	backoff := s.minBackoff
+
+	for {
+		connected, err := s.stream()
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		s.logger.Error("stream-lost", err, lager.Data{"last-event-id": s.lastID, "backoff": backoff.String()})
+		s.notify(StateDisconnected, err)
+
+		if connected {
+			backoff = s.minBackoff
+		}
+
+		timer := s.clock.NewTimer(backoff)
+		select {
+		case <-timer.C():
+		case <-s.ctx.Done():
+			timer.Stop()
+			return
+		}
+
+		backoff *= 2
+		if backoff > s.maxBackoff {
+			backoff = s.maxBackoff
+		}
+	}
+}
+
+// stream reads events until the connection fails, and reports whether it
+// was established at all.
+func (s *reconnectingSource) stream() (bool, error) {
+	request, err := http.NewRequest("GET", s.url, nil)
+	if err != nil {
+		return false, err
+	}
+	request = request.WithContext(s.ctx)
+	request.Header.Set("Accept", "text/event-stream")
+	if s.lastID != "" {
+		request.Header.Set("Last-Event-ID", s.lastID)
+	}
+
+	response, err := s.httpClient.Do(request)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	s.logger.Info("connected", lager.Data{"last-event-id": s.lastID})
+	s.notify(StateConnected, nil)
+
+	reader := bufio.NewReader(response.Body)
+	var id, eventType, data string
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return true, err
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			s.dispatch(id, eventType, data)
+			id, eventType, data = "", "", ""
+			continue
+		}
+
+		field, value := line, ""
+		if i := strings.Index(line, ":"); i >= 0 {
+			field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+		}
+
+		switch field {
+		case "id":
+			id = value
+		case "event":
+			eventType = value
+		case "data":
+			data += value
+		}
+	}
+}
+
+func (s *reconnectingSource) dispatch(id, eventType, data string) {
+	if eventType == "" || eventType == event.HeartbeatEvent {
+		return
+	}
+
+	if id != "" {
+		epoch, seq, err := event.ParseEventID(id)
+		if err != nil {
+			s.logger.Error("invalid-event-id", err, lager.Data{"id": id})
+			return
+		}
+		if epoch == s.lastEpoch && seq <= s.lastSeq {
+			return
+		}
+		if s.lastID != "" && epoch != s.lastEpoch {
+			s.logger.Info("stream-reset", lager.Data{"last-event-id": s.lastID, "id": id})
+			s.notify(StateReset, nil)
+		}
+		s.lastID, s.lastEpoch, s.lastSeq = id, epoch, seq
+	}
+
+	ev, err := executor.UnmarshalEvent(executor.EventType(eventType), []byte(data))
+	if err != nil {
+		s.logger.Error("failed-to-unmarshal-event", err, lager.Data{"event-type": eventType, "id": id})
+		return
+	}
+
+	select {
+	case s.events <- ev:
+	case <-s.ctx.Done():
+	}
+}
+
+func (s *reconnectingSource) notify(state ConnectionState, err error) {
+	if s.onStateChange != nil {
+		s.onStateChange(state, err)
+	}
+}
@@ -0,0 +1,201 @@
+package eventclient_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/eventclient"
+	"code.cloudfoundry.org/lager/lagertest"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/ginkgomon"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("SubscribeToEventsWithReconnect", func() {
+	var (
+		hub       event.Hub
+		process   ifrit.Process
+		handler   *swappableHandler
+		server    *httptest.Server
+		fakeClock *fakeclock.FakeClock
+		states    chan eventclient.ConnectionState
+		source    executor.EventSource
+		received  chan executor.Event
+	)
+
+	BeforeEach(func() {
+		logger := lagertest.NewTestLogger("test")
+
+		hub = event.NewHub()
+		stream := event.NewStream(logger, hub, clock.NewClock(), 10, 0)
+		process = ginkgomon.Invoke(stream)
+		handler = &swappableHandler{handler: stream}
+		server = httptest.NewServer(handler)
+
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		states = make(chan eventclient.ConnectionState, 10)
+		source = eventclient.SubscribeToEventsWithReconnect(
+			logger,
+			http.DefaultClient,
+			server.URL,
+			fakeClock,
+			time.Second,
+			4*time.Second,
+			func(state eventclient.ConnectionState, err error) {
+				states <- state
+			},
+		)
+
+		received = make(chan executor.Event, 10)
+		go func() {
+			defer GinkgoRecover()
+			for {
+				ev, err := source.Next()
+				if err != nil {
+					Expect(err).To(Equal(eventclient.ErrSourceClosed))
+					return
+				}
+				received <- ev
+			}
+		}()
+
+		Eventually(states).Should(Receive(Equal(eventclient.StateConnected)))
+	})
+
+	AfterEach(func() {
+		source.Close()
+		server.Close()
+		ginkgomon.Interrupt(process)
+		hub.Close()
+	})
+
+	emit := func(guid string) {
+		hub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: guid}))
+	}
+
+	It("decodes the streamed events", func() {
+		emit("guid-1")
+
+		var ev executor.Event
+		Eventually(received).Should(Receive(&ev))
+		Expect(ev).To(BeAssignableToTypeOf(executor.ContainerReservedEvent{}))
+		Expect(ev.(executor.ContainerReservedEvent).Container().Guid).To(Equal("guid-1"))
+	})
+
+	Context("when the stream is lost", func() {
+		BeforeEach(func() {
+			emit("guid-1")
+			Eventually(received).Should(Receive())
+
+			server.CloseClientConnections()
+			Eventually(states).Should(Receive(Equal(eventclient.StateDisconnected)))
+		})
+
+		It("reconnects after the backoff and resumes after the last event received", func() {
+			emit("guid-2")
+			Consistently(states).ShouldNot(Receive())
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(states).Should(Receive(Equal(eventclient.StateConnected)))
+
+			var ev executor.Event
+			Eventually(received).Should(Receive(&ev))
+			Expect(ev.(executor.ContainerReservedEvent).Container().Guid).To(Equal("guid-2"))
+			Consistently(received).ShouldNot(Receive())
+		})
+	})
+
+	Context("when the executor restarts while the stream is lost", func() {
+		var restartedHub event.Hub
+		var restartedProcess ifrit.Process
+
+		BeforeEach(func() {
+			emit("guid-1")
+			Eventually(received).Should(Receive())
+
+			restartedHub = event.NewHub()
+			restartedStream := event.NewStream(lagertest.NewTestLogger("test"), restartedHub, clock.NewClock(), 10, 0)
+			restartedProcess = ginkgomon.Invoke(restartedStream)
+			handler.Set(restartedStream)
+
+			server.CloseClientConnections()
+			Eventually(states).Should(Receive(Equal(eventclient.StateDisconnected)))
+		})
+
+		AfterEach(func() {
+			ginkgomon.Interrupt(restartedProcess)
+			restartedHub.Close()
+		})
+
+		It("reports the reset and delivers the events since the restart", func() {
+			restartedHub.Emit(executor.NewContainerReservedEvent(executor.Container{Guid: "guid-2"}))
+
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+			Eventually(states).Should(Receive(Equal(eventclient.StateConnected)))
+			Eventually(states).Should(Receive(Equal(eventclient.StateReset)))
+
+			var ev executor.Event
+			Eventually(received).Should(Receive(&ev))
+			Expect(ev.(executor.ContainerReservedEvent).Container().Guid).To(Equal("guid-2"))
+		})
+	})
+
+	It("waits at least MinBackoff between reconnections", func() {
+		otherClock := fakeclock.NewFakeClock(time.Now())
+		otherStates := make(chan eventclient.ConnectionState, 10)
+		other := eventclient.SubscribeToEventsWithReconnect(
+			lagertest.NewTestLogger("test"),
+			http.DefaultClient,
+			server.URL,
+			otherClock,
+			0,
+			0,
+			func(state eventclient.ConnectionState, err error) {
+				otherStates <- state
+			},
+		)
+		defer other.Close()
+		Eventually(otherStates).Should(Receive(Equal(eventclient.StateConnected)))
+
+		server.CloseClientConnections()
+		Eventually(otherStates).Should(Receive(Equal(eventclient.StateDisconnected)))
+		Consistently(otherStates).ShouldNot(Receive())
+
+		otherClock.WaitForWatcherAndIncrement(eventclient.MinBackoff)
+		Eventually(otherStates).Should(Receive(Equal(eventclient.StateConnected)))
+	})
+
+	It("stops reading once closed", func() {
+		source.Close()
+
+		_, err := source.Next()
+		Expect(err).To(Equal(eventclient.ErrSourceClosed))
+	})
+})
+
+type swappableHandler struct {
+	lock    sync.Mutex
+	handler http.Handler
+}
+
+func (h *swappableHandler) Set(handler http.Handler) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.handler = handler
+}
+
+func (h *swappableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	handler := h.handler
+	h.lock.Unlock()
+
+	handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,13 @@
+package eventclient_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestEventClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "EventClient Suite")
+}
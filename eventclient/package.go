@@ -0,0 +1 @@
+package eventclient // import "code.cloudfoundry.org/executor/eventclient"
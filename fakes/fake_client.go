@@ -2,8 +2,10 @@
 package fakes
 
 import (
+	"context"
 	"io"
 	"sync"
+	"time"
 
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/lager"
@@ -101,12 +103,13 @@ type FakeClient struct {
 		result1 executor.ExecutorResources
 		result2 error
 	}
-	GetFilesStub        func(logger lager.Logger, guid string, path string) (io.ReadCloser, error)
+	GetFilesStub        func(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error)
 	getFilesMutex       sync.RWMutex
 	getFilesArgsForCall []struct {
+		ctx    context.Context
 		logger lager.Logger
 		guid   string
-		path   string
+		paths  []string
 	}
 	getFilesReturns struct {
 		result1 io.ReadCloser
@@ -149,6 +152,178 @@ type FakeClient struct {
 	cleanupArgsForCall []struct {
 		arg1 lager.Logger
 	}
+	ListDeadLettersStub        func(logger lager.Logger) ([]executor.DeadLetter, error)
+	listDeadLettersMutex       sync.RWMutex
+	listDeadLettersArgsForCall []struct {
+		logger lager.Logger
+	}
+	listDeadLettersReturns struct {
+		result1 []executor.DeadLetter
+		result2 error
+	}
+	RetryDeadLetterStub        func(logger lager.Logger, guid string) error
+	retryDeadLetterMutex       sync.RWMutex
+	retryDeadLetterArgsForCall []struct {
+		logger lager.Logger
+		guid   string
+	}
+	retryDeadLetterReturns struct {
+		result1 error
+	}
+	DiscardDeadLetterStub        func(logger lager.Logger, guid string) error
+	discardDeadLetterMutex       sync.RWMutex
+	discardDeadLetterArgsForCall []struct {
+		logger lager.Logger
+		guid   string
+	}
+	discardDeadLetterReturns struct {
+		result1 error
+	}
+	SubscribeToFilteredEventsStub        func(lager.Logger, executor.EventFilter) (executor.EventSource, error)
+	subscribeToFilteredEventsMutex       sync.RWMutex
+	subscribeToFilteredEventsArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 executor.EventFilter
+	}
+	subscribeToFilteredEventsReturns struct {
+		result1 executor.EventSource
+		result2 error
+	}
+	PutFilesStub        func(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error
+	putFilesMutex       sync.RWMutex
+	putFilesArgsForCall []struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		destPath  string
+		tarStream io.Reader
+	}
+	putFilesReturns struct {
+		result1 error
+	}
+	RunProcessStub        func(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error)
+	runProcessMutex       sync.RWMutex
+	runProcessArgsForCall []struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		spec      executor.ProcessSpec
+		processIO executor.ProcessIO
+	}
+	runProcessReturns struct {
+		result1 int
+		result2 error
+	}
+	AttachContainerStub        func(logger lager.Logger, guid string) (io.ReadCloser, error)
+	attachContainerMutex       sync.RWMutex
+	attachContainerArgsForCall []struct {
+		logger lager.Logger
+		guid   string
+	}
+	attachContainerReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	CapacityStub        func(arg1 lager.Logger) (executor.ExecutorCapacity, error)
+	capacityMutex       sync.RWMutex
+	capacityArgsForCall []struct {
+		arg1 lager.Logger
+	}
+	capacityReturns struct {
+		result1 executor.ExecutorCapacity
+		result2 error
+	}
+	DrainStub        func(arg1 lager.Logger)
+	drainMutex       sync.RWMutex
+	drainArgsForCall []struct {
+		arg1 lager.Logger
+	}
+	EvacuateStub        func(arg1 lager.Logger) error
+	evacuateMutex       sync.RWMutex
+	evacuateArgsForCall []struct {
+		arg1 lager.Logger
+	}
+	evacuateReturns struct {
+		result1 error
+	}
+	AllocateContainersAtomicallyStub        func(logger lager.Logger, requests []executor.AllocationRequest) ([]executor.AllocationFailure, error)
+	allocateContainersAtomicallyMutex       sync.RWMutex
+	allocateContainersAtomicallyArgsForCall []struct {
+		logger   lager.Logger
+		requests []executor.AllocationRequest
+	}
+	allocateContainersAtomicallyReturns struct {
+		result1 []executor.AllocationFailure
+		result2 error
+	}
+	DeleteContainersStub        func(logger lager.Logger, tags executor.Tags) map[string]error
+	deleteContainersMutex       sync.RWMutex
+	deleteContainersArgsForCall []struct {
+		logger lager.Logger
+		tags   executor.Tags
+	}
+	deleteContainersReturns struct {
+		result1 map[string]error
+	}
+	RecordHealthcheckStub        func(arg1 lager.Logger, arg2 executor.HealthcheckResult)
+	recordHealthcheckMutex       sync.RWMutex
+	recordHealthcheckArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 executor.HealthcheckResult
+	}
+	HealthcheckHistoryStub        func(arg1 lager.Logger) []executor.HealthcheckResult
+	healthcheckHistoryMutex       sync.RWMutex
+	healthcheckHistoryArgsForCall []struct {
+		arg1 lager.Logger
+	}
+	healthcheckHistoryReturns struct {
+		result1 []executor.HealthcheckResult
+	}
+	ListContainersPageStub        func(arg1 lager.Logger, arg2 executor.ContainerListOptions) (executor.ContainerPage, error)
+	listContainersPageMutex       sync.RWMutex
+	listContainersPageArgsForCall []struct {
+		arg1 lager.Logger
+		arg2 executor.ContainerListOptions
+	}
+	listContainersPageReturns struct {
+		result1 executor.ContainerPage
+		result2 error
+	}
+	WatchContainerStub        func(ctx context.Context, logger lager.Logger, guid string, knownState executor.State, timeout time.Duration) (executor.Container, error)
+	watchContainerMutex       sync.RWMutex
+	watchContainerArgsForCall []struct {
+		ctx        context.Context
+		logger     lager.Logger
+		guid       string
+		knownState executor.State
+		timeout    time.Duration
+	}
+	watchContainerReturns struct {
+		result1 executor.Container
+		result2 error
+	}
+	PutFilesChunkedStub        func(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader, opts executor.ChunkedUploadOptions) error
+	putFilesChunkedMutex       sync.RWMutex
+	putFilesChunkedArgsForCall []struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		destPath  string
+		tarStream io.Reader
+		opts      executor.ChunkedUploadOptions
+	}
+	putFilesChunkedReturns struct {
+		result1 error
+	}
+	PrewarmCacheStub        func(logger lager.Logger, assets []executor.CacheAsset) map[string]error
+	prewarmCacheMutex       sync.RWMutex
+	prewarmCacheArgsForCall []struct {
+		logger lager.Logger
+		assets []executor.CacheAsset
+	}
+	prewarmCacheReturns struct {
+		result1 map[string]error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -499,17 +674,18 @@ func (fake *FakeClient) TotalResourcesReturns(result1 executor.ExecutorResources
 	}{result1, result2}
 }
 
-func (fake *FakeClient) GetFiles(logger lager.Logger, guid string, path string) (io.ReadCloser, error) {
+func (fake *FakeClient) GetFiles(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error) {
 	fake.getFilesMutex.Lock()
 	fake.getFilesArgsForCall = append(fake.getFilesArgsForCall, struct {
+		ctx    context.Context
 		logger lager.Logger
 		guid   string
-		path   string
-	}{logger, guid, path})
-	fake.recordInvocation("GetFiles", []interface{}{logger, guid, path})
+		paths  []string
+	}{ctx, logger, guid, paths})
+	fake.recordInvocation("GetFiles", []interface{}{ctx, logger, guid, paths})
 	fake.getFilesMutex.Unlock()
 	if fake.GetFilesStub != nil {
-		return fake.GetFilesStub(logger, guid, path)
+		return fake.GetFilesStub(ctx, logger, guid, paths...)
 	} else {
 		return fake.getFilesReturns.result1, fake.getFilesReturns.result2
 	}
@@ -521,10 +697,10 @@ func (fake *FakeClient) GetFilesCallCount() int {
 	return len(fake.getFilesArgsForCall)
 }
 
-func (fake *FakeClient) GetFilesArgsForCall(i int) (lager.Logger, string, string) {
+func (fake *FakeClient) GetFilesArgsForCall(i int) (context.Context, lager.Logger, string, []string) {
 	fake.getFilesMutex.RLock()
 	defer fake.getFilesMutex.RUnlock()
-	return fake.getFilesArgsForCall[i].logger, fake.getFilesArgsForCall[i].guid, fake.getFilesArgsForCall[i].path
+	return fake.getFilesArgsForCall[i].ctx, fake.getFilesArgsForCall[i].logger, fake.getFilesArgsForCall[i].guid, fake.getFilesArgsForCall[i].paths
 }
 
 func (fake *FakeClient) GetFilesReturns(result1 io.ReadCloser, result2 error) {
@@ -685,6 +861,626 @@ func (fake *FakeClient) CleanupArgsForCall(i int) lager.Logger {
 	return fake.cleanupArgsForCall[i].arg1
 }
 
+func (fake *FakeClient) ListDeadLetters(logger lager.Logger) ([]executor.DeadLetter, error) {
+	fake.listDeadLettersMutex.Lock()
+	fake.listDeadLettersArgsForCall = append(fake.listDeadLettersArgsForCall, struct {
+		logger lager.Logger
+	}{logger})
+	fake.recordInvocation("ListDeadLetters", []interface{}{logger})
+	fake.listDeadLettersMutex.Unlock()
+	if fake.ListDeadLettersStub != nil {
+		return fake.ListDeadLettersStub(logger)
+	} else {
+		return fake.listDeadLettersReturns.result1, fake.listDeadLettersReturns.result2
+	}
+}
+
+func (fake *FakeClient) ListDeadLettersCallCount() int {
+	fake.listDeadLettersMutex.RLock()
+	defer fake.listDeadLettersMutex.RUnlock()
+	return len(fake.listDeadLettersArgsForCall)
+}
+
+func (fake *FakeClient) ListDeadLettersArgsForCall(i int) lager.Logger {
+	fake.listDeadLettersMutex.RLock()
+	defer fake.listDeadLettersMutex.RUnlock()
+	return fake.listDeadLettersArgsForCall[i].logger
+}
+
+func (fake *FakeClient) ListDeadLettersReturns(result1 []executor.DeadLetter, result2 error) {
+	fake.ListDeadLettersStub = nil
+	fake.listDeadLettersReturns = struct {
+		result1 []executor.DeadLetter
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) RetryDeadLetter(logger lager.Logger, guid string) error {
+	fake.retryDeadLetterMutex.Lock()
+	fake.retryDeadLetterArgsForCall = append(fake.retryDeadLetterArgsForCall, struct {
+		logger lager.Logger
+		guid   string
+	}{logger, guid})
+	fake.recordInvocation("RetryDeadLetter", []interface{}{logger, guid})
+	fake.retryDeadLetterMutex.Unlock()
+	if fake.RetryDeadLetterStub != nil {
+		return fake.RetryDeadLetterStub(logger, guid)
+	} else {
+		return fake.retryDeadLetterReturns.result1
+	}
+}
+
+func (fake *FakeClient) RetryDeadLetterCallCount() int {
+	fake.retryDeadLetterMutex.RLock()
+	defer fake.retryDeadLetterMutex.RUnlock()
+	return len(fake.retryDeadLetterArgsForCall)
+}
+
+func (fake *FakeClient) RetryDeadLetterArgsForCall(i int) (lager.Logger, string) {
+	fake.retryDeadLetterMutex.RLock()
+	defer fake.retryDeadLetterMutex.RUnlock()
+	return fake.retryDeadLetterArgsForCall[i].logger, fake.retryDeadLetterArgsForCall[i].guid
+}
+
+func (fake *FakeClient) RetryDeadLetterReturns(result1 error) {
+	fake.RetryDeadLetterStub = nil
+	fake.retryDeadLetterReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) DiscardDeadLetter(logger lager.Logger, guid string) error {
+	fake.discardDeadLetterMutex.Lock()
+	fake.discardDeadLetterArgsForCall = append(fake.discardDeadLetterArgsForCall, struct {
+		logger lager.Logger
+		guid   string
+	}{logger, guid})
+	fake.recordInvocation("DiscardDeadLetter", []interface{}{logger, guid})
+	fake.discardDeadLetterMutex.Unlock()
+	if fake.DiscardDeadLetterStub != nil {
+		return fake.DiscardDeadLetterStub(logger, guid)
+	} else {
+		return fake.discardDeadLetterReturns.result1
+	}
+}
+
+func (fake *FakeClient) DiscardDeadLetterCallCount() int {
+	fake.discardDeadLetterMutex.RLock()
+	defer fake.discardDeadLetterMutex.RUnlock()
+	return len(fake.discardDeadLetterArgsForCall)
+}
+
+func (fake *FakeClient) DiscardDeadLetterArgsForCall(i int) (lager.Logger, string) {
+	fake.discardDeadLetterMutex.RLock()
+	defer fake.discardDeadLetterMutex.RUnlock()
+	return fake.discardDeadLetterArgsForCall[i].logger, fake.discardDeadLetterArgsForCall[i].guid
+}
+
+func (fake *FakeClient) DiscardDeadLetterReturns(result1 error) {
+	fake.DiscardDeadLetterStub = nil
+	fake.discardDeadLetterReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) SubscribeToFilteredEvents(arg1 lager.Logger, arg2 executor.EventFilter) (executor.EventSource, error) {
+	fake.subscribeToFilteredEventsMutex.Lock()
+	fake.subscribeToFilteredEventsArgsForCall = append(fake.subscribeToFilteredEventsArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 executor.EventFilter
+	}{arg1, arg2})
+	fake.recordInvocation("SubscribeToFilteredEvents", []interface{}{arg1, arg2})
+	fake.subscribeToFilteredEventsMutex.Unlock()
+	if fake.SubscribeToFilteredEventsStub != nil {
+		return fake.SubscribeToFilteredEventsStub(arg1, arg2)
+	} else {
+		return fake.subscribeToFilteredEventsReturns.result1, fake.subscribeToFilteredEventsReturns.result2
+	}
+}
+
+func (fake *FakeClient) SubscribeToFilteredEventsCallCount() int {
+	fake.subscribeToFilteredEventsMutex.RLock()
+	defer fake.subscribeToFilteredEventsMutex.RUnlock()
+	return len(fake.subscribeToFilteredEventsArgsForCall)
+}
+
+func (fake *FakeClient) SubscribeToFilteredEventsArgsForCall(i int) (lager.Logger, executor.EventFilter) {
+	fake.subscribeToFilteredEventsMutex.RLock()
+	defer fake.subscribeToFilteredEventsMutex.RUnlock()
+	return fake.subscribeToFilteredEventsArgsForCall[i].arg1, fake.subscribeToFilteredEventsArgsForCall[i].arg2
+}
+
+func (fake *FakeClient) SubscribeToFilteredEventsReturns(result1 executor.EventSource, result2 error) {
+	fake.SubscribeToFilteredEventsStub = nil
+	fake.subscribeToFilteredEventsReturns = struct {
+		result1 executor.EventSource
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) PutFiles(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader) error {
+	fake.putFilesMutex.Lock()
+	fake.putFilesArgsForCall = append(fake.putFilesArgsForCall, struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		destPath  string
+		tarStream io.Reader
+	}{ctx, logger, guid, destPath, tarStream})
+	fake.recordInvocation("PutFiles", []interface{}{ctx, logger, guid, destPath, tarStream})
+	fake.putFilesMutex.Unlock()
+	if fake.PutFilesStub != nil {
+		return fake.PutFilesStub(ctx, logger, guid, destPath, tarStream)
+	} else {
+		return fake.putFilesReturns.result1
+	}
+}
+
+func (fake *FakeClient) PutFilesCallCount() int {
+	fake.putFilesMutex.RLock()
+	defer fake.putFilesMutex.RUnlock()
+	return len(fake.putFilesArgsForCall)
+}
+
+func (fake *FakeClient) PutFilesArgsForCall(i int) (context.Context, lager.Logger, string, string, io.Reader) {
+	fake.putFilesMutex.RLock()
+	defer fake.putFilesMutex.RUnlock()
+	return fake.putFilesArgsForCall[i].ctx, fake.putFilesArgsForCall[i].logger, fake.putFilesArgsForCall[i].guid, fake.putFilesArgsForCall[i].destPath, fake.putFilesArgsForCall[i].tarStream
+}
+
+func (fake *FakeClient) PutFilesReturns(result1 error) {
+	fake.PutFilesStub = nil
+	fake.putFilesReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) RunProcess(ctx context.Context, logger lager.Logger, guid string, spec executor.ProcessSpec, processIO executor.ProcessIO) (int, error) {
+	fake.runProcessMutex.Lock()
+	fake.runProcessArgsForCall = append(fake.runProcessArgsForCall, struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		spec      executor.ProcessSpec
+		processIO executor.ProcessIO
+	}{ctx, logger, guid, spec, processIO})
+	fake.recordInvocation("RunProcess", []interface{}{ctx, logger, guid, spec, processIO})
+	fake.runProcessMutex.Unlock()
+	if fake.RunProcessStub != nil {
+		return fake.RunProcessStub(ctx, logger, guid, spec, processIO)
+	} else {
+		return fake.runProcessReturns.result1, fake.runProcessReturns.result2
+	}
+}
+
+func (fake *FakeClient) RunProcessCallCount() int {
+	fake.runProcessMutex.RLock()
+	defer fake.runProcessMutex.RUnlock()
+	return len(fake.runProcessArgsForCall)
+}
+
+func (fake *FakeClient) RunProcessArgsForCall(i int) (context.Context, lager.Logger, string, executor.ProcessSpec, executor.ProcessIO) {
+	fake.runProcessMutex.RLock()
+	defer fake.runProcessMutex.RUnlock()
+	return fake.runProcessArgsForCall[i].ctx, fake.runProcessArgsForCall[i].logger, fake.runProcessArgsForCall[i].guid, fake.runProcessArgsForCall[i].spec, fake.runProcessArgsForCall[i].processIO
+}
+
+func (fake *FakeClient) RunProcessReturns(result1 int, result2 error) {
+	fake.RunProcessStub = nil
+	fake.runProcessReturns = struct {
+		result1 int
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) AttachContainer(logger lager.Logger, guid string) (io.ReadCloser, error) {
+	fake.attachContainerMutex.Lock()
+	fake.attachContainerArgsForCall = append(fake.attachContainerArgsForCall, struct {
+		logger lager.Logger
+		guid   string
+	}{logger, guid})
+	fake.recordInvocation("AttachContainer", []interface{}{logger, guid})
+	fake.attachContainerMutex.Unlock()
+	if fake.AttachContainerStub != nil {
+		return fake.AttachContainerStub(logger, guid)
+	} else {
+		return fake.attachContainerReturns.result1, fake.attachContainerReturns.result2
+	}
+}
+
+func (fake *FakeClient) AttachContainerCallCount() int {
+	fake.attachContainerMutex.RLock()
+	defer fake.attachContainerMutex.RUnlock()
+	return len(fake.attachContainerArgsForCall)
+}
+
+func (fake *FakeClient) AttachContainerArgsForCall(i int) (lager.Logger, string) {
+	fake.attachContainerMutex.RLock()
+	defer fake.attachContainerMutex.RUnlock()
+	return fake.attachContainerArgsForCall[i].logger, fake.attachContainerArgsForCall[i].guid
+}
+
+func (fake *FakeClient) AttachContainerReturns(result1 io.ReadCloser, result2 error) {
+	fake.AttachContainerStub = nil
+	fake.attachContainerReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) Capacity(arg1 lager.Logger) (executor.ExecutorCapacity, error) {
+	fake.capacityMutex.Lock()
+	fake.capacityArgsForCall = append(fake.capacityArgsForCall, struct {
+		arg1 lager.Logger
+	}{arg1})
+	fake.recordInvocation("Capacity", []interface{}{arg1})
+	fake.capacityMutex.Unlock()
+	if fake.CapacityStub != nil {
+		return fake.CapacityStub(arg1)
+	} else {
+		return fake.capacityReturns.result1, fake.capacityReturns.result2
+	}
+}
+
+func (fake *FakeClient) CapacityCallCount() int {
+	fake.capacityMutex.RLock()
+	defer fake.capacityMutex.RUnlock()
+	return len(fake.capacityArgsForCall)
+}
+
+func (fake *FakeClient) CapacityArgsForCall(i int) lager.Logger {
+	fake.capacityMutex.RLock()
+	defer fake.capacityMutex.RUnlock()
+	return fake.capacityArgsForCall[i].arg1
+}
+
+func (fake *FakeClient) CapacityReturns(result1 executor.ExecutorCapacity, result2 error) {
+	fake.CapacityStub = nil
+	fake.capacityReturns = struct {
+		result1 executor.ExecutorCapacity
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) Drain(arg1 lager.Logger) {
+	fake.drainMutex.Lock()
+	fake.drainArgsForCall = append(fake.drainArgsForCall, struct {
+		arg1 lager.Logger
+	}{arg1})
+	fake.recordInvocation("Drain", []interface{}{arg1})
+	fake.drainMutex.Unlock()
+	if fake.DrainStub != nil {
+		fake.DrainStub(arg1)
+	}
+}
+
+func (fake *FakeClient) DrainCallCount() int {
+	fake.drainMutex.RLock()
+	defer fake.drainMutex.RUnlock()
+	return len(fake.drainArgsForCall)
+}
+
+func (fake *FakeClient) DrainArgsForCall(i int) lager.Logger {
+	fake.drainMutex.RLock()
+	defer fake.drainMutex.RUnlock()
+	return fake.drainArgsForCall[i].arg1
+}
+
+func (fake *FakeClient) Evacuate(arg1 lager.Logger) error {
+	fake.evacuateMutex.Lock()
+	fake.evacuateArgsForCall = append(fake.evacuateArgsForCall, struct {
+		arg1 lager.Logger
+	}{arg1})
+	fake.recordInvocation("Evacuate", []interface{}{arg1})
+	fake.evacuateMutex.Unlock()
+	if fake.EvacuateStub != nil {
+		return fake.EvacuateStub(arg1)
+	} else {
+		return fake.evacuateReturns.result1
+	}
+}
+
+func (fake *FakeClient) EvacuateCallCount() int {
+	fake.evacuateMutex.RLock()
+	defer fake.evacuateMutex.RUnlock()
+	return len(fake.evacuateArgsForCall)
+}
+
+func (fake *FakeClient) EvacuateArgsForCall(i int) lager.Logger {
+	fake.evacuateMutex.RLock()
+	defer fake.evacuateMutex.RUnlock()
+	return fake.evacuateArgsForCall[i].arg1
+}
+
+func (fake *FakeClient) EvacuateReturns(result1 error) {
+	fake.EvacuateStub = nil
+	fake.evacuateReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) AllocateContainersAtomically(logger lager.Logger, requests []executor.AllocationRequest) ([]executor.AllocationFailure, error) {
+	var requestsCopy []executor.AllocationRequest
+	if requests != nil {
+		requestsCopy = make([]executor.AllocationRequest, len(requests))
+		copy(requestsCopy, requests)
+	}
+	fake.allocateContainersAtomicallyMutex.Lock()
+	fake.allocateContainersAtomicallyArgsForCall = append(fake.allocateContainersAtomicallyArgsForCall, struct {
+		logger   lager.Logger
+		requests []executor.AllocationRequest
+	}{logger, requestsCopy})
+	fake.recordInvocation("AllocateContainersAtomically", []interface{}{logger, requestsCopy})
+	fake.allocateContainersAtomicallyMutex.Unlock()
+	if fake.AllocateContainersAtomicallyStub != nil {
+		return fake.AllocateContainersAtomicallyStub(logger, requests)
+	} else {
+		return fake.allocateContainersAtomicallyReturns.result1, fake.allocateContainersAtomicallyReturns.result2
+	}
+}
+
+func (fake *FakeClient) AllocateContainersAtomicallyCallCount() int {
+	fake.allocateContainersAtomicallyMutex.RLock()
+	defer fake.allocateContainersAtomicallyMutex.RUnlock()
+	return len(fake.allocateContainersAtomicallyArgsForCall)
+}
+
+func (fake *FakeClient) AllocateContainersAtomicallyArgsForCall(i int) (lager.Logger, []executor.AllocationRequest) {
+	fake.allocateContainersAtomicallyMutex.RLock()
+	defer fake.allocateContainersAtomicallyMutex.RUnlock()
+	return fake.allocateContainersAtomicallyArgsForCall[i].logger, fake.allocateContainersAtomicallyArgsForCall[i].requests
+}
+
+func (fake *FakeClient) AllocateContainersAtomicallyReturns(result1 []executor.AllocationFailure, result2 error) {
+	fake.AllocateContainersAtomicallyStub = nil
+	fake.allocateContainersAtomicallyReturns = struct {
+		result1 []executor.AllocationFailure
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) DeleteContainers(logger lager.Logger, tags executor.Tags) map[string]error {
+	fake.deleteContainersMutex.Lock()
+	fake.deleteContainersArgsForCall = append(fake.deleteContainersArgsForCall, struct {
+		logger lager.Logger
+		tags   executor.Tags
+	}{logger, tags})
+	fake.recordInvocation("DeleteContainers", []interface{}{logger, tags})
+	fake.deleteContainersMutex.Unlock()
+	if fake.DeleteContainersStub != nil {
+		return fake.DeleteContainersStub(logger, tags)
+	} else {
+		return fake.deleteContainersReturns.result1
+	}
+}
+
+func (fake *FakeClient) DeleteContainersCallCount() int {
+	fake.deleteContainersMutex.RLock()
+	defer fake.deleteContainersMutex.RUnlock()
+	return len(fake.deleteContainersArgsForCall)
+}
+
+func (fake *FakeClient) DeleteContainersArgsForCall(i int) (lager.Logger, executor.Tags) {
+	fake.deleteContainersMutex.RLock()
+	defer fake.deleteContainersMutex.RUnlock()
+	return fake.deleteContainersArgsForCall[i].logger, fake.deleteContainersArgsForCall[i].tags
+}
+
+func (fake *FakeClient) DeleteContainersReturns(result1 map[string]error) {
+	fake.DeleteContainersStub = nil
+	fake.deleteContainersReturns = struct {
+		result1 map[string]error
+	}{result1}
+}
+
+func (fake *FakeClient) RecordHealthcheck(arg1 lager.Logger, arg2 executor.HealthcheckResult) {
+	fake.recordHealthcheckMutex.Lock()
+	fake.recordHealthcheckArgsForCall = append(fake.recordHealthcheckArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 executor.HealthcheckResult
+	}{arg1, arg2})
+	fake.recordInvocation("RecordHealthcheck", []interface{}{arg1, arg2})
+	fake.recordHealthcheckMutex.Unlock()
+	if fake.RecordHealthcheckStub != nil {
+		fake.RecordHealthcheckStub(arg1, arg2)
+	}
+}
+
+func (fake *FakeClient) RecordHealthcheckCallCount() int {
+	fake.recordHealthcheckMutex.RLock()
+	defer fake.recordHealthcheckMutex.RUnlock()
+	return len(fake.recordHealthcheckArgsForCall)
+}
+
+func (fake *FakeClient) RecordHealthcheckArgsForCall(i int) (lager.Logger, executor.HealthcheckResult) {
+	fake.recordHealthcheckMutex.RLock()
+	defer fake.recordHealthcheckMutex.RUnlock()
+	return fake.recordHealthcheckArgsForCall[i].arg1, fake.recordHealthcheckArgsForCall[i].arg2
+}
+
+func (fake *FakeClient) HealthcheckHistory(arg1 lager.Logger) []executor.HealthcheckResult {
+	fake.healthcheckHistoryMutex.Lock()
+	fake.healthcheckHistoryArgsForCall = append(fake.healthcheckHistoryArgsForCall, struct {
+		arg1 lager.Logger
+	}{arg1})
+	fake.recordInvocation("HealthcheckHistory", []interface{}{arg1})
+	fake.healthcheckHistoryMutex.Unlock()
+	if fake.HealthcheckHistoryStub != nil {
+		return fake.HealthcheckHistoryStub(arg1)
+	} else {
+		return fake.healthcheckHistoryReturns.result1
+	}
+}
+
+func (fake *FakeClient) HealthcheckHistoryCallCount() int {
+	fake.healthcheckHistoryMutex.RLock()
+	defer fake.healthcheckHistoryMutex.RUnlock()
+	return len(fake.healthcheckHistoryArgsForCall)
+}
+
+func (fake *FakeClient) HealthcheckHistoryArgsForCall(i int) lager.Logger {
+	fake.healthcheckHistoryMutex.RLock()
+	defer fake.healthcheckHistoryMutex.RUnlock()
+	return fake.healthcheckHistoryArgsForCall[i].arg1
+}
+
+func (fake *FakeClient) HealthcheckHistoryReturns(result1 []executor.HealthcheckResult) {
+	fake.HealthcheckHistoryStub = nil
+	fake.healthcheckHistoryReturns = struct {
+		result1 []executor.HealthcheckResult
+	}{result1}
+}
+
+func (fake *FakeClient) ListContainersPage(arg1 lager.Logger, arg2 executor.ContainerListOptions) (executor.ContainerPage, error) {
+	fake.listContainersPageMutex.Lock()
+	fake.listContainersPageArgsForCall = append(fake.listContainersPageArgsForCall, struct {
+		arg1 lager.Logger
+		arg2 executor.ContainerListOptions
+	}{arg1, arg2})
+	fake.recordInvocation("ListContainersPage", []interface{}{arg1, arg2})
+	fake.listContainersPageMutex.Unlock()
+	if fake.ListContainersPageStub != nil {
+		return fake.ListContainersPageStub(arg1, arg2)
+	} else {
+		return fake.listContainersPageReturns.result1, fake.listContainersPageReturns.result2
+	}
+}
+
+func (fake *FakeClient) ListContainersPageCallCount() int {
+	fake.listContainersPageMutex.RLock()
+	defer fake.listContainersPageMutex.RUnlock()
+	return len(fake.listContainersPageArgsForCall)
+}
+
+func (fake *FakeClient) ListContainersPageArgsForCall(i int) (lager.Logger, executor.ContainerListOptions) {
+	fake.listContainersPageMutex.RLock()
+	defer fake.listContainersPageMutex.RUnlock()
+	return fake.listContainersPageArgsForCall[i].arg1, fake.listContainersPageArgsForCall[i].arg2
+}
+
+func (fake *FakeClient) ListContainersPageReturns(result1 executor.ContainerPage, result2 error) {
+	fake.ListContainersPageStub = nil
+	fake.listContainersPageReturns = struct {
+		result1 executor.ContainerPage
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) WatchContainer(ctx context.Context, logger lager.Logger, guid string, knownState executor.State, timeout time.Duration) (executor.Container, error) {
+	fake.watchContainerMutex.Lock()
+	fake.watchContainerArgsForCall = append(fake.watchContainerArgsForCall, struct {
+		ctx        context.Context
+		logger     lager.Logger
+		guid       string
+		knownState executor.State
+		timeout    time.Duration
+	}{ctx, logger, guid, knownState, timeout})
+	fake.recordInvocation("WatchContainer", []interface{}{ctx, logger, guid, knownState, timeout})
+	fake.watchContainerMutex.Unlock()
+	if fake.WatchContainerStub != nil {
+		return fake.WatchContainerStub(ctx, logger, guid, knownState, timeout)
+	} else {
+		return fake.watchContainerReturns.result1, fake.watchContainerReturns.result2
+	}
+}
+
+func (fake *FakeClient) WatchContainerCallCount() int {
+	fake.watchContainerMutex.RLock()
+	defer fake.watchContainerMutex.RUnlock()
+	return len(fake.watchContainerArgsForCall)
+}
+
+func (fake *FakeClient) WatchContainerArgsForCall(i int) (context.Context, lager.Logger, string, executor.State, time.Duration) {
+	fake.watchContainerMutex.RLock()
+	defer fake.watchContainerMutex.RUnlock()
+	return fake.watchContainerArgsForCall[i].ctx, fake.watchContainerArgsForCall[i].logger, fake.watchContainerArgsForCall[i].guid, fake.watchContainerArgsForCall[i].knownState, fake.watchContainerArgsForCall[i].timeout
+}
+
+func (fake *FakeClient) WatchContainerReturns(result1 executor.Container, result2 error) {
+	fake.WatchContainerStub = nil
+	fake.watchContainerReturns = struct {
+		result1 executor.Container
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeClient) PutFilesChunked(ctx context.Context, logger lager.Logger, guid string, destPath string, tarStream io.Reader, opts executor.ChunkedUploadOptions) error {
+	fake.putFilesChunkedMutex.Lock()
+	fake.putFilesChunkedArgsForCall = append(fake.putFilesChunkedArgsForCall, struct {
+		ctx       context.Context
+		logger    lager.Logger
+		guid      string
+		destPath  string
+		tarStream io.Reader
+		opts      executor.ChunkedUploadOptions
+	}{ctx, logger, guid, destPath, tarStream, opts})
+	fake.recordInvocation("PutFilesChunked", []interface{}{ctx, logger, guid, destPath, tarStream, opts})
+	fake.putFilesChunkedMutex.Unlock()
+	if fake.PutFilesChunkedStub != nil {
+		return fake.PutFilesChunkedStub(ctx, logger, guid, destPath, tarStream, opts)
+	} else {
+		return fake.putFilesChunkedReturns.result1
+	}
+}
+
+func (fake *FakeClient) PutFilesChunkedCallCount() int {
+	fake.putFilesChunkedMutex.RLock()
+	defer fake.putFilesChunkedMutex.RUnlock()
+	return len(fake.putFilesChunkedArgsForCall)
+}
+
+func (fake *FakeClient) PutFilesChunkedArgsForCall(i int) (context.Context, lager.Logger, string, string, io.Reader, executor.ChunkedUploadOptions) {
+	fake.putFilesChunkedMutex.RLock()
+	defer fake.putFilesChunkedMutex.RUnlock()
+	return fake.putFilesChunkedArgsForCall[i].ctx, fake.putFilesChunkedArgsForCall[i].logger, fake.putFilesChunkedArgsForCall[i].guid, fake.putFilesChunkedArgsForCall[i].destPath, fake.putFilesChunkedArgsForCall[i].tarStream, fake.putFilesChunkedArgsForCall[i].opts
+}
+
+func (fake *FakeClient) PutFilesChunkedReturns(result1 error) {
+	fake.PutFilesChunkedStub = nil
+	fake.putFilesChunkedReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeClient) PrewarmCache(logger lager.Logger, assets []executor.CacheAsset) map[string]error {
+	var assetsCopy []executor.CacheAsset
+	if assets != nil {
+		assetsCopy = make([]executor.CacheAsset, len(assets))
+		copy(assetsCopy, assets)
+	}
+	fake.prewarmCacheMutex.Lock()
+	fake.prewarmCacheArgsForCall = append(fake.prewarmCacheArgsForCall, struct {
+		logger lager.Logger
+		assets []executor.CacheAsset
+	}{logger, assetsCopy})
+	fake.recordInvocation("PrewarmCache", []interface{}{logger, assetsCopy})
+	fake.prewarmCacheMutex.Unlock()
+	if fake.PrewarmCacheStub != nil {
+		return fake.PrewarmCacheStub(logger, assets)
+	} else {
+		return fake.prewarmCacheReturns.result1
+	}
+}
+
+func (fake *FakeClient) PrewarmCacheCallCount() int {
+	fake.prewarmCacheMutex.RLock()
+	defer fake.prewarmCacheMutex.RUnlock()
+	return len(fake.prewarmCacheArgsForCall)
+}
+
+func (fake *FakeClient) PrewarmCacheArgsForCall(i int) (lager.Logger, []executor.CacheAsset) {
+	fake.prewarmCacheMutex.RLock()
+	defer fake.prewarmCacheMutex.RUnlock()
+	return fake.prewarmCacheArgsForCall[i].logger, fake.prewarmCacheArgsForCall[i].assets
+}
+
+func (fake *FakeClient) PrewarmCacheReturns(result1 map[string]error) {
+	fake.PrewarmCacheStub = nil
+	fake.prewarmCacheReturns = struct {
+		result1 map[string]error
+	}{result1}
+}
+
 func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -720,6 +1516,42 @@ func (fake *FakeClient) Invocations() map[string][][]interface{} {
 	defer fake.setHealthyMutex.RUnlock()
 	fake.cleanupMutex.RLock()
 	defer fake.cleanupMutex.RUnlock()
+	fake.listDeadLettersMutex.RLock()
+	defer fake.listDeadLettersMutex.RUnlock()
+	fake.retryDeadLetterMutex.RLock()
+	defer fake.retryDeadLetterMutex.RUnlock()
+	fake.discardDeadLetterMutex.RLock()
+	defer fake.discardDeadLetterMutex.RUnlock()
+	fake.subscribeToFilteredEventsMutex.RLock()
+	defer fake.subscribeToFilteredEventsMutex.RUnlock()
+	fake.putFilesMutex.RLock()
+	defer fake.putFilesMutex.RUnlock()
+	fake.runProcessMutex.RLock()
+	defer fake.runProcessMutex.RUnlock()
+	fake.attachContainerMutex.RLock()
+	defer fake.attachContainerMutex.RUnlock()
+	fake.capacityMutex.RLock()
+	defer fake.capacityMutex.RUnlock()
+	fake.drainMutex.RLock()
+	defer fake.drainMutex.RUnlock()
+	fake.evacuateMutex.RLock()
+	defer fake.evacuateMutex.RUnlock()
+	fake.allocateContainersAtomicallyMutex.RLock()
+	defer fake.allocateContainersAtomicallyMutex.RUnlock()
+	fake.deleteContainersMutex.RLock()
+	defer fake.deleteContainersMutex.RUnlock()
+	fake.recordHealthcheckMutex.RLock()
+	defer fake.recordHealthcheckMutex.RUnlock()
+	fake.healthcheckHistoryMutex.RLock()
+	defer fake.healthcheckHistoryMutex.RUnlock()
+	fake.listContainersPageMutex.RLock()
+	defer fake.listContainersPageMutex.RUnlock()
+	fake.watchContainerMutex.RLock()
+	defer fake.watchContainerMutex.RUnlock()
+	fake.putFilesChunkedMutex.RLock()
+	defer fake.putFilesChunkedMutex.RUnlock()
+	fake.prewarmCacheMutex.RLock()
+	defer fake.prewarmCacheMutex.RUnlock()
 	return fake.invocations
 }
 
@@ -0,0 +1,299 @@
+package gardenclient
+
+import (
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/lager"
+)
+
+var (
+	ErrCircuitOpen = errors.New("garden circuit breaker is open")
+	ErrCallTimeout = errors.New("timed out waiting for garden")
+)
+
+// client guards a garden client with timeouts, retries and a circuit breaker.
+//
+// Each call to garden fails with ErrCallTimeout if it has not returned within
+// callTimeout; the call itself is left to finish in the background, and its
+// results are discarded. A container created by a create that timed out is
+// destroyed once garden returns it.
+//
+// Calls that are safe to repeat are retried up to retries times, waiting
+// retryBackoff between attempts, when they fail to reach garden or time out.
+// After breakerThreshold consecutive calls fail that way the breaker opens
+// and calls fail with ErrCircuitOpen for breakerCooldown, after which a single
+// call is let through to probe garden again. Errors returned by garden itself
+// pass through untouched and do not count towards the breaker.
+type client struct {
+	logger           lager.Logger
+	gardenClient     garden.Client
+	clock            clock.Clock
+	callTimeout      time.Duration
+	retries          int
+	retryBackoff     time.Duration
+	breakerThreshold int
+	breakerCooldown  time.Duration
+
+	lock        sync.Mutex
+	failures    int
+	openUntil   time.Time
+	breakerOpen bool
+}
+
+// New returns gardenClient guarded by timeouts, retries and a circuit
+// breaker. A callTimeout or breakerThreshold of zero disables the timeout or
+// the breaker.
+func New(
+	logger lager.Logger,
+	gardenClient garden.Client,
+	clock clock.Clock,
+	callTimeout time.Duration,
+	retries int,
+	retryBackoff time.Duration,
+	breakerThreshold int,
+	breakerCooldown time.Duration,
+) garden.Client {
+	return &client{
+		logger:           logger.Session("garden-client"),
+		gardenClient:     gardenClient,
+		clock:            clock,
+		callTimeout:      callTimeout,
+		retries:          retries,
+		retryBackoff:     retryBackoff,
+		breakerThreshold: breakerThreshold,
+		breakerCooldown:  breakerCooldown,
+	}
+}
+
+func (c *client) Ping() error {
+	_, err := c.call("ping", true, func() (interface{}, error) {
+		return nil, c.gardenClient.Ping()
+	}, nil)
+	return err
+}
+
+func (c *client) Capacity() (garden.Capacity, error) {
+	result, err := c.call("capacity", true, func() (interface{}, error) {
+		return c.gardenClient.Capacity()
+	}, nil)
+	if err != nil {
+		return garden.Capacity{}, err
+	}
+	capacity, _ := result.(garden.Capacity)
+	return capacity, nil
+}
+
+func (c *client) Create(spec garden.ContainerSpec) (garden.Container, error) {
+	result, err := c.call("create", false, func() (interface{}, error) {
+		return c.gardenClient.Create(spec)
+	}, c.destroyAbandoned)
+	if err != nil {
+		return nil, err
+	}
+	container, _ := result.(garden.Container)
+	return container, nil
+}
+
+func (c *client) Destroy(handle string) error {
+	_, err := c.call("destroy", false, func() (interface{}, error) {
+		return nil, c.gardenClient.Destroy(handle)
+	}, nil)
+	return err
+}
+
+func (c *client) Containers(properties garden.Properties) ([]garden.Container, error) {
+	result, err := c.call("containers", true, func() (interface{}, error) {
+		return c.gardenClient.Containers(properties)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	containers, _ := result.([]garden.Container)
+	return containers, nil
+}
+
+func (c *client) BulkInfo(handles []string) (map[string]garden.ContainerInfoEntry, error) {
+	result, err := c.call("bulk-info", true, func() (interface{}, error) {
+		return c.gardenClient.BulkInfo(handles)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	infos, _ := result.(map[string]garden.ContainerInfoEntry)
+	return infos, nil
+}
+
+func (c *client) BulkMetrics(handles []string) (map[string]garden.ContainerMetricsEntry, error) {
+	result, err := c.call("bulk-metrics", true, func() (interface{}, error) {
+		return c.gardenClient.BulkMetrics(handles)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	metrics, _ := result.(map[string]garden.ContainerMetricsEntry)
+	return metrics, nil
+}
+
+func (c *client) Lookup(handle string) (garden.Container, error) {
+	result, err := c.call("lookup", true, func() (interface{}, error) {
+		return c.gardenClient.Lookup(handle)
+	}, nil)
+	if err != nil {
+		return nil, err
+	}
+	container, _ := result.(garden.Container)
+	return container, nil
+}
+
+// destroyAbandoned destroys a container whose create returned after the call
+// had timed out, as nothing else knows about it.
+func (c *client) destroyAbandoned(logger lager.Logger, result interface{}) {
+	container, ok := result.(garden.Container)
+	if !ok || container == nil {
+		return
+	}
+
+	logger = logger.WithData(lager.Data{"handle": container.Handle()})
+	logger.Info("destroying-abandoned-container")
+	err := c.gardenClient.Destroy(container.Handle())
+	if err != nil {
+		logger.Error("failed-to-destroy-abandoned-container", err)
+	}
+}
+
+// call makes a call to garden under the breaker, retrying it if it is
+// retryable. abandon, if given, is passed the result of any attempt that
+// succeeds after it has timed out.
+func (c *client) call(
+	name string,
+	retryable bool,
+	call func() (interface{}, error),
+	abandon func(lager.Logger, interface{}),
+) (interface{}, error) {
+	logger := c.logger.Session(name)
+
+	if !c.allow() {
+		logger.Error("circuit-open", ErrCircuitOpen)
+		return nil, ErrCircuitOpen
+	}
+
+	attempts := 1
+	if retryable {
+		attempts += c.retries
+	}
+
+	var result interface{}
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		result, err = c.withTimeout(logger, call, abandon)
+		if !isConnectionError(err) {
+			break
+		}
+
+		logger.Error("failed-to-reach-garden", err, lager.Data{"attempt": attempt, "attempts": attempts})
+		if attempt < attempts && c.retryBackoff > 0 {
+			c.clock.Sleep(c.retryBackoff)
+		}
+	}
+
+	c.record(logger, isConnectionError(err))
+	return result, err
+}
+
+type callResult struct {
+	value interface{}
+	err   error
+}
+
+// withTimeout gives each attempt its own result, so an attempt that returns
+// after timing out cannot overwrite the result of a later one.
+func (c *client) withTimeout(
+	logger lager.Logger,
+	call func() (interface{}, error),
+	abandon func(lager.Logger, interface{}),
+) (interface{}, error) {
+	if c.callTimeout <= 0 {
+		return call()
+	}
+
+	results := make(chan callResult, 1)
+	go func() {
+		value, err := call()
+		results <- callResult{value, err}
+	}()
+
+	timer := c.clock.NewTimer(c.callTimeout)
+	defer timer.Stop()
+
+	select {
+	case r := <-results:
+		return r.value, r.err
+	case <-timer.C():
+		if abandon != nil {
+			go func() {
+				r := <-results
+				if r.err == nil {
+					abandon(logger, r.value)
+				}
+			}()
+		}
+		return nil, ErrCallTimeout
+	}
+}
+
+func (c *client) allow() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.breakerOpen {
+		return true
+	}
+	if c.clock.Now().Before(c.openUntil) {
+		return false
+	}
+
+	// let one call through to probe garden, and hold the rest off until it
+	// reports back
+	c.openUntil = c.clock.Now().Add(c.breakerCooldown)
+	return true
+}
+
+func (c *client) record(logger lager.Logger, unreachable bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !unreachable {
+		if c.breakerOpen {
+			logger.Info("circuit-closed")
+		}
+		c.failures = 0
+		c.breakerOpen = false
+		return
+	}
+
+	c.failures++
+	if c.breakerThreshold > 0 && c.failures >= c.breakerThreshold {
+		if !c.breakerOpen {
+			logger.Info("circuit-opened", lager.Data{"failures": c.failures, "cooldown": c.breakerCooldown.String()})
+		}
+		c.breakerOpen = true
+		c.openUntil = c.clock.Now().Add(c.breakerCooldown)
+	}
+}
+
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	if err == ErrCallTimeout || err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	_, ok := err.(net.Error)
+	return ok
+}
@@ -0,0 +1,154 @@
+package gardenclient_test
+
+import (
+	"errors"
+	"net"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/gardenclient"
+	"code.cloudfoundry.org/garden"
+	"code.cloudfoundry.org/garden/gardenfakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		gardenClient    *gardenfakes.FakeClient
+		fakeClock       *fakeclock.FakeClock
+		client          garden.Client
+		connectionError error
+	)
+
+	BeforeEach(func() {
+		gardenClient = &gardenfakes.FakeClient{}
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		connectionError = &net.OpError{Op: "dial", Err: errors.New("connection refused")}
+
+		client = gardenclient.New(lagertest.NewTestLogger("test"), gardenClient, fakeClock, time.Second, 2, 0, 3, time.Minute)
+	})
+
+	It("retries calls that fail to reach garden", func() {
+		gardenClient.LookupStub = func(string) (garden.Container, error) {
+			if gardenClient.LookupCallCount() < 3 {
+				return nil, connectionError
+			}
+			return &gardenfakes.FakeContainer{}, nil
+		}
+
+		_, err := client.Lookup("some-handle")
+		Expect(err).NotTo(HaveOccurred())
+		Expect(gardenClient.LookupCallCount()).To(Equal(3))
+	})
+
+	It("times out calls that garden does not answer", func() {
+		release := make(chan struct{})
+		defer close(release)
+		gardenClient.CapacityStub = func() (garden.Capacity, error) {
+			<-release
+			return garden.Capacity{}, nil
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := client.Capacity()
+			errs <- err
+		}()
+
+		for i := 0; i < 3; i++ {
+			fakeClock.WaitForWatcherAndIncrement(time.Second)
+		}
+
+		Eventually(errs).Should(Receive(Equal(gardenclient.ErrCallTimeout)))
+		Expect(gardenClient.CapacityCallCount()).To(Equal(3))
+	})
+
+	It("returns the result of the attempt that answered rather than a late one", func() {
+		release := make(chan struct{})
+		gardenClient.CapacityStub = func() (garden.Capacity, error) {
+			if gardenClient.CapacityCallCount() == 1 {
+				<-release
+				return garden.Capacity{MaxContainers: 1}, nil
+			}
+			return garden.Capacity{MaxContainers: 2}, nil
+		}
+
+		capacities := make(chan garden.Capacity, 1)
+		go func() {
+			capacity, _ := client.Capacity()
+			capacities <- capacity
+		}()
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+
+		var capacity garden.Capacity
+		Eventually(capacities).Should(Receive(&capacity))
+		close(release)
+		Expect(capacity.MaxContainers).To(Equal(uint64(2)))
+	})
+
+	It("destroys a container whose create returns after timing out", func() {
+		release := make(chan struct{})
+		lateContainer := &gardenfakes.FakeContainer{}
+		lateContainer.HandleReturns("late-handle")
+		gardenClient.CreateStub = func(garden.ContainerSpec) (garden.Container, error) {
+			<-release
+			return lateContainer, nil
+		}
+
+		errs := make(chan error, 1)
+		go func() {
+			_, err := client.Create(garden.ContainerSpec{})
+			errs <- err
+		}()
+
+		fakeClock.WaitForWatcherAndIncrement(time.Second)
+		Eventually(errs).Should(Receive(Equal(gardenclient.ErrCallTimeout)))
+		Expect(gardenClient.DestroyCallCount()).To(Equal(0))
+
+		close(release)
+		Eventually(gardenClient.DestroyCallCount).Should(Equal(1))
+		Expect(gardenClient.DestroyArgsForCall(0)).To(Equal("late-handle"))
+	})
+
+	It("does not retry errors returned by garden", func() {
+		gardenClient.LookupReturns(nil, garden.ContainerNotFoundError{Handle: "some-handle"})
+
+		_, err := client.Lookup("some-handle")
+		Expect(err).To(Equal(garden.ContainerNotFoundError{Handle: "some-handle"}))
+		Expect(gardenClient.LookupCallCount()).To(Equal(1))
+	})
+
+	It("does not retry creates", func() {
+		gardenClient.CreateReturns(nil, connectionError)
+
+		_, err := client.Create(garden.ContainerSpec{})
+		Expect(err).To(Equal(connectionError))
+		Expect(gardenClient.CreateCallCount()).To(Equal(1))
+	})
+
+	Context("when calls keep failing to reach garden", func() {
+		BeforeEach(func() {
+			gardenClient.PingReturns(connectionError)
+			gardenClient.DestroyReturns(connectionError)
+			for i := 0; i < 3; i++ {
+				client.Destroy("some-handle")
+			}
+		})
+
+		It("opens the breaker until the cooldown has passed", func() {
+			Expect(client.Ping()).To(Equal(gardenclient.ErrCircuitOpen))
+			Expect(gardenClient.PingCallCount()).To(Equal(0))
+
+			fakeClock.Increment(time.Minute)
+			gardenClient.PingReturns(nil)
+
+			Expect(client.Ping()).To(Succeed())
+			Expect(client.Ping()).To(Succeed())
+			Expect(gardenClient.PingCallCount()).To(Equal(2))
+		})
+	})
+})
@@ -0,0 +1,13 @@
+package gardenclient_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"testing"
+)
+
+func TestGardenClient(t *testing.T) {
+	RegisterFailHandler(Fail)
+	RunSpecs(t, "GardenClient Suite")
+}
@@ -0,0 +1 @@
+package gardenclient // import "code.cloudfoundry.org/executor/gardenclient"
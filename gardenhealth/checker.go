@@ -1,7 +1,10 @@
 package gardenhealth
 
 import (
+	"archive/tar"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"time"
 
 	"code.cloudfoundry.org/executor"
@@ -42,6 +45,7 @@ type checker struct {
 	containerOwnerName string
 	retryInterval      time.Duration
 	healthcheckSpec    garden.ProcessSpec
+	streamOutPath      string
 	executorClient     executor.Client
 	gardenClient       garden.Client
 	guidGenerator      guidgen.Generator
@@ -51,12 +55,15 @@ type checker struct {
 //
 // healthcheckSpec describes the process to run in the healthcheck container and
 // retryInterval describes the amount of time to wait to sleep when retrying a
-// failed garden command.
+// failed garden command. When streamOutPath is set, that path is also streamed
+// out of the container after the process exits, to check the file transfer
+// path.
 func NewChecker(
 	rootFSPath string,
 	containerOwnerName string,
 	retryInterval time.Duration,
 	healthcheckSpec garden.ProcessSpec,
+	streamOutPath string,
 	gardenClient garden.Client,
 	guidGenerator guidgen.Generator,
 ) Checker {
@@ -65,6 +72,7 @@ func NewChecker(
 		containerOwnerName: containerOwnerName,
 		retryInterval:      retryInterval,
 		healthcheckSpec:    healthcheckSpec,
+		streamOutPath:      streamOutPath,
 		gardenClient:       gardenClient,
 		guidGenerator:      guidGenerator,
 	}
@@ -240,9 +248,43 @@ func (c *checker) wait(logger lager.Logger, proc garden.Process) (int, error) {
 	return exitCode, err
 }
 
+func (c *checker) streamOut(logger lager.Logger, container garden.Container) error {
+	logger = logger.Session("stream-out", lager.Data{"path": c.streamOutPath})
+	logger.Debug("starting")
+	defer logger.Debug("finished")
+
+	return retryOnFail(c.retryInterval, func(attempt uint) error {
+		err := c.readStream(container)
+		if err != nil {
+			logger.Error("failed", err, lager.Data{"attempt": attempt})
+			return err
+		}
+
+		logger.Debug("succeeded", lager.Data{"attempt": attempt})
+		return nil
+	})
+}
+
+func (c *checker) readStream(container garden.Container) error {
+	stream, err := container.StreamOut(garden.StreamOutSpec{Path: c.streamOutPath, User: c.healthcheckSpec.User})
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	tarReader := tar.NewReader(stream)
+	_, err = tarReader.Next()
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(ioutil.Discard, tarReader)
+	return err
+}
+
 // Healthcheck destroys any existing healthcheck containers, creates a new container,
-// runs a process in the new container, waits for the process to exit, then destroys
-// the created container.
+// runs a process in the new container, waits for the process to exit, optionally
+// streams a file out of the container, then destroys the created container.
 //
 // If any of these steps fail, the failed step will be retried
 // up to gardenhealth.MaxRetries times. If the command continues to fail after the
@@ -296,6 +338,13 @@ func (c *checker) Healthcheck(logger lager.Logger) (healthcheckResult error) {
 		return HealthcheckFailedError(exitCode)
 	}
 
+	if c.streamOutPath != "" {
+		err = c.streamOut(logger, container)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -1,7 +1,10 @@
 package gardenhealth_test
 
 import (
+	"archive/tar"
+	"bytes"
 	"errors"
+	"io/ioutil"
 
 	"code.cloudfoundry.org/executor/depot/containerstore"
 	"code.cloudfoundry.org/executor/gardenhealth"
@@ -25,6 +28,7 @@ var _ = Describe("Checker", func() {
 		gardenChecker   gardenhealth.Checker
 		gardenClient    *gardenfakes.FakeClient
 		healthcheckSpec garden.ProcessSpec
+		streamOutPath   string
 		logger          *lagertest.TestLogger
 	)
 
@@ -34,11 +38,15 @@ var _ = Describe("Checker", func() {
 			Args: []string{"-c", "echo", "hello"},
 			User: "vcap",
 		}
+		streamOutPath = ""
 		logger = lagertest.NewTestLogger("test")
 		gardenClient = &gardenfakes.FakeClient{}
+	})
+
+	JustBeforeEach(func() {
 		guidGenerator := &fakeguidgen.FakeGenerator{}
 		guidGenerator.GuidReturns("abc-123")
-		gardenChecker = gardenhealth.NewChecker(rootfsPath, containerOwnerName, 0, healthcheckSpec, gardenClient, guidGenerator)
+		gardenChecker = gardenhealth.NewChecker(rootfsPath, containerOwnerName, 0, healthcheckSpec, streamOutPath, gardenClient, guidGenerator)
 	})
 
 	Describe("Healthcheck", func() {
@@ -215,6 +223,44 @@ var _ = Describe("Checker", func() {
 			})
 		})
 
+		Context("when a path to stream out is configured", func() {
+			BeforeEach(func() {
+				streamOutPath = "/etc/hostname"
+				gardenClient.CreateReturns(fakeContainer, nil)
+				fakeContainer.RunReturns(fakeProcess, nil)
+				fakeProcess.WaitReturns(0, nil)
+			})
+
+			It("streams the path out of the container", func() {
+				buffer := &bytes.Buffer{}
+				tarWriter := tar.NewWriter(buffer)
+				Expect(tarWriter.WriteHeader(&tar.Header{Name: "hostname", Size: 5, Mode: 0644})).To(Succeed())
+				_, err := tarWriter.Write([]byte("hello"))
+				Expect(err).NotTo(HaveOccurred())
+				Expect(tarWriter.Close()).To(Succeed())
+				fakeContainer.StreamOutReturns(ioutil.NopCloser(buffer), nil)
+
+				Expect(gardenChecker.Healthcheck(logger)).To(Succeed())
+
+				Expect(fakeContainer.StreamOutCallCount()).To(Equal(1))
+				Expect(fakeContainer.StreamOutArgsForCall(0)).To(Equal(garden.StreamOutSpec{Path: "/etc/hostname", User: "vcap"}))
+			})
+
+			Context("when streaming out fails", func() {
+				var streamErr = errors.New("stream failed")
+
+				BeforeEach(func() {
+					fakeContainer.StreamOutReturns(nil, streamErr)
+				})
+
+				It("retries and returns the error", func() {
+					err := gardenChecker.Healthcheck(logger)
+					Expect(fakeContainer.StreamOutCallCount()).To(Equal(retryCount))
+					Expect(err).To(Equal(streamErr))
+				})
+			})
+		})
+
 		Context("when destroying fails", func() {
 			var destroyErr = garden.ContainerNotFoundError{}
 
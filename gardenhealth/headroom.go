@@ -0,0 +1,74 @@
+package gardenhealth
+
+import (
+	"fmt"
+	"syscall"
+
+	"code.cloudfoundry.org/lager"
+)
+
+type InsufficientHeadroomError struct {
+	Path     string
+	Resource string
+	Free     uint64
+	Minimum  uint64
+}
+
+func (e InsufficientHeadroomError) Error() string {
+	return fmt.Sprintf("only %d %s free on %s, need at least %d", e.Free, e.Resource, e.Path, e.Minimum)
+}
+
+type headroomChecker struct {
+	Checker
+	path          string
+	minFreeBytes  uint64
+	minFreeInodes uint64
+}
+
+// NewHeadroomChecker wraps checker so each healthcheck first verifies that the
+// filesystem holding path has at least minFreeBytes of disk and minFreeInodes
+// inodes available. A minimum of zero skips that check. When there is not
+// enough headroom the check fails with an InsufficientHeadroomError without
+// running checker.
+func NewHeadroomChecker(checker Checker, path string, minFreeBytes, minFreeInodes uint64) Checker {
+	return &headroomChecker{
+		Checker:       checker,
+		path:          path,
+		minFreeBytes:  minFreeBytes,
+		minFreeInodes: minFreeInodes,
+	}
+}
+
+func (c *headroomChecker) Healthcheck(logger lager.Logger) error {
+	err := c.checkHeadroom(logger.Session("check-headroom", lager.Data{"path": c.path}))
+	if err != nil {
+		return err
+	}
+
+	return c.Checker.Healthcheck(logger)
+}
+
+func (c *headroomChecker) checkHeadroom(logger lager.Logger) error {
+	var stat syscall.Statfs_t
+	err := syscall.Statfs(c.path, &stat)
+	if err != nil {
+		logger.Error("failed-to-stat-filesystem", err)
+		return err
+	}
+
+	freeBytes := uint64(stat.Bavail) * uint64(stat.Bsize)
+	if freeBytes < c.minFreeBytes {
+		err = InsufficientHeadroomError{Path: c.path, Resource: "bytes", Free: freeBytes, Minimum: c.minFreeBytes}
+		logger.Error("insufficient-disk", err)
+		return err
+	}
+
+	freeInodes := uint64(stat.Ffree)
+	if freeInodes < c.minFreeInodes {
+		err = InsufficientHeadroomError{Path: c.path, Resource: "inodes", Free: freeInodes, Minimum: c.minFreeInodes}
+		logger.Error("insufficient-inodes", err)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package gardenhealth_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"math"
+	"os"
+
+	"code.cloudfoundry.org/executor/gardenhealth"
+	"code.cloudfoundry.org/executor/gardenhealth/fakegardenhealth"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("HeadroomChecker", func() {
+	var (
+		path                        string
+		minFreeBytes, minFreeInodes uint64
+		innerChecker                *fakegardenhealth.FakeChecker
+		logger                      *lagertest.TestLogger
+		err                         error
+	)
+
+	BeforeEach(func() {
+		path, err = ioutil.TempDir("", "headroom")
+		Expect(err).NotTo(HaveOccurred())
+
+		minFreeBytes = 0
+		minFreeInodes = 0
+		innerChecker = &fakegardenhealth.FakeChecker{}
+		logger = lagertest.NewTestLogger("test")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(path)
+	})
+
+	JustBeforeEach(func() {
+		err = gardenhealth.NewHeadroomChecker(innerChecker, path, minFreeBytes, minFreeInodes).Healthcheck(logger)
+	})
+
+	Context("when the filesystem has enough headroom", func() {
+		BeforeEach(func() {
+			minFreeBytes = 1
+			innerChecker.HealthcheckReturns(errors.New("boom"))
+		})
+
+		It("runs the wrapped check", func() {
+			Expect(err).To(MatchError("boom"))
+			Expect(innerChecker.HealthcheckCallCount()).To(Equal(1))
+		})
+	})
+
+	Context("when the filesystem is short of disk", func() {
+		BeforeEach(func() {
+			minFreeBytes = math.MaxUint64
+		})
+
+		It("fails without running the wrapped check", func() {
+			Expect(err).To(BeAssignableToTypeOf(gardenhealth.InsufficientHeadroomError{}))
+			Expect(err.(gardenhealth.InsufficientHeadroomError).Resource).To(Equal("bytes"))
+			Expect(innerChecker.HealthcheckCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the filesystem is short of inodes", func() {
+		BeforeEach(func() {
+			minFreeInodes = math.MaxUint64
+		})
+
+		It("fails without running the wrapped check", func() {
+			Expect(err).To(BeAssignableToTypeOf(gardenhealth.InsufficientHeadroomError{}))
+			Expect(err.(gardenhealth.InsufficientHeadroomError).Resource).To(Equal("inodes"))
+			Expect(innerChecker.HealthcheckCallCount()).To(Equal(0))
+		})
+	})
+
+	Context("when the path cannot be statted", func() {
+		BeforeEach(func() {
+			os.RemoveAll(path)
+		})
+
+		It("fails without running the wrapped check", func() {
+			Expect(err).To(HaveOccurred())
+			Expect(innerChecker.HealthcheckCallCount()).To(Equal(0))
+		})
+	})
+})
@@ -6,6 +6,7 @@ import (
 
 	"code.cloudfoundry.org/clock"
 	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/depot/event"
 	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
 	"code.cloudfoundry.org/lager"
 )
@@ -18,13 +19,19 @@ func (HealthcheckTimeoutError) Error() string {
 	return "garden healthcheck timed out"
 }
 
-// Runner coordinates health checks against an executor client.  When checks fail or
-// time out, its executor will be marked as unhealthy until a successful check occurs.
+// Runner coordinates health checks against an executor client.  When failureThreshold
+// checks in a row fail or time out, its executor will be marked as unhealthy until
+// successThreshold checks in a row succeed. Each change of health is emitted as a
+// CellHealthyEvent or CellUnhealthyEvent.
 //
 // See NewRunner and Runner.Run for more details.
 type Runner struct {
 	failures         int
+	successes        int
 	healthy          bool
+	reported         bool
+	failureThreshold int
+	successThreshold int
 	checkInterval    time.Duration
 	emissionInterval time.Duration
 	timeoutInterval  time.Duration
@@ -32,6 +39,7 @@ type Runner struct {
 	checker          Checker
 	executorClient   executor.Client
 	metronClient     loggregator_v2.Client
+	eventEmitter     event.Hub
 	clock            clock.Clock
 }
 
@@ -39,25 +47,40 @@ type Runner struct {
 //
 // The checkInterval parameter controls how often the healthcheck should run, and
 // the timeoutInterval sets the time to wait for the healthcheck to complete before
-// marking the executor as unhealthy.
+// counting it as failed. The failureThreshold and successThreshold set how many
+// checks in a row must fail or succeed before the executor's health changes;
+// values below 1 are treated as 1.
 func NewRunner(
 	checkInterval time.Duration,
 	emissionInterval time.Duration,
 	timeoutInterval time.Duration,
+	failureThreshold int,
+	successThreshold int,
 	logger lager.Logger,
 	checker Checker,
 	executorClient executor.Client,
 	metronClient loggregator_v2.Client,
+	eventEmitter event.Hub,
 	clock clock.Clock,
 ) *Runner {
+	if failureThreshold < 1 {
+		failureThreshold = 1
+	}
+	if successThreshold < 1 {
+		successThreshold = 1
+	}
+
 	return &Runner{
 		checkInterval:    checkInterval,
 		emissionInterval: emissionInterval,
 		timeoutInterval:  timeoutInterval,
+		failureThreshold: failureThreshold,
+		successThreshold: successThreshold,
 		logger:           logger.Session("garden-healthcheck"),
 		checker:          checker,
 		executorClient:   executorClient,
 		metronClient:     metronClient,
+		eventEmitter:     eventEmitter,
 		clock:            clock,
 		healthy:          false,
 		failures:         0,
@@ -78,6 +101,7 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 	logger.Info("starting")
 
+	startedAt := r.clock.Now()
 	go r.healthcheckCycle(logger, healthcheckComplete)
 
 	select {
@@ -85,13 +109,15 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 		return nil
 
 	case <-healthcheckTimeout.C():
-		r.setUnhealthy(logger)
+		r.recordResult(logger, startedAt, HealthcheckTimeoutError{})
+		r.setUnhealthy(logger, HealthcheckTimeoutError{})
 		r.checker.Cancel(logger)
 		return HealthcheckTimeoutError{}
 
 	case err := <-healthcheckComplete:
+		r.recordResult(logger, startedAt, err)
 		if err != nil {
-			r.setUnhealthy(logger)
+			r.setUnhealthy(logger, err)
 			return err
 		}
 		healthcheckTimeout.Stop()
@@ -114,10 +140,12 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 		case <-startHealthcheck.C():
 			healthcheckTimeout.Reset(r.timeoutInterval)
+			startedAt = r.clock.Now()
 			go r.healthcheckCycle(logger, healthcheckComplete)
 
 		case <-healthcheckTimeout.C():
-			r.setUnhealthy(logger)
+			r.recordResult(logger, startedAt, HealthcheckTimeoutError{})
+			r.recordFailure(logger, HealthcheckTimeoutError{})
 			r.checker.Cancel(logger)
 
 		case <-emitInterval.C():
@@ -125,14 +153,18 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 
 		case err := <-healthcheckComplete:
 			timeoutOk := healthcheckTimeout.Stop()
+			if timeoutOk {
+				r.recordResult(logger, startedAt, err)
+			}
+
 			switch err.(type) {
 			case nil:
 				if timeoutOk {
-					r.setHealthy(logger)
+					r.recordSuccess(logger)
 				}
 
 			default:
-				r.setUnhealthy(logger)
+				r.recordFailure(logger, err)
 			}
 
 			startHealthcheck.Reset(r.checkInterval)
@@ -140,16 +172,65 @@ func (r *Runner) Run(signals <-chan os.Signal, ready chan<- struct{}) error {
 	}
 }
 
+// recordResult hands the outcome of a check to the executor so operators can
+// see why its health changed.
+func (r *Runner) recordResult(logger lager.Logger, startedAt time.Time, err error) {
+	result := executor.HealthcheckResult{
+		CheckedAt: startedAt.UnixNano(),
+		Duration:  r.clock.Since(startedAt),
+	}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	r.executorClient.RecordHealthcheck(logger, result)
+}
+
+// recordSuccess marks the executor healthy once enough checks in a row have
+// passed, or straight away if it is already healthy.
+func (r *Runner) recordSuccess(logger lager.Logger) {
+	r.failures = 0
+	r.successes++
+	if r.healthy || r.successes >= r.successThreshold {
+		r.setHealthy(logger)
+		return
+	}
+	logger.Info("waiting-for-more-successful-checks", lager.Data{"successes": r.successes, "threshold": r.successThreshold})
+}
+
+// recordFailure marks the executor unhealthy once enough checks in a row have
+// failed.
+func (r *Runner) recordFailure(logger lager.Logger, err error) {
+	r.successes = 0
+	r.failures++
+	if r.failures >= r.failureThreshold {
+		r.setUnhealthy(logger, err)
+		return
+	}
+	logger.Info("tolerating-failed-check", lager.Data{"failures": r.failures, "threshold": r.failureThreshold})
+}
+
 func (r *Runner) setHealthy(logger lager.Logger) {
+	changed := !r.reported || !r.healthy
+	r.healthy = true
+	r.reported = true
 	r.logger.Info("set-state-healthy")
 	r.executorClient.SetHealthy(logger, true)
 	r.emitUnhealthyCellMetric(logger)
+	if changed {
+		r.eventEmitter.Emit(executor.NewCellHealthyEvent())
+	}
 }
 
-func (r *Runner) setUnhealthy(logger lager.Logger) {
-	r.logger.Error("set-state-unhealthy", nil)
+func (r *Runner) setUnhealthy(logger lager.Logger, err error) {
+	changed := !r.reported || r.healthy
+	r.healthy = false
+	r.reported = true
+	r.logger.Error("set-state-unhealthy", err)
 	r.executorClient.SetHealthy(logger, false)
 	r.emitUnhealthyCellMetric(logger)
+	if changed {
+		r.eventEmitter.Emit(executor.NewCellUnhealthyEvent(err.Error()))
+	}
 }
 
 func (r *Runner) emitUnhealthyCellMetric(logger lager.Logger) {
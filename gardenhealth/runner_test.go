@@ -11,6 +11,8 @@ import (
 	"github.com/tedsuo/ifrit/ginkgomon"
 
 	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	eventfakes "code.cloudfoundry.org/executor/depot/event/fakes"
 	fakeexecutor "code.cloudfoundry.org/executor/fakes"
 	"code.cloudfoundry.org/executor/gardenhealth/fakegardenhealth"
 	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
@@ -30,8 +32,11 @@ var _ = Describe("Runner", func() {
 		executorClient                  *fakeexecutor.FakeClient
 		fakeClock                       *fakeclock.FakeClock
 		fakeMetronClient                *mfakes.FakeClient
+		fakeEventHub                    *eventfakes.FakeHub
 		checkInterval, emissionInterval time.Duration
 		timeoutDuration                 time.Duration
+		failureThreshold                int
+		successThreshold                int
 		metricMap                       map[string]float64
 		m                               sync.RWMutex
 	)
@@ -46,8 +51,11 @@ var _ = Describe("Runner", func() {
 		checkInterval = 2 * time.Minute
 		timeoutDuration = 1 * time.Minute
 		emissionInterval = 30 * time.Second
+		failureThreshold = 1
+		successThreshold = 1
 
 		fakeMetronClient = new(mfakes.FakeClient)
+		fakeEventHub = new(eventfakes.FakeHub)
 
 		m = sync.RWMutex{}
 	})
@@ -71,7 +79,7 @@ var _ = Describe("Runner", func() {
 			return nil
 		}
 
-		runner = gardenhealth.NewRunner(checkInterval, emissionInterval, timeoutDuration, logger, checker, executorClient, fakeMetronClient, fakeClock)
+		runner = gardenhealth.NewRunner(checkInterval, emissionInterval, timeoutDuration, failureThreshold, successThreshold, logger, checker, executorClient, fakeMetronClient, fakeEventHub, fakeClock)
 		process = ifrit.Background(runner)
 
 	})
@@ -98,6 +106,14 @@ var _ = Describe("Runner", func() {
 					Eventually(process.Wait()).Should(Receive(Equal(checkErr)))
 					Eventually(getMetrics).Should(HaveKeyWithValue(UnhealthyCell, float64(1)))
 				})
+
+				It("records the failed check with the executor", func() {
+					Eventually(process.Wait()).Should(Receive(Equal(checkErr)))
+					Expect(executorClient.RecordHealthcheckCallCount()).To(Equal(1))
+					_, result := executorClient.RecordHealthcheckArgsForCall(0)
+					Expect(result.CheckedAt).To(Equal(fakeClock.Now().UnixNano()))
+					Expect(result.Error).To(Equal(checkErr.Error()))
+				})
 			})
 
 			Context("because the health check timed out", func() {
@@ -218,6 +234,61 @@ var _ = Describe("Runner", func() {
 				Expect(healthy).Should(Equal(true))
 				Eventually(getMetrics).Should(HaveKeyWithValue(UnhealthyCell, float64(0)))
 			})
+
+			It("emits an event each time the cell's health changes", func() {
+				Eventually(fakeEventHub.EmitCallCount).Should(Equal(1))
+				Expect(fakeEventHub.EmitArgsForCall(0)).To(Equal(executor.NewCellHealthyEvent()))
+
+				Expect(healthyValues).To(BeSent(false))
+				checkValues <- errors.New("boom")
+				fakeClock.WaitForWatcherAndIncrement(checkInterval)
+
+				Eventually(fakeEventHub.EmitCallCount).Should(Equal(2))
+				Expect(fakeEventHub.EmitArgsForCall(1)).To(Equal(executor.NewCellUnhealthyEvent("boom")))
+
+				Expect(healthyValues).To(BeSent(true))
+				checkValues <- nil
+				fakeClock.WaitForNWatchersAndIncrement(checkInterval, 2)
+
+				Eventually(fakeEventHub.EmitCallCount).Should(Equal(3))
+				Expect(fakeEventHub.EmitArgsForCall(2)).To(Equal(executor.NewCellHealthyEvent()))
+			})
+		})
+
+		Context("when garden fails fewer checks in a row than the unhealthy threshold", func() {
+			var checkValues chan error
+
+			BeforeEach(func() {
+				failureThreshold = 2
+				checkValues = make(chan error, 1)
+				executorClient.HealthyReturns(true)
+				checker.HealthcheckStub = func(lager.Logger) error {
+					return <-checkValues
+				}
+
+				checkValues <- nil
+
+				// Set emission interval to a high value so that it doesn't trigger in this test
+				emissionInterval = 100 * time.Minute
+			})
+
+			It("only sets healthy to false once the threshold is reached", func() {
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(1))
+
+				checkValues <- errors.New("boom")
+				fakeClock.WaitForWatcherAndIncrement(checkInterval)
+
+				Eventually(checker.HealthcheckCallCount).Should(Equal(2))
+				Consistently(executorClient.SetHealthyCallCount).Should(Equal(1))
+				Expect(fakeEventHub.EmitCallCount()).To(Equal(1))
+
+				checkValues <- errors.New("boom")
+				fakeClock.WaitForNWatchersAndIncrement(checkInterval, 2)
+
+				Eventually(executorClient.SetHealthyCallCount).Should(Equal(2))
+				_, healthy := executorClient.SetHealthyArgsForCall(1)
+				Expect(healthy).Should(Equal(false))
+			})
 		})
 
 		Context("When the healthcheck times out", func() {
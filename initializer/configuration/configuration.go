@@ -15,6 +15,8 @@ var (
 	ErrMemoryFlagInvalid       = fmt.Errorf("memory limit must be a positive number or '%s'", Automatic)
 	ErrDiskFlagInvalid         = fmt.Errorf("disk limit must be a positive number or '%s'", Automatic)
 	ErrAutoDiskCapacityInvalid = fmt.Errorf("auto disk limit must result in a positive number")
+	ErrOvercommitFactorInvalid = fmt.Errorf("memory overcommit factor must be at least 1")
+	ErrReservedPercentInvalid  = fmt.Errorf("host reserved percentage must be between 0 and 99")
 )
 
 func ConfigureCapacity(
@@ -23,17 +25,37 @@ func ConfigureCapacity(
 	diskMBFlag string,
 	maxCacheSizeInBytes uint64,
 	autoDiskMBOverhead int,
+	memoryOvercommitFactor float64,
+	autoMemoryReservedPercent int,
+	autoDiskReservedPercent int,
 ) (executor.ExecutorResources, error) {
+	if !ValidOvercommitFactor(memoryOvercommitFactor) {
+		return executor.ExecutorResources{}, ErrOvercommitFactorInvalid
+	}
+	if !validPercent(autoMemoryReservedPercent) || !validPercent(autoDiskReservedPercent) {
+		return executor.ExecutorResources{}, ErrReservedPercentInvalid
+	}
+
 	gardenCapacity, err := gardenClient.Capacity()
 	if err != nil {
 		return executor.ExecutorResources{}, err
 	}
 
+	// automatic capacities leave the reserved share of the host to the host
+	gardenCapacity.MemoryInBytes = withoutReserved(gardenCapacity.MemoryInBytes, autoMemoryReservedPercent)
+	gardenCapacity.DiskInBytes = withoutReserved(gardenCapacity.DiskInBytes, autoDiskReservedPercent)
+
 	memory, err := memoryInMB(gardenCapacity, memoryMBFlag)
 	if err != nil {
 		return executor.ExecutorResources{}, err
 	}
 
+	// the overcommit factor lets containers reserve more memory than the
+	// cell has, relying on them not all using their whole limit
+	if memoryOvercommitFactor > 0 {
+		memory = int(float64(memory) * memoryOvercommitFactor)
+	}
+
 	disk, err := diskInMB(gardenCapacity, diskMBFlag, maxCacheSizeInBytes, autoDiskMBOverhead)
 	if err != nil {
 		return executor.ExecutorResources{}, err
@@ -46,6 +68,20 @@ func ConfigureCapacity(
 	}, nil
 }
 
+// ValidOvercommitFactor reports whether factor is unset (0) or at least 1; a
+// factor below 1 would shrink the capacity instead of overcommitting it.
+func ValidOvercommitFactor(factor float64) bool {
+	return factor == 0 || factor >= 1
+}
+
+func validPercent(percent int) bool {
+	return percent >= 0 && percent < 100
+}
+
+func withoutReserved(bytes uint64, reservedPercent int) uint64 {
+	return bytes * uint64(100-reservedPercent) / 100
+}
+
 func memoryInMB(capacity garden.Capacity, memoryMBFlag string) (int, error) {
 	if memoryMBFlag == Automatic {
 		return int(capacity.MemoryInBytes / (1024 * 1024)), nil
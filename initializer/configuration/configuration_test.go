@@ -25,17 +25,23 @@ var _ = Describe("configuration", func() {
 			memLimit, diskLimit string
 			maxCacheSizeInBytes uint64
 			autoDiskMBOverhead  int
+			overcommitFactor    float64
+			memReservedPercent  int
+			diskReservedPercent int
 		)
 
 		BeforeEach(func() {
 			maxCacheSizeInBytes = 0
 			autoDiskMBOverhead = 0
+			overcommitFactor = 0
+			memReservedPercent = 0
+			diskReservedPercent = 0
 			memLimit = ""
 			diskLimit = ""
 		})
 
 		JustBeforeEach(func() {
-			capacity, err = configuration.ConfigureCapacity(gardenClient, memLimit, diskLimit, maxCacheSizeInBytes, autoDiskMBOverhead)
+			capacity, err = configuration.ConfigureCapacity(gardenClient, memLimit, diskLimit, maxCacheSizeInBytes, autoDiskMBOverhead, overcommitFactor, memReservedPercent, diskReservedPercent)
 		})
 
 		Context("when getting the capacity fails", func() {
@@ -75,6 +81,27 @@ var _ = Describe("configuration", func() {
 					It("uses the garden server's memory capacity", func() {
 						Expect(capacity.MemoryMB).To(Equal(3))
 					})
+
+					Context("when a share of the memory is reserved for the host", func() {
+						BeforeEach(func() {
+							memReservedPercent = 50
+						})
+
+						It("leaves the reserved share out of the capacity", func() {
+							Expect(err).NotTo(HaveOccurred())
+							Expect(capacity.MemoryMB).To(Equal(1))
+						})
+					})
+
+					Context("when the reserved share is not a percentage", func() {
+						BeforeEach(func() {
+							memReservedPercent = 100
+						})
+
+						It("returns an error", func() {
+							Expect(err).To(Equal(configuration.ErrReservedPercentInvalid))
+						})
+					})
 				})
 
 				Context("when the memory limit flag is a positive number", func() {
@@ -91,6 +118,38 @@ var _ = Describe("configuration", func() {
 					})
 				})
 
+				Context("when a memory overcommit factor is given", func() {
+					BeforeEach(func() {
+						memLimit = "auto"
+						overcommitFactor = 1.5
+					})
+
+					It("scales the memory capacity by it", func() {
+						Expect(err).NotTo(HaveOccurred())
+						Expect(capacity.MemoryMB).To(Equal(4))
+					})
+				})
+
+				Context("when the memory overcommit factor is negative", func() {
+					BeforeEach(func() {
+						overcommitFactor = -1
+					})
+
+					It("returns an error", func() {
+						Expect(err).To(Equal(configuration.ErrOvercommitFactorInvalid))
+					})
+				})
+
+				Context("when the memory overcommit factor is below 1", func() {
+					BeforeEach(func() {
+						overcommitFactor = 0.5
+					})
+
+					It("returns an error", func() {
+						Expect(err).To(Equal(configuration.ErrOvercommitFactorInvalid))
+					})
+				})
+
 				Context("when the memory limit flag is not a number", func() {
 					BeforeEach(func() {
 						memLimit = "stuff"
@@ -143,6 +202,17 @@ var _ = Describe("configuration", func() {
 						})
 					})
 
+					Context("when a share of the disk is reserved for the host", func() {
+						BeforeEach(func() {
+							diskReservedPercent = 25
+						})
+
+						It("leaves the reserved share out of the capacity", func() {
+							Expect(err).NotTo(HaveOccurred())
+							Expect(capacity.DiskMB).To(Equal(3))
+						})
+					})
+
 					Context("when the auto disk mb overhead property is set", func() {
 						BeforeEach(func() {
 							autoDiskMBOverhead = 1
@@ -0,0 +1,72 @@
+package initializer
+
+import (
+	"errors"
+	"os"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
+	"code.cloudfoundry.org/lager"
+	"github.com/tedsuo/ifrit"
+	"github.com/tedsuo/ifrit/grouper"
+)
+
+var (
+	ErrMetronClientRequired = errors.New("a metron client is required")
+	ErrInvalidConfig        = errors.New("invalid executor configuration")
+)
+
+// Config configures an executor embedded in another program. Start from
+// NewConfig and override the settings that differ; ExecutorConfig is used as
+// given, so zero values are kept rather than replaced with defaults.
+type Config struct {
+	ExecutorConfig
+
+	Logger                  lager.Logger
+	MetronClient            loggregator_v2.Client
+	Clock                   clock.Clock
+	GardenHealthcheckRootFS string
+}
+
+// NewConfig returns a Config whose ExecutorConfig is DefaultConfiguration.
+func NewConfig() Config {
+	return Config{ExecutorConfig: DefaultConfiguration}
+}
+
+// New builds a complete executor from config, returning its client and a
+// single runner that starts all of its members in order and stops them in
+// reverse. Logger defaults to a logger named "executor" that discards its
+// output and Clock to the real clock.
+func New(config Config) (executor.Client, ifrit.Runner, error) {
+	if config.MetronClient == nil {
+		return nil, nil, ErrMetronClientRequired
+	}
+
+	logger := config.Logger
+	if logger == nil {
+		logger = lager.NewLogger("executor")
+	}
+
+	clk := config.Clock
+	if clk == nil {
+		clk = clock.NewClock()
+	}
+
+	if !config.ExecutorConfig.Validate(logger) {
+		return nil, nil, ErrInvalidConfig
+	}
+
+	client, members, err := Initialize(
+		logger,
+		config.ExecutorConfig,
+		config.GardenHealthcheckRootFS,
+		config.MetronClient,
+		clk,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, grouper.NewOrdered(os.Interrupt, members), nil
+}
@@ -10,8 +10,12 @@ import (
 	"fmt"
 	"io/ioutil"
 	"math"
+	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/archiver/compressor"
@@ -23,14 +27,20 @@ import (
 	"code.cloudfoundry.org/executor"
 	"code.cloudfoundry.org/executor/containermetrics"
 	"code.cloudfoundry.org/executor/depot"
+	"code.cloudfoundry.org/executor/depot/callbacks"
 	"code.cloudfoundry.org/executor/depot/containerstore"
+	"code.cloudfoundry.org/executor/depot/downloadcache"
 	"code.cloudfoundry.org/executor/depot/event"
+	"code.cloudfoundry.org/executor/depot/limiter"
 	"code.cloudfoundry.org/executor/depot/metrics"
+	"code.cloudfoundry.org/executor/depot/steps"
 	"code.cloudfoundry.org/executor/depot/transformer"
 	"code.cloudfoundry.org/executor/depot/uploader"
+	"code.cloudfoundry.org/executor/gardenclient"
 	"code.cloudfoundry.org/executor/gardenhealth"
 	"code.cloudfoundry.org/executor/guidgen"
 	"code.cloudfoundry.org/executor/initializer/configuration"
+	"code.cloudfoundry.org/executor/ratelimit"
 	"code.cloudfoundry.org/garden"
 	GardenClient "code.cloudfoundry.org/garden/client"
 	GardenConnection "code.cloudfoundry.org/garden/client/connection"
@@ -42,14 +52,16 @@ import (
 	"github.com/google/shlex"
 	"github.com/tedsuo/ifrit"
 	"github.com/tedsuo/ifrit/grouper"
+	"github.com/tedsuo/ifrit/http_server"
+	"golang.org/x/net/http/httpproxy"
 )
 
 const (
 	PingGardenInterval             = time.Second
 	StalledMetricHeartbeatInterval = 5 * time.Second
 	StalledGardenDuration          = "StalledGardenDuration"
-	maxConcurrentUploads           = 5
 	metricsReportInterval          = 1 * time.Minute
+	completionCallbackTimeout      = 30 * time.Second
 )
 
 type executorContainers struct {
@@ -79,56 +91,112 @@ func (s systemcertsRetriever) SystemCerts() *x509.CertPool {
 }
 
 type ExecutorConfig struct {
-	AutoDiskOverheadMB                 int                   `json:"auto_disk_capacity_overhead_mb"`
-	CachePath                          string                `json:"cache_path,omitempty"`
-	ContainerInodeLimit                uint64                `json:"container_inode_limit,omitempty"`
-	ContainerMaxCpuShares              uint64                `json:"container_max_cpu_shares,omitempty"`
-	ContainerMetricsReportInterval     durationjson.Duration `json:"container_metrics_report_interval,omitempty"`
-	ContainerOwnerName                 string                `json:"container_owner_name,omitempty"`
-	ContainerReapInterval              durationjson.Duration `json:"container_reap_interval,omitempty"`
-	CreateWorkPoolSize                 int                   `json:"create_work_pool_size,omitempty"`
-	DeleteWorkPoolSize                 int                   `json:"delete_work_pool_size,omitempty"`
-	DiskMB                             string                `json:"disk_mb,omitempty"`
-	ExportNetworkEnvVars               bool                  `json:"export_network_env_vars,omitempty"`
-	GardenAddr                         string                `json:"garden_addr,omitempty"`
-	GardenHealthcheckCommandRetryPause durationjson.Duration `json:"garden_healthcheck_command_retry_pause,omitempty"`
-	GardenHealthcheckEmissionInterval  durationjson.Duration `json:"garden_healthcheck_emission_interval,omitempty"`
-	GardenHealthcheckInterval          durationjson.Duration `json:"garden_healthcheck_interval,omitempty"`
-	GardenHealthcheckProcessArgs       []string              `json:"garden_healthcheck_process_args,omitempty"`
-	GardenHealthcheckProcessDir        string                `json:"garden_healthcheck_process_dir"`
-	GardenHealthcheckProcessEnv        []string              `json:"garden_healthcheck_process_env,omitempty"`
-	GardenHealthcheckProcessPath       string                `json:"garden_healthcheck_process_path"`
-	GardenHealthcheckProcessUser       string                `json:"garden_healthcheck_process_user"`
-	GardenHealthcheckTimeout           durationjson.Duration `json:"garden_healthcheck_timeout,omitempty"`
-	GardenNetwork                      string                `json:"garden_network,omitempty"`
-	HealthCheckContainerOwnerName      string                `json:"healthcheck_container_owner_name,omitempty"`
-	HealthCheckWorkPoolSize            int                   `json:"healthcheck_work_pool_size,omitempty"`
-	HealthyMonitoringInterval          durationjson.Duration `json:"healthy_monitoring_interval,omitempty"`
-	InstanceIdentityCAPath             string                `json:"instance_identity_ca_path,omitempty"`
-	InstanceIdentityCredDir            string                `json:"instance_identity_cred_dir,omitempty"`
-	InstanceIdentityPrivateKeyPath     string                `json:"instance_identity_private_key_path,omitempty"`
-	InstanceIdentityValidityPeriod     durationjson.Duration `json:"instance_identity_validity_period,omitempty"`
-	MaxCacheSizeInBytes                uint64                `json:"max_cache_size_in_bytes,omitempty"`
-	MaxConcurrentDownloads             int                   `json:"max_concurrent_downloads,omitempty"`
-	MemoryMB                           string                `json:"memory_mb,omitempty"`
-	MetricsWorkPoolSize                int                   `json:"metrics_work_pool_size,omitempty"`
-	PathToCACertsForDownloads          string                `json:"path_to_ca_certs_for_downloads"`
-	PathToTLSCert                      string                `json:"path_to_tls_cert"`
-	PathToTLSKey                       string                `json:"path_to_tls_key"`
-	PathToTLSCACert                    string                `json:"path_to_tls_ca_cert"`
-	PostSetupHook                      string                `json:"post_setup_hook"`
-	PostSetupUser                      string                `json:"post_setup_user"`
-	ReadWorkPoolSize                   int                   `json:"read_work_pool_size,omitempty"`
-	ReservedExpirationTime             durationjson.Duration `json:"reserved_expiration_time,omitempty"`
-	SkipCertVerify                     bool                  `json:"skip_cert_verify,omitempty"`
-	TempDir                            string                `json:"temp_dir,omitempty"`
-	TrustedSystemCertificatesPath      string                `json:"trusted_system_certificates_path"`
-	UnhealthyMonitoringInterval        durationjson.Duration `json:"unhealthy_monitoring_interval,omitempty"`
-	VolmanDriverPaths                  string                `json:"volman_driver_paths"`
+	AllowAllocationsWhenUnhealthy      bool                           `json:"allow_allocations_when_unhealthy,omitempty"`
+	AuditLogPath                       string                         `json:"audit_log_path,omitempty"`
+	AutoDiskOverheadMB                 int                            `json:"auto_disk_capacity_overhead_mb"`
+	AutoDiskReservedPercent            int                            `json:"auto_disk_capacity_reserved_percent,omitempty"`
+	AutoMemoryReservedPercent          int                            `json:"auto_memory_capacity_reserved_percent,omitempty"`
+	BlobstoreUploaders                 map[string]uploader.Blobstore  `json:"blobstore_uploaders,omitempty"`
+	CachePath                          string                         `json:"cache_path,omitempty"`
+	CgroupMode                         string                         `json:"cgroup_mode,omitempty"`
+	CompletedContainerRetention        durationjson.Duration          `json:"completed_container_retention,omitempty"`
+	CompletionCallbackJournalDir       string                         `json:"completion_callback_journal_dir,omitempty"`
+	CompletionCallbackMaxAttempts      int                            `json:"completion_callback_max_attempts,omitempty"`
+	CompletionCallbackMaxBackoff       durationjson.Duration          `json:"completion_callback_max_backoff,omitempty"`
+	ContainerEnv                       []executor.EnvironmentVariable `json:"container_env,omitempty"`
+	ContainerEnvFiles                  []string                       `json:"container_env_files,omitempty"`
+	ContainerInodeLimit                uint64                         `json:"container_inode_limit,omitempty"`
+	ContainerMaxCpuShares              uint64                         `json:"container_max_cpu_shares,omitempty"`
+	ContainerMetricsReportInterval     durationjson.Duration          `json:"container_metrics_report_interval,omitempty"`
+	ContainerOwnerName                 string                         `json:"container_owner_name,omitempty"`
+	ContainerPlatform                  string                         `json:"container_platform,omitempty"`
+	ContainerReapInterval              durationjson.Duration          `json:"container_reap_interval,omitempty"`
+	CreateWorkPoolSize                 int                            `json:"create_work_pool_size,omitempty"`
+	DefaultActionUser                  string                         `json:"default_action_user,omitempty"`
+	DeleteWorkPoolSize                 int                            `json:"delete_work_pool_size,omitempty"`
+	DisallowPrivilegedContainers       bool                           `json:"disallow_privileged_containers,omitempty"`
+	DiskMB                             string                         `json:"disk_mb,omitempty"`
+	EventJournalMaxBytes               int64                          `json:"event_journal_max_bytes,omitempty"`
+	EventJournalPath                   string                         `json:"event_journal_path,omitempty"`
+	EventStreamBufferSize              int                            `json:"event_stream_buffer_size,omitempty"`
+	EventStreamHeartbeatInterval       durationjson.Duration          `json:"event_stream_heartbeat_interval,omitempty"`
+	EventSubscriberBufferSize          int                            `json:"event_subscriber_buffer_size,omitempty"`
+	EventSubscriberOverflowPolicy      string                         `json:"event_subscriber_overflow_policy,omitempty"`
+	EventsCACertFile                   string                         `json:"events_ca_cert_file,omitempty"`
+	EventsListenAddr                   string                         `json:"events_listen_addr,omitempty"`
+	EventsServerCertFile               string                         `json:"events_server_cert_file,omitempty"`
+	EventsServerKeyFile                string                         `json:"events_server_key_file,omitempty"`
+	ExportNetworkEnvVars               bool                           `json:"export_network_env_vars,omitempty"`
+	GardenAddr                         string                         `json:"garden_addr,omitempty"`
+	GardenCircuitBreakerCooldown       durationjson.Duration          `json:"garden_circuit_breaker_cooldown,omitempty"`
+	GardenCircuitBreakerThreshold      int                            `json:"garden_circuit_breaker_threshold,omitempty"`
+	GardenClientCallTimeout            durationjson.Duration          `json:"garden_client_call_timeout,omitempty"`
+	GardenClientRetries                int                            `json:"garden_client_retries,omitempty"`
+	GardenClientRetryBackoff           durationjson.Duration          `json:"garden_client_retry_backoff,omitempty"`
+	GardenHealthcheckCommandRetryPause durationjson.Duration          `json:"garden_healthcheck_command_retry_pause,omitempty"`
+	GardenHealthcheckEmissionInterval  durationjson.Duration          `json:"garden_healthcheck_emission_interval,omitempty"`
+	GardenHealthcheckFailureThreshold  int                            `json:"garden_healthcheck_failure_threshold,omitempty"`
+	GardenHealthcheckHeadroomPath      string                         `json:"garden_healthcheck_headroom_path,omitempty"`
+	GardenHealthcheckInterval          durationjson.Duration          `json:"garden_healthcheck_interval,omitempty"`
+	GardenHealthcheckMinFreeDiskMB     uint64                         `json:"garden_healthcheck_min_free_disk_mb,omitempty"`
+	GardenHealthcheckMinFreeInodes     uint64                         `json:"garden_healthcheck_min_free_inodes,omitempty"`
+	GardenHealthcheckProcessArgs       []string                       `json:"garden_healthcheck_process_args,omitempty"`
+	GardenHealthcheckProcessDir        string                         `json:"garden_healthcheck_process_dir"`
+	GardenHealthcheckProcessEnv        []string                       `json:"garden_healthcheck_process_env,omitempty"`
+	GardenHealthcheckProcessPath       string                         `json:"garden_healthcheck_process_path"`
+	GardenHealthcheckProcessUser       string                         `json:"garden_healthcheck_process_user"`
+	GardenHealthcheckStreamOutPath     string                         `json:"garden_healthcheck_stream_out_path,omitempty"`
+	GardenHealthcheckSuccessThreshold  int                            `json:"garden_healthcheck_success_threshold,omitempty"`
+	GardenHealthcheckTimeout           durationjson.Duration          `json:"garden_healthcheck_timeout,omitempty"`
+	GardenNetwork                      string                         `json:"garden_network,omitempty"`
+	GetFilesDeadline                   durationjson.Duration          `json:"get_files_deadline,omitempty"`
+	GetFilesIdleTimeout                durationjson.Duration          `json:"get_files_idle_timeout,omitempty"`
+	GetFilesMaxBytes                   int64                          `json:"get_files_max_bytes,omitempty"`
+	HealthCheckContainerOwnerName      string                         `json:"healthcheck_container_owner_name,omitempty"`
+	HealthCheckWorkPoolSize            int                            `json:"healthcheck_work_pool_size,omitempty"`
+	HealthyMonitoringInterval          durationjson.Duration          `json:"healthy_monitoring_interval,omitempty"`
+	HelperAssetsContainerPath          string                         `json:"helper_assets_container_path,omitempty"`
+	HelperAssetsDirs                   map[string]string              `json:"helper_assets_dirs,omitempty"`
+	HTTPClientRateLimit                ratelimit.Limit                `json:"http_client_rate_limit,omitempty"`
+	HTTPProxy                          string                         `json:"http_proxy,omitempty"`
+	HTTPRouteRateLimits                map[string]ratelimit.Limit     `json:"http_route_rate_limits,omitempty"`
+	HTTPSProxy                         string                         `json:"https_proxy,omitempty"`
+	InstanceIdentityCAPath             string                         `json:"instance_identity_ca_path,omitempty"`
+	InstanceIdentityCredDir            string                         `json:"instance_identity_cred_dir,omitempty"`
+	InstanceIdentityPrivateKeyPath     string                         `json:"instance_identity_private_key_path,omitempty"`
+	InstanceIdentityValidityPeriod     durationjson.Duration          `json:"instance_identity_validity_period,omitempty"`
+	MaxCacheSizeInBytes                uint64                         `json:"max_cache_size_in_bytes,omitempty"`
+	MaxConcurrentDownloads             int                            `json:"max_concurrent_downloads,omitempty"`
+	MaxConcurrentUploads               int                            `json:"max_concurrent_uploads,omitempty"`
+	MemoryMB                           string                         `json:"memory_mb,omitempty"`
+	MemoryOvercommitFactor             float64                        `json:"memory_overcommit_factor,omitempty"`
+	MetricsWorkPoolSize                int                            `json:"metrics_work_pool_size,omitempty"`
+	NoProxy                            string                         `json:"no_proxy,omitempty"`
+	OrphanedContainerPolicy            string                         `json:"orphaned_container_policy,omitempty"`
+	PathToCACertsForDownloads          string                         `json:"path_to_ca_certs_for_downloads"`
+	PathToTLSCert                      string                         `json:"path_to_tls_cert"`
+	PathToTLSKey                       string                         `json:"path_to_tls_key"`
+	PathToTLSCACert                    string                         `json:"path_to_tls_ca_cert"`
+	MaxContainerInodeLimit             uint64                         `json:"max_container_inode_limit,omitempty"`
+	MaxProcessNofile                   uint64                         `json:"max_process_nofile,omitempty"`
+	MaxProcessNproc                    uint64                         `json:"max_process_nproc,omitempty"`
+	PostSetupHook                      string                         `json:"post_setup_hook"`
+	PostSetupUser                      string                         `json:"post_setup_user"`
+	PrometheusListenAddr               string                         `json:"prometheus_listen_addr,omitempty"`
+	ReadWorkPoolSize                   int                            `json:"read_work_pool_size,omitempty"`
+	ReservedExpirationTime             durationjson.Duration          `json:"reserved_expiration_time,omitempty"`
+	SkipCertVerify                     bool                           `json:"skip_cert_verify,omitempty"`
+	StepRegistry                       *transformer.Registry          `json:"-"`
+	StopGracePeriod                    durationjson.Duration          `json:"stop_grace_period,omitempty"`
+	TempDir                            string                         `json:"temp_dir,omitempty"`
+	TrustedSystemCertificatesPath      string                         `json:"trusted_system_certificates_path"`
+	UnhealthyMonitoringInterval        durationjson.Duration          `json:"unhealthy_monitoring_interval,omitempty"`
+	VolmanDriverPaths                  string                         `json:"volman_driver_paths"`
 }
 
 const (
 	defaultMaxConcurrentDownloads  = 5
+	defaultMaxConcurrentUploads    = 5
 	defaultCreateWorkPoolSize      = 32
 	defaultDeleteWorkPoolSize      = 32
 	defaultReadWorkPoolSize        = 64
@@ -144,15 +212,27 @@ var DefaultConfiguration = ExecutorConfig{
 	TempDir:                            "/tmp",
 	ReservedExpirationTime:             durationjson.Duration(time.Minute),
 	ContainerReapInterval:              durationjson.Duration(time.Minute),
+	StopGracePeriod:                    durationjson.Duration(time.Minute),
 	ContainerInodeLimit:                200000,
 	ContainerMaxCpuShares:              0,
 	CachePath:                          "/tmp/cache",
+	CgroupMode:                         string(containerstore.CgroupModeAuto),
+	OrphanedContainerPolicy:            string(containerstore.OrphanPolicyDestroy),
+	CompletionCallbackMaxAttempts:      10,
+	CompletionCallbackMaxBackoff:       durationjson.Duration(time.Minute),
 	MaxCacheSizeInBytes:                10 * 1024 * 1024 * 1024,
 	SkipCertVerify:                     false,
 	HealthyMonitoringInterval:          durationjson.Duration(30 * time.Second),
 	UnhealthyMonitoringInterval:        durationjson.Duration(500 * time.Millisecond),
+	EventJournalMaxBytes:               64 * 1024 * 1024,
+	EventStreamBufferSize:              1024,
+	EventStreamHeartbeatInterval:       durationjson.Duration(15 * time.Second),
+	EventSubscriberBufferSize:          event.SUBSCRIBER_BUFFER,
+	EventSubscriberOverflowPolicy:      string(event.OverflowDisconnect),
 	ExportNetworkEnvVars:               false,
 	ContainerOwnerName:                 "executor",
+	ContainerPlatform:                  string(steps.PlatformLinux),
+	HelperAssetsContainerPath:          "/etc/cf-assets/helpers",
 	HealthCheckContainerOwnerName:      "executor-health-check",
 	CreateWorkPoolSize:                 defaultCreateWorkPoolSize,
 	DeleteWorkPoolSize:                 defaultDeleteWorkPoolSize,
@@ -160,13 +240,21 @@ var DefaultConfiguration = ExecutorConfig{
 	MetricsWorkPoolSize:                defaultMetricsWorkPoolSize,
 	HealthCheckWorkPoolSize:            defaultHealthCheckWorkPoolSize,
 	MaxConcurrentDownloads:             defaultMaxConcurrentDownloads,
+	MaxConcurrentUploads:               defaultMaxConcurrentUploads,
 	GardenHealthcheckInterval:          durationjson.Duration(10 * time.Minute),
 	GardenHealthcheckEmissionInterval:  durationjson.Duration(30 * time.Second),
 	GardenHealthcheckTimeout:           durationjson.Duration(10 * time.Minute),
 	GardenHealthcheckCommandRetryPause: durationjson.Duration(time.Second),
+	GardenHealthcheckFailureThreshold:  1,
+	GardenHealthcheckSuccessThreshold:  1,
 	GardenHealthcheckProcessArgs:       []string{},
 	GardenHealthcheckProcessEnv:        []string{},
 	ContainerMetricsReportInterval:     durationjson.Duration(15 * time.Second),
+	GardenClientCallTimeout:            durationjson.Duration(10 * time.Minute),
+	GardenClientRetries:                2,
+	GardenClientRetryBackoff:           durationjson.Duration(100 * time.Millisecond),
+	GardenCircuitBreakerThreshold:      10,
+	GardenCircuitBreakerCooldown:       durationjson.Duration(10 * time.Second),
 }
 
 func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRootFS string, metronClient loggregator_v2.Client, clock clock.Clock) (executor.Client, grouper.Members, error) {
@@ -189,6 +277,17 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 
 	destroyContainers(gardenClient, containersFetcher, logger)
 
+	guardedGardenClient := gardenclient.New(
+		logger,
+		gardenClient,
+		clock,
+		time.Duration(config.GardenClientCallTimeout),
+		config.GardenClientRetries,
+		time.Duration(config.GardenClientRetryBackoff),
+		config.GardenCircuitBreakerThreshold,
+		time.Duration(config.GardenCircuitBreakerCooldown),
+	)
+
 	workDir := setupWorkDir(logger, config.TempDir)
 
 	healthCheckWorkPool, err := workpool.NewWorkPool(config.HealthCheckWorkPoolSize)
@@ -202,8 +301,20 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 		return nil, grouper.Members{}, err
 	}
 
+	proxy := ProxyFromConfig(config)
+	if config.HTTPProxy != "" || config.HTTPSProxy != "" || config.NoProxy != "" {
+		logger.Error("downloads-ignore-proxy-config", nil, lager.Data{
+			"message": "the proxy settings apply to uploads and git downloads only; other downloads use the proxy settings of the executor's environment",
+		})
+	}
+
 	downloader := cacheddownloader.NewDownloader(10*time.Minute, int(math.MaxInt8), assetTLSConfig)
-	uploader := uploader.New(logger, 10*time.Minute, assetTLSConfig)
+	urlUploader := uploader.New(logger, 10*time.Minute, assetTLSConfig, proxy)
+	blobstoreUploaders := map[string]uploader.Uploader{}
+	for scheme, blobstore := range config.BlobstoreUploaders {
+		blobstoreUploaders[scheme] = uploader.NewBlobstoreUploader(logger, 10*time.Minute, assetTLSConfig, proxy, blobstore, clock)
+	}
+	uploader := uploader.NewSchemeUploader(urlUploader, blobstoreUploaders)
 
 	cache := cacheddownloader.NewCache(config.CachePath, int64(config.MaxCacheSizeInBytes))
 	cachedDownloader := cacheddownloader.New(
@@ -218,13 +329,35 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 		return nil, grouper.Members{}, err
 	}
 
-	downloadRateLimiter := make(chan struct{}, uint(config.MaxConcurrentDownloads))
+	instrumentedDownloader := downloadcache.NewInstrumented(cachedDownloader, metronClient)
+
+	downloadRateLimiter := limiter.New(
+		config.MaxConcurrentDownloads,
+		limiter.DownloadQueueWaitDuration,
+		limiter.DownloadQueueDepth,
+		metronClient,
+		clock,
+	)
+	uploadRateLimiter := limiter.New(
+		config.MaxConcurrentUploads,
+		limiter.UploadQueueWaitDuration,
+		limiter.UploadQueueDepth,
+		metronClient,
+		clock,
+	)
+
+	containerEnv, err := loadContainerEnv(config.ContainerEnvFiles, config.ContainerEnv)
+	if err != nil {
+		logger.Error("failed-to-load-container-env", err)
+		return nil, grouper.Members{}, err
+	}
 
 	transformer := initializeTransformer(
-		cachedDownloader,
+		instrumentedDownloader,
 		workDir,
+		ProxyEnvFromConfig(config),
 		downloadRateLimiter,
-		maxConcurrentUploads,
+		uploadRateLimiter,
 		uploader,
 		config.ExportNetworkEnvVars,
 		time.Duration(config.HealthyMonitoringInterval),
@@ -233,21 +366,52 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 		clock,
 		postSetupHook,
 		config.PostSetupUser,
+		config.DefaultActionUser,
+		transformer.ProcessLimits{
+			MaxNofile: config.MaxProcessNofile,
+			MaxNproc:  config.MaxProcessNproc,
+		},
+		containerPlatform(config),
+		containerEnv,
+		config.StepRegistry,
 	)
 
-	hub := event.NewHub()
+	hub := event.NewBoundedHub(config.EventSubscriberBufferSize, event.OverflowPolicy(config.EventSubscriberOverflowPolicy))
 
 	totalCapacity, err := fetchCapacity(logger, gardenClient, config)
 	if err != nil {
 		return nil, grouper.Members{}, err
 	}
 
+	cgroupMode, err := containerstore.ResolveCgroupMode(containerstore.CgroupMode(config.CgroupMode), containerstore.DefaultCgroupRoot)
+	if err != nil {
+		logger.Error("failed-to-determine-cgroup-mode", err)
+		return nil, grouper.Members{}, err
+	}
+	logger.Info("cgroup-mode", lager.Data{"mode": cgroupMode})
+
 	containerConfig := containerstore.ContainerConfig{
-		OwnerName:              config.ContainerOwnerName,
-		INodeLimit:             config.ContainerInodeLimit,
-		MaxCPUShares:           config.ContainerMaxCpuShares,
+		OwnerName:     config.ContainerOwnerName,
+		INodeLimit:    config.ContainerInodeLimit,
+		MaxINodeLimit: config.MaxContainerInodeLimit,
+		MaxCPUShares:  config.ContainerMaxCpuShares,
+		CgroupMode:    cgroupMode,
+		HelperAssets: containerstore.HelperAssets{
+			ContainerPath:       config.HelperAssetsContainerPath,
+			DefaultArchitecture: runtime.GOARCH,
+			Dirs:                config.HelperAssetsDirs,
+		},
+		GetFilesLimits: containerstore.StreamLimits{
+			MaxBytes:    config.GetFilesMaxBytes,
+			IdleTimeout: time.Duration(config.GetFilesIdleTimeout),
+			Deadline:    time.Duration(config.GetFilesDeadline),
+		},
+		DisallowPrivileged:     config.DisallowPrivilegedContainers,
+		StopGracePeriod:        time.Duration(config.StopGracePeriod),
 		ReservedExpirationTime: time.Duration(config.ReservedExpirationTime),
 		ReapInterval:           time.Duration(config.ContainerReapInterval),
+		CompletedRetention:     time.Duration(config.CompletedContainerRetention),
+		OrphanPolicy:           containerstore.OrphanPolicy(config.OrphanedContainerPolicy),
 	}
 
 	driverConfig := vollocal.NewDriverConfig()
@@ -259,15 +423,39 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 		return nil, grouper.Members{}, err
 	}
 
+	callbackJournalDir := config.CompletionCallbackJournalDir
+	if callbackJournalDir == "" {
+		callbackJournalDir = filepath.Join(config.TempDir, "completion-callbacks")
+	}
+
+	callbackJournal, err := callbacks.NewJournal(callbackJournalDir)
+	if err != nil {
+		logger.Error("failed-to-create-completion-callback-journal", err)
+		return nil, grouper.Members{}, err
+	}
+
+	callbackNotifier := callbacks.NewNotifier(
+		logger,
+		callbackJournal,
+		&http.Client{
+			Timeout:   completionCallbackTimeout,
+			Transport: &http.Transport{TLSClientConfig: assetTLSConfig},
+		},
+		clock,
+		config.CompletionCallbackMaxAttempts,
+		time.Duration(config.CompletionCallbackMaxBackoff),
+	)
+
 	containerStore := containerstore.New(
 		containerConfig,
 		&totalCapacity,
-		gardenClient,
-		containerstore.NewDependencyManager(cachedDownloader, downloadRateLimiter),
+		guardedGardenClient,
+		containerstore.NewDependencyManager(instrumentedDownloader, downloadRateLimiter),
 		volmanClient,
 		credManager,
 		clock,
 		hub,
+		callbackNotifier,
 		transformer,
 		config.TrustedSystemCertificatesPath,
 		metronClient,
@@ -283,12 +471,26 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 	depotClient := depot.NewClient(
 		totalCapacity,
 		containerStore,
-		gardenClient,
+		guardedGardenClient,
 		volmanClient,
 		hub,
 		workPoolSettings,
+		!config.AllowAllocationsWhenUnhealthy,
+		instrumentedDownloader,
+		downloadRateLimiter,
+		workDir,
+		metronClient,
+		clock,
 	)
 
+	if config.AuditLogPath != "" {
+		depotClient, err = auditClient(config.AuditLogPath, depotClient)
+		if err != nil {
+			logger.Error("failed-to-open-audit-log", err, lager.Data{"path": config.AuditLogPath})
+			return nil, grouper.Members{}, err
+		}
+	}
+
 	healthcheckSpec := garden.ProcessSpec{
 		Path: config.GardenHealthcheckProcessPath,
 		Args: config.GardenHealthcheckProcessArgs,
@@ -302,44 +504,205 @@ func Initialize(logger lager.Logger, config ExecutorConfig, gardenHealthcheckRoo
 		config.HealthCheckContainerOwnerName,
 		time.Duration(config.GardenHealthcheckCommandRetryPause),
 		healthcheckSpec,
-		gardenClient,
+		config.GardenHealthcheckStreamOutPath,
+		guardedGardenClient,
 		guidgen.DefaultGenerator,
 	)
+	if config.GardenHealthcheckHeadroomPath != "" {
+		gardenHealthcheck = gardenhealth.NewHeadroomChecker(
+			gardenHealthcheck,
+			config.GardenHealthcheckHeadroomPath,
+			config.GardenHealthcheckMinFreeDiskMB*1024*1024,
+			config.GardenHealthcheckMinFreeInodes,
+		)
+	}
+
+	var eventJournal *event.Journal
+	if config.EventJournalPath != "" {
+		eventJournal, err = event.NewJournal(logger, hub, clock, config.EventJournalPath, config.EventJournalMaxBytes)
+		if err != nil {
+			logger.Error("failed-to-open-event-journal", err, lager.Data{"path": config.EventJournalPath})
+			return nil, grouper.Members{}, err
+		}
+	}
+
+	var eventsTLSConfig *tls.Config
+	if config.EventsListenAddr != "" {
+		eventsTLSConfig, err = cfhttp.NewTLSConfig(config.EventsServerCertFile, config.EventsServerKeyFile, config.EventsCACertFile)
+		if err != nil {
+			logger.Error("failed-to-load-events-tls-config", err)
+			return nil, grouper.Members{}, err
+		}
+	}
 
 	return depotClient,
-		grouper.Members{
+		append(append(eventJournalMembers(eventJournal), grouper.Members{
 			{"volman-driver-syncer", volmanDriverSyncer},
 			{"metrics-reporter", &metrics.Reporter{
 				ExecutorSource: depotClient,
+				EventSource:    hub,
 				Interval:       metricsReportInterval,
 				Clock:          clock,
 				Logger:         logger,
 				MetronClient:   metronClient,
 			}},
-			{"hub-closer", closeHub(hub)},
+			{"completion-callback-notifier", callbackNotifier},
+			{"hub-closer", closeHub(logger, depotClient, hub)},
 			{"container-metrics-reporter", containermetrics.NewStatsReporter(
 				logger,
 				time.Duration(config.ContainerMetricsReportInterval),
 				clock,
 				depotClient,
 				metronClient,
+				hub,
 			)},
 			{"garden_health_checker", gardenhealth.NewRunner(
 				time.Duration(config.GardenHealthcheckInterval),
 				time.Duration(config.GardenHealthcheckEmissionInterval),
 				time.Duration(config.GardenHealthcheckTimeout),
+				config.GardenHealthcheckFailureThreshold,
+				config.GardenHealthcheckSuccessThreshold,
 				logger,
 				gardenHealthcheck,
 				depotClient,
 				metronClient,
+				hub,
 				clock,
 			)},
 			{"registry-pruner", containerStore.NewRegistryPruner(logger)},
 			{"container-reaper", containerStore.NewContainerReaper(logger)},
-		},
+		}...), append(prometheusMembers(
+			logger,
+			config.PrometheusListenAddr,
+			depotClient,
+			config.HTTPRouteRateLimits,
+			config.HTTPClientRateLimit,
+			metronClient,
+			clock,
+		), eventsMembers(
+			logger,
+			config.EventsListenAddr,
+			eventsTLSConfig,
+			hub,
+			clock,
+			config.EventStreamBufferSize,
+			time.Duration(config.EventStreamHeartbeatInterval),
+			eventJournal,
+			config.HTTPRouteRateLimits,
+			config.HTTPClientRateLimit,
+			metronClient,
+		)...)...),
 		nil
 }
 
+// eventJournalMembers runs the event journal, when one is configured, ahead
+// of everything that emits events.
+func eventJournalMembers(eventJournal *event.Journal) grouper.Members {
+	if eventJournal == nil {
+		return nil
+	}
+
+	return grouper.Members{
+		{"event-journal", eventJournal},
+	}
+}
+
+// auditClient appends an audit record of every container operation made
+// through depotClient to the file at auditLogPath. The file is opened for
+// appending so it can be rotated by truncating it in place.
+func auditClient(auditLogPath string, depotClient executor.Client) (executor.Client, error) {
+	auditFile, err := os.OpenFile(auditLogPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLogger := lager.NewLogger("executor-audit")
+	auditLogger.RegisterSink(lager.NewWriterSink(auditFile, lager.INFO))
+
+	return depot.NewAuditClient(depotClient, auditLogger), nil
+}
+
+// prometheusMembers serves the executor's metrics for Prometheus to scrape
+// and its health report, when a listen address is configured. Requests are
+// rate limited if any limits are set.
+func prometheusMembers(
+	logger lager.Logger,
+	listenAddr string,
+	depotClient executor.Client,
+	routeRateLimits map[string]ratelimit.Limit,
+	clientRateLimit ratelimit.Limit,
+	metronClient loggregator_v2.Client,
+	clock clock.Clock,
+) grouper.Members {
+	if listenAddr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", &metrics.PrometheusHandler{
+		ExecutorSource: depotClient,
+		Logger:         logger,
+	})
+	mux.Handle("/health", &metrics.HealthHandler{
+		ExecutorSource: depotClient,
+		Logger:         logger,
+	})
+
+	return grouper.Members{
+		{"prometheus-metrics-server", http_server.New(listenAddr, rateLimited(logger, mux, routeRateLimits, clientRateLimit, metronClient, clock))},
+	}
+}
+
+// eventsMembers serves the executor's event stream and its event journal,
+// when a listen address is configured. Events describe the executor's
+// containers, so they are only served over mutual TLS, to clients with a
+// certificate signed by the configured CA. Requests are rate limited if any
+// limits are set.
+func eventsMembers(
+	logger lager.Logger,
+	listenAddr string,
+	tlsConfig *tls.Config,
+	hub event.Hub,
+	clock clock.Clock,
+	eventBufferSize int,
+	eventHeartbeatInterval time.Duration,
+	eventJournal *event.Journal,
+	routeRateLimits map[string]ratelimit.Limit,
+	clientRateLimit ratelimit.Limit,
+	metronClient loggregator_v2.Client,
+) grouper.Members {
+	if listenAddr == "" {
+		return nil
+	}
+
+	mux := http.NewServeMux()
+	eventStream := event.NewStream(logger, hub, clock, eventBufferSize, eventHeartbeatInterval)
+	mux.Handle("/events", eventStream)
+	if eventJournal != nil {
+		mux.Handle("/events/journal", eventJournal)
+	}
+
+	return grouper.Members{
+		{"event-stream", eventStream},
+		{"events-server", http_server.NewTLSServer(listenAddr, rateLimited(logger, mux, routeRateLimits, clientRateLimit, metronClient, clock), tlsConfig)},
+	}
+}
+
+// rateLimited wraps handler in a rate limiter if any limits are set.
+func rateLimited(
+	logger lager.Logger,
+	handler http.Handler,
+	routeRateLimits map[string]ratelimit.Limit,
+	clientRateLimit ratelimit.Limit,
+	metronClient loggregator_v2.Client,
+	clock clock.Clock,
+) http.Handler {
+	if len(routeRateLimits) == 0 && !clientRateLimit.Enabled() {
+		return handler
+	}
+	return ratelimit.NewHandler(logger, handler, routeRateLimits, clientRateLimit, metronClient, clock)
+}
+
 // Until we get a successful response from garden,
 // periodically emit metrics saying how long we've been trying
 // while retrying the connection indefinitely.
@@ -389,7 +752,7 @@ func waitForGarden(logger lager.Logger, gardenClient GardenClient.Client, metron
 }
 
 func fetchCapacity(logger lager.Logger, gardenClient GardenClient.Client, config ExecutorConfig) (executor.ExecutorResources, error) {
-	capacity, err := configuration.ConfigureCapacity(gardenClient, config.MemoryMB, config.DiskMB, config.MaxCacheSizeInBytes, config.AutoDiskOverheadMB)
+	capacity, err := configuration.ConfigureCapacity(gardenClient, config.MemoryMB, config.DiskMB, config.MaxCacheSizeInBytes, config.AutoDiskOverheadMB, config.MemoryOvercommitFactor, config.AutoMemoryReservedPercent, config.AutoDiskReservedPercent)
 	if err != nil {
 		logger.Error("failed-to-configure-capacity", err)
 		return executor.ExecutorResources{}, err
@@ -448,8 +811,9 @@ func setupWorkDir(logger lager.Logger, tempDir string) string {
 func initializeTransformer(
 	cache cacheddownloader.CachedDownloader,
 	workDir string,
-	downloadRateLimiter chan struct{},
-	maxConcurrentUploads uint,
+	proxyEnv []string,
+	downloadRateLimiter limiter.Limiter,
+	uploadRateLimiter limiter.Limiter,
 	uploader uploader.Uploader,
 	exportNetworkEnvVars bool,
 	healthyMonitoringInterval time.Duration,
@@ -458,6 +822,11 @@ func initializeTransformer(
 	clock clock.Clock,
 	postSetupHook []string,
 	postSetupUser string,
+	defaultActionUser string,
+	processLimits transformer.ProcessLimits,
+	platform steps.Platform,
+	containerEnv []executor.EnvironmentVariable,
+	stepRegistry *transformer.Registry,
 ) transformer.Transformer {
 	extractor := extractor.NewDetectable()
 	compressor := compressor.NewTgz()
@@ -468,8 +837,9 @@ func initializeTransformer(
 		extractor,
 		compressor,
 		downloadRateLimiter,
-		make(chan struct{}, maxConcurrentUploads),
+		uploadRateLimiter,
 		workDir,
+		proxyEnv,
 		exportNetworkEnvVars,
 		healthyMonitoringInterval,
 		unhealthyMonitoringInterval,
@@ -477,13 +847,21 @@ func initializeTransformer(
 		clock,
 		postSetupHook,
 		postSetupUser,
+		defaultActionUser,
+		processLimits,
+		platform,
+		containerEnv,
+		stepRegistry,
 	)
 }
 
-func closeHub(hub event.Hub) ifrit.Runner {
+// closeHub drains the executor when it is signalled, so that no new work
+// arrives while the event hub and its subscriptions are closed.
+func closeHub(logger lager.Logger, depotClient executor.Client, hub event.Hub) ifrit.Runner {
 	return ifrit.RunFunc(func(signals <-chan os.Signal, ready chan<- struct{}) error {
 		close(ready)
 		<-signals
+		depotClient.Drain(logger)
 		hub.Close()
 		return nil
 	})
@@ -594,6 +972,91 @@ func (config *ExecutorConfig) Validate(logger lager.Logger) bool {
 		valid = false
 	}
 
+	if !configuration.ValidOvercommitFactor(config.MemoryOvercommitFactor) {
+		logger.Error("memory-overcommit-factor-invalid", nil, lager.Data{"memory-overcommit-factor": config.MemoryOvercommitFactor})
+		valid = false
+	}
+
+	switch containerstore.CgroupMode(config.CgroupMode) {
+	case "", containerstore.CgroupModeAuto, containerstore.CgroupModeV1, containerstore.CgroupModeV2:
+	default:
+		logger.Error("cgroup-mode-invalid", nil, lager.Data{"cgroup-mode": config.CgroupMode})
+		valid = false
+	}
+
+	switch containerstore.OrphanPolicy(config.OrphanedContainerPolicy) {
+	case "", containerstore.OrphanPolicyDestroy, containerstore.OrphanPolicyKeep:
+	default:
+		logger.Error("orphaned-container-policy-invalid", nil, lager.Data{"orphaned-container-policy": config.OrphanedContainerPolicy})
+		valid = false
+	}
+
+	switch event.OverflowPolicy(config.EventSubscriberOverflowPolicy) {
+	case "", event.OverflowDisconnect, event.OverflowDropOldest:
+	default:
+		logger.Error("event-subscriber-overflow-policy-invalid", nil, lager.Data{"event-subscriber-overflow-policy": config.EventSubscriberOverflowPolicy})
+		valid = false
+	}
+
+	if !config.HTTPClientRateLimit.Valid() {
+		logger.Error("http-client-rate-limit-invalid", nil, lager.Data{"http-client-rate-limit": config.HTTPClientRateLimit})
+		valid = false
+	}
+
+	for route, limit := range config.HTTPRouteRateLimits {
+		if !limit.Valid() {
+			logger.Error("http-route-rate-limit-invalid", nil, lager.Data{"route": route, "limit": limit})
+			valid = false
+		}
+	}
+
+	for scheme, blobstore := range config.BlobstoreUploaders {
+		if scheme == "http" || scheme == "https" || !validBlobstore(blobstore) {
+			logger.Error("blobstore-uploader-invalid", nil, lager.Data{"scheme": scheme, "endpoint": blobstore.Endpoint})
+			valid = false
+		}
+	}
+
+	if !validProxyURL(config.HTTPProxy) {
+		logger.Error("http-proxy-invalid", nil, lager.Data{"http-proxy": config.HTTPProxy})
+		valid = false
+	}
+
+	if !validProxyURL(config.HTTPSProxy) {
+		logger.Error("https-proxy-invalid", nil, lager.Data{"https-proxy": config.HTTPSProxy})
+		valid = false
+	}
+
+	if config.ContainerPlatform != "" && !steps.Platform(config.ContainerPlatform).Valid() {
+		logger.Error("container-platform-invalid", nil, lager.Data{"container-platform": config.ContainerPlatform})
+		valid = false
+	}
+
+	if len(config.HelperAssetsDirs) > 0 && config.HelperAssetsContainerPath == "" {
+		logger.Error("helper-assets-container-path-invalid", nil)
+		valid = false
+	}
+
+	if config.GetFilesMaxBytes < 0 || config.GetFilesIdleTimeout < 0 || config.GetFilesDeadline < 0 {
+		logger.Error("get-files-limits-invalid", nil)
+		valid = false
+	}
+
+	if config.StopGracePeriod < 0 {
+		logger.Error("stop-grace-period-invalid", nil)
+		valid = false
+	}
+
+	if config.CompletedContainerRetention < 0 {
+		logger.Error("completed-container-retention-invalid", nil)
+		valid = false
+	}
+
+	if config.CompletionCallbackMaxAttempts <= 0 {
+		logger.Error("completion-callback-max-attempts-invalid", nil)
+		valid = false
+	}
+
 	if config.HealthyMonitoringInterval <= 0 {
 		logger.Error("healthy-monitoring-interval-invalid", nil)
 		valid = false
@@ -619,14 +1082,154 @@ func (config *ExecutorConfig) Validate(logger lager.Logger) bool {
 		valid = false
 	}
 
+	if config.MaxConcurrentDownloads <= 0 {
+		logger.Error("max-concurrent-downloads-invalid", nil)
+		valid = false
+	}
+
+	if config.MaxConcurrentUploads <= 0 {
+		logger.Error("max-concurrent-uploads-invalid", nil)
+		valid = false
+	}
+
 	if config.PostSetupHook != "" && config.PostSetupUser == "" {
 		logger.Error("post-setup-hook-requires-a-user", nil)
 		valid = false
 	}
 
+	if config.EventsListenAddr != "" && (config.EventsServerCertFile == "" || config.EventsServerKeyFile == "" || config.EventsCACertFile == "") {
+		logger.Error("events-listen-addr-requires-mutual-tls", nil)
+		valid = false
+	}
+
 	return valid
 }
 
+// ProxyFromConfig picks the proxy for the uploaders' requests from the
+// configured proxy settings, without touching the process environment.
+// Settings left empty keep whatever the executor was started with. The
+// cached downloader has no way to be given a proxy, so downloads other than
+// git clones only use the proxy settings of the environment the executor was
+// started with; Initialize logs an error when they are configured.
+func ProxyFromConfig(config ExecutorConfig) func(*http.Request) (*url.URL, error) {
+	proxyConfig := httpproxy.FromEnvironment()
+	if config.HTTPProxy != "" {
+		proxyConfig.HTTPProxy = config.HTTPProxy
+	}
+	if config.HTTPSProxy != "" {
+		proxyConfig.HTTPSProxy = config.HTTPSProxy
+	}
+	if config.NoProxy != "" {
+		proxyConfig.NoProxy = config.NoProxy
+	}
+
+	proxyForURL := proxyConfig.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxyForURL(req.URL)
+	}
+}
+
+// ProxyEnvFromConfig is the environment that points git at the configured
+// proxy settings, for git download steps. It only holds the settings that
+// are set, so the others keep whatever the executor was started with.
+func ProxyEnvFromConfig(config ExecutorConfig) []string {
+	env := []string{}
+	if config.HTTPProxy != "" {
+		env = append(env, "http_proxy="+config.HTTPProxy)
+	}
+	if config.HTTPSProxy != "" {
+		env = append(env, "https_proxy="+config.HTTPSProxy)
+	}
+	if config.NoProxy != "" {
+		env = append(env, "no_proxy="+config.NoProxy)
+	}
+	return env
+}
+
+// containerPlatform is the configured container platform, defaulting to
+// Linux when it is left unset.
+func containerPlatform(config ExecutorConfig) steps.Platform {
+	if config.ContainerPlatform == "" {
+		return steps.PlatformLinux
+	}
+	return steps.Platform(config.ContainerPlatform)
+}
+
+// validProxyURL reports whether proxy is empty or an http, https or socks5
+// proxy URL.
+func validProxyURL(proxy string) bool {
+	if proxy == "" {
+		return true
+	}
+
+	proxyURL, err := url.Parse(proxy)
+	if err != nil || proxyURL.Host == "" {
+		return false
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+		return true
+	default:
+		return false
+	}
+}
+
+// validBlobstore reports whether blobstore has an http or https endpoint, a
+// region, a pair of credentials, and a part size the blobstore will accept.
+func validBlobstore(blobstore uploader.Blobstore) bool {
+	endpoint, err := url.Parse(blobstore.Endpoint)
+	if err != nil || endpoint.Host == "" || (endpoint.Scheme != "http" && endpoint.Scheme != "https") {
+		return false
+	}
+	if blobstore.PartSize != 0 && blobstore.PartSize < uploader.MinPartSize {
+		return false
+	}
+	return blobstore.Region != "" && blobstore.AccessKeyID != "" && blobstore.SecretAccessKey != ""
+}
+
+// loadContainerEnv reads the KEY=VALUE lines of each env file, in order, and
+// then applies env on top. Blank lines and lines starting with # are skipped.
+func loadContainerEnv(envFiles []string, env []executor.EnvironmentVariable) ([]executor.EnvironmentVariable, error) {
+	merged := []executor.EnvironmentVariable{}
+	indexes := map[string]int{}
+	set := func(envVar executor.EnvironmentVariable) {
+		if i, ok := indexes[envVar.Name]; ok {
+			merged[i] = envVar
+			return
+		}
+		indexes[envVar.Name] = len(merged)
+		merged = append(merged, envVar)
+	}
+
+	for _, envFile := range envFiles {
+		contents, err := ioutil.ReadFile(envFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to open container env file '%s'", envFile)
+		}
+
+		for n, line := range strings.Split(string(contents), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+
+			parts := strings.SplitN(line, "=", 2)
+			if len(parts) != 2 || parts[0] == "" {
+				return nil, fmt.Errorf("Invalid line %d in container env file '%s'", n+1, envFile)
+			}
+
+			set(executor.EnvironmentVariable{Name: parts[0], Value: parts[1]})
+		}
+	}
+
+	for _, envVar := range env {
+		set(envVar)
+	}
+
+	return merged, nil
+}
+
 func appendCACerts(caCertPool *x509.CertPool, pathToCA string) (*x509.CertPool, error) {
 	certBytes, err := ioutil.ReadFile(pathToCA)
 	if err != nil {
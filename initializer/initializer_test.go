@@ -7,6 +7,7 @@ import (
 	"encoding/pem"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
@@ -60,10 +61,14 @@ var _ = Describe("Initializer", func() {
 		config = initializer.ExecutorConfig{
 			AutoDiskOverheadMB:                 1,
 			CachePath:                          "/tmp/cache",
+			CompletionCallbackJournalDir:       "/tmp/completion-callbacks",
+			CompletionCallbackMaxAttempts:      10,
+			CompletionCallbackMaxBackoff:       durationjson.Duration(time.Minute),
 			ContainerInodeLimit:                200000,
 			ContainerMaxCpuShares:              0,
 			ContainerMetricsReportInterval:     durationjson.Duration(15 * time.Second),
 			ContainerOwnerName:                 "executor",
+			ContainerPlatform:                  "linux",
 			ContainerReapInterval:              durationjson.Duration(time.Minute),
 			CreateWorkPoolSize:                 32,
 			DeleteWorkPoolSize:                 32,
@@ -82,11 +87,13 @@ var _ = Describe("Initializer", func() {
 			HealthyMonitoringInterval:          durationjson.Duration(30 * time.Second),
 			MaxCacheSizeInBytes:                10 * 1024 * 1024 * 1024,
 			MaxConcurrentDownloads:             5,
+			MaxConcurrentUploads:               5,
 			MemoryMB:                           configuration.Automatic,
 			MetricsWorkPoolSize:                8,
 			ReadWorkPoolSize:                   64,
 			ReservedExpirationTime:             durationjson.Duration(time.Minute),
 			SkipCertVerify:                     false,
+			StopGracePeriod:                    durationjson.Duration(time.Minute),
 			TempDir:                            "/tmp",
 			UnhealthyMonitoringInterval:        durationjson.Duration(500 * time.Millisecond),
 			VolmanDriverPaths:                  "/tmpvolman1:/tmp/volman2",
@@ -355,6 +362,86 @@ var _ = Describe("Initializer", func() {
 		})
 	})
 
+	Describe("configuring the container environment", func() {
+		Context("when the env files are valid", func() {
+			BeforeEach(func() {
+				config.ContainerEnvFiles = []string{"fixtures/container-env"}
+				config.ContainerEnv = []executor.EnvironmentVariable{
+					{Name: "HTTP_PROXY", Value: "http://other-proxy.example.com:3128"},
+				}
+			})
+
+			It("does not error", func() {
+				Consistently(errCh).ShouldNot(Receive(HaveOccurred()))
+			})
+		})
+
+		Context("when an env file contains an invalid line", func() {
+			BeforeEach(func() {
+				config.ContainerEnvFiles = []string{"fixtures/container-env-invalid"}
+			})
+
+			It("fails", func() {
+				Eventually(errCh).Should(Receive(MatchError("Invalid line 1 in container env file 'fixtures/container-env-invalid'")))
+			})
+		})
+
+		Context("when an env file does not exist", func() {
+			BeforeEach(func() {
+				config.ContainerEnvFiles = []string{"sandwich"}
+			})
+
+			It("fails", func() {
+				Eventually(errCh).Should(Receive(MatchError("Unable to open container env file 'sandwich'")))
+			})
+		})
+	})
+
+	Describe("ProxyFromConfig", func() {
+		var proxy func(*http.Request) (*url.URL, error)
+
+		BeforeEach(func() {
+			config.HTTPProxy = "http://proxy.example.com:3128"
+			config.NoProxy = "blobstore.internal"
+		})
+
+		JustBeforeEach(func() {
+			proxy = initializer.ProxyFromConfig(config)
+		})
+
+		proxyFor := func(rawURL string) *url.URL {
+			request, err := http.NewRequest("PUT", rawURL, nil)
+			Expect(err).NotTo(HaveOccurred())
+			proxyURL, err := proxy(request)
+			Expect(err).NotTo(HaveOccurred())
+			return proxyURL
+		}
+
+		It("proxies requests with the configured settings", func() {
+			Expect(proxyFor("http://upload.example.com/droplet")).To(Equal(&url.URL{Scheme: "http", Host: "proxy.example.com:3128"}))
+			Expect(proxyFor("http://blobstore.internal/droplet")).To(BeNil())
+		})
+
+		It("leaves the environment alone", func() {
+			Expect(os.Getenv("HTTP_PROXY")).To(BeEmpty())
+			Expect(os.Getenv("NO_PROXY")).To(BeEmpty())
+		})
+	})
+
+	Describe("ProxyEnvFromConfig", func() {
+		BeforeEach(func() {
+			config.HTTPProxy = "http://proxy.example.com:3128"
+			config.NoProxy = "blobstore.internal"
+		})
+
+		It("returns the environment for the settings that are set", func() {
+			Expect(initializer.ProxyEnvFromConfig(config)).To(ConsistOf(
+				"http_proxy=http://proxy.example.com:3128",
+				"no_proxy=blobstore.internal",
+			))
+		})
+	})
+
 	Describe("TLSConfigFromConfig", func() {
 		var (
 			tlsConfig             *tls.Config
@@ -572,4 +659,54 @@ var _ = Describe("Initializer", func() {
 			})
 		})
 	})
+
+	Describe("New", func() {
+		var embedConfig initializer.Config
+
+		BeforeEach(func() {
+			config.ContainerMaxCpuShares = 1024
+			config.GardenHealthcheckProcessPath = "/bin/sh"
+			config.GardenHealthcheckProcessUser = "vcap"
+		})
+
+		JustBeforeEach(func() {
+			Eventually(done).Should(BeClosed())
+
+			embedConfig = initializer.Config{
+				ExecutorConfig: config,
+				Logger:         logger,
+				MetronClient:   fakeMetronClient,
+				Clock:          fakeClock,
+			}
+		})
+
+		It("builds an executor", func() {
+			client, runner, err := initializer.New(embedConfig)
+			Expect(err).NotTo(HaveOccurred())
+			Expect(client).NotTo(BeNil())
+			Expect(runner).NotTo(BeNil())
+		})
+
+		It("keeps settings that are set to their zero value", func() {
+			embedConfig.MaxConcurrentDownloads = 0
+
+			_, _, err := initializer.New(embedConfig)
+			Expect(err).To(Equal(initializer.ErrInvalidConfig))
+		})
+
+		Context("when no metron client is given", func() {
+			It("returns an error", func() {
+				embedConfig.MetronClient = nil
+
+				_, _, err := initializer.New(embedConfig)
+				Expect(err).To(Equal(initializer.ErrMetronClientRequired))
+			})
+		})
+
+		Describe("NewConfig", func() {
+			It("starts from the default configuration", func() {
+				Expect(initializer.NewConfig().ExecutorConfig).To(Equal(initializer.DefaultConfiguration))
+			})
+		})
+	})
 })
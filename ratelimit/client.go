@@ -0,0 +1,105 @@
+package ratelimit
+
+import (
+	"context"
+	"io"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
+	"code.cloudfoundry.org/lager"
+)
+
+const RateLimitedCalls = "RateLimitedCalls"
+
+// Names of the client calls that can be limited.
+const (
+	CallGetContainer       = "get-container"
+	CallListContainers     = "list-containers"
+	CallListContainersPage = "list-containers-page"
+	CallGetBulkMetrics     = "get-bulk-metrics"
+	CallGetFiles           = "get-files"
+)
+
+type client struct {
+	executor.Client
+	logger       lager.Logger
+	limiter      *limiter
+	metronClient loggregator_v2.Client
+}
+
+// NewClient rate limits the reads of the client it wraps with token buckets,
+// so a caller polling them can not starve lifecycle calls. Each call named in
+// callLimits is limited across all callers. Calls over their limit fail with
+// executor.ErrRateLimited, and the number turned away so far is sent as
+// RateLimitedCalls. Every other call is passed straight through.
+func NewClient(
+	logger lager.Logger,
+	c executor.Client,
+	callLimits map[string]Limit,
+	metronClient loggregator_v2.Client,
+	clock clock.Clock,
+) executor.Client {
+	return &client{
+		Client:       c,
+		logger:       logger.Session("rate-limit"),
+		limiter:      newLimiter(callLimits, Limit{}, clock),
+		metronClient: metronClient,
+	}
+}
+
+func (c *client) GetContainer(logger lager.Logger, guid string) (executor.Container, error) {
+	err := c.allow(logger, CallGetContainer)
+	if err != nil {
+		return executor.Container{}, err
+	}
+	return c.Client.GetContainer(logger, guid)
+}
+
+func (c *client) ListContainers(logger lager.Logger) ([]executor.Container, error) {
+	err := c.allow(logger, CallListContainers)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client.ListContainers(logger)
+}
+
+func (c *client) ListContainersPage(logger lager.Logger, opts executor.ContainerListOptions) (executor.ContainerPage, error) {
+	err := c.allow(logger, CallListContainersPage)
+	if err != nil {
+		return executor.ContainerPage{}, err
+	}
+	return c.Client.ListContainersPage(logger, opts)
+}
+
+func (c *client) GetBulkMetrics(logger lager.Logger) (map[string]executor.Metrics, error) {
+	err := c.allow(logger, CallGetBulkMetrics)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client.GetBulkMetrics(logger)
+}
+
+func (c *client) GetFiles(ctx context.Context, logger lager.Logger, guid string, paths ...string) (io.ReadCloser, error) {
+	err := c.allow(logger, CallGetFiles)
+	if err != nil {
+		return nil, err
+	}
+	return c.Client.GetFiles(ctx, logger, guid, paths...)
+}
+
+func (c *client) allow(logger lager.Logger, call string) error {
+	allowed, retryAfter, limited := c.limiter.allow(call, "")
+	if allowed {
+		return nil
+	}
+
+	logger.Info("call-limited", lager.Data{"call": call, "retry-after": retryAfter.String()})
+
+	err := c.metronClient.SendMetric(RateLimitedCalls, limited)
+	if err != nil {
+		c.logger.Error("failed-to-send-rate-limited-calls-metric", err)
+	}
+
+	return executor.ErrRateLimited
+}
@@ -0,0 +1,67 @@
+package ratelimit_test
+
+import (
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor"
+	"code.cloudfoundry.org/executor/fakes"
+	"code.cloudfoundry.org/executor/ratelimit"
+	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Client", func() {
+	var (
+		logger           *lagertest.TestLogger
+		fakeClient       *fakes.FakeClient
+		fakeClock        *fakeclock.FakeClock
+		fakeMetronClient *mfakes.FakeClient
+		client           executor.Client
+	)
+
+	BeforeEach(func() {
+		logger = lagertest.NewTestLogger("test")
+		fakeClient = new(fakes.FakeClient)
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeMetronClient = new(mfakes.FakeClient)
+		client = ratelimit.NewClient(logger, fakeClient, map[string]ratelimit.Limit{
+			ratelimit.CallListContainers: {Rate: 1, Burst: 1},
+		}, fakeMetronClient, fakeClock)
+	})
+
+	It("fails calls over their limit until the bucket refills", func() {
+		_, err := client.ListContainers(logger)
+		Expect(err).NotTo(HaveOccurred())
+
+		_, err = client.ListContainers(logger)
+		Expect(err).To(Equal(executor.ErrRateLimited))
+		Expect(fakeClient.ListContainersCallCount()).To(Equal(1))
+
+		fakeClock.Increment(time.Second)
+		_, err = client.ListContainers(logger)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(fakeClient.ListContainersCallCount()).To(Equal(2))
+	})
+
+	It("sends the number of limited calls", func() {
+		client.ListContainers(logger)
+		client.ListContainers(logger)
+
+		Expect(fakeMetronClient.SendMetricCallCount()).To(Equal(1))
+		name, value := fakeMetronClient.SendMetricArgsForCall(0)
+		Expect(name).To(Equal(ratelimit.RateLimitedCalls))
+		Expect(value).To(Equal(1))
+	})
+
+	It("leaves calls without a limit alone", func() {
+		for i := 0; i < 10; i++ {
+			_, err := client.GetContainer(logger, "some-guid")
+			Expect(err).NotTo(HaveOccurred())
+		}
+		Expect(fakeClient.GetContainerCallCount()).To(Equal(10))
+	})
+})
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+
+	"code.cloudfoundry.org/clock"
+	"code.cloudfoundry.org/go-loggregator/loggregator_v2"
+	"code.cloudfoundry.org/lager"
+)
+
+const RateLimitedRequests = "RateLimitedRequests"
+
+type handler struct {
+	logger       lager.Logger
+	handler      http.Handler
+	limiter      *limiter
+	metronClient loggregator_v2.Client
+}
+
+// NewHandler rate limits the requests to handler with token buckets. Each
+// path in routeLimits is limited across all clients, and each client, as
+// identified by its remote address, is limited across all paths by
+// clientLimit. Requests over either limit are answered with 429 Too Many
+// Requests, and the number turned away so far is sent as
+// RateLimitedRequests.
+func NewHandler(
+	logger lager.Logger,
+	h http.Handler,
+	routeLimits map[string]Limit,
+	clientLimit Limit,
+	metronClient loggregator_v2.Client,
+	clock clock.Clock,
+) http.Handler {
+	return &handler{
+		logger:       logger.Session("rate-limit"),
+		handler:      h,
+		limiter:      newLimiter(routeLimits, clientLimit, clock),
+		metronClient: metronClient,
+	}
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+
+	allowed, retryAfter, limited := h.limiter.allow(r.URL.Path, client)
+	if !allowed {
+		h.recordLimited(limited)
+		h.logger.Info("request-limited", lager.Data{"path": r.URL.Path, "client": client})
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+		http.Error(w, "too many requests", http.StatusTooManyRequests)
+		return
+	}
+
+	h.handler.ServeHTTP(w, r)
+}
+
+func (h *handler) recordLimited(limited int) {
+	err := h.metronClient.SendMetric(RateLimitedRequests, limited)
+	if err != nil {
+		h.logger.Error("failed-to-send-rate-limited-requests-metric", err)
+	}
+}
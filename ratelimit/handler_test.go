@@ -0,0 +1,107 @@
+package ratelimit_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	"code.cloudfoundry.org/clock/fakeclock"
+	"code.cloudfoundry.org/executor/ratelimit"
+	mfakes "code.cloudfoundry.org/go-loggregator/loggregator_v2/fakes"
+	"code.cloudfoundry.org/lager/lagertest"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Handler", func() {
+	var (
+		fakeClock        *fakeclock.FakeClock
+		fakeMetronClient *mfakes.FakeClient
+		routeLimits      map[string]ratelimit.Limit
+		clientLimit      ratelimit.Limit
+		handler          http.Handler
+	)
+
+	BeforeEach(func() {
+		fakeClock = fakeclock.NewFakeClock(time.Now())
+		fakeMetronClient = new(mfakes.FakeClient)
+		routeLimits = map[string]ratelimit.Limit{
+			"/metrics": {Rate: 1, Burst: 2},
+		}
+		clientLimit = ratelimit.Limit{}
+	})
+
+	JustBeforeEach(func() {
+		ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		})
+		handler = ratelimit.NewHandler(lagertest.NewTestLogger("test"), ok, routeLimits, clientLimit, fakeMetronClient, fakeClock)
+	})
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", path, nil)
+		request.RemoteAddr = remoteAddr
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	It("allows a burst and then refills at the route's rate", func() {
+		Expect(get("/metrics", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+		Expect(get("/metrics", "10.0.0.2:1234").Code).To(Equal(http.StatusOK))
+
+		limited := get("/metrics", "10.0.0.3:1234")
+		Expect(limited.Code).To(Equal(http.StatusTooManyRequests))
+		Expect(limited.Header().Get("Retry-After")).To(Equal("1"))
+
+		fakeClock.Increment(time.Second)
+		Expect(get("/metrics", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+	})
+
+	It("leaves routes without a limit alone", func() {
+		for i := 0; i < 10; i++ {
+			Expect(get("/health", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+		}
+	})
+
+	It("sends the number of limited requests", func() {
+		get("/metrics", "10.0.0.1:1234")
+		get("/metrics", "10.0.0.1:1234")
+		get("/metrics", "10.0.0.1:1234")
+		get("/metrics", "10.0.0.1:1234")
+
+		Expect(fakeMetronClient.SendMetricCallCount()).To(Equal(2))
+		name, value := fakeMetronClient.SendMetricArgsForCall(1)
+		Expect(name).To(Equal(ratelimit.RateLimitedRequests))
+		Expect(value).To(Equal(2))
+	})
+
+	Context("with a per-client limit", func() {
+		BeforeEach(func() {
+			routeLimits = nil
+			clientLimit = ratelimit.Limit{Rate: 1, Burst: 1}
+		})
+
+		It("limits each client separately across routes", func() {
+			Expect(get("/metrics", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+			Expect(get("/health", "10.0.0.1:5678").Code).To(Equal(http.StatusTooManyRequests))
+			Expect(get("/health", "10.0.0.2:1234").Code).To(Equal(http.StatusOK))
+		})
+
+		Context("and a route limit", func() {
+			BeforeEach(func() {
+				routeLimits = map[string]ratelimit.Limit{
+					"/metrics": {Rate: 1, Burst: 2},
+				}
+			})
+
+			It("does not take a token from the route for a limited client", func() {
+				Expect(get("/metrics", "10.0.0.1:1234").Code).To(Equal(http.StatusOK))
+				Expect(get("/metrics", "10.0.0.1:1234").Code).To(Equal(http.StatusTooManyRequests))
+				Expect(get("/metrics", "10.0.0.2:1234").Code).To(Equal(http.StatusOK))
+				Expect(get("/metrics", "10.0.0.3:1234").Code).To(Equal(http.StatusTooManyRequests))
+			})
+		})
+	})
+})
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"code.cloudfoundry.org/clock"
+)
+
+// maxIdleClients is how many clients' buckets are kept before those that have
+// refilled completely are forgotten.
+const maxIdleClients = 1024
+
+// Limit allows Rate requests per second on average, in bursts of up to Burst.
+// A zero Rate leaves requests unlimited.
+type Limit struct {
+	Rate  float64 `json:"rate"`
+	Burst int     `json:"burst"`
+}
+
+func (l Limit) Enabled() bool {
+	return l.Rate > 0
+}
+
+func (l Limit) Valid() bool {
+	return l.Rate >= 0 && l.Burst >= 0
+}
+
+func (l Limit) capacity() float64 {
+	if l.Burst < 1 {
+		return 1
+	}
+	return float64(l.Burst)
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// refill adds the tokens earned since the bucket was last used, and reports
+// whether it holds one. If not it also returns how long until it will.
+func (b *bucket) refill(limit Limit, now time.Time) (bool, time.Duration) {
+	b.tokens = math.Min(limit.capacity(), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+}
+
+func (b *bucket) full(limit Limit, now time.Time) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*limit.Rate >= limit.capacity()
+}
+
+// limiter keeps a token bucket for each route in routeLimits, and one for
+// each client when clientLimit is enabled.
+type limiter struct {
+	routeLimits map[string]Limit
+	clientLimit Limit
+	clock       clock.Clock
+
+	lock    sync.Mutex
+	routes  map[string]*bucket
+	clients map[string]*bucket
+	limited int
+}
+
+func newLimiter(routeLimits map[string]Limit, clientLimit Limit, clock clock.Clock) *limiter {
+	return &limiter{
+		routeLimits: routeLimits,
+		clientLimit: clientLimit,
+		clock:       clock,
+		routes:      map[string]*bucket{},
+		clients:     map[string]*bucket{},
+	}
+}
+
+// allow takes a token from both the route's and the client's buckets when
+// both hold one, and otherwise takes neither. A limited request is counted,
+// and allow returns how long until both buckets will hold a token along with
+// the number of requests limited so far.
+func (l *limiter) allow(route, client string) (bool, time.Duration, int) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := l.clock.Now()
+	allowed := true
+	var retryAfter time.Duration
+	buckets := []*bucket{}
+
+	if limit, ok := l.routeLimits[route]; ok && limit.Enabled() {
+		b := l.bucketFor(l.routes, route, limit, now)
+		ok, wait := b.refill(limit, now)
+		allowed = allowed && ok
+		retryAfter = wait
+		buckets = append(buckets, b)
+	}
+
+	if l.clientLimit.Enabled() {
+		if len(l.clients) >= maxIdleClients {
+			for key, b := range l.clients {
+				if b.full(l.clientLimit, now) {
+					delete(l.clients, key)
+				}
+			}
+		}
+
+		b := l.bucketFor(l.clients, client, l.clientLimit, now)
+		ok, wait := b.refill(l.clientLimit, now)
+		allowed = allowed && ok
+		if wait > retryAfter {
+			retryAfter = wait
+		}
+		buckets = append(buckets, b)
+	}
+
+	if !allowed {
+		l.limited++
+		return false, retryAfter, l.limited
+	}
+
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return true, 0, l.limited
+}
+
+func (l *limiter) bucketFor(buckets map[string]*bucket, key string, limit Limit, now time.Time) *bucket {
+	b, ok := buckets[key]
+	if !ok {
+		b = &bucket{tokens: limit.capacity(), last: now}
+		buckets[key] = b
+	}
+	return b
+}
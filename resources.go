@@ -3,6 +3,8 @@ package executor
 import (
 	"encoding/json"
 	"errors"
+	"net"
+	"strings"
 	"time"
 
 	"code.cloudfoundry.org/bbs/models"
@@ -35,13 +37,16 @@ type Container struct {
 	Resource
 	RunInfo
 	Tags        Tags
+	Annotations map[string]string  `json:"annotations,omitempty"`
 	State       State              `json:"state"`
 	AllocatedAt int64              `json:"allocated_at"`
+	CompletedAt int64              `json:"completed_at,omitempty"`
 	ExternalIP  string             `json:"external_ip"`
 	InternalIP  string             `json:"internal_ip"`
 	RunResult   ContainerRunResult `json:"run_result"`
 	MemoryLimit uint64             `json:"memory_limit"`
 	DiskLimit   uint64             `json:"disk_limit"`
+	CPUShares   uint64             `json:"cpu_shares"`
 }
 
 func NewContainerFromResource(guid string, resource *Resource, tags Tags) Container {
@@ -75,6 +80,7 @@ func (c *Container) TransistionToInitialize(req *RunRequest) error {
 	c.State = StateInitializing
 	c.RunInfo = req.RunInfo
 	c.Tags.Add(req.Tags)
+	c.Annotations = mergeAnnotations(c.Annotations, req.Annotations)
 	return nil
 }
 
@@ -95,9 +101,27 @@ func (c *Container) TransitionToComplete(failed bool, failureReason string) {
 
 func (newContainer Container) Copy() Container {
 	newContainer.Tags = newContainer.Tags.Copy()
+	newContainer.Annotations = mergeAnnotations(nil, newContainer.Annotations)
 	return newContainer
 }
 
+// mergeAnnotations returns a copy of annotations with other added to it,
+// overwriting any existing keys.
+func mergeAnnotations(annotations, other map[string]string) map[string]string {
+	if annotations == nil && other == nil {
+		return nil
+	}
+
+	merged := make(map[string]string, len(annotations)+len(other))
+	for k, v := range annotations {
+		merged[k] = v
+	}
+	for k, v := range other {
+		merged[k] = v
+	}
+	return merged
+}
+
 func (c *Container) IsCreated() bool {
 	return c.State != StateReserved && c.State != StateInitializing && c.State != StateCompleted
 }
@@ -123,6 +147,7 @@ func (c *Container) HasTags(tags Tags) bool {
 
 func NewReservedContainerFromAllocationRequest(req *AllocationRequest, allocatedAt int64) Container {
 	c := NewContainerFromResource(req.Guid, &req.Resource, req.Tags)
+	c.Annotations = mergeAnnotations(nil, req.Annotations)
 	c.State = StateReserved
 	c.AllocatedAt = allocatedAt
 	return c
@@ -154,30 +179,236 @@ type CachedDependency struct {
 	ChecksumAlgorithm string `json:"checksum_value"`
 }
 
+// CacheAsset is an asset to fetch into the download cache before the
+// containers that need it arrive. Assets used by cached dependencies are kept
+// in the cache expanded, so they should set AsDirectory; those used by
+// download actions are kept as archives.
+type CacheAsset struct {
+	From              string `json:"from"`
+	CacheKey          string `json:"cache_key"`
+	ChecksumAlgorithm string `json:"checksum_algorithm,omitempty"`
+	ChecksumValue     string `json:"checksum_value,omitempty"`
+	AsDirectory       bool   `json:"as_directory,omitempty"`
+}
+
 type CertificateProperties struct {
 	OrganizationalUnit []string `json:"organizational_unit"`
 }
 
+// MaxCPUWeight is the largest CPUWeight, which gives the container the
+// executor's maximum CPU shares.
+const MaxCPUWeight = 100
+
 type RunInfo struct {
 	CPUWeight                     uint                        `json:"cpu_weight"`
 	DiskScope                     DiskLimitScope              `json:"disk_scope,omitempty"`
+	InodeLimit                    uint64                      `json:"inode_limit,omitempty"`
 	Ports                         []PortMapping               `json:"ports"`
+	HostPortRange                 *PortRange                  `json:"host_port_range,omitempty"`
 	LogConfig                     LogConfig                   `json:"log_config"`
 	MetricsConfig                 MetricsConfig               `json:"metrics_config"`
 	StartTimeoutMs                uint                        `json:"start_timeout_ms"`
+	StopGracePeriodMs             uint                        `json:"stop_grace_period_ms,omitempty"`
 	Privileged                    bool                        `json:"privileged"`
 	CachedDependencies            []CachedDependency          `json:"cached_dependencies"`
 	Setup                         *models.Action              `json:"setup"`
 	Action                        *models.Action              `json:"run"`
 	Monitor                       *models.Action              `json:"monitor"`
+	Sidecars                      []Sidecar                   `json:"sidecars,omitempty"`
+	HTTPCheck                     *HTTPCheck                  `json:"http_check,omitempty"`
+	PortCheck                     *PortCheck                  `json:"port_check,omitempty"`
+	StartupMonitor                *models.Action              `json:"startup_monitor,omitempty"`
+	HealthCheckPolicy             HealthCheckPolicy           `json:"health_check_policy"`
 	EgressRules                   []*models.SecurityGroupRule `json:"egress_rules,omitempty"`
 	Env                           []EnvironmentVariable       `json:"env,omitempty"`
 	TrustedSystemCertificatesPath string                      `json:"trusted_system_certificates_path,omitempty"`
 	VolumeMounts                  []VolumeMount               `json:"volume_mounts"`
 	Network                       *Network                    `json:"network,omitempty"`
+	NetworkBandwidthLimit         *BandwidthLimit             `json:"network_bandwidth_limit,omitempty"`
+	DNSServers                    []string                    `json:"dns_servers,omitempty"`
+	HostsEntries                  []HostsEntry                `json:"hosts_entries,omitempty"`
 	CertificateProperties         CertificateProperties       `json:"certificate_properties"`
 	ImageUsername                 string                      `json:"image_username"`
 	ImagePassword                 string                      `json:"image_password"`
+	Architecture                  string                      `json:"architecture,omitempty"`
+	StopSignal                    StopSignal                  `json:"stop_signal"`
+	CompletionCallbackURL         string                      `json:"completion_callback_url,omitempty"`
+	RestartPolicy                 RestartPolicy               `json:"restart_policy"`
+}
+
+type Signal string
+
+const (
+	SignalTerm Signal = "TERM"
+)
+
+// StopSignal is the signal delivered to the processes of a container's
+// action when it is stopped. The zero value sends TERM to the process only.
+// Signals are delivered by garden, which can only send TERM to the process
+// itself, so any other signal, and signalling the process group, is
+// rejected as invalid rather than silently replaced by TERM.
+type StopSignal struct {
+	Signal       Signal `json:"signal,omitempty"`
+	ProcessGroup bool   `json:"process_group,omitempty"`
+}
+
+func (s StopSignal) Valid() bool {
+	return (s.Signal == "" || s.Signal == SignalTerm) && !s.ProcessGroup
+}
+
+const DefaultSidecarLogSource = "SIDECAR"
+
+// Sidecar is a long running action run alongside a container's Action. The
+// container fails when a sidecar exits, but sidecars are not health checked
+// and do not delay the container being reported running. Their output uses
+// LogSource, or DefaultSidecarLogSource when it is empty.
+type Sidecar struct {
+	Name      string         `json:"name"`
+	Action    *models.Action `json:"action"`
+	LogSource string         `json:"log_source,omitempty"`
+}
+
+type RestartMode string
+
+const (
+	RestartNever     RestartMode = "never"
+	RestartOnFailure RestartMode = "on-failure"
+	RestartAlways    RestartMode = "always"
+)
+
+const (
+	DefaultRestartBackoff    = time.Second
+	DefaultMaxRestartBackoff = 5 * time.Minute
+)
+
+// RestartPolicy decides whether a container's action is run again in the same
+// container when it exits. The zero value never restarts. MaxRestarts of zero
+// allows any number of restarts. The delay before each restart doubles from
+// InitialBackoffMs up to MaxBackoffMs.
+type RestartPolicy struct {
+	Mode             RestartMode `json:"mode,omitempty"`
+	MaxRestarts      uint        `json:"max_restarts,omitempty"`
+	InitialBackoffMs uint        `json:"initial_backoff_ms,omitempty"`
+	MaxBackoffMs     uint        `json:"max_backoff_ms,omitempty"`
+}
+
+func (p RestartPolicy) Valid() bool {
+	switch p.Mode {
+	case "", RestartNever, RestartOnFailure, RestartAlways:
+	default:
+		return false
+	}
+
+	return p.MaxBackoffMs == 0 || p.InitialBackoffMs <= p.MaxBackoffMs
+}
+
+// ShouldRestart reports whether an action that has already been restarted
+// restarts times should be restarted after exiting.
+func (p RestartPolicy) ShouldRestart(failed bool, restarts uint) bool {
+	if p.MaxRestarts > 0 && restarts >= p.MaxRestarts {
+		return false
+	}
+
+	switch p.Mode {
+	case RestartAlways:
+		return true
+	case RestartOnFailure:
+		return failed
+	default:
+		return false
+	}
+}
+
+// Backoff returns the delay before the restart following restarts previous
+// ones.
+func (p RestartPolicy) Backoff(restarts uint) time.Duration {
+	backoff := DefaultRestartBackoff
+	if p.InitialBackoffMs > 0 {
+		backoff = time.Duration(p.InitialBackoffMs) * time.Millisecond
+	}
+
+	maxBackoff := DefaultMaxRestartBackoff
+	if p.MaxBackoffMs > 0 {
+		maxBackoff = time.Duration(p.MaxBackoffMs) * time.Millisecond
+	}
+
+	for i := uint(0); i < restarts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > maxBackoff {
+		return maxBackoff
+	}
+	return backoff
+}
+
+const (
+	DefaultHTTPCheckMinStatus = 200
+	DefaultHTTPCheckMaxStatus = 399
+)
+
+// HTTPCheck is a health check that GETs Path on Port of the container and
+// passes when the response status is between MinStatus and MaxStatus. It can
+// be used instead of a Monitor action.
+type HTTPCheck struct {
+	Path      string `json:"path"`
+	Port      uint16 `json:"port"`
+	TimeoutMs uint   `json:"timeout_ms,omitempty"`
+	MinStatus int    `json:"min_status,omitempty"`
+	MaxStatus int    `json:"max_status,omitempty"`
+}
+
+func (c HTTPCheck) Valid() bool {
+	if c.Port == 0 {
+		return false
+	}
+
+	min, max := c.StatusRange()
+	return min > 0 && min <= max
+}
+
+// StatusRange returns the passing status range, applying the defaults.
+func (c HTTPCheck) StatusRange() (int, int) {
+	min, max := c.MinStatus, c.MaxStatus
+	if min == 0 {
+		min = DefaultHTTPCheckMinStatus
+	}
+	if max == 0 {
+		max = DefaultHTTPCheckMaxStatus
+	}
+	return min, max
+}
+
+// PortCheck is a health check that passes when a TCP connection can be opened
+// to Port of the container, or to every port in the container's Ports when
+// Port is zero. It can be used instead of a Monitor action.
+type PortCheck struct {
+	Port      uint16 `json:"port,omitempty"`
+	TimeoutMs uint   `json:"timeout_ms,omitempty"`
+}
+
+// CheckedPorts returns the container ports probed by the check.
+func (c PortCheck) CheckedPorts(ports []PortMapping) []uint16 {
+	if c.Port != 0 {
+		return []uint16{c.Port}
+	}
+
+	checked := make([]uint16, 0, len(ports))
+	for _, port := range ports {
+		checked = append(checked, port.ContainerPort)
+	}
+	return checked
+}
+
+// HealthCheckPolicy tunes the two phases of a container's health check: the
+// startup phase, which runs until the check first passes, and the liveness
+// phase after it. Zero values use the executor's configured intervals and
+// thresholds of one.
+type HealthCheckPolicy struct {
+	StartupIntervalMs        uint `json:"startup_interval_ms,omitempty"`
+	LivenessIntervalMs       uint `json:"liveness_interval_ms,omitempty"`
+	StartupSuccessThreshold  uint `json:"startup_success_threshold,omitempty"`
+	LivenessFailureThreshold uint `json:"liveness_failure_threshold,omitempty"`
 }
 
 type BindMountMode uint8
@@ -195,6 +426,44 @@ type VolumeMount struct {
 	Mode          BindMountMode          `json:"mode"`
 }
 
+// Valid reports whether the mount names a volume, where to mount it and a
+// known mode.
+func (m VolumeMount) Valid() bool {
+	if m.Driver == "" || m.VolumeId == "" || m.ContainerPath == "" {
+		return false
+	}
+	return m.Mode == BindMountModeRO || m.Mode == BindMountModeRW
+}
+
+// BandwidthLimit shapes the container's network traffic to a sustained rate,
+// allowing bursts of up to BurstRateInBytesPerSecond.
+type BandwidthLimit struct {
+	RateInBytesPerSecond      uint64 `json:"rate_in_bytes_per_second"`
+	BurstRateInBytesPerSecond uint64 `json:"burst_rate_in_bytes_per_second"`
+}
+
+func (l BandwidthLimit) Valid() bool {
+	return l.RateInBytesPerSecond > 0 && l.BurstRateInBytesPerSecond > 0
+}
+
+// HostsEntry is a line appended to the container's /etc/hosts.
+type HostsEntry struct {
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+func (e HostsEntry) Valid() bool {
+	if net.ParseIP(e.IP) == nil || len(e.Hostnames) == 0 {
+		return false
+	}
+	for _, hostname := range e.Hostnames {
+		if hostname == "" || strings.ContainsAny(hostname, " \t\n#") {
+			return false
+		}
+	}
+	return true
+}
+
 type Network struct {
 	Properties map[string]string `json:"properties",omitempty"`
 }
@@ -243,11 +512,83 @@ type PortMapping struct {
 	HostPort      uint16 `json:"host_port,omitempty"`
 }
 
+// PortRange is an inclusive range of host ports that the container's port
+// mappings without an explicit HostPort are allocated from, instead of
+// garden assigning them.
+type PortRange struct {
+	Start uint16 `json:"start"`
+	End   uint16 `json:"end"`
+}
+
+func (r PortRange) Valid() bool {
+	return r.Start > 0 && r.Start <= r.End
+}
+
+func (r PortRange) Contains(port uint16) bool {
+	return r.Start <= port && port <= r.End
+}
+
+// validPorts reports whether no host port is mapped twice and, with a
+// range, whether the range can hold every mapping without a host port.
+func validPorts(ports []PortMapping, hostPortRange *PortRange) bool {
+	unassigned := 0
+	hostPorts := map[uint16]bool{}
+	for _, port := range ports {
+		if port.HostPort == 0 {
+			unassigned++
+			continue
+		}
+		if hostPorts[port.HostPort] {
+			return false
+		}
+		hostPorts[port.HostPort] = true
+	}
+
+	if hostPortRange == nil {
+		return true
+	}
+	if !hostPortRange.Valid() {
+		return false
+	}
+	return unassigned <= int(hostPortRange.End-hostPortRange.Start)+1
+}
+
 type ContainerRunResult struct {
 	Failed        bool   `json:"failed"`
 	FailureReason string `json:"failure_reason"`
 
 	Stopped bool `json:"stopped"`
+	Killed  bool `json:"killed"`
+
+	// Evacuated is set when the container was stopped because the executor is
+	// evacuating, so the work should be rescheduled elsewhere.
+	Evacuated bool `json:"evacuated,omitempty"`
+
+	// OutOfMemory is set when garden reports that the container ran out of
+	// memory before it failed.
+	OutOfMemory bool `json:"out_of_memory,omitempty"`
+
+	// Restarts is the number of times the action was restarted under the
+	// container's RestartPolicy.
+	Restarts uint `json:"restarts,omitempty"`
+
+	// HealthCheckOutput is the tail of the output of the container's last
+	// failed health check, when the container failed.
+	HealthCheckOutput string `json:"health_check_output,omitempty"`
+
+	// Steps reports how each of the container's top level steps ran, in the
+	// order they started.
+	Steps []StepResult `json:"steps,omitempty"`
+}
+
+// StepResult reports how one of a container's steps ran. Times are in
+// nanoseconds since the epoch; FinishedAt is zero while the step runs.
+type StepResult struct {
+	Name       string `json:"name"`
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at,omitempty"`
+	Failed     bool   `json:"failed"`
+	Error      string `json:"error,omitempty"`
 }
 
 type ExecutorResources struct {
@@ -256,6 +597,22 @@ type ExecutorResources struct {
 	Containers int `json:"containers"`
 }
 
+// ExecutorCapacity reports the executor's total resources, the resources
+// reserved by its containers, and what its containers are actually using.
+type ExecutorCapacity struct {
+	Total     ExecutorResources `json:"total"`
+	Allocated ExecutorResources `json:"allocated"`
+	InUse     ResourceUsage     `json:"in_use"`
+}
+
+// ResourceUsage is the resources consumed by the containers garden reported
+// metrics for.
+type ResourceUsage struct {
+	MemoryInBytes uint64 `json:"memory_in_bytes"`
+	DiskInBytes   uint64 `json:"disk_in_bytes"`
+	Containers    int    `json:"containers"`
+}
+
 func NewExecutorResources(memoryMB, diskMB, containers int) ExecutorResources {
 	return ExecutorResources{
 		MemoryMB:   memoryMB,
@@ -290,6 +647,14 @@ func (r *ExecutorResources) Add(res *Resource) {
 
 type Tags map[string]string
 
+// RequestIDTag is the tag holding the ID callers use to correlate a
+// container's history across components.
+const RequestIDTag = "request-id"
+
+func (t Tags) RequestID() string {
+	return t[RequestIDTag]
+}
+
 func (t Tags) Copy() Tags {
 	if t == nil {
 		return nil
@@ -316,23 +681,136 @@ var ErrUnknownEventType = errors.New("unknown event type")
 const (
 	EventTypeInvalid EventType = ""
 
-	EventTypeContainerComplete EventType = "container_complete"
-	EventTypeContainerRunning  EventType = "container_running"
-	EventTypeContainerReserved EventType = "container_reserved"
+	EventTypeContainerComplete  EventType = "container_complete"
+	EventTypeContainerRunning   EventType = "container_running"
+	EventTypeContainerReserved  EventType = "container_reserved"
+	EventTypeContainerDestroyed EventType = "container_destroyed"
+	EventTypeContainerOOM       EventType = "container_oom"
+	EventTypeContainerMetrics   EventType = "container_metrics"
+	EventTypeAllocationExpired  EventType = "allocation_expired"
+	EventTypeCellHealthy        EventType = "cell_healthy"
+	EventTypeCellUnhealthy      EventType = "cell_unhealthy"
 )
 
+// EventFilter selects the events delivered to a subscriber. Empty fields match
+// everything; Guids match lifecycle and container metrics events, and Tags
+// only match lifecycle events.
+type EventFilter struct {
+	Guids      []string    `json:"guids,omitempty"`
+	Tags       Tags        `json:"tags,omitempty"`
+	EventTypes []EventType `json:"event_types,omitempty"`
+}
+
+func (f EventFilter) Matches(event Event) bool {
+	if len(f.EventTypes) > 0 && !containsEventType(f.EventTypes, event.EventType()) {
+		return false
+	}
+
+	if len(f.Guids) == 0 && len(f.Tags) == 0 {
+		return true
+	}
+
+	if metricsEvent, ok := event.(ContainerMetricsEvent); ok {
+		return len(f.Tags) == 0 && containsString(f.Guids, metricsEvent.Guid)
+	}
+
+	lifecycleEvent, ok := event.(LifecycleEvent)
+	if !ok {
+		return false
+	}
+
+	container := lifecycleEvent.Container()
+	if len(f.Guids) > 0 && !containsString(f.Guids, container.Guid) {
+		return false
+	}
+
+	return len(f.Tags) == 0 || container.HasTags(f.Tags)
+}
+
+// UnmarshalEvent decodes the JSON encoding of an event of the given type. It
+// returns ErrUnknownEventType for types it does not know.
+func UnmarshalEvent(eventType EventType, payload []byte) (Event, error) {
+	switch eventType {
+	case EventTypeContainerComplete:
+		var ev ContainerCompleteEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeContainerRunning:
+		var ev ContainerRunningEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeContainerReserved:
+		var ev ContainerReservedEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeContainerDestroyed:
+		var ev ContainerDestroyedEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeContainerOOM:
+		var ev ContainerOOMEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeContainerMetrics:
+		var ev ContainerMetricsEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeAllocationExpired:
+		var ev AllocationExpiredEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeCellHealthy:
+		var ev CellHealthyEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	case EventTypeCellUnhealthy:
+		var ev CellUnhealthyEvent
+		err := json.Unmarshal(payload, &ev)
+		return ev, err
+	default:
+		return nil, ErrUnknownEventType
+	}
+}
+
+func containsEventType(eventTypes []EventType, eventType EventType) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 type LifecycleEvent interface {
 	Container() Container
 	lifecycleEvent()
 }
 
+// ContainerCompleteEvent and ContainerRunningEvent carry the container's tags
+// and annotations alongside it, so subscribers can filter them without
+// decoding the whole container.
 type ContainerCompleteEvent struct {
-	RawContainer Container `json:"container"`
+	RawContainer      Container         `json:"container"`
+	HealthCheckOutput string            `json:"health_check_output,omitempty"`
+	Tags              Tags              `json:"tags,omitempty"`
+	Annotations       map[string]string `json:"annotations,omitempty"`
 }
 
 func NewContainerCompleteEvent(container Container) ContainerCompleteEvent {
 	return ContainerCompleteEvent{
-		RawContainer: container,
+		RawContainer:      container,
+		HealthCheckOutput: container.RunResult.HealthCheckOutput,
+		Tags:              container.Tags,
+		Annotations:       container.Annotations,
 	}
 }
 
@@ -341,12 +819,16 @@ func (e ContainerCompleteEvent) Container() Container { return e.RawContainer }
 func (ContainerCompleteEvent) lifecycleEvent()        {}
 
 type ContainerRunningEvent struct {
-	RawContainer Container `json:"container"`
+	RawContainer Container         `json:"container"`
+	Tags         Tags              `json:"tags,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
 }
 
 func NewContainerRunningEvent(container Container) ContainerRunningEvent {
 	return ContainerRunningEvent{
 		RawContainer: container,
+		Tags:         container.Tags,
+		Annotations:  container.Annotations,
 	}
 }
 
@@ -367,3 +849,88 @@ func NewContainerReservedEvent(container Container) ContainerReservedEvent {
 func (ContainerReservedEvent) EventType() EventType   { return EventTypeContainerReserved }
 func (e ContainerReservedEvent) Container() Container { return e.RawContainer }
 func (ContainerReservedEvent) lifecycleEvent()        {}
+
+type ContainerDestroyedEvent struct {
+	RawContainer Container `json:"container"`
+}
+
+func NewContainerDestroyedEvent(container Container) ContainerDestroyedEvent {
+	return ContainerDestroyedEvent{
+		RawContainer: container,
+	}
+}
+
+func (ContainerDestroyedEvent) EventType() EventType   { return EventTypeContainerDestroyed }
+func (e ContainerDestroyedEvent) Container() Container { return e.RawContainer }
+func (ContainerDestroyedEvent) lifecycleEvent()        {}
+
+type ContainerOOMEvent struct {
+	RawContainer Container `json:"container"`
+}
+
+func NewContainerOOMEvent(container Container) ContainerOOMEvent {
+	return ContainerOOMEvent{
+		RawContainer: container,
+	}
+}
+
+func (ContainerOOMEvent) EventType() EventType   { return EventTypeContainerOOM }
+func (e ContainerOOMEvent) Container() Container { return e.RawContainer }
+func (ContainerOOMEvent) lifecycleEvent()        {}
+
+// AllocationExpiredEvent is emitted when a reservation that was never run is
+// reclaimed after the reserved expiration time, and its resources freed.
+type AllocationExpiredEvent struct {
+	RawContainer Container `json:"container"`
+}
+
+func NewAllocationExpiredEvent(container Container) AllocationExpiredEvent {
+	return AllocationExpiredEvent{
+		RawContainer: container,
+	}
+}
+
+func (AllocationExpiredEvent) EventType() EventType   { return EventTypeAllocationExpired }
+func (e AllocationExpiredEvent) Container() Container { return e.RawContainer }
+func (AllocationExpiredEvent) lifecycleEvent()        {}
+
+// ContainerMetricsEvent carries a container's periodically collected usage.
+type ContainerMetricsEvent struct {
+	Guid          string  `json:"guid"`
+	Metrics       Metrics `json:"metrics"`
+	CPUPercentage float64 `json:"cpu_percentage"`
+}
+
+func NewContainerMetricsEvent(guid string, metrics Metrics, cpuPercentage float64) ContainerMetricsEvent {
+	return ContainerMetricsEvent{
+		Guid:          guid,
+		Metrics:       metrics,
+		CPUPercentage: cpuPercentage,
+	}
+}
+
+func (ContainerMetricsEvent) EventType() EventType { return EventTypeContainerMetrics }
+
+// CellHealthyEvent is emitted when the garden health check marks the cell
+// healthy.
+type CellHealthyEvent struct{}
+
+func NewCellHealthyEvent() CellHealthyEvent {
+	return CellHealthyEvent{}
+}
+
+func (CellHealthyEvent) EventType() EventType { return EventTypeCellHealthy }
+
+// CellUnhealthyEvent is emitted when the garden health check marks the cell
+// unhealthy, with the error from the check that tipped it over.
+type CellUnhealthyEvent struct {
+	Reason string `json:"reason,omitempty"`
+}
+
+func NewCellUnhealthyEvent(reason string) CellUnhealthyEvent {
+	return CellUnhealthyEvent{
+		Reason: reason,
+	}
+}
+
+func (CellUnhealthyEvent) EventType() EventType { return EventTypeCellUnhealthy }
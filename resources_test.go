@@ -1,6 +1,9 @@
 package executor_test
 
 import (
+	"encoding/json"
+	"time"
+
 	"code.cloudfoundry.org/executor"
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -47,6 +50,40 @@ var _ = Describe("Container", func() {
 		})
 	})
 
+	Describe("Annotations", func() {
+		var container executor.Container
+
+		BeforeEach(func() {
+			allocationRequest := executor.NewAllocationRequest("some-guid", &executor.Resource{}, executor.Tags{"a": "b"})
+			allocationRequest.Annotations = map[string]string{"app": "some-app"}
+			container = executor.NewReservedContainerFromAllocationRequest(&allocationRequest, 0)
+		})
+
+		It("keeps the allocation annotations and adds the run annotations", func() {
+			runRequest := executor.NewRunRequest("some-guid", &executor.RunInfo{}, nil)
+			runRequest.Annotations = map[string]string{"instance": "0"}
+			Expect(container.TransistionToInitialize(&runRequest)).To(Succeed())
+
+			Expect(container.Annotations).To(Equal(map[string]string{"app": "some-app", "instance": "0"}))
+		})
+
+		It("includes the tags and annotations on running and complete events", func() {
+			running := executor.NewContainerRunningEvent(container)
+			Expect(running.Tags).To(Equal(executor.Tags{"a": "b"}))
+			Expect(running.Annotations).To(Equal(map[string]string{"app": "some-app"}))
+
+			complete := executor.NewContainerCompleteEvent(container)
+			Expect(complete.Tags).To(Equal(executor.Tags{"a": "b"}))
+			Expect(complete.Annotations).To(Equal(map[string]string{"app": "some-app"}))
+		})
+
+		It("does not share annotations with copies", func() {
+			copied := container.Copy()
+			copied.Annotations["app"] = "other-app"
+			Expect(container.Annotations["app"]).To(Equal("some-app"))
+		})
+	})
+
 	Describe("Subtract", func() {
 		const (
 			defaultDiskMB     = 20
@@ -73,3 +110,118 @@ var _ = Describe("Container", func() {
 		})
 	})
 })
+
+var _ = Describe("EventFilter", func() {
+	var container executor.Container
+
+	BeforeEach(func() {
+		container = executor.Container{
+			Guid: "some-guid",
+			Tags: executor.Tags{"domain": "cf-apps", "lifecycle": "buildpack"},
+		}
+	})
+
+	It("matches every event when empty", func() {
+		filter := executor.EventFilter{}
+		Expect(filter.Matches(executor.NewContainerRunningEvent(container))).To(BeTrue())
+	})
+
+	It("matches events for the requested guids", func() {
+		filter := executor.EventFilter{Guids: []string{"other-guid", "some-guid"}}
+		Expect(filter.Matches(executor.NewContainerRunningEvent(container))).To(BeTrue())
+
+		filter = executor.EventFilter{Guids: []string{"other-guid"}}
+		Expect(filter.Matches(executor.NewContainerRunningEvent(container))).To(BeFalse())
+	})
+
+	It("matches container metrics events for the requested guids", func() {
+		metricsEvent := executor.NewContainerMetricsEvent("some-guid", executor.Metrics{}, 0)
+
+		filter := executor.EventFilter{Guids: []string{"some-guid"}}
+		Expect(filter.Matches(metricsEvent)).To(BeTrue())
+
+		filter = executor.EventFilter{Guids: []string{"other-guid"}}
+		Expect(filter.Matches(metricsEvent)).To(BeFalse())
+	})
+
+	It("matches events for containers with all the requested tags", func() {
+		filter := executor.EventFilter{Tags: executor.Tags{"domain": "cf-apps"}}
+		Expect(filter.Matches(executor.NewContainerRunningEvent(container))).To(BeTrue())
+
+		filter = executor.EventFilter{Tags: executor.Tags{"domain": "cf-apps", "lifecycle": "docker"}}
+		Expect(filter.Matches(executor.NewContainerRunningEvent(container))).To(BeFalse())
+	})
+
+	It("matches events of the requested types", func() {
+		filter := executor.EventFilter{EventTypes: []executor.EventType{executor.EventTypeContainerComplete}}
+		Expect(filter.Matches(executor.NewContainerCompleteEvent(container))).To(BeTrue())
+		Expect(filter.Matches(executor.NewContainerRunningEvent(container))).To(BeFalse())
+	})
+})
+
+var _ = Describe("UnmarshalEvent", func() {
+	It("decodes an event of the given type", func() {
+		original := executor.NewContainerCompleteEvent(executor.Container{
+			Guid: "some-guid",
+			Tags: executor.Tags{"domain": "cf-apps"},
+		})
+		payload, err := json.Marshal(original)
+		Expect(err).NotTo(HaveOccurred())
+
+		ev, err := executor.UnmarshalEvent(executor.EventTypeContainerComplete, payload)
+		Expect(err).NotTo(HaveOccurred())
+		Expect(ev).To(BeAssignableToTypeOf(executor.ContainerCompleteEvent{}))
+
+		decoded := ev.(executor.ContainerCompleteEvent)
+		Expect(decoded.Container().Guid).To(Equal("some-guid"))
+		Expect(decoded.Tags).To(Equal(executor.Tags{"domain": "cf-apps"}))
+	})
+
+	It("rejects unknown event types", func() {
+		_, err := executor.UnmarshalEvent("bogus", []byte("{}"))
+		Expect(err).To(Equal(executor.ErrUnknownEventType))
+	})
+})
+
+var _ = Describe("RestartPolicy", func() {
+	Describe("ShouldRestart", func() {
+		It("never restarts by default", func() {
+			policy := executor.RestartPolicy{}
+			Expect(policy.ShouldRestart(true, 0)).To(BeFalse())
+			Expect(policy.ShouldRestart(false, 0)).To(BeFalse())
+		})
+
+		It("restarts only failed actions when on-failure", func() {
+			policy := executor.RestartPolicy{Mode: executor.RestartOnFailure}
+			Expect(policy.ShouldRestart(true, 0)).To(BeTrue())
+			Expect(policy.ShouldRestart(false, 0)).To(BeFalse())
+		})
+
+		It("restarts every action when always", func() {
+			policy := executor.RestartPolicy{Mode: executor.RestartAlways}
+			Expect(policy.ShouldRestart(true, 100)).To(BeTrue())
+			Expect(policy.ShouldRestart(false, 100)).To(BeTrue())
+		})
+
+		It("stops restarting after the maximum number of restarts", func() {
+			policy := executor.RestartPolicy{Mode: executor.RestartAlways, MaxRestarts: 2}
+			Expect(policy.ShouldRestart(true, 1)).To(BeTrue())
+			Expect(policy.ShouldRestart(true, 2)).To(BeFalse())
+		})
+	})
+
+	Describe("Backoff", func() {
+		It("doubles from the default backoff", func() {
+			policy := executor.RestartPolicy{}
+			Expect(policy.Backoff(0)).To(Equal(executor.DefaultRestartBackoff))
+			Expect(policy.Backoff(3)).To(Equal(8 * executor.DefaultRestartBackoff))
+		})
+
+		It("is capped at the maximum backoff", func() {
+			policy := executor.RestartPolicy{InitialBackoffMs: 100, MaxBackoffMs: 1000}
+			Expect(policy.Backoff(1)).To(Equal(200 * time.Millisecond))
+			Expect(policy.Backoff(4)).To(Equal(time.Second))
+			Expect(policy.Backoff(1000)).To(Equal(time.Second))
+		})
+	})
+})
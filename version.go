@@ -0,0 +1,5 @@
+package executor
+
+// Version is the executor's build version. Release builds set it with
+// -ldflags "-X code.cloudfoundry.org/executor.Version=<version>".
+var Version = "dev"